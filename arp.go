@@ -1,7 +1,10 @@
 package arp
 
 import (
+	"context"
+	"fmt"
 	"net"
+	"sync/atomic"
 	"time"
 
 	marp "github.com/mdlayher/arp"
@@ -17,16 +20,44 @@ var (
 )
 
 func (c *Handler) request(srcHwAddr net.HardwareAddr, srcIP net.IP, dstHwAddr net.HardwareAddr, dstIP net.IP) error {
-	arp, err := marp.NewPacket(marp.OperationRequest, srcHwAddr, srcIP, dstHwAddr, dstIP)
+	return c.send(marp.OperationRequest, EthernetBroadcast, srcHwAddr, srcIP, dstHwAddr, dstIP)
+}
+
+// send write an ARP packet to the ethernet destination frameDst; the packet
+// fields are not validated beyond their length.
+func (c *Handler) send(op marp.Operation, frameDst net.HardwareAddr, srcHwAddr net.HardwareAddr, srcIP net.IP, dstHwAddr net.HardwareAddr, dstIP net.IP) error {
+	if c.passive {
+		return ErrPassive
+	}
+	p, err := marp.NewPacket(op, srcHwAddr, srcIP, dstHwAddr, dstIP)
 	if err != nil {
 		return err
 	}
 
-	if err := c.client.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+	packet := Packet{Direction: Outbound, Packet: p, Destination: frameDst}
+	if chain := c.middlewareChain(); len(chain) > 0 {
+		return runMiddleware(chain, 0, packet, c.write)
+	}
+	return c.write(packet)
+}
+
+// write send an outbound packet at the end of the middleware chain
+func (c *Handler) write(packet Packet) error {
+	c.limiter.wait()
+	if err := c.setWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
 		return err
 	}
 
-	return c.client.WriteTo(arp, EthernetBroadcast)
+	if err := c.client.WriteTo(packet.Packet, packet.Destination); err != nil {
+		return writeError(err)
+	}
+	if packet.Packet.Operation == marp.OperationRequest {
+		atomic.AddUint64(&c.counters.requestsSent, 1)
+	} else {
+		atomic.AddUint64(&c.counters.repliesSent, 1)
+	}
+	c.captureSent(packet.Packet)
+	return nil
 }
 
 // Request send ARP request from src to dst
@@ -48,11 +79,11 @@ func (c *Handler) request(srcHwAddr net.HardwareAddr, srcIP net.IP, dstHwAddr ne
 // +============+===+===========+===========+============+============+===================+===========+
 //
 func (c *Handler) Request(srcHwAddr net.HardwareAddr, srcIP net.IP, dstHwAddr net.HardwareAddr, dstIP net.IP) error {
-	if LogAll {
+	if c.logArea(LogPackets) {
 		if srcIP.Equal(dstIP) {
-			log.WithFields(log.Fields{"srcmac": srcHwAddr, "srcip": srcIP, "dstmac": dstHwAddr, "dstip": dstIP}).Debugf("ARP send announcement - I am %s", dstIP)
+			c.log().WithFields(log.Fields{"srcmac": srcHwAddr, "srcip": srcIP, "dstmac": dstHwAddr, "dstip": dstIP}).Debugf("ARP send announcement - I am %s", dstIP)
 		} else {
-			log.WithFields(log.Fields{"srcmac": srcHwAddr, "srcip": srcIP, "dstmac": dstHwAddr, "dstip": dstIP}).Debugf("ARP send request - who is %s", dstIP)
+			c.log().WithFields(log.Fields{"srcmac": srcHwAddr, "srcip": srcIP, "dstmac": dstHwAddr, "dstip": dstIP}).Debugf("ARP send request - who is %s", dstIP)
 		}
 	}
 
@@ -63,23 +94,50 @@ func (c *Handler) Request(srcHwAddr net.HardwareAddr, srcIP net.IP, dstHwAddr ne
 //
 // Call with dstHwAddr = ethernet.Broadcast to reply to all
 func (c *Handler) Reply(srcHwAddr net.HardwareAddr, srcIP net.IP, dstHwAddr net.HardwareAddr, dstIP net.IP) error {
-	if LogAll {
-		log.WithFields(log.Fields{"dstmac": dstHwAddr.String(), "dstip": dstIP.String()}).Debugf("ARP send reply - host %s is at %s", srcIP.String(), srcHwAddr.String())
+	if c.logArea(LogPackets) {
+		c.log().WithFields(log.Fields{"dstmac": dstHwAddr.String(), "dstip": dstIP.String()}).Debugf("ARP send reply - host %s is at %s", srcIP.String(), srcHwAddr.String())
 	}
 	return c.reply(srcHwAddr, srcIP, dstHwAddr, dstIP)
 }
 
 func (c *Handler) reply(srcHwAddr net.HardwareAddr, srcIP net.IP, dstHwAddr net.HardwareAddr, dstIP net.IP) error {
-	p, err := marp.NewPacket(marp.OperationReply, srcHwAddr, srcIP, dstHwAddr, dstIP)
-	if err != nil {
-		return err
+	return c.send(marp.OperationReply, dstHwAddr, srcHwAddr, srcIP, dstHwAddr, dstIP)
+}
+
+// SendRequest send an ARP request with arbitrary fields to the ethernet
+// destination frameDst on the handler socket; the ethernet source is the
+// interface MAC.
+//
+// Unlike Request, the target MAC is not used as the ethernet destination and
+// no field is checked against the table, the host or the spoof lists; use it
+// for research tools that need to craft packets. It returns an error if a MAC
+// is not 6 bytes or an IP is not IPv4.
+func (c *Handler) SendRequest(frameDst net.HardwareAddr, senderMAC net.HardwareAddr, senderIP net.IP, targetMAC net.HardwareAddr, targetIP net.IP) error {
+	if c.logArea(LogPackets) {
+		c.log().WithFields(log.Fields{"framedst": frameDst, "srcmac": senderMAC, "srcip": senderIP, "dstmac": targetMAC, "dstip": targetIP}).Debug("ARP send raw request")
 	}
+	return c.sendRaw(marp.OperationRequest, frameDst, senderMAC, senderIP, targetMAC, targetIP)
+}
 
-	if err := c.client.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
-		return err
+// SendReply send an ARP reply with arbitrary fields to the ethernet
+// destination frameDst on the handler socket; see SendRequest.
+func (c *Handler) SendReply(frameDst net.HardwareAddr, senderMAC net.HardwareAddr, senderIP net.IP, targetMAC net.HardwareAddr, targetIP net.IP) error {
+	if c.logArea(LogPackets) {
+		c.log().WithFields(log.Fields{"framedst": frameDst, "srcmac": senderMAC, "srcip": senderIP, "dstmac": targetMAC, "dstip": targetIP}).Debug("ARP send raw reply")
 	}
+	return c.sendRaw(marp.OperationReply, frameDst, senderMAC, senderIP, targetMAC, targetIP)
+}
 
-	return c.client.WriteTo(p, dstHwAddr)
+func (c *Handler) sendRaw(op marp.Operation, frameDst net.HardwareAddr, senderMAC net.HardwareAddr, senderIP net.IP, targetMAC net.HardwareAddr, targetIP net.IP) error {
+	for _, mac := range []net.HardwareAddr{frameDst, senderMAC, targetMAC} {
+		if err := checkMAC(mac); err != nil {
+			return err
+		}
+	}
+	if senderIP.To4() == nil || targetIP.To4() == nil {
+		return fmt.Errorf("invalid ipv4 sender %s target %s", senderIP, targetIP)
+	}
+	return c.send(op, frameDst, senderMAC, senderIP.To4(), targetMAC, targetIP.To4())
 }
 
 // Probe will send an arp request broadcast on the local link.
@@ -118,14 +176,56 @@ func (c *Handler) announce(mac net.HardwareAddr, ip net.IP) error {
 
 func (c *Handler) announceUnicast(mac net.HardwareAddr, ip net.IP, targetMac net.HardwareAddr) (err error) {
 	err = c.Request(mac, ip, targetMac, ip)
-	go func() {
-		time.Sleep(time.Second * 1)
-		c.request(mac, ip, targetMac, ip)
-		time.Sleep(time.Second * 1)
-	}()
+	c.goroutinePool.Go("ARP announce", func(ctx context.Context) error {
+		if sleepContext(ctx, time.Second*1) {
+			c.request(mac, ip, targetMac, ip)
+		}
+		return nil
+	})
 	return err
 }
 
+// Gratuitous broadcast a gratuitous ARP reply announcing that ip is at mac,
+// i.e. to move an IP to a standby host during failover.
+func (c *Handler) Gratuitous(ip net.IP, mac net.HardwareAddr) error {
+	return c.Reply(mac, ip, EthernetBroadcast, ip)
+}
+
+// AnnounceTo send a unicast ARP announcement to the device with mac binding
+// ip to its MAC in the table; use it to restore the ARP cache of a device
+// after spoofing. The announcement is repeated after one second.
+//
+// ip can be the host IP, the router IP or the IP of a device in the table.
+func (c *Handler) AnnounceTo(mac net.HardwareAddr, ip net.IP) error {
+	if err := checkMAC(mac); err != nil {
+		return err
+	}
+	owner := c.ownerMAC(ip)
+	if owner == nil {
+		return fmt.Errorf("ip %s %w", ip, ErrNotFound)
+	}
+	if c.logArea(LogPackets) {
+		c.log().WithFields(log.Fields{"dstmac": mac.String(), "ip": ip.String(), "mac": owner.String()}).Debug("ARP send unicast announcement")
+	}
+	return c.announceUnicast(owner, ip, mac)
+}
+
+// ownerMAC return the MAC of the ip or nil if unknown
+func (c *Handler) ownerMAC(ip net.IP) net.HardwareAddr {
+	if ip.Equal(c.config.HostIP) {
+		return c.config.HostMAC
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if ip.Equal(c.config.RouterIP) && c.config.RouterMAC != nil {
+		return dupMAC(c.config.RouterMAC)
+	}
+	if entry := lookupIP(c.ipIndex, ip); entry != nil {
+		return dupMAC(entry.MAC)
+	}
+	return nil
+}
+
 // WhoIs will send a request packet to get the MAC address for the IP. Retry 3 times.
 //
 func (c *Handler) WhoIs(ip net.IP) (entry *Entry, err error) {
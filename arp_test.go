@@ -0,0 +1,115 @@
+package arp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_Gratuitous(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	if err := h.Gratuitous(ip1, mac1); err != nil {
+		t.Fatal("Gratuitous error ", err)
+	}
+	p := <-conn.out
+	if p.Operation != marp.OperationReply || !bytes.Equal(p.SenderHardwareAddr, mac1) || !p.SenderIP.Equal(ip1) ||
+		!p.TargetIP.Equal(ip1) || !bytes.Equal(p.TargetHardwareAddr, EthernetBroadcast) {
+		t.Errorf("invalid gratuitous packet %+v", p)
+	}
+
+	if err := h.AnnounceTo(mac1, ip2); err == nil {
+		t.Error("AnnounceTo unknown ip should fail")
+	}
+
+	h.mutex.Lock()
+	h.arpTableAppendLocked(StateNormal, mac2, ip2)
+	h.mutex.Unlock()
+	if err := h.AnnounceTo(mac1, ip2); err != nil {
+		t.Fatal("AnnounceTo error ", err)
+	}
+	select {
+	case p = <-conn.out:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for announcement")
+	}
+	if !bytes.Equal(p.SenderHardwareAddr, mac2) || !p.SenderIP.Equal(ip2) || !bytes.Equal(p.TargetHardwareAddr, mac1) {
+		t.Errorf("invalid announcement %+v", p)
+	}
+}
+
+func Test_RateLimit(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(conn), WithRateLimit(200))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	// burst of 20 frames then one frame every 5ms
+	start := time.Now()
+	for i := 0; i < 60; i++ {
+		if err := h.Request(mac3, ip3, EthernetBroadcast, ip1); err != nil {
+			t.Fatal("Request error ", err)
+		}
+	}
+	if d := time.Since(start); d < time.Millisecond*180 {
+		t.Errorf("rate limit not applied; 60 frames sent in %v", d)
+	}
+
+	h.SetRateLimit(0)
+	start = time.Now()
+	for i := 0; i < 60; i++ {
+		h.Reply(mac3, ip3, mac1, ip1)
+	}
+	if d := time.Since(start); d > time.Millisecond*100 {
+		t.Errorf("rate limit not removed; 60 frames sent in %v", d)
+	}
+}
+
+func Test_SendRaw(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	// spoofed sender that is neither the host nor in the table
+	if err := h.SendReply(mac1, mac2, ip2, EthernetBroadcast, net.IPv4zero); err != nil {
+		t.Fatal("SendReply error ", err)
+	}
+	select {
+	case p := <-conn.out:
+		if p.Operation != marp.OperationReply || p.SenderHardwareAddr.String() != mac2.String() || !p.SenderIP.Equal(ip2) ||
+			p.TargetHardwareAddr.String() != EthernetBroadcast.String() || !p.TargetIP.Equal(net.IPv4zero) {
+			t.Errorf("invalid packet %+v", p)
+		}
+	default:
+		t.Fatal("packet not sent")
+	}
+
+	if err := h.SendRequest(EthernetBroadcast, mac3, ip3, mac1, ip1); err != nil {
+		t.Fatal("SendRequest error ", err)
+	}
+	if stats := h.Stats(); stats.RequestsSent != 1 || stats.RepliesSent != 1 {
+		t.Errorf("invalid stats %+v", stats)
+	}
+
+	if err := h.SendRequest(net.HardwareAddr{1}, mac3, ip3, mac1, ip1); !errors.Is(err, ErrInvalidMAC) {
+		t.Error("expected ErrInvalidMAC ", err)
+	}
+	if err := h.SendReply(mac1, mac3, net.ParseIP("fe80::1"), mac1, ip1); err == nil {
+		t.Error("expected invalid ipv4 error")
+	}
+}
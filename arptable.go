@@ -1,9 +1,7 @@
 package arp
 
 import (
-	"bytes"
 	"fmt"
-	"math/rand"
 	"net"
 	"time"
 
@@ -11,14 +9,45 @@ import (
 )
 
 // Entry holds a mac to ip entry
+//
+// IP is the last new IPv4 address seen for the MAC; Addresses holds all IPv4
+// addresses used by the MAC including IP (i.e. dual homed devices).
+//
+// Entry marshals to JSON with MAC and IPs as strings and LastUpdate in RFC3339 format.
 type Entry struct {
-	MAC        net.HardwareAddr
-	IP         net.IP
-	State      arpState
-	LastUpdate time.Time
-	Online     bool
+	MAC         net.HardwareAddr  `json:"mac"`
+	IP          net.IP            `json:"ip"`
+	Addresses   []Address         `json:"addresses,omitempty"`
+	IPv6        []net.IP          `json:"ipv6,omitempty"`
+	State       arpState          `json:"state"`
+	LastUpdate  time.Time         `json:"lastUpdate"`
+	Online      bool              `json:"online"`
+	Name        string            `json:"name,omitempty"`
+	Vendor      string            `json:"vendor,omitempty"`      // from the MAC OUI; see LookupVendor
+	Hostname    string            `json:"hostname,omitempty"`    // from reverse DNS; see WithReverseDNS
+	MDNSName    string            `json:"mdnsName,omitempty"`    // from mDNS announcements; see ListenAndServeMDNS
+	NetBIOSName string            `json:"netbiosName,omitempty"` // from NetBIOS node status; see WithNetBIOS
+	DHCP        *DHCPInfo         `json:"dhcp,omitempty"`        // see ListenAndServeDHCP; do not modify
+	DeviceClass DeviceClass       `json:"deviceClass,omitempty"` // from the DHCP fingerprint; see ListenAndServeDHCP
+	Packets     uint64            `json:"packets,omitempty"`     // ARP packets received from the MAC
+	FirstSeen   time.Time         `json:"firstSeen"`
+	Labels      map[string]string `json:"labels,omitempty"`   // see SetLabel; do not modify
+	DeviceID    string            `json:"deviceId,omitempty"` // stable UUID saved in the store and shared by linked MACs; see FindDevice
+
+	misses int // probes sent since the last update; see OfflineThreshold
+	hits   int // packets received while offline; see OfflineThreshold
+}
+
+// Address is an IPv4 address used by the MAC and the time it was last seen.
+type Address struct {
+	IP       net.IP    `json:"ip"`
+	LastSeen time.Time `json:"lastSeen"`
 }
 
+// addressSeenResolution is the precision of Address.LastSeen; the addresses
+// are copy on write so updating every packet would allocate.
+const addressSeenResolution = time.Second * 30
+
 type arpState string
 
 const (
@@ -34,13 +63,13 @@ const (
 
 // PrintTable will print the ARP table to stdout.
 func (c *Handler) PrintTable() {
-	log.Infof("ARP Table: %v entries", len(c.table))
+	c.log().Infof("ARP Table: %v entries", len(c.table))
 
 	// Don't mutex lock; it is called from multiple locked locations
 	table := c.table
 	for _, v := range table {
 		if v != nil {
-			log.WithFields(log.Fields{"mac": v.MAC.String(), "ip": v.IP.String()}).
+			c.log().WithFields(log.Fields{"mac": v.MAC.String(), "ip": v.IP.String()}).
 				Infof("ARP table %5v %10s %18s  %14s  %v", v.Online, v.State, v.MAC, v.IP, time.Since(v.LastUpdate))
 		}
 	}
@@ -48,8 +77,8 @@ func (c *Handler) PrintTable() {
 
 // FindMAC return the entry or nil if not found.
 func (c *Handler) FindMAC(mac net.HardwareAddr) *Entry {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return c.findMACLocked(mac)
 }
 
@@ -57,48 +86,235 @@ func (c *Handler) FindMAC(mac net.HardwareAddr) *Entry {
 //
 // CAUTION: Lock the mutex before calling this.
 func (c *Handler) findMACLocked(mac net.HardwareAddr) *Entry {
-	for i := range c.table {
-		if c.table[i] != nil && bytes.Equal(c.table[i].MAC, mac) {
-			return c.table[i]
-		}
-	}
-	return nil
+	return c.macIndex[string(mac)]
 }
 
 // FindIP return the entry or nil if not found.
 func (c *Handler) FindIP(ip net.IP) *Entry {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 
 	if ip.Equal(net.IPv4zero) {
 		return nil
 	}
 
-	for i := range c.table {
-		// When in Hunt state, the IP is claimed by a virtual host; ignore the entry
-		if c.table[i] != nil &&
-			c.table[i].IP.Equal(ip) && c.table[i].State != StateVirtualHost {
-			return c.table[i]
-		}
-	}
-	return nil
+	// When in Hunt state, the IP is claimed by a virtual host; virtual entries
+	// are kept in a separate index
+	return lookupIP(c.ipIndex, ip)
 }
 
 // FindVirtualIP return the entry or nil if not found.
 func (c *Handler) FindVirtualIP(ip net.IP) *Entry {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return lookupIP(c.virtualIndex, ip)
+}
+
+// ipKey return the index key for the ip; IPv4 addresses are always 4 bytes.
+func ipKey(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return string(ip4)
+	}
+	return string(ip.To16())
+}
+
+// lookupIP return the entry for ip in the index; it does not allocate unlike
+// index[ipKey(ip)] as the key conversion is in the map index expression.
+func lookupIP(index map[string]*Entry, ip net.IP) *Entry {
+	if ip4 := ip.To4(); ip4 != nil {
+		return index[string(ip4)]
+	}
+	return index[string(ip.To16())]
+}
+
+// indexAddLocked insert the entry MAC and IPs in the lookup indexes.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) indexAddLocked(entry *Entry) {
+	c.indexInitLocked()
+	c.macIndex[string(entry.MAC)] = entry
+	if !entry.IP.Equal(net.IPv4zero) && !c.conflictOwnerLocked(entry, entry.IP) {
+		c.ipIndexLocked(entry)[ipKey(entry.IP)] = entry
+	}
+	for _, a := range entry.Addresses {
+		if !c.conflictOwnerLocked(entry, a.IP) {
+			c.ipIndexLocked(entry)[ipKey(a.IP)] = entry
+		}
+	}
+	for _, ip := range entry.IPv6 {
+		c.ipIndexLocked(entry)[ipKey(ip)] = entry
+	}
+}
+
+func (c *Handler) indexInitLocked() {
+	if c.macIndex == nil {
+		c.macIndex = make(map[string]*Entry, cap(c.table))
+		c.ipIndex = make(map[string]*Entry, cap(c.table))
+		c.virtualIndex = make(map[string]*Entry)
+	}
+}
+
+// indexRemoveLocked remove the entry MAC and IPs from the lookup indexes.
+// Keys that now point to a different entry are left untouched.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) indexRemoveLocked(entry *Entry) {
+	if c.macIndex[string(entry.MAC)] == entry {
+		delete(c.macIndex, string(entry.MAC))
+	}
+	c.indexRemoveIPLocked(entry, entry.IP)
+	for _, a := range entry.Addresses {
+		c.indexRemoveIPLocked(entry, a.IP)
+	}
+	for _, ip := range entry.IPv6 {
+		c.indexRemoveIPLocked(entry, ip)
+	}
+}
+
+func (c *Handler) indexRemoveIPLocked(entry *Entry, ip net.IP) {
+	index := c.ipIndexLocked(entry)
+	if key := ipKey(ip); index[key] == entry {
+		delete(index, key)
+	}
+}
+
+// ipIndexLocked return the IP index for the entry state
+func (c *Handler) ipIndexLocked(entry *Entry) map[string]*Entry {
+	if entry.State == StateVirtualHost {
+		return c.virtualIndex
+	}
+	return c.ipIndex
+}
+
+// setIPLocked change the entry IPv4 and update the index. The previous IP
+// remains in Addresses until it expires.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) setIPLocked(entry *Entry, ip net.IP) {
+	entry.IP = dupIP(ip)
+	c.addAddressLocked(entry, entry.IP, time.Now())
+}
+
+// hasAddress return true if ip is one of the entry IPv4 addresses.
+func (e *Entry) hasAddress(ip net.IP) bool {
+	return e.addressIndex(ip) >= 0
+}
+
+func (e *Entry) addressIndex(ip net.IP) int {
+	for i := range e.Addresses {
+		if e.Addresses[i].IP.Equal(ip) {
+			return i
+		}
+	}
+	return -1
+}
+
+// addAddressLocked add ip to the entry addresses or update its last seen time,
+// and update the index. An address moving from another entry is removed from
+// that entry unless it is its IP.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) addAddressLocked(entry *Entry, ip net.IP, now time.Time) {
+	if ip.Equal(net.IPv4zero) || ip.To4() == nil {
+		return
+	}
+
+	// copy on write; events and snapshots may hold the previous slice
+	i := entry.addressIndex(ip)
+	addresses := make([]Address, len(entry.Addresses), len(entry.Addresses)+1)
+	copy(addresses, entry.Addresses)
+	if i < 0 {
+		i = len(addresses)
+		addresses = append(addresses, Address{IP: dupIP(ip).To4()})
+	}
+	addresses[i].LastSeen = now
+	entry.Addresses = addresses
+
+	c.indexInitLocked()
+	if c.conflictOwnerLocked(entry, ip) {
+		return
+	}
+	index := c.ipIndexLocked(entry)
+	if other := index[ipKey(ip)]; other != nil && other != entry && !other.IP.Equal(ip) {
+		c.removeAddressLocked(other, ip)
+	}
+	index[ipKey(ip)] = entry
+}
+
+// touchAddressLocked update the last seen time of a known address; it does
+// not allocate unless the time is older than addressSeenResolution.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) touchAddressLocked(entry *Entry, ip net.IP, now time.Time) {
+	if i := entry.addressIndex(ip); i >= 0 && now.Sub(entry.Addresses[i].LastSeen) > addressSeenResolution {
+		c.addAddressLocked(entry, ip, now)
+	}
+}
+
+// removeAddressLocked remove ip from the entry addresses and the index.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) removeAddressLocked(entry *Entry, ip net.IP) {
+	i := entry.addressIndex(ip)
+	if i < 0 {
+		return
+	}
+	addresses := make([]Address, 0, len(entry.Addresses)-1)
+	addresses = append(addresses, entry.Addresses[:i]...)
+	entry.Addresses = append(addresses, entry.Addresses[i+1:]...)
+	if !entry.IP.Equal(ip) {
+		c.indexRemoveIPLocked(entry, ip)
+	}
+}
+
+// expireAddresses remove the addresses other than IP not seen since deadline.
+// It return a copy of the entry before and after the change.
+func (c *Handler) expireAddresses(entry *Entry, deadline time.Time) (previous Entry, current Entry, changed bool) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	for _, entry := range c.table {
-		if entry != nil && entry.State == StateVirtualHost && entry.IP.Equal(ip) {
-			return entry
+	previous = *entry
+	for _, a := range previous.Addresses {
+		if !a.IP.Equal(entry.IP) && a.LastSeen.Before(deadline) {
+			c.removeAddressLocked(entry, a.IP)
+			changed = true
+		}
+	}
+	return previous, *entry, changed
+}
+
+// setIPv6Locked change the entry IPv6 addresses and update the index.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) setIPv6Locked(entry *Entry, ips []net.IP) {
+	for _, ip := range entry.IPv6 {
+		c.indexRemoveIPLocked(entry, ip)
+	}
+	entry.IPv6 = ips
+	for _, ip := range entry.IPv6 {
+		c.ipIndexLocked(entry)[ipKey(ip)] = entry
+	}
+}
+
+// deleteLocked remove the entry from the table and indexes.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) deleteLocked(entry *Entry) {
+	for i := range c.table {
+		if c.table[i] == entry {
+			c.table[i] = nil
+			c.indexRemoveLocked(entry)
+			return
 		}
 	}
-	return nil
 }
 
 // GetTable return a shallow copy of the arp table
 func (c *Handler) GetTable() (table []*Entry) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
 	table = make([]*Entry, 0, len(c.table)) // create an array large enough
 	t := c.table
 	for _, entry := range t {
@@ -109,76 +325,111 @@ func (c *Handler) GetTable() (table []*Entry) {
 	return table
 }
 
-// arpTableAppendLocked
+// arpTableAppendLocked append an entry; it logs the error and returns nil if
+// the entry cannot be added. See appendEntryLocked.
 //
 // CAUTION: must be called with the mutex already locked. It has a race condition if not locked.
 //          call c.mutex.Lock() before entering this function
 //
 func (c *Handler) arpTableAppendLocked(state arpState, clientMAC net.HardwareAddr, clientIP net.IP) (ret *Entry) {
+	entry, err := c.appendEntryLocked(state, clientMAC, clientIP)
+	if err != nil {
+		c.log().WithFields(log.Fields{"ip": clientIP, "mac": clientMAC.String()}).Error("ARP cannot add entry ", err)
+		return nil
+	}
+	return entry
+}
+
+// appendEntryLocked append an entry to the table; it returns ErrTableFull if
+// the table reached the number of addresses in the LAN or an error if the
+// device ID cannot be created.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) appendEntryLocked(state arpState, clientMAC net.HardwareAddr, clientIP net.IP) (*Entry, error) {
 	mac := dupMAC(clientMAC) // copy the underlying slice
 	ip := dupIP(clientIP)    // copy the underlysing slice
 
-	if LogAll {
-		log.WithFields(log.Fields{"ip": ip.String(), "mac": mac.String()}).Debug("ARP new mac detected")
+	if c.logArea(LogTable) {
+		c.log().WithFields(log.Fields{"ip": ip.String(), "mac": mac.String()}).Debug("ARP new mac detected")
 	}
 
-	entry := &Entry{State: state, MAC: mac, IP: ip.To4(), LastUpdate: time.Now(), Online: false}
+	now := time.Now()
+	entry := &Entry{State: state, MAC: mac, IP: ip.To4(), LastUpdate: now, FirstSeen: now, Online: false}
+	if state != StateVirtualHost {
+		id, err := newDeviceID()
+		if err != nil {
+			return nil, err
+		}
+		entry.Vendor = LookupVendor(mac)
+		entry.DeviceID = id
+	}
 
 	// Attempt to reuse deleted entry if available
 	for i := range c.table {
 		if c.table[i] == nil {
 			c.table[i] = entry
-			return entry
+			c.addAddressLocked(entry, entry.IP, now)
+			c.indexAddLocked(entry)
+			return entry, nil
 		}
 	}
 
-	// Don't extend table when past the maximum capacity. The initial table
-	// should have plenty of capacity to store all IPs (ie. 256 capacity)
-	// This will cause a buffer rellocation and likely result in pointer errors in
-	// other goroutines.
-	if len(c.table) >= cap(c.table) {
-		log.Error("ARP arptable is too big", len(c.table), cap(c.table))
-		return nil
+	// Don't extend table when past the number of addresses in the LAN.
+	if len(c.table) >= c.tableLimitLocked() {
+		return nil, fmt.Errorf("%w: %d entries", ErrTableFull, len(c.table))
 	}
 
-	table := c.table // to be safe test array location -  don't want c.table array to change on us
+	// The table grows when full; goroutines iterating over the previous array
+	// are safe as the array holds pointers and entries are never moved.
 	c.table = append(c.table, entry)
-	if len(table) > 0 && &c.table[0] != &table[0] {
-		// tell the world if the underlaying array changed.
-		// the logic assume existing pointers will not change
-		log.Error("ARP ERROR new table array allocated", len(c.table), cap(c.table))
+	c.addAddressLocked(entry, entry.IP, now)
+	c.indexAddLocked(entry)
+
+	return entry, nil
+}
+
+// minTableSize is the initial table capacity and the minimum table limit.
+const minTableSize = 256
+
+// maxTableSize is the table limit for networks larger than /16.
+const maxTableSize = 1 << 16
+
+// tableLimit return the maximum number of entries for the LAN; it is the
+// number of addresses in the LAN between minTableSize and maxTableSize.
+func tableLimit(lan net.IPNet) int {
+	ones, bits := lan.Mask.Size()
+	if bits != 32 || ones < 16 {
+		return maxTableSize
 	}
+	if n := 1 << uint(bits-ones); n > minTableSize {
+		return n
+	}
+	return minTableSize
+}
 
-	return entry
+// tableLimitLocked return the maximum number of entries in the table.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) tableLimitLocked() int {
+	if c.tableLimit == 0 {
+		return minTableSize
+	}
+	return c.tableLimit
 }
 
 func (c *Handler) deleteVirtualMAC(virtual *Entry) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	for i := range c.table {
-		if c.table[i] != nil && bytes.Equal(c.table[i].MAC, virtual.MAC) && c.table[i].State == StateVirtualHost {
-			if LogAll {
-				log.WithFields(log.Fields{"ip": c.table[i].IP, "mac": c.table[i].MAC.String()}).Debug("ARP deleting virtual mac")
-			}
-			c.table[i] = nil
-			c.PrintTable()
-			return
+	if entry := c.findMACLocked(virtual.MAC); entry != nil && entry.State == StateVirtualHost {
+		if c.logArea(LogSpoof) {
+			c.log().WithFields(log.Fields{"ip": entry.IP, "mac": entry.MAC.String()}).Debug("ARP deleting virtual mac")
 		}
+		c.deleteLocked(entry)
+		c.releaseMACLocked(entry.MAC)
+		c.PrintTable()
+		return
 	}
-	log.WithFields(log.Fields{"ip": virtual.IP, "mac": virtual.MAC.String()}).Error("ARP deleting non-existent virtual mac", *virtual)
+	c.log().WithFields(log.Fields{"ip": virtual.IP, "mac": virtual.MAC.String()}).Error("ARP deleting non-existent virtual mac", *virtual)
 	c.PrintTable()
 }
-
-func newVirtualHardwareAddr() net.HardwareAddr {
-	buf := make([]byte, 6)
-	_, err := rand.Read(buf)
-	if err != nil {
-		log.Error("ARP error in new virtual MAC", err)
-		return net.HardwareAddr{}
-	}
-	// Set the local bit
-	buf[0] = (buf[0] | 2) & 0xfe // Set local bit, ensure unicast address
-	mac, _ := net.ParseMAC(fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", buf[0], buf[1], buf[2], buf[3], buf[4], buf[5]))
-	return mac
-}
@@ -1,8 +1,13 @@
 package arp
 
 import (
+	"encoding/json"
 	"net"
+	"strings"
 	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
 )
 
 var (
@@ -40,7 +45,7 @@ func Test_AddMany(t *testing.T) {
 		t.Error("expected cannot find entry ", mac2.String(), ip2)
 	}
 
-	h.table[1] = nil
+	h.deleteLocked(entry2)
 
 	if len(h.table) != 3 || entry3 != h.FindMAC(mac3) || entry3 != h.FindIP(ip3) {
 		t.Error("expected cannot find entry ", mac3.String(), ip3)
@@ -77,3 +82,113 @@ func Test_DeleteVirtualMAC(t *testing.T) {
 		t.Error("expected cannot find entry ", mac2.String(), ip2)
 	}
 }
+
+func Test_ChangeIP(t *testing.T) {
+
+	h := &Handler{table: make([]*Entry, 0, 256)}
+	entry := h.arpTableAppendLocked(StateNormal, mac1, ip1)
+
+	h.setIPLocked(entry, ip2)
+	if entry != h.FindIP(ip1) || entry != h.FindIP(ip2) || !entry.IP.Equal(ip2) || len(entry.Addresses) != 2 {
+		t.Error("expected index updated to new IP ", ip2)
+	}
+
+	// the previous IP remains until it expires
+	if _, current, changed := h.expireAddresses(entry, time.Now().Add(time.Second)); !changed || len(current.Addresses) != 1 || h.FindIP(ip1) != nil {
+		t.Error("expected previous IP expired ", current.Addresses)
+	}
+
+	virtual := h.arpTableAppendLocked(StateVirtualHost, mac3, ip2)
+	if entry != h.FindIP(ip2) || virtual != h.FindVirtualIP(ip2) {
+		t.Error("expected virtual entry in separate index ", ip2)
+	}
+}
+
+func Test_SnapshotJSON(t *testing.T) {
+	h := &Handler{table: make([]*Entry, 0, 256)}
+	entry := h.arpTableAppendLocked(StateNormal, mac1, ip1)
+	h.setIPv6Locked(entry, []net.IP{net.ParseIP("fe80::1")})
+	h.arpTableAppendLocked(StateVirtualHost, mac2, ip2)
+
+	table := h.Snapshot()
+	if len(table) != 1 || &table[0].MAC[0] == &entry.MAC[0] {
+		t.Fatal("invalid snapshot ", table)
+	}
+
+	b, err := json.Marshal(table[0])
+	if err != nil {
+		t.Fatal("marshal error ", err)
+	}
+	if !strings.Contains(string(b), `"mac":"01:02:03:04:05:01"`) || !strings.Contains(string(b), `"ip":"192.168.0.1"`) {
+		t.Error("invalid json ", string(b))
+	}
+
+	var e Entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		t.Fatal("unmarshal error ", err)
+	}
+	if e.MAC.String() != mac1.String() || !e.IP.Equal(ip1) || len(e.IPv6) != 1 || !e.LastUpdate.Equal(entry.LastUpdate) {
+		t.Error("invalid entry ", e)
+	}
+}
+
+func Test_TableLimit(t *testing.T) {
+	tests := []struct {
+		ones  int
+		limit int
+	}{{30, 256}, {24, 256}, {23, 512}, {16, 65536}, {8, 65536}}
+	for _, tt := range tests {
+		if limit := tableLimit(net.IPNet{IP: net.IPv4(10, 0, 0, 0), Mask: net.CIDRMask(tt.ones, 32)}); limit != tt.limit {
+			t.Errorf("invalid limit for /%d got %d want %d", tt.ones, limit, tt.limit)
+		}
+	}
+
+	// the table grows past the initial capacity on a /23 network
+	h := &Handler{table: make([]*Entry, 0, minTableSize), tableLimit: 512}
+	for i := 0; i < 512; i++ {
+		if h.arpTableAppendLocked(StateNormal, net.HardwareAddr{0x02, 0, 0, 0, byte(i >> 8), byte(i)}, net.IPv4(10, 0, byte(i>>8), byte(i))) == nil {
+			t.Fatalf("cannot add entry %d", i)
+		}
+	}
+	if h.arpTableAppendLocked(StateNormal, mac1, ip1) != nil {
+		t.Error("table exceeded limit")
+	}
+	if e := h.FindIP(net.IPv4(10, 0, 1, 255)); e == nil || e != h.table[511] {
+		t.Error("cannot find entry after table grew")
+	}
+}
+
+func Test_MultipleAddresses(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropNewest)
+
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip3))
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip2, EthernetBroadcast, ip3))
+	for _, want := range []EventType{EventNewDevice, EventIPChanged} {
+		if e := <-s.C; e.Type != want {
+			t.Fatalf("invalid event %+v want %s", e, want)
+		}
+	}
+
+	// the dual homed device alternates addresses without changing IP
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip3))
+	select {
+	case e := <-s.C:
+		t.Fatalf("unexpected event %+v", e)
+	case <-time.After(time.Millisecond * 50):
+	}
+	entry := h.FindMAC(mac1)
+	if !entry.IP.Equal(ip2) || len(entry.Addresses) != 2 || h.FindIP(ip1) != entry || h.FindIP(ip2) != entry {
+		t.Fatalf("invalid entry %+v", entry)
+	}
+
+	// an address moving to another device is removed from the entry
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac2, ip1, EthernetBroadcast, ip3))
+	if len(h.FindMAC(mac1).Addresses) != 1 || h.FindIP(ip1) != h.FindMAC(mac2) {
+		t.Fatalf("address not moved %+v", h.FindMAC(mac1))
+	}
+}
@@ -0,0 +1,58 @@
+package arp
+
+import (
+	"fmt"
+	"net"
+)
+
+// NewHandlerAutoDetect creates an ARP handler for the interface using the
+// interface MAC, its first IPv4 address and network, and the default gateway
+// in the routing table.
+//
+// The handler scans the interface network; if the network is larger than
+// /16, the /16 containing the host IP is used. Use NewHandler to set the
+// configuration explicitly.
+func NewHandlerAutoDetect(nic string, options ...Option) (*Handler, error) {
+	ifi, err := net.InterfaceByName(nic)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open nic %s: %w", nic, err)
+	}
+
+	hostIP, homeLAN, err := interfaceIPv4(ifi)
+	if err != nil {
+		return nil, err
+	}
+
+	routerIP, err := defaultGateway(ifi)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get default gateway for nic %s: %w", nic, err)
+	}
+
+	return NewHandler(nic, ifi.HardwareAddr, hostIP, routerIP, homeLAN, options...)
+}
+
+// interfaceIPv4 return the first IPv4 address of the interface and its network
+// limited to /16.
+func interfaceIPv4(ifi *net.Interface) (ip net.IP, lan net.IPNet, err error) {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, lan, fmt.Errorf("cannot get addresses for nic %s: %w", ifi.Name, err)
+	}
+
+	for i := range addrs {
+		ipnet, ok := addrs[i].(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip = ipnet.IP.To4(); ip == nil || ip.Equal(net.IPv4zero) {
+			continue
+		}
+		mask := ipnet.Mask
+		if ones, bits := mask.Size(); bits != 32 || ones < 16 {
+			mask = net.CIDRMask(16, 32)
+		}
+		return ip, net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+	}
+
+	return nil, lan, fmt.Errorf("cannot find IPv4 address for nic %s - is it up?", ifi.Name)
+}
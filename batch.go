@@ -0,0 +1,129 @@
+package arp
+
+import (
+	"fmt"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// MaxBatchSize is the largest number of frames read per syscall; see WithBatchReads.
+const MaxBatchSize = 64
+
+// WithBatchReads read up to size ARP frames per recvmmsg syscall instead of
+// one read syscall per frame, and update the table for all the frames read
+// under a single lock acquisition. It cuts the syscall and lock overhead
+// during ARP storms.
+//
+// Frames are processed one at a time when middleware is registered with Use.
+// It is only supported on linux and requires the same privileges as the
+// default socket.
+func WithBatchReads(size int) Option {
+	return func(c *Handler) error {
+		if size <= 0 || size > MaxBatchSize {
+			return fmt.Errorf("invalid batch size %d", size)
+		}
+		nic := c.config.NIC
+		conn, err := dialBatch(nic, size)
+		if err != nil {
+			return err
+		}
+		c.redial = newRedialConn(conn, func() (PacketConn, error) { return dialBatch(nic, size) })
+		c.client = c.redial
+		return nil
+	}
+}
+
+// batchUpdate is the table update of a packet in a batch; see handleBatch.
+type batchUpdate struct {
+	senderUpdate
+	ok bool
+}
+
+// readBatch return the next packets from the connection and their receive
+// times; connections that do not implement batchReader return a single packet.
+//
+// The receive time is the kernel timestamp if the connection implements
+// receiveTimer or the time the read returned.
+func (c *Handler) readBatch() ([]*marp.Packet, []*ethernet.Frame, []time.Time, error) {
+	var packets []*marp.Packet
+	var frames []*ethernet.Frame
+	var err error
+	if r, ok := c.client.(batchReader); ok {
+		packets, frames, err = r.ReadBatch()
+	} else {
+		packets, frames, err = c.single.read(c.client)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	now := time.Now()
+	if cap(c.readTimes) < len(packets) {
+		c.readTimes = make([]time.Time, len(packets))
+	}
+	times := c.readTimes[:len(packets)]
+	var stamps []time.Time
+	if r, ok := c.client.(receiveTimer); ok {
+		stamps = r.ReceiveTimes()
+	}
+	for i := range times {
+		if times[i] = now; i < len(stamps) && !stamps[i].IsZero() {
+			times[i] = stamps[i]
+		}
+	}
+	return packets, frames, times, nil
+}
+
+// serveBatch dispatch packets read in a single call; see handleBatch.
+func (c *Handler) serveBatch(packets []*marp.Packet, frames []*ethernet.Frame, times []time.Time) {
+	if len(packets) == 1 || len(c.middlewareChain()) > 0 {
+		for i := range packets {
+			c.serve(packets[i], frames[i], times[i])
+		}
+		return
+	}
+	c.handleBatch(packets, frames, times)
+}
+
+// handleBatch is handlePacket for several packets; the table is updated for
+// all packets under a single lock and the notifications are sent after.
+//
+// It is only called from the ListenAndServe read loop so the updates buffer
+// is reused without locking.
+func (c *Handler) handleBatch(packets []*marp.Packet, frames []*ethernet.Frame, times []time.Time) {
+	if cap(c.updates) < len(packets) {
+		c.updates = make([]batchUpdate, len(packets))
+	}
+	updates := c.updates[:len(packets)]
+
+	for i, packet := range packets {
+		updates[i].ok = false
+		if isRawPacket(packet, frames[i]) {
+			c.handleRawPacket(packet, frames[i])
+			continue
+		}
+		if c.guard != nil {
+			c.checkGateway(packet, frames[i])
+		}
+		updates[i].ok = c.checkPacket(packet)
+	}
+
+	c.mutex.Lock()
+	for i, packet := range packets {
+		if updates[i].ok {
+			updates[i].senderUpdate, updates[i].ok = c.updateSenderLocked(packet, times[i])
+		}
+	}
+	c.mutex.Unlock()
+
+	now := time.Now()
+	for i, packet := range packets {
+		if updates[i].ok {
+			c.counters.updateLatency.observe(now.Sub(times[i]))
+			c.senderUpdated(packet, &updates[i].senderUpdate)
+		}
+		updates[i] = batchUpdate{} // do not keep references to the entries
+	}
+}
@@ -0,0 +1,120 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// fakeBatchConn return the packets queued in the fake conn in a single batch
+type fakeBatchConn struct {
+	*fakeConn
+}
+
+func (f fakeBatchConn) ReadBatch() ([]*marp.Packet, []*ethernet.Frame, error) {
+	p, fr, err := f.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	packets, frames := []*marp.Packet{p}, []*ethernet.Frame{fr}
+	for {
+		select {
+		case p := <-f.in:
+			pb, _ := p.MarshalBinary()
+			packets = append(packets, p)
+			frames = append(frames, &ethernet.Frame{Destination: p.TargetHardwareAddr, Source: p.SenderHardwareAddr, EtherType: ethernet.EtherTypeARP, Payload: pb})
+		default:
+			return packets, frames, nil
+		}
+	}
+}
+
+func Test_BatchReads(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(fakeBatchConn{conn}))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropNewest)
+
+	macs := []net.HardwareAddr{mac1, mac2}
+	ips := []net.IP{ip1, ip2}
+	for i := range macs {
+		p, _ := marp.NewPacket(marp.OperationReply, macs[i], ips[i], mac3, ip3)
+		conn.in <- p
+	}
+	p, _ := marp.NewPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip3)
+	conn.in <- p
+	go h.ListenAndServe(0)
+
+	for i := range macs {
+		select {
+		case e := <-s.C:
+			if e.Type != EventNewDevice || e.Entry.MAC.String() != macs[i].String() {
+				t.Errorf("invalid event %+v", e)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for event ", i)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for h.Stats().RequestsRead != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := h.Stats(); stats.PacketsRead != 3 || stats.RepliesRead != 2 || stats.RequestsRead != 1 {
+		t.Errorf("invalid stats %+v", stats)
+	}
+	if e := h.FindMAC(mac1); e == nil || e.Packets != 2 {
+		t.Errorf("invalid entry %v", e)
+	}
+}
+
+// Test_HandleBatchAllocs checks a batch of packets from known online devices does not allocate
+func Test_HandleBatchAllocs(t *testing.T) {
+	h := newBenchHandler(t)
+	packets := benchPackets(t)
+	frames := benchFrames(t, packets)
+	times := make([]time.Time, len(packets))
+	h.handleBatch(packets, frames, times) // allocate the updates buffer
+	if n := testing.AllocsPerRun(100, func() { h.handleBatch(packets, frames, times) }); n != 0 {
+		t.Errorf("handleBatch allocates %v times per batch", n)
+	}
+}
+
+// stampConn report a fixed kernel receive time for every packet
+type stampConn struct {
+	*fakeConn
+	times []time.Time
+}
+
+func (f stampConn) ReceiveTimes() []time.Time { return f.times }
+
+func Test_ReceiveTimes(t *testing.T) {
+	stamp := time.Now().Add(-time.Minute).Round(0)
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(stampConn{fakeConn: conn, times: []time.Time{stamp}}))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropNewest)
+
+	p, _ := marp.NewPacket(marp.OperationReply, mac1, ip1, mac3, ip3)
+	conn.in <- p
+	go h.ListenAndServe(0)
+
+	select {
+	case e := <-s.C:
+		if e.Type != EventNewDevice || !e.Time.Equal(stamp) || !e.Entry.LastUpdate.Equal(stamp) {
+			t.Errorf("invalid event time %v entry %v", e.Time, e.Entry.LastUpdate)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+}
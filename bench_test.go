@@ -0,0 +1,252 @@
+package arp
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+func newBenchHandler(tb testing.TB) *Handler {
+	h := &Handler{table: make([]*Entry, 0, 256)}
+	h.config.HostMAC = mac3
+	h.config.HostIP = ip3
+	h.mutex.Lock()
+	h.arpTableAppendLocked(StateNormal, mac1, ip1).Online = true
+	h.mutex.Unlock()
+	return h
+}
+
+func benchPackets(tb testing.TB) []*marp.Packet {
+	request, err := marp.NewPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	reply, err := marp.NewPacket(marp.OperationReply, mac1, ip1, mac3, ip3)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return []*marp.Packet{request, reply}
+}
+
+// Test_HandlePacketAllocs checks packets from known online devices do not allocate
+func Test_HandlePacketAllocs(t *testing.T) {
+	h := newBenchHandler(t)
+	for _, p := range benchPackets(t) {
+		if n := testing.AllocsPerRun(100, func() { h.handlePacket(p) }); n != 0 {
+			t.Errorf("%v allocates %v times per packet", p.Operation, n)
+		}
+	}
+}
+
+func Benchmark_HandlePacket(b *testing.B) {
+	b.Run("direct", func(b *testing.B) {
+		h := newBenchHandler(b)
+		packets := benchPackets(b)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			h.handlePacket(packets[i%len(packets)])
+		}
+	})
+
+	// the read loop enqueue and the worker serve run in the same goroutine
+	b.Run("workers", func(b *testing.B) {
+		h := newBenchHandler(b)
+		if err := WithWorkers(1, 16)(h); err != nil {
+			b.Fatal(err)
+		}
+		packets := benchPackets(b)
+		frames := benchFrames(b, packets)
+		q := &h.workers.queues[0]
+		now := time.Now()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			h.enqueue(packets[i%len(packets)], frames[i%len(frames)], now)
+			item := <-q.queue
+			item.serve(h)
+			q.free <- item
+		}
+	})
+}
+
+// Test_WorkerAllocs checks the worker path does not allocate for packets from known online devices
+func Test_WorkerAllocs(t *testing.T) {
+	h := newBenchHandler(t)
+	if err := WithWorkers(1, 16)(h); err != nil {
+		t.Fatal(err)
+	}
+	packets := benchPackets(t)
+	frames := benchFrames(t, packets)
+	q := &h.workers.queues[0]
+	now := time.Now()
+	for i, p := range packets {
+		n := testing.AllocsPerRun(100, func() {
+			h.enqueue(p, frames[i], now)
+			item := <-q.queue
+			item.serve(h)
+			q.free <- item
+		})
+		if n != 0 {
+			t.Errorf("%v allocates %v times per packet", p.Operation, n)
+		}
+	}
+}
+
+func benchFrames(tb testing.TB, packets []*marp.Packet) []*ethernet.Frame {
+	frames := make([]*ethernet.Frame, len(packets))
+	for i, p := range packets {
+		pb, err := p.MarshalBinary()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		frames[i] = &ethernet.Frame{Destination: p.TargetHardwareAddr, Source: p.SenderHardwareAddr, EtherType: ethernet.EtherTypeARP, Payload: pb}
+	}
+	return frames
+}
+
+func Test_DecoderAllocs(t *testing.T) {
+	var d frameDecoder
+	frame := arpFrame(t, marp.OperationReply, mac1, ip1, mac2, ip2)
+
+	p, f, err := d.decode(frame)
+	if err != nil || p.Operation != marp.OperationReply || !p.SenderIP.Equal(ip1) || p.TargetHardwareAddr.String() != mac2.String() || f.Source.String() != mac1.String() {
+		t.Fatal("invalid decode ", p, f, err)
+	}
+	if _, _, err := d.decode(frame[:20]); err == nil {
+		t.Error("expected error for short frame")
+	}
+
+	if n := testing.AllocsPerRun(100, func() { d.decode(frame) }); n != 0 {
+		t.Errorf("decode allocates %v times per frame", n)
+	}
+}
+
+func Benchmark_Decode(b *testing.B) {
+	var d frameDecoder
+	frame := arpFrame(b, marp.OperationReply, mac1, ip1, mac2, ip2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.decode(frame)
+	}
+}
+
+func Benchmark_HandleBatch(b *testing.B) {
+	h := newBenchHandler(b)
+	packets := benchPackets(b)
+	frames := benchFrames(b, packets)
+	times := make([]time.Time, len(packets))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.handleBatch(packets, frames, times)
+	}
+}
+
+// newTableHandler return a handler with n online devices; the device i has
+// MAC 02:00:00:00:hi:lo and IP 10.0.hi.lo.
+func newTableHandler(tb testing.TB, n int) *Handler {
+	h := &Handler{table: make([]*Entry, 0, n), tableLimit: maxTableSize}
+	h.config.HostMAC = mac3
+	h.config.HostIP = ip3
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i := 0; i < n; i++ {
+		mac, ip := tableAddr(i)
+		e := h.arpTableAppendLocked(StateNormal, mac, ip)
+		if e == nil {
+			tb.Fatal("cannot add entry ", i)
+		}
+		e.Online = true
+	}
+	return h
+}
+
+func tableAddr(i int) (net.HardwareAddr, net.IP) {
+	return net.HardwareAddr{0x02, 0, 0, 0, byte(i >> 8), byte(i)}, net.IPv4(10, 0, byte(i>>8), byte(i)).To4()
+}
+
+func Benchmark_TableLookup(b *testing.B) {
+	for _, n := range []int{10, 1000, 10000} {
+		h := newTableHandler(b, n)
+		mac, ip := tableAddr(n - 1)
+		packet, err := marp.NewPacket(marp.OperationReply, mac, ip, mac3, ip3)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("FindMAC/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if h.FindMAC(mac) == nil {
+					b.Fatal("mac not found")
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("FindIP/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if h.FindIP(ip) == nil {
+					b.Fatal("ip not found")
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("handlePacket/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				h.handlePacket(packet)
+			}
+		})
+	}
+}
+
+// discardBatchConn is a fakeConn that discards batch writes
+type discardBatchConn struct {
+	*fakeConn
+}
+
+func (f discardBatchConn) WriteBatch(packets []*marp.Packet, addrs []net.HardwareAddr) (int, error) {
+	return len(packets), nil
+}
+
+// Benchmark_SpoofBurst measure the spoof replies of n hunts due at the same
+// time sent one write per reply and in a single batch.
+func Benchmark_SpoofBurst(b *testing.B) {
+	lan := net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}
+	for _, n := range []int{16, 256} {
+		b.Run(fmt.Sprintf("reply/%d", n), func(b *testing.B) {
+			h, err := NewHandler("eth0", mac3, ip3, ip3, lan, WithPacketConn(newFakeConn()))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer h.Stop()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < n; j++ {
+					h.reply(mac3, ip1, mac2, ip2)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("batch/%d", n), func(b *testing.B) {
+			h, err := NewHandler("eth0", mac3, ip3, ip3, lan, WithPacketConn(discardBatchConn{newFakeConn()}))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer h.Stop()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < n; j++ {
+					h.replySpoof(mac3, ip1, mac2, ip2)
+				}
+				h.flushSpoof() // do not wait for spoofBatchWindow
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Package boltstore implements arp.Store on a bbolt database file.
+//
+// Usage:
+//
+//	s, err := boltstore.Open("/var/lib/arp/devices.db")
+//	...
+//	defer s.Close()
+//	c.SetStore(s)
+package boltstore
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/irai/arp"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	devicesBucket  = []byte("devices")
+	scheduleBucket = []byte("schedule")
+)
+
+// Store is an arp.Store backed by a bbolt database. Entries are stored as
+// JSON keyed by MAC address and schedule rules keyed by name.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open open or create the database file at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(devicesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(scheduleBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close close the database file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Load implements arp.Store
+func (s *Store) Load() (entries []arp.Entry, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(devicesBucket).ForEach(func(k, v []byte) error {
+			var e arp.Entry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			entries = append(entries, e)
+			return nil
+		})
+	})
+	return entries, err
+}
+
+// Save implements arp.Store
+func (s *Store) Save(entry arp.Entry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(devicesBucket).Put([]byte(entry.MAC.String()), b)
+	})
+}
+
+// Delete remove the entry with the given MAC from the store.
+func (s *Store) Delete(entry arp.Entry) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(devicesBucket).Delete([]byte(entry.MAC.String()))
+	})
+}
+
+// LoadSchedule return the schedule rules saved by SaveSchedule; see arp.Handler.AddSchedule
+func (s *Store) LoadSchedule() (rules []arp.ScheduleRule, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(scheduleBucket).ForEach(func(k, v []byte) error {
+			var r arp.ScheduleRule
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			rules = append(rules, r)
+			return nil
+		})
+	})
+	return rules, err
+}
+
+// SaveSchedule replace the stored schedule rules with rules
+func (s *Store) SaveSchedule(rules []arp.ScheduleRule) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(scheduleBucket); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucket(scheduleBucket)
+		if err != nil {
+			return err
+		}
+		for _, r := range rules {
+			v, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(r.Name), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
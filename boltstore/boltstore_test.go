@@ -0,0 +1,64 @@
+package boltstore
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/irai/arp"
+)
+
+func Test_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal("open error ", err)
+	}
+
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	first := time.Now().Add(-time.Hour)
+	if err := s.Save(arp.Entry{MAC: mac, IP: net.IPv4(192, 168, 0, 1).To4(), Name: "printer", FirstSeen: first}); err != nil {
+		t.Fatal("save error ", err)
+	}
+	s.Close()
+
+	if s, err = Open(path); err != nil {
+		t.Fatal("reopen error ", err)
+	}
+	defer s.Close()
+
+	entries, err := s.Load()
+	if err != nil || len(entries) != 1 {
+		t.Fatal("load error ", entries, err)
+	}
+	e := entries[0]
+	if e.MAC.String() != mac.String() || e.IP.String() != "192.168.0.1" || e.Name != "printer" || !e.FirstSeen.Equal(first) {
+		t.Error("invalid entry ", e)
+	}
+}
+
+func Test_Schedule(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "devices.db"))
+	if err != nil {
+		t.Fatal("open error ", err)
+	}
+	defer s.Close()
+
+	rule := arp.ScheduleRule{Name: "console", MAC: net.HardwareAddr{0x02, 0, 0, 0, 0, 1}, Days: []time.Weekday{time.Friday},
+		Start: time.Hour * 22, End: time.Hour * 7}
+	if err := s.SaveSchedule([]arp.ScheduleRule{rule, {Name: "kids", Group: "kids", Start: time.Hour * 21, End: time.Hour * 23}}); err != nil {
+		t.Fatal("save error ", err)
+	}
+	if err := s.SaveSchedule([]arp.ScheduleRule{rule}); err != nil {
+		t.Fatal("save error ", err)
+	}
+	rules, err := s.LoadSchedule()
+	if err != nil || len(rules) != 1 {
+		t.Fatal("load error ", rules, err)
+	}
+	r := rules[0]
+	if r.Name != "console" || r.MAC.String() != rule.MAC.String() || len(r.Days) != 1 || r.Days[0] != time.Friday || r.Start != rule.Start || r.End != rule.End {
+		t.Errorf("invalid rule %+v", r)
+	}
+}
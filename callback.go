@@ -0,0 +1,86 @@
+package arp
+
+import (
+	"net"
+)
+
+// deviceCallback is a callback registered with OnDevice
+type deviceCallback struct {
+	fn func(Event)
+}
+
+// deviceCallbacks hold the callbacks by MAC; the lists are copy on write.
+type deviceCallbacks map[string][]*deviceCallback
+
+// OnDevice call callback with every event of the device with mac, i.e. to
+// alert when the doorbell camera goes offline. Call cancel to remove the
+// callback.
+//
+// Callbacks run in a single goroutine in the order of the events, so a slow
+// callback delays the others; events are dropped if the callbacks fall behind.
+// Events published before OnDevice returns are not delivered.
+func (c *Handler) OnDevice(mac net.HardwareAddr, callback func(Event)) (cancel func(), err error) {
+	if err := checkMAC(mac); err != nil {
+		return nil, err
+	}
+	key := string(mac)
+	cb := &deviceCallback{fn: callback}
+
+	c.mutex.Lock()
+	start := c.callbacks == nil
+	if start {
+		c.callbacks = make(deviceCallbacks)
+	}
+	list := c.callbacks[key]
+	c.callbacks[key] = append(append(make([]*deviceCallback, 0, len(list)+1), list...), cb)
+	c.mutex.Unlock()
+
+	if start {
+		go c.callbackLoop(c.goroutinePool.Begin("ARP callbackLoop"), c.Subscribe(storeQueueSize, DropOldest))
+	}
+	return func() { c.removeCallback(key, cb) }, nil
+}
+
+// removeCallback remove cb from the callbacks of the MAC key
+func (c *Handler) removeCallback(key string, cb *deviceCallback) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	list := c.callbacks[key]
+	for i := range list {
+		if list[i] == cb {
+			if len(list) == 1 {
+				delete(c.callbacks, key)
+				return
+			}
+			c.callbacks[key] = append(append(make([]*deviceCallback, 0, len(list)-1), list[:i]...), list[i+1:]...)
+			return
+		}
+	}
+}
+
+// callbackLoop call the device callbacks with the events until the handler stops
+func (c *Handler) callbackLoop(h *goroutine, sub *Subscription) {
+	defer h.End()
+	defer c.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-c.goroutinePool.StopChannel:
+			return
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if event.Entry.MAC == nil {
+				continue
+			}
+			c.mutex.RLock()
+			list := c.callbacks[string(event.Entry.MAC)]
+			c.mutex.RUnlock()
+			for _, cb := range list {
+				cb.fn(event)
+			}
+		}
+	}
+}
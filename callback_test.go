@@ -0,0 +1,43 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_OnDevice(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	if _, err := h.OnDevice(net.HardwareAddr{1, 2}, func(Event) {}); err == nil {
+		t.Error("expected invalid mac error")
+	}
+	events := make(chan Event, 4)
+	cancel, err := h.OnDevice(mac1, func(e Event) { events <- e })
+	if err != nil {
+		t.Fatal("OnDevice error ", err)
+	}
+
+	h.notify(EventDeviceOnline, Entry{}, Entry{MAC: mac2, IP: ip2})
+	h.notify(EventDeviceOffline, Entry{}, Entry{MAC: mac1, IP: ip1})
+	select {
+	case e := <-events:
+		if e.Type != EventDeviceOffline || e.Entry.MAC.String() != mac1.String() {
+			t.Errorf("invalid event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for callback")
+	}
+
+	cancel()
+	h.notify(EventDeviceOnline, Entry{}, Entry{MAC: mac1, IP: ip1})
+	select {
+	case e := <-events:
+		t.Error("callback called after cancel ", e)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
@@ -0,0 +1,199 @@
+package arp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrIPInUse is returned by ClaimIP when another host answers the probes for the IP
+var ErrIPInUse = errors.New("ip address in use")
+
+// claim is an IP owned by a virtual host; see ClaimIP.
+type claim struct {
+	ip       net.IP
+	mac      net.HardwareAddr
+	probing  bool          // true until the probes complete
+	conflict chan struct{} // another host answered or probed while probing
+	defended time.Time     // last defensive announcement
+}
+
+// ClaimIP take over ip as described in RFC 5227, i.e. to move a service IP
+// to this host during failover.
+//
+// It probes the IP, returning ErrIPInUse if another host uses it, then adds a
+// virtual host with mac that announces the IP and answers the requests for it.
+// A nil mac uses a MAC from the virtual MAC pool; traffic to the IP is sent to
+// mac hence the interface must accept it. Conflicting announcements are
+// countered at most once every 10 seconds and sent as EventHostConflict.
+//
+// ClaimIP blocks while probing, for up to 7 seconds. The IP is defended until
+// ReleaseIP or Stop.
+func (c *Handler) ClaimIP(ip net.IP, mac net.HardwareAddr) error {
+	ip, mac, err := c.checkVirtualHost(ip, mac)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	if c.claims[ipKey(ip)] != nil || lookupIP(c.virtualIndex, ip) != nil {
+		c.mutex.Unlock()
+		return fmt.Errorf("ip %s is already claimed", ip)
+	}
+	if mac == nil {
+		if mac, err = c.reserveMACLocked(false); err != nil {
+			c.mutex.Unlock()
+			return err
+		}
+	} else if c.findMACLocked(mac) != nil {
+		c.mutex.Unlock()
+		return fmt.Errorf("mac %s is already in the table", mac)
+	}
+	cl := &claim{ip: ip, mac: mac, probing: true, conflict: make(chan struct{}, 1)}
+	if c.claims == nil {
+		c.claims = make(map[string]*claim)
+	}
+	c.claims[ipKey(ip)] = cl
+	c.mutex.Unlock()
+
+	if err := c.claimProbe(cl); err != nil {
+		c.mutex.Lock()
+		if c.claims[ipKey(ip)] == cl {
+			delete(c.claims, ipKey(ip))
+		}
+		c.releaseMACLocked(mac)
+		c.mutex.Unlock()
+		return err
+	}
+
+	c.mutex.Lock()
+	if c.claims[ipKey(ip)] != cl { // ReleaseIP while probing
+		c.releaseMACLocked(mac)
+		c.mutex.Unlock()
+		return fmt.Errorf("ip %s released while probing", ip)
+	}
+	cl.probing = false
+	if _, err := c.addVirtualHostLocked(cl.ip, cl.mac); err != nil { // added while probing
+		delete(c.claims, ipKey(ip))
+		c.releaseMACLocked(mac)
+		c.mutex.Unlock()
+		return err
+	}
+	c.mutex.Unlock()
+
+	c.log().WithFields(log.Fields{"ip": ip, "mac": cl.mac.String()}).Info("ARP claimed IP")
+	return c.announce(cl.mac, cl.ip)
+}
+
+// ReleaseIP stop defending an IP claimed with ClaimIP and delete its virtual
+// host; a claim still probing fails.
+//
+// It returns ErrNotFound if the IP is not claimed.
+func (c *Handler) ReleaseIP(ip net.IP) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cl := c.claims[ipKey(ip)]
+	if cl == nil {
+		return fmt.Errorf("ip %s %w", ip, ErrNotFound)
+	}
+	delete(c.claims, ipKey(ip))
+	c.removeVirtualHostLocked(ip)
+	c.log().WithFields(log.Fields{"ip": cl.ip, "mac": cl.mac.String()}).Info("ARP released IP")
+	return nil
+}
+
+// claimProbe send the RFC 5227 probes for the claimed IP from the host MAC so
+// replies are received; it returns ErrIPInUse if checkClaims saw a conflict.
+func (c *Handler) claimProbe(cl *claim) error {
+	wait := time.Duration(rand.Int63n(int64(conflictProbeWait) + 1))
+	for i := 0; i <= conflictProbeNum; i++ {
+		if i == conflictProbeNum {
+			wait = conflictAnnounceWait
+		}
+		select {
+		case <-time.After(wait):
+		case <-cl.conflict:
+			return fmt.Errorf("ip %s %w", cl.ip, ErrIPInUse)
+		case <-c.goroutinePool.StopChannel:
+			return ErrSocketClosed
+		}
+		if i == conflictProbeNum {
+			break
+		}
+		if err := c.Probe(cl.ip); err != nil {
+			return err
+		}
+		wait = conflictProbeMin + time.Duration(rand.Int63n(int64(conflictProbeMax-conflictProbeMin)+1))
+	}
+
+	// a conflict may have arrived with the last timer
+	select {
+	case <-cl.conflict:
+		return fmt.Errorf("ip %s %w", cl.ip, ErrIPInUse)
+	default:
+	}
+	return nil
+}
+
+// checkClaims look for other hosts using a claimed IP. While probing, a packet
+// from the IP or a probe for it ends the claim; afterwards the claim is
+// defended with a gratuitous reply.
+func (c *Handler) checkClaims(packet *marp.Packet) {
+	if bytes.Equal(packet.SenderHardwareAddr, c.config.HostMAC) {
+		return
+	}
+	c.mutex.RLock()
+	n := len(c.claims)
+	c.mutex.RUnlock()
+	if n == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	cl := lookupClaim(c.claims, packet.SenderIP)
+	if cl == nil && IsProbe(packet) {
+		if cl = lookupClaim(c.claims, packet.TargetIP); cl != nil && !cl.probing {
+			cl = nil // probes for a claimed IP are answered by the virtual host
+		}
+	}
+	if cl == nil || bytes.Equal(packet.SenderHardwareAddr, cl.mac) {
+		c.mutex.Unlock()
+		return
+	}
+	if cl.probing {
+		c.mutex.Unlock()
+		select {
+		case cl.conflict <- struct{}{}:
+		default:
+		}
+		return
+	}
+	now := time.Now()
+	if now.Sub(cl.defended) < conflictDefendInterval {
+		c.mutex.Unlock()
+		return
+	}
+	cl.defended = now
+	mac, ip := cl.mac, cl.ip
+	c.mutex.Unlock()
+
+	c.log().WithFields(log.Fields{"ip": ip, "mac": packet.SenderHardwareAddr.String()}).Warn("ARP defending claimed IP")
+	if err := c.Reply(mac, ip, EthernetBroadcast, ip); err != nil {
+		c.log().WithFields(log.Fields{"ip": ip}).Error("ARP error defending claimed IP ", err)
+	}
+	c.publish(Event{Type: EventHostConflict, Time: now, NIC: c.config.NIC, Entry: Entry{MAC: dupMAC(packet.SenderHardwareAddr), IP: dupIP(ip)}})
+}
+
+func lookupClaim(claims map[string]*claim, ip net.IP) *claim {
+	if len(claims) == 0 || ip == nil {
+		return nil
+	}
+	return claims[ipKey(ip)]
+}
@@ -0,0 +1,75 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_ClaimIP(t *testing.T) {
+	savedWait, savedMin, savedMax, savedAnnounce := conflictProbeWait, conflictProbeMin, conflictProbeMax, conflictAnnounceWait
+	conflictProbeWait, conflictProbeMin, conflictProbeMax, conflictAnnounceWait = 0, time.Millisecond*20, time.Millisecond*20, time.Millisecond*20
+	defer func() {
+		conflictProbeWait, conflictProbeMin, conflictProbeMax, conflictAnnounceWait = savedWait, savedMin, savedMax, savedAnnounce
+	}()
+
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropNewest)
+	vip := net.IPv4(192, 168, 0, 100).To4()
+	vmac := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x64}
+
+	// the current owner answers the probe
+	go func() {
+		time.Sleep(time.Millisecond * 5)
+		h.handlePacket(newTestPacket(marp.OperationReply, mac1, vip, mac3, net.IPv4zero))
+	}()
+	if err := h.ClaimIP(vip, vmac); !errors.Is(err, ErrIPInUse) {
+		t.Fatal("expected ErrIPInUse ", err)
+	}
+
+	if err := h.ClaimIP(vip, vmac); err != nil {
+		t.Fatal("ClaimIP error ", err)
+	}
+	if entry := h.FindVirtualIP(vip); entry == nil || entry.MAC.String() != vmac.String() {
+		t.Fatalf("invalid virtual host %+v", entry)
+	}
+	if err := h.ClaimIP(vip, nil); err == nil {
+		t.Error("ClaimIP twice should fail")
+	}
+
+	// defend once per interval
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, vip, EthernetBroadcast, vip))
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, vip, EthernetBroadcast, vip))
+	var conflicts int
+	for done := false; !done; {
+		select {
+		case e := <-s.C:
+			if e.Type == EventHostConflict && e.Entry.IP.Equal(vip) && e.Entry.MAC.String() == mac1.String() {
+				conflicts++
+			}
+		case <-time.After(time.Millisecond * 100):
+			done = true
+		}
+	}
+	if conflicts != 1 {
+		t.Errorf("expected one conflict got %d", conflicts)
+	}
+
+	if err := h.ReleaseIP(vip); err != nil {
+		t.Fatal("ReleaseIP error ", err)
+	}
+	if h.FindVirtualIP(vip) != nil {
+		t.Error("virtual host not deleted")
+	}
+	if err := h.ReleaseIP(vip); !errors.Is(err, ErrNotFound) {
+		t.Error("expected ErrNotFound ", err)
+	}
+}
@@ -0,0 +1,244 @@
+// Command arp is a companion tool for the arp package.
+//
+// Usage:
+//
+//	arp [flags] scan             send a request to every LAN address and print the table
+//	arp [flags] table            listen for ARP traffic and print the table
+//	arp [flags] watch            print entry events until interrupted
+//	arp [flags] hunt <mac>       force the device to change its IP until interrupted
+//	arp [flags] resolve <ip>     print the MAC for the IP
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/irai/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ifaceFlag    = flag.String("i", "eth0", "network interface to listen to")
+	defaultGw    = flag.String("g", "", "default gateway IPv4 (-g 192.168.1.1)")
+	durationFlag = flag.Duration("t", time.Second*10, "time to wait for devices before printing the table")
+	scanFlag     = flag.Duration("s", time.Minute*5, "interval between full LAN scans for watch and hunt")
+	debugFlag    = flag.Bool("d", false, "enable debug logging")
+	logFlag      = flag.String("log", "", "debug log areas: packets, table, spoof, scan or all (-log spoof,table)")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] scan | table | watch | hunt <mac> | resolve <ip>\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	areas, err := arp.ParseLogAreas(*logFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+	if *debugFlag {
+		areas = arp.LogAllAreas
+	}
+	log.SetLevel(log.WarnLevel)
+	if areas != 0 {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	args := flag.Args()
+	if len(args) < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	var run func(c *arp.Handler, args []string) error
+	var options []arp.Option
+	nargs := 0
+	switch args[0] {
+	case "scan":
+		run = scan
+	case "table":
+		run, options = table, []arp.Option{arp.WithoutScan()}
+	case "watch":
+		run = watch
+	case "hunt":
+		run, nargs = hunt, 1
+	case "resolve":
+		run, nargs, options = resolve, 1, []arp.Option{arp.WithoutScan()}
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if len(args)-1 != nargs {
+		usage()
+		os.Exit(2)
+	}
+
+	c, err := newHandler(*ifaceFlag, options...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	c.SetLogAreas(areas)
+
+	err = run(c, args[1:])
+	c.Stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func newHandler(nic string, options ...arp.Option) (*arp.Handler, error) {
+	routerIP := net.ParseIP(*defaultGw).To4()
+	if routerIP == nil {
+		return arp.NewHandlerAutoDetect(nic, options...)
+	}
+
+	hostIP, homeLAN, hostMAC, err := getNICInfo(nic)
+	if err != nil {
+		return nil, err
+	}
+	return arp.NewHandler(nic, hostMAC, hostIP, routerIP, homeLAN, options...)
+}
+
+// scan send a request to every address in the LAN and print the table.
+func scan(c *arp.Handler, args []string) error {
+	go c.ListenAndServe(time.Hour) // the first full scan is immediate
+	time.Sleep(*durationFlag)
+	printTable(c)
+	return nil
+}
+
+// table print the entries learned from ARP traffic without probing the LAN.
+func table(c *arp.Handler, args []string) error {
+	go c.ListenAndServe(0)
+	time.Sleep(*durationFlag)
+	printTable(c)
+	return nil
+}
+
+// watch print every event until interrupted.
+func watch(c *arp.Handler, args []string) error {
+	s := c.Subscribe(arp.DefaultNotificationQueueSize, arp.DropOldest)
+	defer c.Unsubscribe(s)
+	go c.ListenAndServe(*scanFlag)
+
+	interrupt := interrupted()
+	for {
+		select {
+		case event := <-s.C:
+			printEvent(event)
+		case <-interrupt:
+			return nil
+		}
+	}
+}
+
+// hunt wait for the device to appear and force it to change its IP until
+// interrupted or the hunt finishes.
+func hunt(c *arp.Handler, args []string) error {
+	mac, err := net.ParseMAC(args[0])
+	if err != nil {
+		return err
+	}
+
+	s := c.Subscribe(arp.DefaultNotificationQueueSize, arp.DropOldest)
+	defer c.Unsubscribe(s)
+	go c.ListenAndServe(*scanFlag)
+
+	interrupt := interrupted()
+	entry := waitMAC(c, mac, *durationFlag, interrupt)
+	if entry == nil {
+		return fmt.Errorf("mac %s not found", mac)
+	}
+	if err := c.ForceIPChange(entry.MAC, entry.IP); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case event := <-s.C:
+			if event.Entry.MAC.String() != mac.String() {
+				continue
+			}
+			printEvent(event)
+			if event.Type == arp.EventHuntStopped {
+				return nil
+			}
+		case <-interrupt:
+			return c.StopIPChange(mac)
+		}
+	}
+}
+
+// resolve print the MAC for the IP.
+func resolve(c *arp.Handler, args []string) error {
+	ip := net.ParseIP(args[0]).To4()
+	if ip == nil {
+		return fmt.Errorf("invalid IPv4 %q", args[0])
+	}
+
+	go c.ListenAndServe(0)
+	time.Sleep(time.Millisecond * 100) // wait for the read loop
+	entry, err := c.WhoIs(ip)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("ip %s not found", ip)
+	}
+	fmt.Println(entry.MAC)
+	return nil
+}
+
+func waitMAC(c *arp.Handler, mac net.HardwareAddr, timeout time.Duration, interrupt <-chan os.Signal) *arp.Entry {
+	ticker := time.NewTicker(time.Millisecond * 250)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+
+	for {
+		if entry := c.FindMAC(mac); entry != nil {
+			return entry
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			return nil
+		case <-interrupt:
+			return nil
+		}
+	}
+}
+
+func interrupted() <-chan os.Signal {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	return ch
+}
+
+func printTable(c *arp.Handler) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MAC\tIP\tSTATE\tONLINE\tLAST SEEN")
+	for _, e := range c.GetTable() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%v\n", e.MAC, e.IP, e.State, e.Online, time.Since(e.LastUpdate).Round(time.Second))
+	}
+	w.Flush()
+}
+
+func printEvent(e arp.Event) {
+	fmt.Printf("%s %-11s %s %s", e.Time.Format(time.RFC3339), e.Type, e.Entry.MAC, e.Entry.IP)
+	if e.Type == arp.EventIPChanged || e.Type == arp.EventHuntStopped {
+		fmt.Printf(" (was %s)", e.Previous.IP)
+	}
+	fmt.Println()
+}
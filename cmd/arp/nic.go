@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// getNICInfo return the first IPv4 address, its network and the MAC of the interface.
+func getNICInfo(nic string) (ip net.IP, lan net.IPNet, mac net.HardwareAddr, err error) {
+	ifi, err := net.InterfaceByName(nic)
+	if err != nil {
+		return nil, lan, nil, fmt.Errorf("cannot open nic %s: %w", nic, err)
+	}
+
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, lan, nil, fmt.Errorf("cannot get addresses for nic %s: %w", nic, err)
+	}
+
+	for i := range addrs {
+		tmp, ipnet, err := net.ParseCIDR(addrs[i].String())
+		if err != nil {
+			continue
+		}
+		if ip = tmp.To4(); ip != nil && !ip.Equal(net.IPv4zero) {
+			return ip, net.IPNet{IP: ipnet.IP.To4(), Mask: ipnet.Mask}, ifi.HardwareAddr, nil
+		}
+	}
+
+	return nil, lan, nil, fmt.Errorf("cannot find IPv4 address for nic %s - is it up?", nic)
+}
@@ -0,0 +1,233 @@
+package arp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Config is the configuration of a Handler; see NewHandlerWithConfig and LoadConfig.
+type Config struct {
+	NIC        string
+	HostMAC    net.HardwareAddr
+	HostIP     net.IP
+	RouterIP   net.IP
+	RouterMAC  net.HardwareAddr // optional; resolved by ListenAndServe if nil
+	RouterIPv6 net.IP           // optional; learned from router advertisements if nil
+	HomeLAN    net.IPNet
+
+	ScanInterval  time.Duration      // optional; used when ListenAndServe is called with zero
+	Devices       []DeviceConfig     // optional; added to the table offline
+	SpoofAllowMAC []net.HardwareAddr // optional; see SetSpoofAllowList
+	SpoofAllowIP  []net.IP
+	SpoofDenyMAC  []net.HardwareAddr // optional; see SetSpoofDenyList
+	SpoofDenyIP   []net.IP
+}
+
+// DeviceConfig is a device known in advance, i.e. to name it before it is seen.
+type DeviceConfig struct {
+	MAC           net.HardwareAddr
+	IP            net.IP        // optional
+	Name          string        // optional; see SetName
+	ProbeInterval time.Duration // optional; see SetProbeInterval
+}
+
+// Validate return an error wrapping ErrInvalidConfig if the configuration
+// cannot be used by a handler.
+//
+// The host and router addresses must be IPv4 unicast addresses in HomeLAN,
+// the MACs must be ethernet unicast addresses and the router MAC, if set,
+// must differ from the host MAC. The host may be the router. Devices must have
+// a unique MAC and a probe interval of zero or at least one second.
+func (c Config) Validate() error {
+	if c.NIC == "" {
+		return fmt.Errorf("%w: missing interface name", ErrInvalidConfig)
+	}
+	if err := checkMAC(c.HostMAC); err != nil {
+		return fmt.Errorf("%w: host MAC: %v", ErrInvalidConfig, err)
+	}
+	if isBroadcastOrZero(c.HostMAC) {
+		return fmt.Errorf("%w: host MAC %s is not a unicast address", ErrInvalidConfig, c.HostMAC)
+	}
+
+	lan := c.HomeLAN.IP.To4()
+	if ones, bits := c.HomeLAN.Mask.Size(); lan == nil || bits != 32 || ones == 0 {
+		return fmt.Errorf("%w: home LAN %s is not an IPv4 network", ErrInvalidConfig, c.HomeLAN.String())
+	}
+	homeLAN := net.IPNet{IP: lan.Mask(c.HomeLAN.Mask), Mask: c.HomeLAN.Mask}
+
+	if err := checkLANAddress(homeLAN, "host IP", c.HostIP); err != nil {
+		return err
+	}
+	if c.RouterIP == nil {
+		return fmt.Errorf("%w: missing router IP", ErrInvalidConfig)
+	}
+	if err := checkLANAddress(homeLAN, "router IP", c.RouterIP); err != nil {
+		return err
+	}
+
+	if c.RouterMAC != nil {
+		if err := checkMAC(c.RouterMAC); err != nil {
+			return fmt.Errorf("%w: router MAC: %v", ErrInvalidConfig, err)
+		}
+		if isBroadcastOrZero(c.RouterMAC) {
+			return fmt.Errorf("%w: router MAC %s is not a unicast address", ErrInvalidConfig, c.RouterMAC)
+		}
+		if bytes.Equal(c.RouterMAC, c.HostMAC) && !c.RouterIP.Equal(c.HostIP) {
+			return fmt.Errorf("%w: router MAC %s is the host MAC", ErrInvalidConfig, c.RouterMAC)
+		}
+	}
+	if c.RouterIPv6 != nil && (c.RouterIPv6.To4() != nil || c.RouterIPv6.To16() == nil) {
+		return fmt.Errorf("%w: router IPv6 %s is not an IPv6 address", ErrInvalidConfig, c.RouterIPv6)
+	}
+
+	if c.ScanInterval < 0 {
+		return fmt.Errorf("%w: negative scan interval %v", ErrInvalidConfig, c.ScanInterval)
+	}
+	seen := make(map[string]bool, len(c.Devices))
+	for _, d := range c.Devices {
+		if err := checkMAC(d.MAC); err != nil {
+			return fmt.Errorf("%w: device MAC: %v", ErrInvalidConfig, err)
+		}
+		if seen[string(d.MAC)] {
+			return fmt.Errorf("%w: device %s is duplicated", ErrInvalidConfig, d.MAC)
+		}
+		seen[string(d.MAC)] = true
+		if d.IP != nil && d.IP.To4() == nil {
+			return fmt.Errorf("%w: device %s IP %s is not an IPv4 address", ErrInvalidConfig, d.MAC, d.IP)
+		}
+		if d.ProbeInterval != 0 && d.ProbeInterval < probeIntervalMin {
+			return fmt.Errorf("%w: device %s probe interval %v is shorter than %v", ErrInvalidConfig, d.MAC, d.ProbeInterval, probeIntervalMin)
+		}
+	}
+	for _, list := range [][]net.HardwareAddr{c.SpoofAllowMAC, c.SpoofDenyMAC} {
+		for _, mac := range list {
+			if err := checkMAC(mac); err != nil {
+				return fmt.Errorf("%w: spoof list: %v", ErrInvalidConfig, err)
+			}
+		}
+	}
+	for _, list := range [][]net.IP{c.SpoofAllowIP, c.SpoofDenyIP} {
+		for _, ip := range list {
+			if ip.To16() == nil {
+				return fmt.Errorf("%w: spoof list: invalid IP %s", ErrInvalidConfig, ip)
+			}
+		}
+	}
+	return nil
+}
+
+// checkLANAddress return an error if ip is not a host address in lan.
+func checkLANAddress(lan net.IPNet, name string, ip net.IP) error {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return fmt.Errorf("%w: %s %s is not an IPv4 address", ErrInvalidConfig, name, ip)
+	}
+	if !lan.Contains(ip4) {
+		return fmt.Errorf("%w: %s %s is not in home LAN %s", ErrInvalidConfig, name, ip4, lan.String())
+	}
+	if ones, _ := lan.Mask.Size(); ones <= 30 {
+		broadcast := make(net.IP, 4)
+		for i := range broadcast {
+			broadcast[i] = lan.IP[i] | ^lan.Mask[i]
+		}
+		if ip4.Equal(lan.IP) || ip4.Equal(broadcast) {
+			return fmt.Errorf("%w: %s %s is the network or broadcast address of %s", ErrInvalidConfig, name, ip4, lan.String())
+		}
+	}
+	return nil
+}
+
+func isBroadcastOrZero(mac net.HardwareAddr) bool {
+	return bytes.Equal(mac, EthernetBroadcast) || bytes.Equal(mac, net.HardwareAddr{0, 0, 0, 0, 0, 0})
+}
+
+// copy return a normalised copy that does not share the underlying slices;
+// the configuration must be valid.
+func (c Config) copy() Config {
+	n := c
+	n.HostMAC = dupMAC(c.HostMAC)
+	n.HostIP = dupIP(c.HostIP)
+	n.RouterIP = dupIP(c.RouterIP)
+	if c.RouterMAC != nil {
+		n.RouterMAC = dupMAC(c.RouterMAC)
+	}
+	if c.RouterIPv6 != nil {
+		n.RouterIPv6 = dupIPv6(c.RouterIPv6)
+	}
+	n.HomeLAN = net.IPNet{IP: dupIP(c.HomeLAN.IP.To4().Mask(c.HomeLAN.Mask)), Mask: append(net.IPMask(nil), c.HomeLAN.Mask...)}
+	if c.Devices != nil {
+		n.Devices = make([]DeviceConfig, len(c.Devices))
+		for i, d := range c.Devices {
+			n.Devices[i] = d
+			n.Devices[i].MAC = dupMAC(d.MAC)
+			if d.IP != nil {
+				n.Devices[i].IP = dupIP(d.IP)
+			}
+		}
+	}
+	n.SpoofAllowMAC, n.SpoofDenyMAC = dupMACs(c.SpoofAllowMAC), dupMACs(c.SpoofDenyMAC)
+	n.SpoofAllowIP, n.SpoofDenyIP = dupIPs(c.SpoofAllowIP), dupIPs(c.SpoofDenyIP)
+	return n
+}
+
+func dupMACs(list []net.HardwareAddr) []net.HardwareAddr {
+	if list == nil {
+		return nil
+	}
+	n := make([]net.HardwareAddr, len(list))
+	for i := range list {
+		n[i] = dupMAC(list[i])
+	}
+	return n
+}
+
+func dupIPs(list []net.IP) []net.IP {
+	if list == nil {
+		return nil
+	}
+	n := make([]net.IP, len(list))
+	for i := range list {
+		if n[i] = dupIP(list[i]); n[i] == nil {
+			n[i] = dupIPv6(list[i])
+		}
+	}
+	return n
+}
+
+// applyConfig set the optional configuration in the handler.
+func (c *Handler) applyConfig(config Config) {
+	if len(config.SpoofAllowMAC) > 0 || len(config.SpoofAllowIP) > 0 {
+		c.SetSpoofAllowList(config.SpoofAllowMAC, config.SpoofAllowIP)
+	}
+	if len(config.SpoofDenyMAC) > 0 || len(config.SpoofDenyIP) > 0 {
+		c.SetSpoofDenyList(config.SpoofDenyMAC, config.SpoofDenyIP)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, d := range config.Devices {
+		entry, err := c.restoreLocked(Entry{MAC: d.MAC, IP: d.IP, Name: d.Name})
+		if entry == nil {
+			c.log().WithFields(log.Fields{"mac": d.MAC.String(), "ip": d.IP}).Warn("ARP cannot add configured device ", err)
+			continue
+		}
+		if d.ProbeInterval > 0 {
+			if c.probeIntervals == nil {
+				c.probeIntervals = make(intervalList)
+			}
+			c.probeIntervals[string(d.MAC)] = d.ProbeInterval
+		}
+	}
+}
+
+// Config return a copy of the handler configuration, including the router MAC
+// and IPv6 address resolved at runtime.
+func (c *Handler) Config() Config {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.config.copy()
+}
@@ -0,0 +1,99 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_ConfigValidate(t *testing.T) {
+	lan := net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}
+	valid := Config{NIC: "eth0", HostMAC: mac3, HostIP: ip3, RouterIP: ip1, HomeLAN: lan}
+	if err := valid.Validate(); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(*Config)
+	}{
+		{"nic", func(c *Config) { c.NIC = "" }},
+		{"host mac", func(c *Config) { c.HostMAC = net.HardwareAddr{0x01, 0x02} }},
+		{"broadcast mac", func(c *Config) { c.HostMAC = EthernetBroadcast }},
+		{"host ip", func(c *Config) { c.HostIP = net.ParseIP("fe80::1") }},
+		{"host outside lan", func(c *Config) { c.HostIP = net.IPv4(10, 0, 0, 1) }},
+		{"host broadcast", func(c *Config) { c.HostIP = net.IPv4(192, 168, 0, 255) }},
+		{"router", func(c *Config) { c.RouterIP = nil }},
+		{"router outside lan", func(c *Config) { c.RouterIP = net.IPv4(10, 0, 0, 1) }},
+		{"router mac", func(c *Config) { c.RouterMAC = mac3 }},
+		{"router ipv6", func(c *Config) { c.RouterIPv6 = ip1 }},
+		{"lan", func(c *Config) { c.HomeLAN = net.IPNet{} }},
+	}
+	for _, tt := range tests {
+		config := valid
+		tt.change(&config)
+		if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: expected invalid configuration %v", tt.name, err)
+		}
+	}
+
+	h, err := NewHandlerWithConfig(Config{NIC: "eth0", HostMAC: mac3, HostIP: net.IPv4(192, 168, 0, 3), RouterIP: ip1, HomeLAN: lan},
+		WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandlerWithConfig error ", err)
+	}
+	if config := h.Config(); len(config.HostIP) != net.IPv4len || !config.RouterIP.Equal(ip1) {
+		t.Errorf("invalid config %+v", config)
+	}
+}
+
+func Test_LoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "arp.yaml")
+	os.WriteFile(yamlFile, []byte(`
+nic: eth0
+host_mac: 02:00:00:00:00:03
+host_ip: 192.168.0.3
+home_lan: 192.168.0.0/24
+router_ip: 192.168.0.1
+scan_interval: 5m
+devices:
+  - mac: 02:00:00:00:00:01
+    name: printer
+    probe_interval: 5m
+spoof_allow: [192.168.0.10, 02:00:00:00:00:02]
+`), 0644)
+	config, err := LoadConfig(yamlFile)
+	if err != nil {
+		t.Fatal("LoadConfig error ", err)
+	}
+	if config.ScanInterval != time.Minute*5 || len(config.Devices) != 1 || config.Devices[0].Name != "printer" ||
+		len(config.SpoofAllowIP) != 1 || len(config.SpoofAllowMAC) != 1 {
+		t.Errorf("invalid config %+v", config)
+	}
+
+	h, err := NewHandlerWithConfig(config, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandlerWithConfig error ", err)
+	}
+	if e := h.FindMAC(config.Devices[0].MAC); e == nil || e.Name != "printer" || e.Online {
+		t.Errorf("invalid device %+v", e)
+	}
+	if err := h.CheckSpoof(mac1, ip1); !errors.Is(err, ErrHuntNotAllowed) {
+		t.Error("expected hunt not allowed ", err)
+	}
+
+	jsonFile := filepath.Join(dir, "arp.json")
+	os.WriteFile(jsonFile, []byte(`{"nic": "eth0", "host_mac": "02:00:00:00:00:03", "host_ip": "10.0.0.3",
+		"home_lan": "192.168.0.0/24", "router_ip": "192.168.0.1"}`), 0644)
+	if _, err := LoadConfig(jsonFile); !errors.Is(err, ErrInvalidConfig) {
+		t.Error("expected host outside LAN error ", err)
+	}
+	os.WriteFile(yamlFile, []byte("nic: eth0\nunknown: 1\n"), 0644)
+	if _, err := LoadConfig(yamlFile); !errors.Is(err, ErrInvalidConfig) {
+		t.Error("expected unknown field error ", err)
+	}
+}
@@ -0,0 +1,196 @@
+package arp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is the LoadConfig file format; addresses and durations are strings.
+type configFile struct {
+	NIC          string       `yaml:"nic" json:"nic"`
+	HostMAC      string       `yaml:"host_mac" json:"host_mac"`
+	HostIP       string       `yaml:"host_ip" json:"host_ip"`
+	HomeLAN      string       `yaml:"home_lan" json:"home_lan"`
+	RouterIP     string       `yaml:"router_ip" json:"router_ip"`
+	RouterMAC    string       `yaml:"router_mac" json:"router_mac"`
+	ScanInterval string       `yaml:"scan_interval" json:"scan_interval"`
+	Devices      []deviceFile `yaml:"devices" json:"devices"`
+	SpoofAllow   []string     `yaml:"spoof_allow" json:"spoof_allow"` // MAC or IP
+	SpoofDeny    []string     `yaml:"spoof_deny" json:"spoof_deny"`   // MAC or IP
+}
+
+type deviceFile struct {
+	MAC           string `yaml:"mac" json:"mac"`
+	IP            string `yaml:"ip" json:"ip"`
+	Name          string `yaml:"name" json:"name"`
+	ProbeInterval string `yaml:"probe_interval" json:"probe_interval"`
+}
+
+// LoadConfig read a YAML or JSON configuration file and return the validated
+// Config; files with a .json extension are JSON, otherwise YAML.
+//
+// Only nic is required. The host MAC, host IP and home LAN are read from the
+// interface and the router IP from the routing table if not set, as in
+// NewHandlerAutoDetect. Unknown keys are an error.
+//
+//	nic: eth0
+//	router_ip: 192.168.0.1
+//	scan_interval: 5m
+//	devices:
+//	  - mac: 02:00:00:00:00:01
+//	    name: printer
+//	    probe_interval: 5m
+//	spoof_deny: [192.168.0.10, 02:00:00:00:00:02]
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var file configFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(&file)
+	} else {
+		d := yaml.NewDecoder(bytes.NewReader(b))
+		d.KnownFields(true)
+		err = d.Decode(&file)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+	}
+
+	config, err := file.config()
+	if err != nil {
+		return Config{}, err
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// config parse the file fields; missing host and router addresses are read from the interface.
+func (f configFile) config() (config Config, err error) {
+	invalid := func(name string, value string) error {
+		return fmt.Errorf("%w: invalid %s %q", ErrInvalidConfig, name, value)
+	}
+
+	config.NIC = f.NIC
+	if f.HostMAC != "" {
+		if config.HostMAC, err = net.ParseMAC(f.HostMAC); err != nil {
+			return config, invalid("host_mac", f.HostMAC)
+		}
+	}
+	if f.HostIP != "" {
+		if config.HostIP = net.ParseIP(f.HostIP); config.HostIP == nil {
+			return config, invalid("host_ip", f.HostIP)
+		}
+	}
+	if f.HomeLAN != "" {
+		_, lan, err := net.ParseCIDR(f.HomeLAN)
+		if err != nil {
+			return config, invalid("home_lan", f.HomeLAN)
+		}
+		config.HomeLAN = *lan
+	}
+	if f.RouterIP != "" {
+		if config.RouterIP = net.ParseIP(f.RouterIP); config.RouterIP == nil {
+			return config, invalid("router_ip", f.RouterIP)
+		}
+	}
+	if f.RouterMAC != "" {
+		if config.RouterMAC, err = net.ParseMAC(f.RouterMAC); err != nil {
+			return config, invalid("router_mac", f.RouterMAC)
+		}
+	}
+	if f.ScanInterval != "" {
+		if config.ScanInterval, err = time.ParseDuration(f.ScanInterval); err != nil {
+			return config, invalid("scan_interval", f.ScanInterval)
+		}
+	}
+
+	for _, d := range f.Devices {
+		device := DeviceConfig{Name: d.Name}
+		if device.MAC, err = net.ParseMAC(d.MAC); err != nil {
+			return config, invalid("device mac", d.MAC)
+		}
+		if d.IP != "" {
+			if device.IP = net.ParseIP(d.IP); device.IP == nil {
+				return config, invalid("device ip", d.IP)
+			}
+		}
+		if d.ProbeInterval != "" {
+			if device.ProbeInterval, err = time.ParseDuration(d.ProbeInterval); err != nil {
+				return config, invalid("device probe_interval", d.ProbeInterval)
+			}
+		}
+		config.Devices = append(config.Devices, device)
+	}
+
+	if config.SpoofAllowMAC, config.SpoofAllowIP, err = parseAddressList("spoof_allow", f.SpoofAllow); err != nil {
+		return config, err
+	}
+	if config.SpoofDenyMAC, config.SpoofDenyIP, err = parseAddressList("spoof_deny", f.SpoofDeny); err != nil {
+		return config, err
+	}
+
+	if config.NIC == "" || (config.HostMAC != nil && config.HostIP != nil && config.HomeLAN.IP != nil && config.RouterIP != nil) {
+		return config, nil
+	}
+	return config, detectConfig(&config)
+}
+
+// parseAddressList split a list of MAC and IP addresses.
+func parseAddressList(name string, list []string) (macs []net.HardwareAddr, ips []net.IP, err error) {
+	for _, s := range list {
+		if ip := net.ParseIP(s); ip != nil {
+			ips = append(ips, ip)
+			continue
+		}
+		mac, err := net.ParseMAC(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid %s address %q", ErrInvalidConfig, name, s)
+		}
+		macs = append(macs, mac)
+	}
+	return macs, ips, nil
+}
+
+// detectConfig set the missing host and router addresses from the interface.
+func detectConfig(config *Config) error {
+	ifi, err := net.InterfaceByName(config.NIC)
+	if err != nil {
+		return fmt.Errorf("cannot open nic %s: %w", config.NIC, err)
+	}
+	if config.HostMAC == nil {
+		config.HostMAC = ifi.HardwareAddr
+	}
+	if config.HostIP == nil || config.HomeLAN.IP == nil {
+		hostIP, homeLAN, err := interfaceIPv4(ifi)
+		if err != nil {
+			return err
+		}
+		if config.HostIP == nil {
+			config.HostIP = hostIP
+		}
+		if config.HomeLAN.IP == nil {
+			config.HomeLAN = homeLAN
+		}
+	}
+	if config.RouterIP == nil {
+		if config.RouterIP, err = defaultGateway(ifi); err != nil {
+			return fmt.Errorf("cannot get default gateway for nic %s: %w", config.NIC, err)
+		}
+	}
+	return nil
+}
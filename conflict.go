@@ -0,0 +1,144 @@
+package arp
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// RFC 5227 address conflict detection timers
+var (
+	conflictProbeWait      = time.Second     // PROBE_WAIT: random delay before the first probe
+	conflictProbeNum       = 3               // PROBE_NUM
+	conflictProbeMin       = time.Second     // PROBE_MIN: minimum delay between probes
+	conflictProbeMax       = time.Second * 2 // PROBE_MAX: maximum delay between probes
+	conflictAnnounceWait   = time.Second * 2 // ANNOUNCE_WAIT: wait for replies after the last probe
+	conflictDefendInterval = time.Second * 10
+)
+
+// WithConflictDetection detect other hosts using the host IP as described in
+// RFC 5227.
+//
+// ListenAndServe probes the host IP when it starts and every interval; zero
+// interval probes on start only. EventHostConflict is sent with the MAC of the
+// other host when it answers a probe, probes for the host IP while the host is
+// probing or sends any ARP packet with the host IP; the event is sent at most
+// once per MAC every 10 seconds. Healthy returns ErrAddressConflict while a
+// conflict was seen in the health window.
+func WithConflictDetection(interval time.Duration) Option {
+	return func(c *Handler) error {
+		if interval < 0 {
+			return errors.New("invalid conflict detection interval")
+		}
+		c.conflict = &conflictDetector{interval: interval, alerts: make(map[string]time.Time)}
+		return nil
+	}
+}
+
+type conflictDetector struct {
+	interval time.Duration
+	probing  int32 // atomic value; 1 while conflictProbe is running
+	last     int64 // atomic value; unix nano time of the last conflict
+	mutex    sync.Mutex
+	alerts   map[string]time.Time // event time by MAC
+}
+
+// detectConflict check if the packet was sent by another host using the host IP.
+func (c *Handler) detectConflict(packet *marp.Packet) {
+	d := c.conflict
+	if bytes.Equal(packet.SenderHardwareAddr, c.config.HostMAC) {
+		return
+	}
+	conflict := packet.SenderIP.Equal(c.config.HostIP)
+	if !conflict && atomic.LoadInt32(&d.probing) != 0 {
+		// another host probing for the same IP at the same time
+		conflict = IsProbe(packet) && packet.TargetIP.Equal(c.config.HostIP)
+	}
+	if !conflict {
+		return
+	}
+
+	now := time.Now()
+	atomic.StoreInt64(&d.last, now.UnixNano())
+	d.mutex.Lock()
+	if last, ok := d.alerts[string(packet.SenderHardwareAddr)]; ok && now.Sub(last) < conflictDefendInterval {
+		d.mutex.Unlock()
+		return
+	}
+	if len(d.alerts) >= spoofDetectorMaxRecords {
+		d.alerts = make(map[string]time.Time)
+	}
+	d.alerts[string(packet.SenderHardwareAddr)] = now
+	d.mutex.Unlock()
+
+	c.log().WithFields(log.Fields{"mac": packet.SenderHardwareAddr.String(), "ip": c.config.HostIP}).Error("ARP host IP address conflict")
+	c.publish(Event{Type: EventHostConflict, Time: now, NIC: c.config.NIC, Entry: Entry{MAC: dupMAC(packet.SenderHardwareAddr), IP: dupIP(c.config.HostIP)}})
+}
+
+// hostConflict return ErrAddressConflict if a conflict was seen in the last window.
+func (c *Handler) hostConflict(window time.Duration) error {
+	if c.conflict == nil {
+		return nil
+	}
+	last := atomic.LoadInt64(&c.conflict.last)
+	if last != 0 && time.Since(time.Unix(0, last)) < window {
+		return ErrAddressConflict
+	}
+	return nil
+}
+
+// conflictLoop probe the host IP on start and every interval.
+func (c *Handler) conflictLoop(h *goroutine) {
+	defer h.End()
+
+	for {
+		if !c.conflictProbe() {
+			return
+		}
+		if c.conflict.interval == 0 {
+			return
+		}
+		select {
+		case <-time.After(c.conflict.interval):
+		case <-c.goroutinePool.StopChannel:
+			return
+		}
+	}
+}
+
+// conflictProbe send PROBE_NUM probes for the host IP at random intervals and
+// wait ANNOUNCE_WAIT for replies; replies are processed by detectConflict.
+// It returns false if the handler is stopping.
+func (c *Handler) conflictProbe() bool {
+	d := c.conflict
+	atomic.StoreInt32(&d.probing, 1)
+	defer atomic.StoreInt32(&d.probing, 0)
+
+	if c.logArea(LogScan) {
+		c.log().WithFields(log.Fields{"ip": c.config.HostIP}).Debug("ARP probing host IP for conflicts")
+	}
+	wait := time.Duration(rand.Int63n(int64(conflictProbeWait) + 1))
+	for i := 0; i < conflictProbeNum; i++ {
+		select {
+		case <-time.After(wait):
+		case <-c.goroutinePool.StopChannel:
+			return false
+		}
+		if err := c.Probe(c.config.HostIP); err != nil {
+			c.log().WithFields(log.Fields{"ip": c.config.HostIP}).Error("ARP conflict probe error ", err)
+		}
+		wait = conflictProbeMin + time.Duration(rand.Int63n(int64(conflictProbeMax-conflictProbeMin)+1))
+	}
+	select {
+	case <-time.After(conflictAnnounceWait):
+	case <-c.goroutinePool.StopChannel:
+		return false
+	}
+	return true
+}
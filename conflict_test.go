@@ -0,0 +1,72 @@
+package arp
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_ConflictDetection(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(conn), WithConflictDetection(0))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropNewest)
+
+	// probe from another host is a conflict only while the host is probing
+	probe := newTestPacket(marp.OperationRequest, mac2, net.IPv4zero, EthernetBroadcast, ip3)
+	h.handlePacket(probe)
+	atomic.StoreInt32(&h.conflict.probing, 1)
+	h.handlePacket(probe)
+	atomic.StoreInt32(&h.conflict.probing, 0)
+
+	// reply with the host IP; the second reply is within the defend interval
+	h.handlePacket(newTestPacket(marp.OperationReply, mac1, ip3, mac3, ip3))
+	h.handlePacket(newTestPacket(marp.OperationReply, mac1, ip3, mac3, ip3))
+	h.handlePacket(newTestPacket(marp.OperationReply, mac3, ip3, mac1, ip1)) // host
+
+	var macs []string
+	for done := false; !done; {
+		select {
+		case e := <-s.C:
+			if e.Type == EventHostConflict {
+				if !e.Entry.IP.Equal(ip3) {
+					t.Errorf("invalid conflict IP %s", e.Entry.IP)
+				}
+				macs = append(macs, e.Entry.MAC.String())
+			}
+		case <-time.After(time.Millisecond * 100):
+			done = true
+		}
+	}
+	if len(macs) != 2 || macs[0] != mac2.String() || macs[1] != mac1.String() {
+		t.Errorf("invalid conflicts %v", macs)
+	}
+	if err := h.hostConflict(time.Minute); err != ErrAddressConflict {
+		t.Error("expected ErrAddressConflict ", err)
+	}
+
+	savedWait, savedMin, savedMax, savedAnnounce := conflictProbeWait, conflictProbeMin, conflictProbeMax, conflictAnnounceWait
+	conflictProbeWait, conflictProbeMin, conflictProbeMax, conflictAnnounceWait = 0, time.Millisecond, time.Millisecond, time.Millisecond
+	defer func() {
+		conflictProbeWait, conflictProbeMin, conflictProbeMax, conflictAnnounceWait = savedWait, savedMin, savedMax, savedAnnounce
+	}()
+	for len(conn.out) > 0 {
+		<-conn.out
+	}
+	if !h.conflictProbe() {
+		t.Fatal("conflictProbe stopped")
+	}
+	if n := len(conn.out); n != conflictProbeNum {
+		t.Fatalf("expected %d probes got %d", conflictProbeNum, n)
+	}
+	if p := <-conn.out; !p.SenderIP.Equal(net.IPv4zero) || !p.TargetIP.Equal(ip3) || p.SenderHardwareAddr.String() != mac3.String() {
+		t.Errorf("invalid probe %+v", p)
+	}
+}
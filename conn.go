@@ -0,0 +1,91 @@
+package arp
+
+import (
+	"net"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// PacketConn is the ARP packet source and sink used by the handler; see WithPacketConn.
+//
+// Read must block until a packet is available and return io.EOF to end
+// ListenAndServe; it may reuse the returned packet and frame in the next call.
+// SetWriteDeadline is called before every write if the conn implements it.
+type PacketConn interface {
+	Read() (*marp.Packet, *ethernet.Frame, error)
+	WriteTo(p *marp.Packet, addr net.HardwareAddr) error
+	Close() error
+	SetReadDeadline(t time.Time) error
+}
+
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// setWriteDeadline set the write deadline if supported by the connection
+func (c *Handler) setWriteDeadline(t time.Time) error {
+	if conn, ok := c.client.(writeDeadliner); ok {
+		return conn.SetWriteDeadline(t)
+	}
+	return nil
+}
+
+// batchReader is implemented by connections that read several packets per
+// call; see WithBatchReads.
+type batchReader interface {
+	// ReadBatch return at least one packet; the packets and frames are valid
+	// until the next read.
+	ReadBatch() ([]*marp.Packet, []*ethernet.Frame, error)
+}
+
+// singleBatch return the packet of a single Read as a batch.
+type singleBatch struct {
+	packets [1]*marp.Packet
+	frames  [1]*ethernet.Frame
+}
+
+func (b *singleBatch) read(conn PacketConn) ([]*marp.Packet, []*ethernet.Frame, error) {
+	packet, frame, err := conn.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	b.packets[0], b.frames[0] = packet, frame
+	return b.packets[:], b.frames[:], nil
+}
+
+// batchWriter is implemented by connections that send several packets per
+// call; see replySpoof.
+type batchWriter interface {
+	// WriteBatch send packets[i] to addrs[i]; it returns the number of
+	// packets sent.
+	WriteBatch(packets []*marp.Packet, addrs []net.HardwareAddr) (int, error)
+}
+
+// batchWriter return the connection if it sends packets in batches or nil.
+func (c *Handler) batchWriter() batchWriter {
+	conn := c.client
+	if r, ok := conn.(*redialConn); ok {
+		conn = r.current()
+	}
+	if _, ok := conn.(batchWriter); !ok {
+		return nil
+	}
+	return c.client.(batchWriter)
+}
+
+// dropCounter is implemented by connections that report the frames dropped by
+// the kernel; see Stats.KernelDrops.
+type dropCounter interface {
+	KernelDrops() uint64
+}
+
+// receiveTimer is implemented by connections that report the kernel receive
+// time of the packets, so the table and the events are not skewed by
+// scheduling delays in the read loop.
+type receiveTimer interface {
+	// ReceiveTimes return the receive time of the packets returned by the
+	// last Read or ReadBatch call; a zero time is unknown.
+	ReceiveTimes() []time.Time
+}
@@ -0,0 +1,249 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+	"golang.org/x/net/bpf"
+)
+
+// BSD systems use BPF devices to read and write ethernet frames. The raw
+// package used by marp.Dial does not support write deadlines on BSD and reads
+// a single frame per buffer, so the handler uses its own BPF connection.
+
+// bpfTimeout is the BPF read timeout; Read checks for Close and the read
+// deadline at this interval.
+const bpfTimeout = time.Millisecond * 100
+
+// bpfConn is a PacketConn using a BPF device bound to the interface.
+type bpfConn struct {
+	fd       int
+	closed   int32 // atomic; set by Close
+	deadline atomic.Value
+	buf      []byte // read buffer; must be the BPF buffer length
+	pending  []byte // frames in buf not returned yet
+	rmutex   sync.Mutex
+	wmutex   sync.Mutex
+	decoder  frameDecoder
+}
+
+// dialPacketConn open a BPF device on the interface and filter ARP frames
+func dialPacketConn(ifi *net.Interface) (PacketConn, error) {
+	fd, err := openBPF()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &bpfConn{fd: fd}
+	if err := c.configure(ifi); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("cannot configure bpf device: %w", err)
+	}
+	c.deadline.Store(time.Time{})
+	return c, nil
+}
+
+// openBPF open the cloning device if available or the first free numbered device.
+func openBPF() (int, error) {
+	fd, err := syscall.Open("/dev/bpf", syscall.O_RDWR, 0)
+	if err == nil {
+		return fd, nil
+	}
+	for i := 0; i < 256; i++ {
+		fd, err = syscall.Open(fmt.Sprintf("/dev/bpf%d", i), syscall.O_RDWR, 0)
+		if err == nil {
+			return fd, nil
+		}
+		if err != syscall.EBUSY {
+			break
+		}
+	}
+	if errors.Is(err, os.ErrPermission) {
+		return -1, fmt.Errorf("cannot open bpf device - run as root: %w", err)
+	}
+	return -1, fmt.Errorf("cannot open bpf device: %w", err)
+}
+
+func (c *bpfConn) configure(ifi *net.Interface) error {
+	if err := syscall.SetBpfInterface(c.fd, ifi.Name); err != nil {
+		return err
+	}
+
+	// return packets as soon as they arrive instead of when the buffer is full
+	if err := syscall.SetBpfImmediate(c.fd, 1); err != nil {
+		return err
+	}
+
+	// keep the source MAC in written frames; required to send as a virtual host
+	if err := syscall.SetBpfHeadercmpl(c.fd, 1); err != nil {
+		return err
+	}
+
+	tv := syscall.NsecToTimeval(int64(bpfTimeout))
+	if err := syscall.SetBpfTimeout(c.fd, &tv); err != nil {
+		return err
+	}
+
+	// accept ARP frames only, including 802.1Q and 802.1ad tagged frames, and untagged RARP frames
+	program := []syscall.BpfInsn{
+		*syscall.BpfStmt(syscall.BPF_LD+syscall.BPF_H+syscall.BPF_ABS, 12),
+		*syscall.BpfJump(syscall.BPF_JMP+syscall.BPF_JEQ+syscall.BPF_K, int(ethernet.EtherTypeARP), 7, 0),
+		*syscall.BpfJump(syscall.BPF_JMP+syscall.BPF_JEQ+syscall.BPF_K, int(EtherTypeRARP), 6, 0),
+		*syscall.BpfJump(syscall.BPF_JMP+syscall.BPF_JEQ+syscall.BPF_K, int(ethernet.EtherTypeVLAN), 3, 0),
+		*syscall.BpfJump(syscall.BPF_JMP+syscall.BPF_JEQ+syscall.BPF_K, int(ethernet.EtherTypeServiceVLAN), 0, 5),
+		*syscall.BpfStmt(syscall.BPF_LD+syscall.BPF_H+syscall.BPF_ABS, 20), // double tagged
+		*syscall.BpfJump(syscall.BPF_JMP+syscall.BPF_JEQ+syscall.BPF_K, int(ethernet.EtherTypeARP), 2, 3),
+		*syscall.BpfStmt(syscall.BPF_LD+syscall.BPF_H+syscall.BPF_ABS, 16), // tagged
+		*syscall.BpfJump(syscall.BPF_JMP+syscall.BPF_JEQ+syscall.BPF_K, int(ethernet.EtherTypeARP), 0, 1),
+		*syscall.BpfStmt(syscall.BPF_RET+syscall.BPF_K, 0xffff),
+		*syscall.BpfStmt(syscall.BPF_RET+syscall.BPF_K, 0),
+	}
+	if err := syscall.SetBpf(c.fd, program); err != nil {
+		return err
+	}
+
+	// reads must use the exact buffer length
+	n, err := syscall.BpfBuflen(c.fd)
+	if err != nil {
+		return err
+	}
+	c.buf = make([]byte, n)
+	return nil
+}
+
+// SetPromiscuous enable promiscuous mode on the interface for the lifetime of
+// the BPF device; it cannot be disabled.
+func (c *bpfConn) SetPromiscuous(b bool) error {
+	if !b {
+		return nil
+	}
+	return syscall.SetBpfPromisc(c.fd, 1)
+}
+
+// SetBPF replace the ARP filter of the BPF device with filter
+func (c *bpfConn) SetBPF(filter []bpf.RawInstruction) error {
+	program := make([]syscall.BpfInsn, 0, len(filter))
+	for _, ins := range filter {
+		program = append(program, syscall.BpfInsn{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K})
+	}
+	return syscall.SetBpf(c.fd, program)
+}
+
+// Read return the next ARP packet; it blocks until a packet arrives, the read
+// deadline expires or the connection is closed.
+func (c *bpfConn) Read() (*marp.Packet, *ethernet.Frame, error) {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	for {
+		if atomic.LoadInt32(&c.closed) != 0 {
+			return nil, nil, net.ErrClosed
+		}
+
+		// a single read may return several frames; each has a bpf header
+		for len(c.pending) > 0 {
+			frame := c.nextFrame()
+			if frame == nil {
+				continue
+			}
+			if packet, eth, err := c.decoder.decode(frame); err == nil {
+				return packet, eth, nil
+			}
+		}
+
+		if d := c.deadline.Load().(time.Time); !d.IsZero() && time.Now().After(d) {
+			return nil, nil, os.ErrDeadlineExceeded
+		}
+
+		n, err := syscall.Read(c.fd, c.buf)
+		if err != nil {
+			if err == syscall.EINTR || err == syscall.EAGAIN {
+				continue
+			}
+			return nil, nil, err
+		}
+		c.pending = c.buf[:n]
+	}
+}
+
+// nextFrame remove the next frame from pending; it return nil if the frame is truncated.
+func (c *bpfConn) nextFrame() []byte {
+	if len(c.pending) < int(unsafe.Sizeof(syscall.BpfHdr{})) {
+		c.pending = nil
+		return nil
+	}
+
+	// frames are word aligned in the buffer so the header can be cast in place
+	hdr := (*syscall.BpfHdr)(unsafe.Pointer(&c.pending[0]))
+	start, end := int(hdr.Hdrlen), int(hdr.Hdrlen)+int(hdr.Caplen)
+	if end > len(c.pending) {
+		c.pending = nil
+		return nil
+	}
+	frame := c.pending[start:end]
+
+	next := (end + syscall.BPF_ALIGNMENT - 1) &^ (syscall.BPF_ALIGNMENT - 1)
+	if next > len(c.pending) {
+		next = len(c.pending)
+	}
+	c.pending = c.pending[next:]
+
+	if hdr.Caplen != hdr.Datalen {
+		return nil // snapped
+	}
+	return frame
+}
+
+// WriteTo send the packet; the frame is built the same way as marp.Client.WriteTo.
+func (c *bpfConn) WriteTo(p *marp.Packet, addr net.HardwareAddr) error {
+	return c.WriteToVLAN(p, addr, nil)
+}
+
+// WriteToVLAN send the packet in a frame tagged with vlan; vlan may be nil.
+func (c *bpfConn) WriteToVLAN(p *marp.Packet, addr net.HardwareAddr, vlan *ethernet.VLAN) error {
+	fb, err := encodeFrame(p, vlan)
+	if err != nil {
+		return err
+	}
+
+	c.wmutex.Lock()
+	defer c.wmutex.Unlock()
+	if atomic.LoadInt32(&c.closed) != 0 {
+		return net.ErrClosed
+	}
+	_, err = syscall.Write(c.fd, fb)
+	return err
+}
+
+// Close close the BPF device; a pending Read returns within bpfTimeout.
+func (c *bpfConn) Close() error {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return nil
+	}
+
+	// wait for pending reads and writes so the descriptor is not reused under them
+	c.rmutex.Lock()
+	c.wmutex.Lock()
+	err := syscall.Close(c.fd)
+	c.wmutex.Unlock()
+	c.rmutex.Unlock()
+	return err
+}
+
+// SetReadDeadline implements PacketConn; the zero value means no deadline.
+func (c *bpfConn) SetReadDeadline(t time.Time) error {
+	c.deadline.Store(t)
+	return nil
+}
@@ -0,0 +1,324 @@
+//go:build linux
+// +build linux
+
+package arp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// mmsgFrameLen is the size of each receive buffer; larger frames are truncated
+// and discarded by the decoder.
+const mmsgFrameLen = 1536
+
+// mmsgOOBLen is the size of each control message buffer.
+const mmsgOOBLen = 128
+
+// mmsghdr is the linux struct mmsghdr; Go pads the struct the same way as C.
+type mmsghdr struct {
+	hdr unix.Msghdr
+	n   uint32
+}
+
+// mmsgConn is a PacketConn on an AF_PACKET socket reading up to len(msgs)
+// frames per recvmmsg syscall; see WithBatchReads.
+//
+// The socket is non blocking and polled when there are no frames, the same way
+// as ringConn, so Close and the read deadline are checked every
+// ringPollTimeout.
+type mmsgConn struct {
+	fd       int
+	ifindex  int
+	closed   int32 // atomic; set by Close
+	deadline atomic.Value
+	rmutex   sync.Mutex
+	msgs     []mmsghdr
+	iovs     []unix.Iovec
+	bufs     []byte // len(msgs) frames of mmsgFrameLen
+	oob      []byte // len(msgs) control buffers of mmsgOOBLen
+	decoders []frameDecoder
+	packets  []*marp.Packet
+	frames   []*ethernet.Frame
+	times    []time.Time // kernel receive time of packets
+	last     []time.Time // times of the packets returned by the last read
+	control  socketControl
+	drops    uint32 // atomic; last SO_RXQ_OVFL value
+	next     int    // next packet returned by Read in packets[:n]
+	n        int
+}
+
+// newMmsgConn open an AF_PACKET socket for ARP frames on the interface reading
+// up to size frames per syscall.
+func newMmsgConn(ifi *net.Interface, size int) (conn *mmsgConn, err error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, int(htons(unix.ETH_P_ARP)))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open packet socket: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			unix.Close(fd)
+		}
+	}()
+
+	// the kernel removes the 802.1Q tag and reports it in the auxiliary data
+	if err = unix.SetsockoptInt(fd, unix.SOL_PACKET, unix.PACKET_AUXDATA, 1); err != nil {
+		return nil, fmt.Errorf("cannot set PACKET_AUXDATA: %w", err)
+	}
+	// the kernel reports the frames dropped by the socket with each frame
+	if err = unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_RXQ_OVFL, 1); err != nil {
+		return nil, fmt.Errorf("cannot set SO_RXQ_OVFL: %w", err)
+	}
+	// the kernel reports the receive time with each frame
+	if err = unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TIMESTAMPNS, 1); err != nil {
+		return nil, fmt.Errorf("cannot set SO_TIMESTAMPNS: %w", err)
+	}
+	if err = unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ARP), Ifindex: ifi.Index}); err != nil {
+		return nil, fmt.Errorf("cannot bind packet socket: %w", err)
+	}
+
+	conn = &mmsgConn{
+		fd:       fd,
+		ifindex:  ifi.Index,
+		msgs:     make([]mmsghdr, size),
+		iovs:     make([]unix.Iovec, size),
+		bufs:     make([]byte, size*mmsgFrameLen),
+		oob:      make([]byte, size*mmsgOOBLen),
+		decoders: make([]frameDecoder, size),
+		packets:  make([]*marp.Packet, size),
+		frames:   make([]*ethernet.Frame, size),
+		times:    make([]time.Time, size),
+	}
+	for i := range conn.msgs {
+		conn.iovs[i].Base = &conn.bufs[i*mmsgFrameLen]
+		conn.iovs[i].SetLen(mmsgFrameLen)
+		conn.msgs[i].hdr.Iov = &conn.iovs[i]
+		conn.msgs[i].hdr.SetIovlen(1)
+		conn.msgs[i].hdr.Control = &conn.oob[i*mmsgOOBLen]
+	}
+	conn.deadline.Store(time.Time{})
+	return conn, nil
+}
+
+// Read return the next ARP packet; packets are read from the socket in
+// batches and returned one at a time.
+func (c *mmsgConn) Read() (*marp.Packet, *ethernet.Frame, error) {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	if c.next >= c.n {
+		n, err := c.readLocked()
+		if err != nil {
+			return nil, nil, err
+		}
+		c.next, c.n = 0, n
+	}
+	c.next++
+	c.last = c.times[c.next-1 : c.next]
+	return c.packets[c.next-1], c.frames[c.next-1], nil
+}
+
+// ReadBatch return the packets read in a single recvmmsg call; the packets are
+// valid until the next read.
+func (c *mmsgConn) ReadBatch() ([]*marp.Packet, []*ethernet.Frame, error) {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	if c.next < c.n { // packets left by Read
+		next := c.next
+		c.next = c.n
+		c.last = c.times[next:c.n]
+		return c.packets[next:c.n], c.frames[next:c.n], nil
+	}
+	n, err := c.readLocked()
+	if err != nil {
+		return nil, nil, err
+	}
+	c.next, c.n = n, n
+	c.last = c.times[:n]
+	return c.packets[:n], c.frames[:n], nil
+}
+
+// readLocked decode the frames of the next recvmmsg call into packets; it
+// blocks until a frame arrives, the read deadline expires or the connection
+// is closed.
+func (c *mmsgConn) readLocked() (int, error) {
+	for {
+		if atomic.LoadInt32(&c.closed) != 0 {
+			return 0, net.ErrClosed
+		}
+
+		n, err := c.recvmmsg()
+		if err != nil && err != unix.EAGAIN && err != unix.EINTR {
+			return 0, err
+		}
+		count := 0
+		for i := 0; i < n; i++ {
+			msg := &c.msgs[i]
+			frame := c.bufs[i*mmsgFrameLen : i*mmsgFrameLen+int(msg.n)]
+			packet, eth, err := c.decoders[count].decode(frame)
+			if err != nil || msg.hdr.Flags&unix.MSG_TRUNC != 0 {
+				continue
+			}
+			c.control.parse(c.oob[i*mmsgOOBLen : i*mmsgOOBLen+int(msg.hdr.Controllen)])
+			if c.control.hasVLAN && eth.VLAN == nil {
+				c.decoders[count].setVLAN(c.control.vlan)
+			}
+			if c.control.hasDrops {
+				atomic.StoreUint32(&c.drops, c.control.drops)
+			}
+			c.times[count] = time.Time{}
+			if c.control.hasStamp {
+				c.times[count] = time.Unix(c.control.stamp.Unix())
+			}
+			c.packets[count], c.frames[count] = packet, eth
+			count++
+		}
+		if count > 0 {
+			return count, nil
+		}
+		if n > 0 { // only invalid frames; read again before polling
+			continue
+		}
+
+		if d := c.deadline.Load().(time.Time); !d.IsZero() && time.Now().After(d) {
+			return 0, os.ErrDeadlineExceeded
+		}
+
+		fds := [1]unix.PollFd{{Fd: int32(c.fd), Events: unix.POLLIN | unix.POLLERR}}
+		if _, err := unix.Poll(fds[:], ringPollTimeout); err != nil && err != unix.EINTR {
+			return 0, err
+		}
+	}
+}
+
+// recvmmsg read up to len(msgs) frames without blocking.
+func (c *mmsgConn) recvmmsg() (int, error) {
+	for i := range c.msgs {
+		c.msgs[i].hdr.SetControllen(mmsgOOBLen)
+		c.msgs[i].hdr.Flags = 0
+	}
+	n, _, errno := unix.Syscall6(unix.SYS_RECVMMSG, uintptr(c.fd), uintptr(unsafe.Pointer(&c.msgs[0])),
+		uintptr(len(c.msgs)), unix.MSG_DONTWAIT, 0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// socketControl is the ancillary data received with a frame.
+type socketControl struct {
+	vlan     uint16 // 802.1Q tag control information if hasVLAN
+	hasVLAN  bool
+	drops    uint32 // SO_RXQ_OVFL socket drop counter if hasDrops
+	hasDrops bool
+	stamp    unix.Timespec // SO_TIMESTAMPNS receive time if hasStamp
+	hasStamp bool
+}
+
+// parse set the fields in the oob control messages; it does not allocate.
+func (sc *socketControl) parse(oob []byte) {
+	*sc = socketControl{}
+	for len(oob) >= unix.SizeofCmsghdr {
+		h := (*unix.Cmsghdr)(unsafe.Pointer(&oob[0]))
+		if int(h.Len) < unix.SizeofCmsghdr || int(h.Len) > len(oob) {
+			return
+		}
+		data := oob[unix.CmsgLen(0):h.Len]
+		switch {
+		case h.Level == unix.SOL_PACKET && h.Type == unix.PACKET_AUXDATA && len(data) >= int(unsafe.Sizeof(unix.TpacketAuxdata{})):
+			aux := (*unix.TpacketAuxdata)(unsafe.Pointer(&data[0]))
+			sc.vlan, sc.hasVLAN = aux.Vlan_tci, aux.Status&unix.TP_STATUS_VLAN_VALID != 0
+		case h.Level == unix.SOL_SOCKET && h.Type == unix.SO_RXQ_OVFL && len(data) >= 4:
+			sc.drops, sc.hasDrops = *(*uint32)(unsafe.Pointer(&data[0])), true
+		case h.Level == unix.SOL_SOCKET && h.Type == unix.SCM_TIMESTAMPNS && len(data) >= int(unsafe.Sizeof(unix.Timespec{})):
+			sc.stamp, sc.hasStamp = *(*unix.Timespec)(unsafe.Pointer(&data[0])), true
+		}
+		next := unix.CmsgSpace(len(data))
+		if next >= len(oob) {
+			return
+		}
+		oob = oob[next:]
+	}
+}
+
+// WriteTo send the packet; the frame is built the same way as marp.Client.WriteTo.
+func (c *mmsgConn) WriteTo(p *marp.Packet, addr net.HardwareAddr) error {
+	return sendFrame(c.fd, c.ifindex, p, addr, nil)
+}
+
+// WriteToVLAN send the packet in a frame tagged with vlan; vlan may be nil.
+func (c *mmsgConn) WriteToVLAN(p *marp.Packet, addr net.HardwareAddr, vlan *ethernet.VLAN) error {
+	return sendFrame(c.fd, c.ifindex, p, addr, vlan)
+}
+
+// WriteBatch send the packets in a single sendmmsg call; see sendFrames.
+func (c *mmsgConn) WriteBatch(packets []*marp.Packet, addrs []net.HardwareAddr) (int, error) {
+	return sendFrames(c.fd, c.ifindex, packets, addrs)
+}
+
+// KernelDrops return the number of frames dropped because the socket receive
+// queue was full; the SO_RXQ_OVFL counter is received with each frame so the
+// value is updated when the next frame is read.
+func (c *mmsgConn) KernelDrops() uint64 {
+	return uint64(atomic.LoadUint32(&c.drops))
+}
+
+// ReceiveTimes return the SO_TIMESTAMPNS kernel receive time of the packets
+// returned by the last Read or ReadBatch call; it is only valid in the reading
+// goroutine until the next read.
+func (c *mmsgConn) ReceiveTimes() []time.Time {
+	return c.last
+}
+
+// Close close the socket; a pending Read returns within ringPollTimeout.
+func (c *mmsgConn) Close() error {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return nil
+	}
+
+	// wait for a pending read before closing the socket
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+	return unix.Close(c.fd)
+}
+
+// SetReadDeadline implements PacketConn; the zero value means no deadline.
+func (c *mmsgConn) SetReadDeadline(t time.Time) error {
+	c.deadline.Store(t)
+	return nil
+}
+
+// SetBPF attach filter to the packet socket
+func (c *mmsgConn) SetBPF(filter []bpf.RawInstruction) error {
+	return setPacketBPF(c.fd, filter)
+}
+
+// SetPromiscuous set promiscuous mode on the interface for the lifetime of the socket
+func (c *mmsgConn) SetPromiscuous(b bool) error {
+	return setPacketPromiscuous(c.fd, c.ifindex, b)
+}
+
+// dialBatch open a recvmmsg connection on the interface reading up to size
+// frames per syscall
+func dialBatch(nic string, size int) (PacketConn, error) {
+	ifi, err := net.InterfaceByName(nic)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := newMmsgConn(ifi, size)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,89 @@
+package arp
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+	"unsafe"
+
+	marp "github.com/mdlayher/arp"
+	"golang.org/x/sys/unix"
+)
+
+func Test_MmsgConn(t *testing.T) {
+	ifi, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("no loopback interface ", err)
+	}
+	c, err := newMmsgConn(ifi, 8)
+	if err != nil {
+		t.Skip("packet socket requires root ", err)
+	}
+	defer c.Close()
+
+	// frames sent on loopback are received by the same socket
+	p, _ := marp.NewPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2)
+	if err := c.WriteTo(p, EthernetBroadcast); err != nil {
+		t.Fatal("write error ", err)
+	}
+	packets := []*marp.Packet{p, p}
+	if n, err := c.WriteBatch(packets, []net.HardwareAddr{EthernetBroadcast, EthernetBroadcast}); n != 2 || err != nil {
+		t.Fatal("write batch error ", n, err)
+	}
+
+	start := time.Now().Add(-time.Second)
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	for n := 0; n < 3; {
+		packets, frames, err := c.ReadBatch()
+		if err != nil || len(packets) == 0 || len(packets) != len(frames) {
+			t.Fatal("read error ", packets, err)
+		}
+		times := c.ReceiveTimes()
+		for i, p := range packets {
+			if !p.TargetIP.Equal(ip2) {
+				t.Fatal("invalid packet ", p)
+			}
+			if len(times) != len(packets) || times[i].Before(start) || times[i].After(time.Now()) {
+				t.Fatal("invalid receive time ", times)
+			}
+		}
+		n += len(packets)
+	}
+
+	c.SetReadDeadline(time.Now().Add(time.Millisecond * 200))
+	if _, _, err := c.Read(); err != os.ErrDeadlineExceeded {
+		t.Error("expected deadline error ", err)
+	}
+}
+
+func Test_SocketControl(t *testing.T) {
+	var oob []byte
+	add := func(level int32, typ int32, data []byte) {
+		b := make([]byte, unix.CmsgSpace(len(data)))
+		h := (*unix.Cmsghdr)(unsafe.Pointer(&b[0]))
+		h.Level, h.Type = level, typ
+		h.SetLen(unix.CmsgLen(len(data)))
+		copy(b[unix.CmsgLen(0):], data)
+		oob = append(oob, b...)
+	}
+	aux := unix.TpacketAuxdata{Status: unix.TP_STATUS_VLAN_VALID, Vlan_tci: 10}
+	add(unix.SOL_PACKET, unix.PACKET_AUXDATA, (*[unsafe.Sizeof(aux)]byte)(unsafe.Pointer(&aux))[:])
+	drops := uint32(7)
+	add(unix.SOL_SOCKET, unix.SO_RXQ_OVFL, (*[4]byte)(unsafe.Pointer(&drops))[:])
+	stamp := unix.Timespec{Sec: 1000, Nsec: 5}
+	add(unix.SOL_SOCKET, unix.SCM_TIMESTAMPNS, (*[unsafe.Sizeof(stamp)]byte)(unsafe.Pointer(&stamp))[:])
+
+	var sc socketControl
+	sc.parse(oob)
+	if !sc.hasVLAN || sc.vlan != 10 || !sc.hasDrops || sc.drops != 7 || !sc.hasStamp || sc.stamp != stamp {
+		t.Errorf("invalid control %+v", sc)
+	}
+	sc.parse(oob[:unix.CmsgSpace(int(unsafe.Sizeof(aux)))])
+	if !sc.hasVLAN || sc.hasDrops || sc.hasStamp {
+		t.Errorf("invalid control %+v", sc)
+	}
+	if n := testing.AllocsPerRun(100, func() { sc.parse(oob) }); n != 0 {
+		t.Errorf("parse allocates %v times", n)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package arp
+
+import "errors"
+
+func dialBatch(nic string, size int) (PacketConn, error) {
+	return nil, errors.New("batched reads are only supported on linux")
+}
@@ -0,0 +1,54 @@
+//go:build !windows && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !windows,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package arp
+
+import (
+	"net"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+	"github.com/mdlayher/raw"
+	"golang.org/x/net/bpf"
+)
+
+// rawConn is the mdlayher arp.Client on a raw socket; the socket is kept to
+// attach socket filters.
+type rawConn struct {
+	*marp.Client
+	socket *raw.Conn
+}
+
+// dialPacketConn open a raw socket on the interface
+func dialPacketConn(ifi *net.Interface) (PacketConn, error) {
+	p, err := raw.ListenPacket(ifi, uint16(ethernet.EtherTypeARP), nil)
+	if err != nil {
+		return nil, err
+	}
+	c, err := marp.New(ifi, p)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	return &rawConn{Client: c, socket: p}, nil
+}
+
+// SetPromiscuous set promiscuous mode on the interface
+func (c *rawConn) SetPromiscuous(b bool) error {
+	return c.socket.SetPromiscuous(b)
+}
+
+// SetBPF attach filter to the raw socket
+func (c *rawConn) SetBPF(filter []bpf.RawInstruction) error {
+	return c.socket.SetBPF(filter)
+}
+
+// KernelDrops return the number of frames dropped because the socket receive
+// queue was full; it is zero if not supported.
+func (c *rawConn) KernelDrops() uint64 {
+	stats, err := c.socket.Stats()
+	if err != nil {
+		return 0
+	}
+	return stats.Drops
+}
@@ -0,0 +1,367 @@
+//go:build linux
+// +build linux
+
+package arp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// Ring buffer geometry; 16 blocks of 64KB hold about 500 full size frames or
+// several thousand ARP frames.
+const (
+	ringBlockSize    = 1 << 16
+	ringBlockCount   = 16
+	ringFrameSize    = 1 << 11
+	ringBlockTimeout = 50 // milliseconds before the kernel retires a partially filled block
+	ringPollTimeout  = 100
+)
+
+// ringConn is a PacketConn reading frames from an AF_PACKET TPACKET_V3 ring
+// buffer shared with the kernel. Frames are parsed in place so no read syscall
+// or copy to a user buffer is required per frame; the socket is only polled
+// when the ring is empty.
+type ringConn struct {
+	fd       int
+	ifindex  int
+	ring     []byte
+	block    int    // current block
+	offset   int    // offset of the next frame in the current block
+	pending  uint32 // frames not read in the current block
+	closed   int32  // atomic; set by Close
+	deadline atomic.Value
+	rmutex   sync.Mutex
+	decoder  frameDecoder
+	drops    uint64       // atomic; see KernelDrops
+	times    [1]time.Time // kernel receive time of the last packet
+}
+
+// newRingConn open an AF_PACKET socket for ARP and RARP frames on the
+// interface and map its receive ring.
+//
+// The socket is bound to all protocols so RARP frames reach the ring; the
+// kernel drops other frames with ringFilter. A filter set with SetBPF replaces it.
+func newRingConn(ifi *net.Interface) (conn *ringConn, err error) {
+	// protocol zero receives nothing until bind, after the filter is attached
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open packet socket: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			unix.Close(fd)
+		}
+	}()
+
+	if err = unix.SetsockoptInt(fd, unix.SOL_PACKET, unix.PACKET_VERSION, unix.TPACKET_V3); err != nil {
+		return nil, fmt.Errorf("cannot set TPACKET_V3: %w", err)
+	}
+	req := unix.TpacketReq3{
+		Block_size:     ringBlockSize,
+		Block_nr:       ringBlockCount,
+		Frame_size:     ringFrameSize,
+		Frame_nr:       ringBlockSize / ringFrameSize * ringBlockCount,
+		Retire_blk_tov: ringBlockTimeout,
+	}
+	if err = unix.SetsockoptTpacketReq3(fd, unix.SOL_PACKET, unix.PACKET_RX_RING, &req); err != nil {
+		return nil, fmt.Errorf("cannot set rx ring: %w", err)
+	}
+
+	ring, err := unix.Mmap(fd, 0, ringBlockSize*ringBlockCount, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("cannot map rx ring: %w", err)
+	}
+
+	filter, err := ringFilter()
+	if err == nil {
+		err = setPacketBPF(fd, filter)
+	}
+	if err != nil {
+		unix.Munmap(ring)
+		return nil, fmt.Errorf("cannot attach ARP filter: %w", err)
+	}
+
+	if err = unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ALL), Ifindex: ifi.Index}); err != nil {
+		unix.Munmap(ring)
+		return nil, fmt.Errorf("cannot bind packet socket: %w", err)
+	}
+
+	conn = &ringConn{fd: fd, ifindex: ifi.Index, ring: ring}
+	conn.deadline.Store(time.Time{})
+	return conn, nil
+}
+
+// ringFilter return the default ring socket filter accepting ARP and RARP
+// frames; the kernel removes the 802.1Q tag before running the filter.
+func ringFilter() ([]bpf.RawInstruction, error) {
+	return bpf.Assemble([]bpf.Instruction{
+		bpf.LoadAbsolute{Off: 12, Size: 2}, // ethertype
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: uint32(ethernet.EtherTypeARP), SkipTrue: 1},
+		bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: uint32(EtherTypeRARP), SkipTrue: 1},
+		bpf.RetConstant{Val: 0xffff},
+		bpf.RetConstant{Val: 0},
+	})
+}
+
+// blockStatus return the status word of the current block descriptor
+func (c *ringConn) blockStatus() *uint32 {
+	return (*uint32)(unsafe.Pointer(&c.ring[c.block*ringBlockSize+int(unsafe.Offsetof(unix.TpacketBlockDesc{}.Hdr))]))
+}
+
+// blockHeader return the current block header
+func (c *ringConn) blockHeader() *unix.TpacketHdrV1 {
+	return (*unix.TpacketHdrV1)(unsafe.Pointer(&c.ring[c.block*ringBlockSize+int(unsafe.Offsetof(unix.TpacketBlockDesc{}.Hdr))]))
+}
+
+// releaseBlock return the current block to the kernel and move to the next one
+func (c *ringConn) releaseBlock() {
+	atomic.StoreUint32(c.blockStatus(), unix.TP_STATUS_KERNEL)
+	c.block = (c.block + 1) % ringBlockCount
+	c.pending = 0
+}
+
+// Read return the next ARP packet; it blocks until a packet arrives, the read
+// deadline expires, the connection is closed or the socket reports an error,
+// i.e. ENETDOWN when the interface goes down.
+func (c *ringConn) Read() (*marp.Packet, *ethernet.Frame, error) {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	for {
+		if atomic.LoadInt32(&c.closed) != 0 {
+			return nil, nil, net.ErrClosed
+		}
+
+		if c.pending > 0 {
+			base := c.block*ringBlockSize + c.offset
+			hdr := (*unix.Tpacket3Hdr)(unsafe.Pointer(&c.ring[base]))
+			frame := c.ring[base+int(hdr.Mac) : base+int(hdr.Mac)+int(hdr.Snaplen)]
+			status, tci := hdr.Status, hdr.Hv1.Vlan_tci
+			c.times[0] = time.Unix(int64(hdr.Sec), int64(hdr.Nsec))
+			c.offset += int(hdr.Next_offset)
+			c.pending--
+
+			// decoding copies the frame out of the ring
+			packet, eth, err := c.decoder.decode(frame)
+			if c.pending == 0 {
+				c.releaseBlock()
+			}
+			if err != nil {
+				continue
+			}
+			// the kernel removes the 802.1Q tag and reports it in the frame header
+			if eth.VLAN == nil && status&unix.TP_STATUS_VLAN_VALID != 0 {
+				c.decoder.setVLAN(uint16(tci))
+			}
+			return packet, eth, nil
+		}
+
+		if atomic.LoadUint32(c.blockStatus())&unix.TP_STATUS_USER != 0 {
+			h := c.blockHeader()
+			c.pending, c.offset = h.Num_pkts, int(h.Offset_to_first_pkt)
+			if c.pending == 0 {
+				c.releaseBlock()
+			}
+			continue
+		}
+
+		if d := c.deadline.Load().(time.Time); !d.IsZero() && time.Now().After(d) {
+			return nil, nil, os.ErrDeadlineExceeded
+		}
+
+		fds := []unix.PollFd{{Fd: int32(c.fd), Events: unix.POLLIN | unix.POLLERR}}
+		n, err := unix.Poll(fds, ringPollTimeout)
+		if err != nil && err != unix.EINTR {
+			return nil, nil, err
+		}
+		// poll returns at once while the error is pending; return it or Read spins
+		if n > 0 && fds[0].Revents&(unix.POLLERR|unix.POLLHUP|unix.POLLNVAL) != 0 {
+			return nil, nil, c.pollError(fds[0].Revents)
+		}
+	}
+}
+
+// pollError return the pending socket error for the poll revents.
+func (c *ringConn) pollError(revents int16) error {
+	if revents&unix.POLLNVAL != 0 {
+		return net.ErrClosed
+	}
+	if errno, err := unix.GetsockoptInt(c.fd, unix.SOL_SOCKET, unix.SO_ERROR); err != nil {
+		return os.NewSyscallError("getsockopt", err)
+	} else if errno != 0 {
+		return os.NewSyscallError("poll", unix.Errno(errno))
+	}
+	return fmt.Errorf("packet socket poll error: revents %#x", revents)
+}
+
+// WriteTo send the packet; the frame is built the same way as marp.Client.WriteTo.
+func (c *ringConn) WriteTo(p *marp.Packet, addr net.HardwareAddr) error {
+	return c.WriteToVLAN(p, addr, nil)
+}
+
+// WriteToVLAN send the packet in a frame tagged with vlan; vlan may be nil.
+func (c *ringConn) WriteToVLAN(p *marp.Packet, addr net.HardwareAddr, vlan *ethernet.VLAN) error {
+	return sendFrame(c.fd, c.ifindex, p, addr, vlan)
+}
+
+// sendFrame send the packet on the AF_PACKET socket fd.
+func sendFrame(fd int, ifindex int, p *marp.Packet, addr net.HardwareAddr, vlan *ethernet.VLAN) error {
+	fb, err := encodeFrame(p, vlan)
+	if err != nil {
+		return err
+	}
+
+	sa := &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ARP), Ifindex: ifindex, Halen: uint8(len(addr))}
+	copy(sa.Addr[:], addr)
+	return unix.Sendto(fd, fb, 0, sa)
+}
+
+// WriteBatch send the packets in a single sendmmsg call; see sendFrames.
+func (c *ringConn) WriteBatch(packets []*marp.Packet, addrs []net.HardwareAddr) (int, error) {
+	return sendFrames(c.fd, c.ifindex, packets, addrs)
+}
+
+// sendFrames send the packets on the AF_PACKET socket fd with sendmmsg; it
+// returns the number of packets sent. The call is repeated if the kernel sends
+// part of the batch and polled for up to writeTimeout if the socket buffer is full.
+func sendFrames(fd int, ifindex int, packets []*marp.Packet, addrs []net.HardwareAddr) (int, error) {
+	if len(packets) == 0 {
+		return 0, nil
+	}
+	msgs := make([]mmsghdr, len(packets))
+	iovs := make([]unix.Iovec, len(packets))
+	sas := make([]unix.RawSockaddrLinklayer, len(packets))
+	for i, p := range packets {
+		fb, err := encodeFrame(p, nil)
+		if err != nil {
+			return 0, err
+		}
+		sas[i] = unix.RawSockaddrLinklayer{Family: unix.AF_PACKET, Protocol: htons(unix.ETH_P_ARP), Ifindex: int32(ifindex), Halen: uint8(len(addrs[i]))}
+		copy(sas[i].Addr[:], addrs[i])
+		iovs[i].Base = &fb[0]
+		iovs[i].SetLen(len(fb))
+		msgs[i].hdr.Name = (*byte)(unsafe.Pointer(&sas[i]))
+		msgs[i].hdr.Namelen = unix.SizeofSockaddrLinklayer
+		msgs[i].hdr.Iov = &iovs[i]
+		msgs[i].hdr.SetIovlen(1)
+	}
+
+	deadline := time.Now().Add(writeTimeout)
+	sent := 0
+	for sent < len(msgs) {
+		n, _, errno := unix.Syscall6(unix.SYS_SENDMMSG, uintptr(fd), uintptr(unsafe.Pointer(&msgs[sent])),
+			uintptr(len(msgs)-sent), unix.MSG_DONTWAIT, 0, 0)
+		switch {
+		case errno == unix.EINTR:
+		case errno == unix.EAGAIN:
+			if time.Now().After(deadline) {
+				return sent, os.ErrDeadlineExceeded
+			}
+			fds := [1]unix.PollFd{{Fd: int32(fd), Events: unix.POLLOUT}}
+			if _, err := unix.Poll(fds[:], int(writeTimeout/time.Millisecond)); err != nil && err != unix.EINTR {
+				return sent, err
+			}
+		case errno != 0:
+			return sent, errno
+		default:
+			sent += int(n)
+		}
+	}
+	return sent, nil
+}
+
+// ReceiveTimes return the kernel receive time of the packet returned by the
+// last Read call; the ring frame header carries the time so no socket option
+// is required.
+func (c *ringConn) ReceiveTimes() []time.Time {
+	return c.times[:]
+}
+
+// KernelDrops return the number of frames dropped because the ring was full.
+func (c *ringConn) KernelDrops() uint64 {
+	if atomic.LoadInt32(&c.closed) == 0 {
+		// the kernel resets the statistics on every call
+		if stats, err := unix.GetsockoptTpacketStatsV3(c.fd, unix.SOL_PACKET, unix.PACKET_STATISTICS); err == nil {
+			return atomic.AddUint64(&c.drops, uint64(stats.Drops))
+		}
+	}
+	return atomic.LoadUint64(&c.drops)
+}
+
+// Close unmap the ring and close the socket; a pending Read returns within ringPollTimeout.
+func (c *ringConn) Close() error {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return nil
+	}
+
+	// wait for a pending read before unmapping the ring
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+	unix.Munmap(c.ring)
+	return unix.Close(c.fd)
+}
+
+// SetReadDeadline implements PacketConn; the zero value means no deadline.
+func (c *ringConn) SetReadDeadline(t time.Time) error {
+	c.deadline.Store(t)
+	return nil
+}
+
+// htons convert to network byte order
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return *(*uint16)(unsafe.Pointer(&b[0]))
+}
+
+// SetBPF attach filter to the packet socket
+func (c *ringConn) SetBPF(filter []bpf.RawInstruction) error {
+	return setPacketBPF(c.fd, filter)
+}
+
+// SetPromiscuous set promiscuous mode on the interface for the lifetime of the socket
+func (c *ringConn) SetPromiscuous(b bool) error {
+	return setPacketPromiscuous(c.fd, c.ifindex, b)
+}
+
+func setPacketBPF(fd int, filter []bpf.RawInstruction) error {
+	program := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: (*unix.SockFilter)(unsafe.Pointer(&filter[0])),
+	}
+	return unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &program)
+}
+
+func setPacketPromiscuous(fd int, ifindex int, b bool) error {
+	mreq := unix.PacketMreq{Ifindex: int32(ifindex), Type: unix.PACKET_MR_PROMISC}
+	option := unix.PACKET_DROP_MEMBERSHIP
+	if b {
+		option = unix.PACKET_ADD_MEMBERSHIP
+	}
+	return unix.SetsockoptPacketMreq(fd, unix.SOL_PACKET, option, &mreq)
+}
+
+// dialRing open a ring buffer connection on the interface
+func dialRing(nic string) (PacketConn, error) {
+	ifi, err := net.InterfaceByName(nic)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := newRingConn(ifi)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,83 @@
+package arp
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"golang.org/x/sys/unix"
+)
+
+func Test_RingConn(t *testing.T) {
+	ifi, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("no loopback interface ", err)
+	}
+	c, err := newRingConn(ifi)
+	if err != nil {
+		t.Skip("packet socket requires root ", err)
+	}
+	defer c.Close()
+
+	// frames sent on loopback are received by the same socket
+	for i := 0; i < 3; i++ {
+		p, _ := marp.NewPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2)
+		if err := c.WriteTo(p, EthernetBroadcast); err != nil {
+			t.Fatal("write error ", err)
+		}
+	}
+
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < 3; i++ {
+		p, _, err := c.Read()
+		if err != nil || !p.TargetIP.Equal(ip2) {
+			t.Fatal("read error ", p, err)
+		}
+		if stamp := c.ReceiveTimes()[0]; time.Since(stamp) > time.Minute || time.Until(stamp) > 0 {
+			t.Fatal("invalid receive time ", stamp)
+		}
+	}
+
+	// RARP frames are not filtered by the socket
+	p, _ := marp.NewPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2)
+	p.Operation = OperationReverseRequest
+	fb, err := encodeFrame(p, nil)
+	if err != nil {
+		t.Fatal("encode error ", err)
+	}
+	binary.BigEndian.PutUint16(fb[12:14], uint16(EtherTypeRARP))
+	if err := unix.Sendto(c.fd, fb, 0, &unix.SockaddrLinklayer{Ifindex: ifi.Index, Halen: 6}); err != nil {
+		t.Fatal("write error ", err)
+	}
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	if p, f, err := c.Read(); err != nil || f.EtherType != EtherTypeRARP || p.Operation != OperationReverseRequest {
+		t.Fatal("invalid RARP read ", p, err)
+	}
+
+	c.SetReadDeadline(time.Now().Add(time.Millisecond * 200))
+	if _, _, err := c.Read(); err != os.ErrDeadlineExceeded {
+		t.Error("expected deadline error ", err)
+	}
+}
+
+func Test_RingPollError(t *testing.T) {
+	ifi, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("no loopback interface ", err)
+	}
+	c, err := newRingConn(ifi)
+	if err != nil {
+		t.Skip("packet socket requires root ", err)
+	}
+	defer c.Close()
+
+	if err := c.pollError(unix.POLLNVAL); err != net.ErrClosed {
+		t.Error("expected closed error ", err)
+	}
+	if err := c.pollError(unix.POLLERR); err == nil {
+		t.Error("expected poll error")
+	}
+}
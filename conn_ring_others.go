@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package arp
+
+import "errors"
+
+func dialRing(nic string) (PacketConn, error) {
+	return nil, errors.New("ring buffer is only supported on linux")
+}
@@ -0,0 +1,116 @@
+package arp
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// fakeConn is a PacketConn driven by the test
+type fakeConn struct {
+	in     chan *marp.Packet
+	out    chan *marp.Packet
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{in: make(chan *marp.Packet, 16), out: make(chan *marp.Packet, 512), closed: make(chan struct{})}
+}
+
+func (f *fakeConn) Read() (*marp.Packet, *ethernet.Frame, error) {
+	select {
+	case p := <-f.in:
+		pb, _ := p.MarshalBinary()
+		return p, &ethernet.Frame{Destination: p.TargetHardwareAddr, Source: p.SenderHardwareAddr, EtherType: ethernet.EtherTypeARP, Payload: pb}, nil
+	case <-f.closed:
+		return nil, nil, io.EOF
+	}
+}
+
+func (f *fakeConn) WriteTo(p *marp.Packet, addr net.HardwareAddr) error {
+	select {
+	case f.out <- p:
+	default:
+	}
+	return nil
+}
+
+func (f *fakeConn) Close() error {
+	f.once.Do(func() { close(f.closed) })
+	return nil
+}
+
+func (f *fakeConn) SetReadDeadline(t time.Time) error { return nil }
+
+func Test_PacketConn(t *testing.T) {
+	hostMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	hostIP := net.IPv4(192, 168, 0, 100).To4()
+	conn := newFakeConn()
+
+	h, err := NewHandler("eth0", hostMAC, hostIP, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	s := h.Subscribe(16, DropNewest)
+	go h.ListenAndServe(0)
+	defer h.Stop()
+
+	p, _ := marp.NewPacket(marp.OperationReply, mac1, ip1, hostMAC, hostIP)
+	conn.in <- p
+	select {
+	case e := <-s.C:
+		if e.Type != EventNewDevice || !e.Entry.IP.Equal(ip1) {
+			t.Errorf("invalid event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+
+	if err := h.Request(hostMAC, hostIP, EthernetBroadcast, ip2); err != nil {
+		t.Fatal("Request error ", err)
+	}
+	for p := range conn.out {
+		if p.Operation == marp.OperationRequest && p.TargetIP.Equal(ip2) {
+			break
+		}
+	}
+	if stats := h.Stats(); stats.RequestsSent == 0 || stats.PacketsRead != 1 {
+		t.Errorf("invalid stats %+v", stats)
+	}
+}
+
+func Test_StopAndWait(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	go h.ListenAndServe(0)
+	for h.Healthy() != nil {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := h.StopAndWait(ctx); err != nil {
+		t.Fatal("StopAndWait error ", err)
+	}
+	select {
+	case <-conn.closed:
+	default:
+		t.Error("socket not closed")
+	}
+	if stats := h.Stats(); stats.Goroutines != 0 {
+		t.Error("goroutines still running ", stats.Goroutines)
+	}
+	if err := h.Stop(); err != nil {
+		t.Error("Stop after StopAndWait error ", err)
+	}
+}
@@ -0,0 +1,286 @@
+//go:build windows
+// +build windows
+
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/windows"
+)
+
+// Windows has no raw sockets for ethernet frames; packets are read and written
+// with Npcap (https://npcap.com) which must be installed on the host. The
+// wpcap.dll functions are loaded at runtime so no cgo is required.
+
+var (
+	npcapOnce sync.Once
+	npcapErr  error
+
+	pcapOpenLive    *windows.Proc
+	pcapNextEx      *windows.Proc
+	pcapSendPacket  *windows.Proc
+	pcapCompile     *windows.Proc
+	pcapSetFilter   *windows.Proc
+	pcapFreeCode    *windows.Proc
+	pcapGetErr      *windows.Proc
+	pcapClose       *windows.Proc
+	errNpcapMissing = errors.New("npcap is not installed; download from https://npcap.com")
+)
+
+// pcapTimeout is the read timeout in milliseconds; Read checks for Close and
+// the read deadline at this interval.
+const pcapTimeout = 100
+
+// pcapPacketHeader is struct pcap_pkthdr; long is 32 bits on Windows.
+type pcapPacketHeader struct {
+	sec    int32
+	usec   int32
+	caplen uint32
+	len    uint32
+}
+
+// bpfProgram is struct bpf_program
+type bpfProgram struct {
+	len   uint32
+	insns uintptr
+}
+
+// loadNpcap load wpcap.dll from the Npcap directory in System32.
+//
+// Npcap installs in System32\Npcap which is not in the DLL search path. The
+// DLL is loaded by absolute path with LoadLibraryEx so the search path of the
+// process is not changed; its dependency Packet.dll is resolved from the
+// Npcap directory and other dependencies from System32 only.
+func loadNpcap() error {
+	npcapOnce.Do(func() {
+		system, err := windows.GetSystemDirectory()
+		if err != nil {
+			npcapErr = fmt.Errorf("%w: %v", errNpcapMissing, err)
+			return
+		}
+		path := filepath.Join(system, "Npcap", "wpcap.dll")
+		handle, err := windows.LoadLibraryEx(path, 0, windows.LOAD_LIBRARY_SEARCH_DLL_LOAD_DIR|windows.LOAD_LIBRARY_SEARCH_SYSTEM32)
+		if err != nil {
+			npcapErr = fmt.Errorf("%w: %s: %v", errNpcapMissing, path, err)
+			return
+		}
+		dll := &windows.DLL{Name: path, Handle: handle}
+		procs := []struct {
+			proc **windows.Proc
+			name string
+		}{
+			{&pcapOpenLive, "pcap_open_live"},
+			{&pcapNextEx, "pcap_next_ex"},
+			{&pcapSendPacket, "pcap_sendpacket"},
+			{&pcapCompile, "pcap_compile"},
+			{&pcapSetFilter, "pcap_setfilter"},
+			{&pcapFreeCode, "pcap_freecode"},
+			{&pcapGetErr, "pcap_geterr"},
+			{&pcapClose, "pcap_close"},
+		}
+		for _, p := range procs {
+			if *p.proc, err = dll.FindProc(p.name); err != nil {
+				dll.Release()
+				npcapErr = fmt.Errorf("%w: %v", errNpcapMissing, err)
+				return
+			}
+		}
+	})
+	return npcapErr
+}
+
+// npcapDevice return the Npcap device name for the interface; i.e. \Device\NPF_{GUID}
+func npcapDevice(ifi *net.Interface) (string, error) {
+	size := uint32(15 * 1024)
+	for {
+		buf := make([]byte, size)
+		info := (*syscall.IpAdapterInfo)(unsafe.Pointer(&buf[0]))
+		err := syscall.GetAdaptersInfo(info, &size)
+		if err == syscall.ERROR_BUFFER_OVERFLOW {
+			continue // size is updated with the required length
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot list adapters: %w", err)
+		}
+
+		for ; info != nil; info = info.Next {
+			if int(info.Index) == ifi.Index {
+				return `\Device\NPF_` + cString(info.AdapterName[:]), nil
+			}
+		}
+		return "", fmt.Errorf("adapter not found for interface %s", ifi.Name)
+	}
+}
+
+func cString(b []byte) string {
+	for i := range b {
+		if b[i] == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// npcapConn is a PacketConn using a Npcap capture handle.
+type npcapConn struct {
+	handle   uintptr
+	closed   int32 // atomic; set by Close
+	deadline atomic.Value
+	rmutex   sync.Mutex // serialise reads and close
+	wmutex   sync.Mutex // serialise writes
+	decoder  frameDecoder
+}
+
+// dialPacketConn open the interface with Npcap and filter ARP frames
+func dialPacketConn(ifi *net.Interface) (PacketConn, error) {
+	if err := loadNpcap(); err != nil {
+		return nil, err
+	}
+	device, err := npcapDevice(ifi)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := syscall.BytePtrFromString(device)
+	if err != nil {
+		return nil, err
+	}
+	errbuf := make([]byte, 256) // PCAP_ERRBUF_SIZE
+	handle, _, _ := pcapOpenLive.Call(uintptr(unsafe.Pointer(name)), 1600, 0, pcapTimeout, uintptr(unsafe.Pointer(&errbuf[0])))
+	if handle == 0 {
+		return nil, fmt.Errorf("npcap cannot open %s: %s", device, cString(errbuf))
+	}
+
+	c := &npcapConn{handle: handle}
+	if err := c.setFilter("arp or rarp or (vlan and arp)"); err != nil {
+		pcapClose.Call(handle)
+		return nil, err
+	}
+	c.deadline.Store(time.Time{})
+	return c, nil
+}
+
+func (c *npcapConn) setFilter(filter string) error {
+	expr, err := syscall.BytePtrFromString(filter)
+	if err != nil {
+		return err
+	}
+	var program bpfProgram
+	const netmaskUnknown = 0xffffffff
+	if r, _, _ := pcapCompile.Call(c.handle, uintptr(unsafe.Pointer(&program)), uintptr(unsafe.Pointer(expr)), 1, netmaskUnknown); int32(r) != 0 {
+		return fmt.Errorf("npcap cannot compile filter: %s", c.lastError())
+	}
+	defer pcapFreeCode.Call(uintptr(unsafe.Pointer(&program)))
+
+	if r, _, _ := pcapSetFilter.Call(c.handle, uintptr(unsafe.Pointer(&program))); int32(r) != 0 {
+		return fmt.Errorf("npcap cannot set filter: %s", c.lastError())
+	}
+	return nil
+}
+
+// SetBPF replace the ARP filter of the npcap handle with filter
+func (c *npcapConn) SetBPF(filter []bpf.RawInstruction) error {
+	program := bpfProgram{len: uint32(len(filter)), insns: uintptr(unsafe.Pointer(&filter[0]))}
+	if r, _, _ := pcapSetFilter.Call(c.handle, uintptr(unsafe.Pointer(&program))); int32(r) != 0 {
+		return fmt.Errorf("npcap cannot set filter: %s", c.lastError())
+	}
+	return nil
+}
+
+func (c *npcapConn) lastError() string {
+	r, _, _ := pcapGetErr.Call(c.handle)
+	p := *(**byte)(unsafe.Pointer(&r)) // C string owned by npcap
+	if p == nil {
+		return "unknown error"
+	}
+	return cString(unsafe.Slice(p, 256)) // PCAP_ERRBUF_SIZE
+}
+
+// Read return the next ARP packet; it blocks until a packet arrives, the read
+// deadline expires or the connection is closed.
+func (c *npcapConn) Read() (*marp.Packet, *ethernet.Frame, error) {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	for {
+		if atomic.LoadInt32(&c.closed) != 0 {
+			return nil, nil, net.ErrClosed
+		}
+		if d := c.deadline.Load().(time.Time); !d.IsZero() && time.Now().After(d) {
+			return nil, nil, os.ErrDeadlineExceeded
+		}
+
+		var header *pcapPacketHeader
+		var data *byte
+		r, _, _ := pcapNextEx.Call(c.handle, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data)))
+		switch int32(r) {
+		case 1:
+		case 0:
+			continue // timeout
+		default:
+			return nil, nil, fmt.Errorf("npcap read error: %s", c.lastError())
+		}
+
+		// decoding copies the frame; data is owned by npcap until the next call
+		if packet, frame, err := c.decoder.decode(unsafe.Slice(data, header.caplen)); err == nil {
+			return packet, frame, nil
+		}
+	}
+}
+
+// WriteTo send the packet; the frame is built the same way as marp.Client.WriteTo.
+func (c *npcapConn) WriteTo(p *marp.Packet, addr net.HardwareAddr) error {
+	return c.WriteToVLAN(p, addr, nil)
+}
+
+// WriteToVLAN send the packet in a frame tagged with vlan; vlan may be nil.
+func (c *npcapConn) WriteToVLAN(p *marp.Packet, addr net.HardwareAddr, vlan *ethernet.VLAN) error {
+	fb, err := encodeFrame(p, vlan)
+	if err != nil {
+		return err
+	}
+
+	c.wmutex.Lock()
+	defer c.wmutex.Unlock()
+	if atomic.LoadInt32(&c.closed) != 0 {
+		return net.ErrClosed
+	}
+	if r, _, _ := pcapSendPacket.Call(c.handle, uintptr(unsafe.Pointer(&fb[0])), uintptr(len(fb))); int32(r) != 0 {
+		return fmt.Errorf("npcap write error: %s", c.lastError())
+	}
+	return nil
+}
+
+// Close release the capture handle; a pending Read returns within pcapTimeout.
+func (c *npcapConn) Close() error {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return nil
+	}
+
+	// wait for pending reads and writes to finish before closing the handle
+	c.rmutex.Lock()
+	c.wmutex.Lock()
+	pcapClose.Call(c.handle)
+	c.wmutex.Unlock()
+	c.rmutex.Unlock()
+	return nil
+}
+
+// SetReadDeadline implements PacketConn; the zero value means no deadline.
+func (c *npcapConn) SetReadDeadline(t time.Time) error {
+	c.deadline.Store(t)
+	return nil
+}
@@ -0,0 +1,161 @@
+package arp
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// csvHeader is the first row written by ExportCSV
+var csvHeader = []string{"mac", "ip", "addresses", "ipv6", "name", "first_seen", "last_seen"}
+
+// ExportCSV write the table to w as CSV with a header row. Addresses and
+// IPv6 hold space separated lists and times are in RFC3339 format.
+func (c *Handler) ExportCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, e := range c.Snapshot() {
+		addresses := make([]string, 0, len(e.Addresses))
+		for _, a := range e.Addresses {
+			addresses = append(addresses, a.IP.String())
+		}
+		ipv6 := make([]string, 0, len(e.IPv6))
+		for _, ip := range e.IPv6 {
+			ipv6 = append(ipv6, ip.String())
+		}
+		record := []string{e.MAC.String(), e.IP.String(), strings.Join(addresses, " "), strings.Join(ipv6, " "),
+			e.Name, formatCSVTime(e.FirstSeen), formatCSVTime(e.LastUpdate)}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatCSVTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// ImportCSV add the devices in r to the table; r has the ExportCSV format
+// and only the mac column is required. The header row is optional.
+//
+// Imported devices follow the Store rules: they start offline, are probed by
+// the polling loop and their last seen time is reset to the import time.
+// The name of devices already in the table is updated if not empty. The
+// table is unchanged if any row is invalid. It returns ErrTableFull if some
+// devices do not fit in the table, or the first error adding a device; the
+// other devices are imported.
+func (c *Handler) ImportCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) > 0 && len(records[0]) > 0 && records[0][0] == csvHeader[0] {
+		records = records[1:]
+	}
+
+	entries := make([]Entry, 0, len(records))
+	for i, record := range records {
+		e, err := parseCSVRecord(record)
+		if err != nil {
+			return fmt.Errorf("invalid csv row %d: %w", i+1, err)
+		}
+		entries = append(entries, e)
+	}
+
+	full := 0
+	var failed error
+	c.mutex.Lock()
+	for i := range entries {
+		if entry := c.findMACLocked(entries[i].MAC); entry != nil {
+			if entries[i].Name != "" {
+				entry.Name = entries[i].Name
+			}
+			continue
+		}
+		entry, err := c.restoreLocked(entries[i])
+		if errors.Is(err, ErrTableFull) {
+			full++
+			continue
+		}
+		if err != nil {
+			if failed == nil {
+				failed = fmt.Errorf("cannot import %s: %w", entries[i].MAC, err)
+			}
+			continue
+		}
+		if entry == nil { // duplicates are handled above
+			continue
+		}
+		for _, a := range entries[i].Addresses {
+			c.addAddressLocked(entry, a.IP, entry.LastUpdate)
+		}
+	}
+	c.mutex.Unlock()
+
+	if failed != nil {
+		return failed
+	}
+	if full > 0 {
+		return fmt.Errorf("%w: %d entries not imported", ErrTableFull, full)
+	}
+	return nil
+}
+
+// parseCSVRecord return the entry in the ExportCSV record.
+func parseCSVRecord(record []string) (e Entry, err error) {
+	field := func(i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	if e.MAC, err = net.ParseMAC(field(0)); err != nil || len(e.MAC) != 6 {
+		return e, fmt.Errorf("%w %q", ErrInvalidMAC, field(0))
+	}
+	e.IP = net.IPv4zero
+	if s := field(1); s != "" {
+		if e.IP = net.ParseIP(s).To4(); e.IP == nil {
+			return e, fmt.Errorf("invalid ip %q", s)
+		}
+	}
+	for _, s := range strings.Fields(field(2)) {
+		ip := net.ParseIP(s).To4()
+		if ip == nil {
+			return e, fmt.Errorf("invalid address %q", s)
+		}
+		e.Addresses = append(e.Addresses, Address{IP: ip})
+	}
+	for _, s := range strings.Fields(field(3)) {
+		ip := net.ParseIP(s)
+		if ip == nil || ip.To4() != nil {
+			return e, fmt.Errorf("invalid ipv6 %q", s)
+		}
+		e.IPv6 = append(e.IPv6, ip)
+	}
+	e.Name = field(4)
+	if s := field(5); s != "" {
+		if e.FirstSeen, err = time.Parse(time.RFC3339, s); err != nil {
+			return e, err
+		}
+	}
+	if s := field(6); s != "" {
+		if e.LastUpdate, err = time.Parse(time.RFC3339, s); err != nil {
+			return e, err
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,42 @@
+package arp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func Test_CSV(t *testing.T) {
+	h := &Handler{table: make([]*Entry, 0, 256)}
+	entry := h.arpTableAppendLocked(StateNormal, mac1, ip1)
+	h.setIPLocked(entry, ip2)
+	h.setIPv6Locked(entry, []net.IP{net.ParseIP("fe80::1")})
+	entry.Name = "laptop, office"
+	h.arpTableAppendLocked(StateNormal, mac2, net.IPv4zero)
+
+	var b bytes.Buffer
+	if err := h.ExportCSV(&b); err != nil {
+		t.Fatal("export error ", err)
+	}
+	if !strings.HasPrefix(b.String(), "mac,ip,addresses,ipv6,name,first_seen,last_seen\n01:02:03:04:05:01,192.168.0.2,192.168.0.1 192.168.0.2,fe80::1,\"laptop, office\",") {
+		t.Fatalf("invalid csv %q", b.String())
+	}
+
+	n := &Handler{table: make([]*Entry, 0, 256)}
+	if err := n.ImportCSV(strings.NewReader(b.String())); err != nil {
+		t.Fatal("import error ", err)
+	}
+	e := n.FindMAC(mac1)
+	if e == nil || e.Online || !e.IP.Equal(ip2) || n.FindIP(ip1) != e || len(e.IPv6) != 1 || e.Name != "laptop, office" || !e.FirstSeen.Equal(entry.FirstSeen.Truncate(1e9)) {
+		t.Fatalf("invalid entry %+v", e)
+	}
+	if n.FindMAC(mac2) == nil || len(n.Snapshot()) != 2 {
+		t.Fatalf("invalid table %+v", n.Snapshot())
+	}
+
+	// invalid rows reject the whole file
+	if err := n.ImportCSV(strings.NewReader("01:02:03:04:05:03,192.168.0.3\ninvalid\n")); err == nil || n.FindMAC(mac3) != nil {
+		t.Error("expected error for invalid mac")
+	}
+}
@@ -0,0 +1,121 @@
+package arp
+
+import (
+	"encoding/binary"
+	"errors"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+var errInvalidFrame = errors.New("invalid ARP frame")
+
+// arpPacketLen is the length of an ethernet IPv4 ARP packet
+const arpPacketLen = 28
+
+// frameDecoder decodes ethernet ARP frames into a packet and frame that are
+// reused on every call, so steady state reads do not allocate.
+//
+// The frame is copied to the decoder buffer; the input may be reused or
+// released as soon as decode returns. The returned values are valid until
+// the next call to decode.
+type frameDecoder struct {
+	buf    [1536]byte
+	packet marp.Packet
+	frame  ethernet.Frame
+	vlan   ethernet.VLAN
+	svlan  ethernet.VLAN
+}
+
+// decode return the ARP or RARP packet in b; only ethernet and IPv4 addresses are supported.
+//
+// 802.1Q tagged frames and 802.1ad double tagged frames are accepted; the tags
+// are returned in frame.VLAN and frame.ServiceVLAN.
+func (d *frameDecoder) decode(b []byte) (*marp.Packet, *ethernet.Frame, error) {
+	if len(b) > len(d.buf) {
+		return nil, nil, errInvalidFrame
+	}
+
+	d.frame.ServiceVLAN, d.frame.VLAN = nil, nil
+	off := 12
+	if len(b) >= off+6 && ethernet.EtherType(binary.BigEndian.Uint16(b[off:off+2])) == ethernet.EtherTypeServiceVLAN {
+		d.svlan = parseVLAN(b[off+2 : off+4])
+		d.frame.ServiceVLAN = &d.svlan
+		off += 4
+		if ethernet.EtherType(binary.BigEndian.Uint16(b[off:off+2])) != ethernet.EtherTypeVLAN {
+			return nil, nil, errInvalidFrame
+		}
+	}
+	if len(b) >= off+6 && ethernet.EtherType(binary.BigEndian.Uint16(b[off:off+2])) == ethernet.EtherTypeVLAN {
+		d.vlan = parseVLAN(b[off+2 : off+4])
+		d.frame.VLAN = &d.vlan
+		off += 4
+	}
+	if len(b) < off+2+arpPacketLen {
+		return nil, nil, errInvalidFrame
+	}
+	etherType := ethernet.EtherType(binary.BigEndian.Uint16(b[off : off+2]))
+	if etherType != ethernet.EtherTypeARP && etherType != EtherTypeRARP {
+		return nil, nil, errInvalidFrame
+	}
+	off += 2
+
+	n := copy(d.buf[:], b)
+	buf := d.buf[:n]
+	d.frame.Destination = buf[0:6]
+	d.frame.Source = buf[6:12]
+	d.frame.EtherType = etherType
+	d.frame.Payload = buf[off:]
+
+	a := buf[off : off+arpPacketLen]
+	if a[4] != 6 || a[5] != 4 { // hardware and protocol address lengths
+		return nil, nil, errInvalidFrame
+	}
+	p := &d.packet
+	p.HardwareType = binary.BigEndian.Uint16(a[0:2])
+	p.ProtocolType = binary.BigEndian.Uint16(a[2:4])
+	p.HardwareAddrLength = a[4]
+	p.IPLength = a[5]
+	p.Operation = marp.Operation(binary.BigEndian.Uint16(a[6:8]))
+	p.SenderHardwareAddr = a[8:14]
+	p.SenderIP = a[14:18]
+	p.TargetHardwareAddr = a[18:24]
+	p.TargetIP = a[24:28]
+	return p, &d.frame, nil
+}
+
+// setVLAN set the tag of the last decoded frame; used when the tag was removed
+// by the kernel and delivered out of band.
+func (d *frameDecoder) setVLAN(tci uint16) {
+	d.vlan = vlanFromTCI(tci)
+	d.frame.VLAN = &d.vlan
+}
+
+// parseVLAN return the VLAN in the 2 byte tag control information
+func parseVLAN(b []byte) ethernet.VLAN {
+	return vlanFromTCI(binary.BigEndian.Uint16(b))
+}
+
+func vlanFromTCI(tci uint16) ethernet.VLAN {
+	return ethernet.VLAN{
+		Priority:     ethernet.Priority(tci >> 13),
+		DropEligible: tci&0x1000 != 0,
+		ID:           tci & 0x0fff,
+	}
+}
+
+// encodeFrame return the ethernet frame for the packet; the frame is built the
+// same way as marp.Client.WriteTo with an optional 802.1Q tag.
+func encodeFrame(p *marp.Packet, vlan *ethernet.VLAN) ([]byte, error) {
+	pb, err := p.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return (&ethernet.Frame{
+		Destination: p.TargetHardwareAddr,
+		Source:      p.SenderHardwareAddr,
+		VLAN:        vlan,
+		EtherType:   ethernet.EtherTypeARP,
+		Payload:     pb,
+	}).MarshalBinary()
+}
@@ -0,0 +1,250 @@
+package arp
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// AlertType identifies the evidence of ARP spoofing in a SpoofAlert.
+type AlertType string
+
+const (
+	// AlertGatewayClaim when a MAC other than the router claims the router IP
+	AlertGatewayClaim AlertType = "gatewayclaim"
+
+	// AlertUnsolicitedReplies when a MAC sends more replies not addressed to
+	// the host than SpoofDetection.UnsolicitedReplies in the window
+	AlertUnsolicitedReplies AlertType = "unsolicitedreplies"
+
+	// AlertBindingFlap when an IP changes MAC more than SpoofDetection.BindingChanges in the window
+	AlertBindingFlap AlertType = "bindingflap"
+
+	// AlertStorm when a MAC exceeds StormDetection.PacketsPerSecond; see WithStormDetection
+	AlertStorm AlertType = "storm"
+)
+
+// AlertSeverity ranks a SpoofAlert.
+type AlertSeverity string
+
+const (
+	// SeverityMedium for suspicious traffic, i.e. unsolicited replies or an ARP storm
+	SeverityMedium AlertSeverity = "medium"
+
+	// SeverityHigh for a likely attack, i.e. another MAC claiming the router IP
+	SeverityHigh AlertSeverity = "high"
+)
+
+// SpoofAlert is the evidence of a suspected ARP spoofing attack sent in an
+// EventSpoofDetected event or of an ARP storm in an EventStormDetected event.
+type SpoofAlert struct {
+	Type     AlertType
+	Severity AlertSeverity
+	MAC      net.HardwareAddr   // suspected attacker; the last MAC claiming IP
+	IP       net.IP             // the IP being claimed
+	MACs     []net.HardwareAddr // all MACs seen claiming IP in the window
+	IPs      []net.IP           // all IPs seen for MAC in the window; only set for AlertMACFlap
+	Count    int                // packets or binding changes in the window
+	Window   time.Duration
+	Frame    *AlertFrame // offending frame; only set for EventGatewayImpersonation
+}
+
+// SpoofDetection configures the detection of ARP spoofing by other hosts; see WithSpoofDetection.
+type SpoofDetection struct {
+	Window             time.Duration // observation window
+	UnsolicitedReplies int           // replies per MAC not addressed to the host in the window
+	BindingChanges     int           // MAC changes per IP in the window
+}
+
+// DefaultSpoofDetection is the configuration used when WithSpoofDetection is passed a zero value.
+var DefaultSpoofDetection = SpoofDetection{Window: time.Minute, UnsolicitedReplies: 30, BindingChanges: 3}
+
+// spoofDetectorMaxRecords limits the memory used by the detector
+const spoofDetectorMaxRecords = 1024
+
+// WithSpoofDetection watch for other hosts performing ARP spoofing and send
+// EventSpoofDetected with a SpoofAlert when the router IP is claimed by
+// another MAC, a MAC sends excessive unsolicited replies or an IP binding
+// flaps between MACs. Each alert is sent once per window. Router IP claims
+// are reported by WithGatewayGuard instead if it is set.
+//
+// Zero fields in config are set from DefaultSpoofDetection.
+func WithSpoofDetection(config SpoofDetection) Option {
+	return func(c *Handler) error {
+		if config.Window <= 0 {
+			config.Window = DefaultSpoofDetection.Window
+		}
+		if config.UnsolicitedReplies <= 0 {
+			config.UnsolicitedReplies = DefaultSpoofDetection.UnsolicitedReplies
+		}
+		if config.BindingChanges <= 0 {
+			config.BindingChanges = DefaultSpoofDetection.BindingChanges
+		}
+		c.detector = &spoofDetector{config: config, replies: make(map[string]*replyRecord), bindings: make(map[string]*bindingRecord)}
+		return nil
+	}
+}
+
+type spoofDetector struct {
+	config   SpoofDetection
+	mutex    sync.Mutex
+	replies  map[string]*replyRecord   // by sender MAC
+	bindings map[string]*bindingRecord // by sender IP
+	claims   map[string]time.Time      // gateway claim alert time by MAC
+}
+
+type replyRecord struct {
+	start   time.Time
+	count   int
+	alerted bool
+}
+
+type bindingRecord struct {
+	start   time.Time
+	mac     net.HardwareAddr
+	macs    []net.HardwareAddr
+	changes int
+	alerted bool
+}
+
+// detectSpoof check the packet for evidence of spoofing; packets sent by the
+// host and its virtual hosts must be excluded by the caller.
+func (c *Handler) detectSpoof(packet *marp.Packet) {
+	d := c.detector
+	if d == nil || bytes.Equal(packet.SenderHardwareAddr, c.config.HostMAC) || packet.SenderIP.Equal(net.IPv4zero) {
+		return
+	}
+
+	c.mutex.RLock()
+	routerIP, routerMAC := c.config.RouterIP, c.config.RouterMAC
+	c.mutex.RUnlock()
+
+	now := time.Now()
+	var alerts []SpoofAlert
+
+	d.mutex.Lock()
+	if c.guard == nil && routerMAC != nil && packet.SenderIP.Equal(routerIP) && !bytes.Equal(packet.SenderHardwareAddr, routerMAC) {
+		if alert, ok := d.gatewayClaim(now, packet, routerMAC); ok {
+			alerts = append(alerts, alert)
+		}
+	}
+	if packet.Operation == marp.OperationReply && !bytes.Equal(packet.TargetHardwareAddr, c.config.HostMAC) {
+		if alert, ok := d.unsolicitedReply(now, packet); ok {
+			alerts = append(alerts, alert)
+		}
+	}
+	if alert, ok := d.binding(now, packet); ok {
+		alerts = append(alerts, alert)
+	}
+	d.mutex.Unlock()
+
+	for i := range alerts {
+		alert := &alerts[i]
+		c.log().WithFields(log.Fields{"type": alert.Type, "mac": alert.MAC.String(), "ip": alert.IP, "count": alert.Count}).Warn("ARP spoofing detected")
+		c.publish(Event{Type: EventSpoofDetected, Time: now, NIC: c.config.NIC, Entry: Entry{MAC: alert.MAC, IP: alert.IP}, Alert: alert})
+	}
+}
+
+func (d *spoofDetector) gatewayClaim(now time.Time, packet *marp.Packet, routerMAC net.HardwareAddr) (SpoofAlert, bool) {
+	if d.claims == nil {
+		d.claims = make(map[string]time.Time)
+	}
+	if last, ok := d.claims[string(packet.SenderHardwareAddr)]; ok && now.Sub(last) < d.config.Window {
+		return SpoofAlert{}, false
+	}
+	d.claims[string(packet.SenderHardwareAddr)] = now
+	return SpoofAlert{Type: AlertGatewayClaim, Severity: SeverityHigh, MAC: dupMAC(packet.SenderHardwareAddr), IP: dupIP(packet.SenderIP),
+		MACs: []net.HardwareAddr{dupMAC(routerMAC), dupMAC(packet.SenderHardwareAddr)}, Count: 1, Window: d.config.Window}, true
+}
+
+func (d *spoofDetector) unsolicitedReply(now time.Time, packet *marp.Packet) (SpoofAlert, bool) {
+	r, ok := d.replies[string(packet.SenderHardwareAddr)]
+	if !ok {
+		if len(d.replies) >= spoofDetectorMaxRecords {
+			d.purge(now)
+		}
+		r = &replyRecord{start: now}
+		d.replies[string(packet.SenderHardwareAddr)] = r
+	}
+	if now.Sub(r.start) > d.config.Window {
+		*r = replyRecord{start: now}
+	}
+	r.count++
+	if r.alerted || r.count <= d.config.UnsolicitedReplies {
+		return SpoofAlert{}, false
+	}
+	r.alerted = true
+	return SpoofAlert{Type: AlertUnsolicitedReplies, Severity: SeverityMedium, MAC: dupMAC(packet.SenderHardwareAddr), IP: dupIP(packet.SenderIP),
+		MACs: []net.HardwareAddr{dupMAC(packet.SenderHardwareAddr)}, Count: r.count, Window: d.config.Window}, true
+}
+
+func (d *spoofDetector) binding(now time.Time, packet *marp.Packet) (SpoofAlert, bool) {
+	b, ok := lookupBinding(d.bindings, packet.SenderIP)
+	if !ok {
+		if len(d.bindings) >= spoofDetectorMaxRecords {
+			d.purge(now)
+		}
+		mac := dupMAC(packet.SenderHardwareAddr)
+		d.bindings[ipKey(packet.SenderIP)] = &bindingRecord{start: now, mac: mac, macs: []net.HardwareAddr{mac}}
+		return SpoofAlert{}, false
+	}
+	if now.Sub(b.start) > d.config.Window {
+		b.start, b.changes, b.alerted, b.macs = now, 0, false, b.macs[:0]
+		b.macs = append(b.macs, b.mac)
+	}
+	if bytes.Equal(b.mac, packet.SenderHardwareAddr) {
+		return SpoofAlert{}, false
+	}
+
+	b.changes++
+	b.mac = dupMAC(packet.SenderHardwareAddr)
+	if !containsMAC(b.macs, b.mac) {
+		b.macs = append(b.macs, b.mac)
+	}
+	if b.alerted || b.changes <= d.config.BindingChanges {
+		return SpoofAlert{}, false
+	}
+	b.alerted = true
+	macs := make([]net.HardwareAddr, len(b.macs))
+	for i := range b.macs {
+		macs[i] = dupMAC(b.macs[i])
+	}
+	return SpoofAlert{Type: AlertBindingFlap, Severity: SeverityMedium, MAC: dupMAC(b.mac), IP: dupIP(packet.SenderIP), MACs: macs, Count: b.changes, Window: d.config.Window}, true
+}
+
+// purge remove the records older than the window
+func (d *spoofDetector) purge(now time.Time) {
+	for k, r := range d.replies {
+		if now.Sub(r.start) > d.config.Window {
+			delete(d.replies, k)
+		}
+	}
+	for k, b := range d.bindings {
+		if now.Sub(b.start) > d.config.Window {
+			delete(d.bindings, k)
+		}
+	}
+	for k, t := range d.claims {
+		if now.Sub(t) > d.config.Window {
+			delete(d.claims, k)
+		}
+	}
+}
+
+func lookupBinding(index map[string]*bindingRecord, ip net.IP) (*bindingRecord, bool) {
+	b, ok := index[string(ip.To4())]
+	return b, ok
+}
+
+func containsMAC(macs []net.HardwareAddr, mac net.HardwareAddr) bool {
+	for _, m := range macs {
+		if bytes.Equal(m, mac) {
+			return true
+		}
+	}
+	return false
+}
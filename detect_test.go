@@ -0,0 +1,65 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_SpoofDetection(t *testing.T) {
+	routerMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0xfe}
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(newFakeConn()), WithSpoofDetection(SpoofDetection{UnsolicitedReplies: 5, BindingChanges: 2}))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	h.SetRouter(ip2, routerMAC)
+	s := h.Subscribe(64, DropNewest)
+
+	next := func() *SpoofAlert {
+		for {
+			select {
+			case e := <-s.C:
+				if e.Type == EventSpoofDetected {
+					return e.Alert
+				}
+			case <-time.After(time.Millisecond * 100):
+				return nil
+			}
+		}
+	}
+
+	// the router replying to the host is not an alert
+	h.handlePacket(newTestPacket(marp.OperationReply, routerMAC, ip2, mac3, ip3))
+	if alert := next(); alert != nil {
+		t.Fatalf("unexpected alert %+v", alert)
+	}
+
+	// mac1 claims the router IP to mac2
+	h.handlePacket(newTestPacket(marp.OperationReply, mac1, ip2, mac2, ip1))
+	if alert := next(); alert == nil || alert.Type != AlertGatewayClaim || alert.MAC.String() != mac1.String() || len(alert.MACs) != 2 {
+		t.Fatalf("invalid gateway claim alert %+v", alert)
+	}
+
+	// unsolicited replies; the gateway claim is sent once per window
+	for i := 0; i < 5; i++ {
+		h.handlePacket(newTestPacket(marp.OperationReply, mac1, ip2, mac2, ip1))
+	}
+	if alert := next(); alert == nil || alert.Type != AlertUnsolicitedReplies || alert.Count != 6 {
+		t.Fatalf("invalid unsolicited replies alert %+v", alert)
+	}
+
+	// binding flap between mac1 and the router
+	h.handlePacket(newTestPacket(marp.OperationReply, routerMAC, ip2, mac3, ip3))
+	h.handlePacket(newTestPacket(marp.OperationReply, mac1, ip2, mac3, ip3))
+	alert := next()
+	if alert == nil || alert.Type != AlertBindingFlap || !alert.IP.Equal(ip2) || len(alert.MACs) != 2 {
+		t.Fatalf("invalid binding flap alert %+v", alert)
+	}
+	if alert := next(); alert != nil {
+		t.Errorf("unexpected alert %+v", alert)
+	}
+}
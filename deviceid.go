@@ -0,0 +1,46 @@
+package arp
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// randReader is the source of device IDs; tests replace it to simulate failures
+var randReader io.Reader = rand.Reader
+
+// newDeviceID return a random (version 4) UUID or an error if the system
+// random source fails.
+func newDeviceID() (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(randReader, b[:]); err != nil {
+		return "", fmt.Errorf("cannot create device ID: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// FindDevice return the most recently updated entry of the device with the
+// stable identifier id or nil if not found; see Entry.DeviceID.
+func (c *Handler) FindDevice(id string) *Entry {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.findDeviceLocked(id)
+}
+
+// findDeviceLocked
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) findDeviceLocked(id string) *Entry {
+	var found *Entry
+	for _, e := range c.table {
+		if e == nil || e.DeviceID != id || e.State == StateVirtualHost {
+			continue
+		}
+		if found == nil || e.LastUpdate.After(found.LastUpdate) {
+			found = e
+		}
+	}
+	return found
+}
@@ -0,0 +1,67 @@
+package arp
+
+import (
+	"crypto/rand"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_DeviceID(t *testing.T) {
+	if id, err := newDeviceID(); err != nil || !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Fatalf("invalid device ID %s", id)
+	}
+
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	h.mutex.Lock()
+	e1 := h.arpTableAppendLocked(StateNormal, mac1, ip1)
+	restored, err := h.restoreLocked(Entry{MAC: mac2, IP: ip2, DeviceID: "stored-id"})
+	h.mutex.Unlock()
+	if e1.DeviceID == "" || err != nil || restored.DeviceID != "stored-id" {
+		t.Fatalf("invalid device IDs %q %+v %v", e1.DeviceID, restored, err)
+	}
+	if e := h.FindDevice(e1.DeviceID); e != e1 {
+		t.Errorf("invalid device %+v", e)
+	}
+	if e := h.FindDevice("stored-id"); e == nil || e.MAC.String() != mac2.String() {
+		t.Errorf("invalid restored device %+v", e)
+	}
+	if e := h.FindDevice("unknown"); e != nil {
+		t.Errorf("unknown device found %+v", e)
+	}
+}
+
+type failReader struct{}
+
+func (failReader) Read(b []byte) (int, error) { return 0, errors.New("entropy exhausted") }
+
+func Test_DeviceIDError(t *testing.T) {
+	randReader = failReader{}
+	defer func() { randReader = rand.Reader }()
+
+	if _, err := newDeviceID(); err == nil {
+		t.Fatal("expected device ID error")
+	}
+
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	if err := h.ImportCSV(strings.NewReader(mac1.String() + "," + ip1.String() + "\n")); err == nil || errors.Is(err, ErrTableFull) {
+		t.Error("expected device ID error ", err)
+	}
+	h.handlePacket(newTestPacket(marp.OperationReply, mac2, ip2, mac3, ip3))
+	if len(h.GetTable()) != 0 {
+		t.Errorf("entries added without device ID %+v", h.GetTable())
+	}
+}
@@ -0,0 +1,141 @@
+package arp
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// deviceLinkWindow is the maximum time between the last packet of a MAC and the
+// first packet of the MAC it is linked to
+var deviceLinkWindow = time.Hour * 24
+
+// IsRandomMAC return true if mac is a locally administered unicast MAC, i.e.
+// a privacy MAC randomised by iOS, Android or Windows.
+func IsRandomMAC(mac net.HardwareAddr) bool {
+	return len(mac) > 0 && mac[0]&0x02 != 0 && mac[0]&0x01 == 0
+}
+
+// WithDeviceLinking link successive randomised MACs of the same device so
+// privacy MACs do not look like new devices.
+//
+// When a randomised MAC appears, changes IP or gets a DHCP or mDNS name, it
+// is linked to the most recent randomised MAC not seen since the new MAC
+// appeared, in the last 24 hours, with the same DHCP hostname, the same mDNS
+// name or the same IP. The entry takes the DeviceID of the previous MAC and
+// EventDeviceLinked is sent with the previous MAC in Event.Previous; the
+// entries are not merged. See LinkedMACs.
+func WithDeviceLinking() Option {
+	return func(c *Handler) error {
+		c.linkDevices = true
+		return nil
+	}
+}
+
+// LinkedMACs return the MACs of the device with mac, ordered by first seen;
+// it return nil if mac is not in the table.
+func (c *Handler) LinkedMACs(mac net.HardwareAddr) []net.HardwareAddr {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry := c.findMACLocked(mac)
+	if entry == nil {
+		return nil
+	}
+	list := []*Entry{entry}
+	for _, e := range c.table {
+		if e != nil && e != entry && e.State != StateVirtualHost && e.DeviceID != "" && e.DeviceID == entry.DeviceID {
+			list = append(list, e)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].FirstSeen.Before(list[j].FirstSeen) })
+	macs := make([]net.HardwareAddr, len(list))
+	for i := range list {
+		macs[i] = dupMAC(list[i].MAC)
+	}
+	return macs
+}
+
+// linkLoop link the randomised MACs of new devices and name changes until
+// the handler stops.
+func (c *Handler) linkLoop(h *goroutine, sub *Subscription) {
+	defer h.End()
+	defer c.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-c.goroutinePool.StopChannel:
+			return
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			switch event.Type {
+			case EventNewDevice, EventIPChanged, EventHostnameChanged:
+			default:
+				continue
+			}
+			if !IsRandomMAC(event.Entry.MAC) {
+				continue
+			}
+			c.linkDevice(event.Entry.MAC)
+		}
+	}
+}
+
+// linkDevice link the randomised mac to a previous MAC of the same device.
+func (c *Handler) linkDevice(mac net.HardwareAddr) {
+	c.mutex.Lock()
+	entry := c.findMACLocked(mac)
+	if entry == nil || entry.State == StateVirtualHost {
+		c.mutex.Unlock()
+		return
+	}
+
+	var match *Entry
+	var reason string
+	for _, e := range c.table {
+		if e != nil && e != entry && entry.DeviceID != "" && e.DeviceID == entry.DeviceID { // already linked
+			c.mutex.Unlock()
+			return
+		}
+	}
+	for _, e := range c.table {
+		if e == nil || e == entry || e.State == StateVirtualHost || !IsRandomMAC(e.MAC) ||
+			!e.LastUpdate.Before(entry.FirstSeen) || entry.FirstSeen.Sub(e.LastUpdate) > deviceLinkWindow {
+			continue
+		}
+		if match != nil && !e.LastUpdate.After(match.LastUpdate) {
+			continue
+		}
+		if r := linkReason(entry, e); r != "" {
+			match, reason = e, r
+		}
+	}
+	if match == nil {
+		c.mutex.Unlock()
+		return
+	}
+	previous := *match
+	entry.DeviceID = match.DeviceID
+	current := *entry
+	c.mutex.Unlock()
+
+	c.log().WithFields(log.Fields{"mac": current.MAC.String(), "previous": previous.MAC.String(), "device": current.DeviceID, "reason": reason}).Info("ARP device linked")
+	c.notify(EventDeviceLinked, previous, current)
+}
+
+// linkReason return the evidence that a and b are the same device or ""
+func linkReason(a *Entry, b *Entry) string {
+	switch {
+	case a.dhcpHostname() != "" && a.dhcpHostname() == b.dhcpHostname():
+		return "dhcp hostname"
+	case a.MDNSName != "" && a.MDNSName == b.MDNSName:
+		return "mdns name"
+	case !a.IP.Equal(net.IPv4zero) && a.IP.Equal(b.IP):
+		return "ip"
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_DeviceLinking(t *testing.T) {
+	random1 := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x01, 0x01}
+	random2 := net.HardwareAddr{0x3a, 0x00, 0x00, 0x00, 0x01, 0x02}
+	if !IsRandomMAC(random1) || !IsRandomMAC(random2) || IsRandomMAC(mac1) || IsRandomMAC(EthernetBroadcast) {
+		t.Fatal("invalid random MAC detection")
+	}
+
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()), WithDeviceLinking())
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropNewest)
+
+	now := time.Now()
+	h.mutex.Lock()
+	old := h.arpTableAppendLocked(StateNormal, random1, ip1)
+	old.DHCP = &DHCPInfo{Hostname: "phone"}
+	old.FirstSeen, old.LastUpdate = now.Add(-time.Hour*2), now.Add(-time.Hour)
+	other := h.arpTableAppendLocked(StateNormal, mac1, ip2) // not random
+	other.DHCP = &DHCPInfo{Hostname: "phone"}
+	other.LastUpdate = now.Add(-time.Hour)
+	entry := h.arpTableAppendLocked(StateNormal, random2, ip2)
+	entry.DHCP = &DHCPInfo{Hostname: "phone"}
+	entry.FirstSeen = now
+	h.mutex.Unlock()
+
+	h.mutex.RLock()
+	oldID := old.DeviceID
+	h.mutex.RUnlock()
+	h.linkDevice(random2)
+	if e := h.FindDevice(oldID); e == nil || e.MAC.String() != random2.String() {
+		t.Errorf("invalid device %+v", e)
+	}
+	if macs := h.LinkedMACs(random2); len(macs) != 2 || macs[0].String() != random1.String() || macs[1].String() != random2.String() {
+		t.Errorf("invalid linked MACs %v", macs)
+	}
+	if macs := h.LinkedMACs(mac1); len(macs) != 1 {
+		t.Errorf("invalid linked MACs for global MAC %v", macs)
+	}
+	select {
+	case e := <-s.C:
+		if e.Type != EventDeviceLinked || e.Previous.MAC.String() != random1.String() || e.Entry.DeviceID != oldID {
+			t.Errorf("invalid event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("no device linked event")
+	}
+}
@@ -0,0 +1,327 @@
+package arp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DHCP message types (option 53)
+const (
+	dhcpDiscover = 1
+	dhcpRequest  = 3
+	dhcpAck      = 5
+	dhcpInform   = 8
+)
+
+// DHCP options
+const (
+	dhcpOptionPad         = 0
+	dhcpOptionHostname    = 12
+	dhcpOptionRequestedIP = 50
+	dhcpOptionLeaseTime   = 51
+	dhcpOptionMessageType = 53
+	dhcpOptionParamList   = 55
+	dhcpOptionVendorClass = 60
+	dhcpOptionEnd         = 255
+)
+
+// dhcpPendingSize is the maximum number of unknown clients kept until the ACK
+const dhcpPendingSize = 256
+
+var (
+	dhcpMagicCookie = []byte{99, 130, 83, 99}
+	errDHCPInvalid  = errors.New("invalid dhcp packet")
+)
+
+// DHCPInfo is the DHCP information snooped for a device; see ListenAndServeDHCP.
+type DHCPInfo struct {
+	Hostname    string        `json:"hostname,omitempty"`    // option 12
+	RequestedIP net.IP        `json:"requestedIP,omitempty"` // option 50 or the client IP when renewing
+	LeaseTime   time.Duration `json:"leaseTime,omitempty"`   // option 51 in the last ACK
+	Fingerprint string        `json:"fingerprint,omitempty"` // option 55 as a comma separated list
+	VendorClass string        `json:"vendorClass,omitempty"` // option 60
+	LastUpdate  time.Time     `json:"lastUpdate"`
+}
+
+// dhcpSnooper holds the DHCP sockets and the information of clients not in
+// the table yet.
+type dhcpSnooper struct {
+	conns   []net.PacketConn
+	pending map[string]DHCPInfo // by MAC; protected by the handler mutex
+}
+
+// dhcpPacket is the parsed BOOTP header and the options we use
+type dhcpPacket struct {
+	op          byte
+	mac         net.HardwareAddr
+	ciaddr      net.IP
+	yiaddr      net.IP
+	msgType     byte
+	hostname    string
+	requestedIP net.IP
+	leaseTime   time.Duration
+	fingerprint string
+	vendorClass string
+}
+
+// dhcpConns return the DHCP sockets or nil if ListenAndServeDHCP is not running.
+func (c *Handler) dhcpConns() []net.PacketConn {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.dhcp == nil {
+		return nil
+	}
+	return c.dhcp.conns
+}
+
+// ListenAndServeDHCP passively listen for DHCP packets and save the client
+// hostname, requested IP and lease time in Entry.DHCP. A DHCP ACK is an
+// authoritative IP change: the entry IP is updated without waiting for the
+// next ARP packet.
+//
+// It listens on the DHCP server port for client broadcasts and, if available,
+// on the client port for broadcast ACKs; ACKs unicast to the client are not
+// seen. Binding the ports requires root and fails if a DHCP server runs on
+// the host.
+func (c *Handler) ListenAndServeDHCP() error {
+	server, err := net.ListenPacket("udp4", ":67")
+	if err != nil {
+		c.log().WithFields(log.Fields{"nic": c.config.NIC}).Error("DHCP error in socket:", err)
+		return err
+	}
+	conns := []net.PacketConn{server}
+	if client, err := net.ListenPacket("udp4", ":68"); err == nil {
+		conns = append(conns, client)
+	} else if c.logArea(LogTable) {
+		c.log().Debug("DHCP client port not available; broadcast ACKs are not seen ", err)
+	}
+
+	c.mutex.Lock()
+	c.dhcp = &dhcpSnooper{conns: conns, pending: make(map[string]DHCPInfo)}
+	c.mutex.Unlock()
+
+	for _, conn := range conns[1:] {
+		go c.serveDHCP(c.goroutinePool.Begin("DHCP ListenAndServe"), conn)
+	}
+	return c.serveDHCP(c.goroutinePool.Begin("DHCP ListenAndServe"), server)
+}
+
+// serveDHCP read DHCP packets from conn until the handler stops.
+func (c *Handler) serveDHCP(h *goroutine, conn net.PacketConn) error {
+	defer h.End()
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if h.Stopping() { // are we stopping all goroutines?
+			return nil
+		}
+		if err != nil {
+			atomic.AddUint64(&c.counters.readErrors, 1)
+			c.log().Error("DHCP read error ", err)
+			if err1, ok := err.(net.Error); ok && err1.Temporary() {
+				time.Sleep(time.Millisecond * 30)
+				continue
+			}
+			return err
+		}
+		p, err := parseDHCP(buf[:n])
+		if err != nil {
+			if c.logArea(LogTable) {
+				c.log().Debug("DHCP invalid packet ", err)
+			}
+			continue
+		}
+		c.handleDHCP(p)
+	}
+}
+
+// parseDHCP return the BOOTP header and options in b.
+func parseDHCP(b []byte) (p dhcpPacket, err error) {
+	if len(b) < 240 || b[1] != 1 || b[2] != 6 || string(b[236:240]) != string(dhcpMagicCookie) {
+		return p, errDHCPInvalid
+	}
+	p.op = b[0]
+	p.ciaddr = dupIP(b[12:16])
+	p.yiaddr = dupIP(b[16:20])
+	p.mac = dupMAC(b[28:34])
+
+	for i := 240; i < len(b) && b[i] != dhcpOptionEnd; {
+		if b[i] == dhcpOptionPad {
+			i++
+			continue
+		}
+		if i+1 >= len(b) || i+2+int(b[i+1]) > len(b) {
+			return p, errDHCPInvalid
+		}
+		code, value := b[i], b[i+2:i+2+int(b[i+1])]
+		i += 2 + len(value)
+
+		switch {
+		case code == dhcpOptionMessageType && len(value) == 1:
+			p.msgType = value[0]
+		case code == dhcpOptionHostname:
+			p.hostname = string(value)
+		case code == dhcpOptionRequestedIP && len(value) == 4:
+			p.requestedIP = dupIP(value)
+		case code == dhcpOptionLeaseTime && len(value) == 4:
+			p.leaseTime = time.Duration(binary.BigEndian.Uint32(value)) * time.Second
+		case code == dhcpOptionParamList:
+			p.fingerprint = dhcpFingerprint(value)
+		case code == dhcpOptionVendorClass:
+			p.vendorClass = string(value)
+		}
+	}
+	if p.msgType == 0 {
+		return p, errDHCPInvalid
+	}
+	return p, nil
+}
+
+// handleDHCP update the entry with the DHCP information in the packet.
+func (c *Handler) handleDHCP(p dhcpPacket) {
+	switch {
+	case p.op == 1 && (p.msgType == dhcpDiscover || p.msgType == dhcpRequest || p.msgType == dhcpInform):
+		c.actionDHCPRequest(p)
+	case p.op == 2 && p.msgType == dhcpAck && !p.yiaddr.Equal(net.IPv4zero):
+		c.actionDHCPAck(p)
+	}
+}
+
+// actionDHCPRequest save the client information; it is kept until the ACK if
+// the client is not in the table.
+func (c *Handler) actionDHCPRequest(p dhcpPacket) {
+	if c.isRouterMAC(p.mac) {
+		return
+	}
+	info := DHCPInfo{Hostname: p.hostname, RequestedIP: p.requestedIP, Fingerprint: p.fingerprint, VendorClass: p.vendorClass, LastUpdate: time.Now()}
+	if info.RequestedIP == nil && !p.ciaddr.Equal(net.IPv4zero) {
+		info.RequestedIP = p.ciaddr
+	}
+
+	c.mutex.Lock()
+	entry := c.findMACLocked(p.mac)
+	if entry == nil {
+		if len(c.dhcp.pending) >= dhcpPendingSize {
+			c.dhcp.pending = make(map[string]DHCPInfo)
+		}
+		c.dhcp.pending[string(p.mac)] = info
+		c.mutex.Unlock()
+		return
+	}
+	if entry.State == StateVirtualHost {
+		c.mutex.Unlock()
+		return
+	}
+	previous := *entry
+	if entry.DHCP != nil {
+		info.LeaseTime = entry.DHCP.LeaseTime
+	}
+	setDHCPInfo(entry, info)
+	current := *entry
+	c.mutex.Unlock()
+
+	if previous.dhcpHostname() != info.Hostname {
+		c.notify(EventHostnameChanged, previous, current)
+	}
+	if previous.DeviceClass != current.DeviceClass {
+		c.notifyDeviceClass(previous, current)
+	}
+}
+
+func (e *Entry) dhcpHostname() string {
+	if e.DHCP == nil {
+		return ""
+	}
+	return e.DHCP.Hostname
+}
+
+// setDHCPInfo set the entry DHCP information and the device class if the
+// fingerprint is known.
+func setDHCPInfo(entry *Entry, info DHCPInfo) {
+	entry.DHCP = &info // copy on write; events and snapshots may hold the previous info
+	if class := classifyDevice(info.VendorClass, info.Fingerprint); class != DeviceUnknown {
+		entry.DeviceClass = class
+	}
+}
+
+// notifyDeviceClass send EventDeviceClassChanged for the entry.
+func (c *Handler) notifyDeviceClass(previous Entry, current Entry) {
+	if c.logArea(LogTable) {
+		c.log().WithFields(log.Fields{"mac": current.MAC.String(), "ip": current.IP, "class": current.DeviceClass,
+			"vendorclass": current.DHCP.VendorClass, "fingerprint": current.DHCP.Fingerprint}).Debug("DHCP device classified")
+	}
+	c.notify(EventDeviceClassChanged, previous, current)
+}
+
+// actionDHCPAck set the entry IP to the address assigned by the server and
+// create the entry if the client is not in the table.
+func (c *Handler) actionDHCPAck(p dhcpPacket) {
+	if c.isRouterMAC(p.mac) || !c.config.HomeLAN.Contains(p.yiaddr) {
+		return
+	}
+	now := time.Now()
+
+	c.mutex.Lock()
+	info, ok := c.dhcp.pending[string(p.mac)]
+	delete(c.dhcp.pending, string(p.mac))
+
+	var event EventType
+	var evicted *Entry
+	previous := Entry{}
+	entry := c.findMACLocked(p.mac)
+	if entry == nil {
+		evicted = c.evictOldestLocked()
+		if entry = c.arpTableAppendLocked(StateNormal, p.mac, p.yiaddr); entry == nil {
+			c.mutex.Unlock()
+			return
+		}
+		entry.Online = true
+		event = EventNewDevice
+	} else {
+		if entry.State == StateVirtualHost {
+			c.mutex.Unlock()
+			return
+		}
+		previous = *entry
+		if !ok && entry.DHCP != nil {
+			info = *entry.DHCP
+		}
+		if entry.State == StateNormal && !entry.IP.Equal(p.yiaddr) {
+			c.setIPLocked(entry, p.yiaddr)
+			event = EventIPChanged
+		}
+		if !entry.Online {
+			entry.Online = true
+			event = EventDeviceOnline
+		}
+	}
+	if p.hostname != "" {
+		info.Hostname = p.hostname
+	}
+	info.LeaseTime, info.LastUpdate = p.leaseTime, now
+	setDHCPInfo(entry, info)
+	entry.LastUpdate = now
+	entry.misses = 0
+	current := *entry
+	c.mutex.Unlock()
+
+	if evicted != nil {
+		c.notifyEvicted(*evicted)
+	}
+	if event == "" && previous.dhcpHostname() != info.Hostname {
+		event = EventHostnameChanged
+	}
+	if event != "" {
+		c.log().WithFields(log.Fields{"mac": current.MAC, "ip": current.IP, "previousip": previous.IP, "hostname": info.Hostname}).Info("DHCP ack received")
+		c.notify(event, previous, current)
+	}
+	if previous.DeviceClass != current.DeviceClass {
+		c.notifyDeviceClass(previous, current)
+	}
+}
@@ -0,0 +1,110 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+// newDHCPPacket return a DHCP packet with the message type and the options in order.
+func newDHCPPacket(op byte, msgType byte, mac net.HardwareAddr, yiaddr net.IP, options ...[]byte) []byte {
+	b := make([]byte, 240)
+	b[0], b[1], b[2] = op, 1, 6
+	copy(b[16:], yiaddr.To4())
+	copy(b[28:], mac)
+	copy(b[236:], dhcpMagicCookie)
+	b = append(b, dhcpOptionMessageType, 1, msgType)
+	for _, o := range options {
+		b = append(b, o...)
+	}
+	return append(b, dhcpOptionEnd)
+}
+
+func Test_DHCPSnooping(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	h.dhcp = &dhcpSnooper{pending: make(map[string]DHCPInfo)}
+	s := h.Subscribe(16, DropNewest)
+
+	handle := func(b []byte) {
+		p, err := parseDHCP(b)
+		if err != nil {
+			t.Fatal("parse error ", err)
+		}
+		h.handleDHCP(p)
+	}
+	hostname := append([]byte{dhcpOptionHostname, 6}, "laptop"...)
+	lease := []byte{dhcpOptionLeaseTime, 4, 0, 0, 0x0e, 0x10}
+
+	// the request of an unknown client is kept until the ACK
+	handle(newDHCPPacket(1, dhcpRequest, mac1, net.IPv4zero, hostname, []byte{dhcpOptionRequestedIP, 4, 192, 168, 0, 1}))
+	if h.FindMAC(mac1) != nil {
+		t.Fatal("unexpected entry before ack")
+	}
+	handle(newDHCPPacket(2, dhcpAck, mac1, ip1, lease))
+	e := <-s.C
+	if e.Type != EventNewDevice || !e.Entry.IP.Equal(ip1) || e.Entry.DHCP == nil ||
+		e.Entry.DHCP.Hostname != "laptop" || !e.Entry.DHCP.RequestedIP.Equal(ip1) || e.Entry.DHCP.LeaseTime != time.Hour {
+		t.Fatalf("invalid event %+v", e)
+	}
+
+	// the ACK changes the IP without waiting for an ARP packet
+	handle(newDHCPPacket(2, dhcpAck, mac1, ip2, lease))
+	if e := <-s.C; e.Type != EventIPChanged || !e.Entry.IP.Equal(ip2) || e.Entry.DHCP.Hostname != "laptop" {
+		t.Fatalf("invalid event %+v", e)
+	}
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip2, EthernetBroadcast, ip3))
+	select {
+	case e := <-s.C:
+		t.Fatalf("unexpected event %+v", e)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	if _, err := parseDHCP(newDHCPPacket(1, dhcpRequest, mac1, net.IPv4zero, []byte{dhcpOptionHostname, 10, 'a'})); err == nil {
+		t.Error("expected error for truncated option")
+	}
+}
+
+func Test_DHCPFingerprint(t *testing.T) {
+	tests := []struct {
+		vendor string
+		params []byte
+		class  DeviceClass
+	}{
+		{"", []byte{1, 121, 3, 6, 15, 119, 252}, DeviceIOS},
+		{"", []byte{1, 121, 3, 6, 15, 119, 252, 95, 44, 46}, DeviceMacOS},
+		{"android-dhcp-13", []byte{1, 3, 6, 15, 26, 28, 51, 58, 59, 43, 114, 108}, DeviceAndroid},
+		{"MSFT 5.0", []byte{1, 3, 6, 15, 31, 33, 43, 44, 46, 47, 119, 121, 249, 252}, DeviceWindows},
+		{"Hewlett-Packard JetDirect", []byte{1, 3, 6}, DevicePrinter},
+		{"", []byte{1, 3, 6}, DeviceUnknown},
+	}
+	for _, tt := range tests {
+		if class := classifyDevice(tt.vendor, dhcpFingerprint(tt.params)); class != tt.class {
+			t.Errorf("invalid class for %q %v got %q want %q", tt.vendor, tt.params, class, tt.class)
+		}
+	}
+
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	h.dhcp = &dhcpSnooper{pending: make(map[string]DHCPInfo)}
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip3))
+	s := h.Subscribe(16, DropNewest)
+
+	p, err := parseDHCP(newDHCPPacket(1, dhcpDiscover, mac1, net.IPv4zero,
+		[]byte{dhcpOptionParamList, 7, 1, 121, 3, 6, 15, 119, 252}))
+	if err != nil {
+		t.Fatal("parse error ", err)
+	}
+	h.handleDHCP(p)
+	if e := <-s.C; e.Type != EventDeviceClassChanged || e.Entry.DeviceClass != DeviceIOS || e.Entry.DHCP.Fingerprint != "1,121,3,6,15,119,252" {
+		t.Fatalf("invalid event %+v", e)
+	}
+}
@@ -0,0 +1,93 @@
+package arp
+
+import (
+	"net"
+)
+
+// Changes holds the difference between two snapshots; see Diff.
+type Changes struct {
+	Added   []Entry  // entries in new only
+	Removed []Entry  // entries in old only
+	Changed []Change // entries in both with a different IP, state or name
+}
+
+// Change is an entry in both snapshots
+type Change struct {
+	Previous Entry
+	Entry    Entry
+}
+
+// Empty return true if there are no changes.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0
+}
+
+// Diff return the entries added, removed and changed between the old and new
+// snapshots; entries are matched by MAC.
+//
+// An entry changed if its IP, addresses, IPv6 addresses, online status,
+// state, names, device class or labels differ. Times and counters are
+// ignored so periodic pollers of Snapshot see the same changes as the
+// event subscribers. Added and Changed follow the order of new and Removed
+// the order of old.
+func Diff(old []Entry, new []Entry) (changes Changes) {
+	previous := make(map[string]int, len(old))
+	for i := range old {
+		previous[string(old[i].MAC)] = i
+	}
+
+	seen := make(map[string]bool, len(new))
+	for i := range new {
+		key := string(new[i].MAC)
+		seen[key] = true
+		j, ok := previous[key]
+		if !ok {
+			changes.Added = append(changes.Added, new[i])
+			continue
+		}
+		if !sameEntry(&old[j], &new[i]) {
+			changes.Changed = append(changes.Changed, Change{Previous: old[j], Entry: new[i]})
+		}
+	}
+	for i := range old {
+		if !seen[string(old[i].MAC)] {
+			changes.Removed = append(changes.Removed, old[i])
+		}
+	}
+	return changes
+}
+
+// sameEntry return true if the entries have the same fields ignoring times and counters.
+func sameEntry(a *Entry, b *Entry) bool {
+	if !a.IP.Equal(b.IP) || a.Online != b.Online || a.State != b.State ||
+		a.Name != b.Name || a.Hostname != b.Hostname || a.MDNSName != b.MDNSName || a.NetBIOSName != b.NetBIOSName ||
+		a.DeviceClass != b.DeviceClass || a.dhcpHostname() != b.dhcpHostname() || a.DeviceID != b.DeviceID ||
+		len(a.Addresses) != len(b.Addresses) || len(a.IPv6) != len(b.IPv6) || len(a.Labels) != len(b.Labels) {
+		return false
+	}
+	for i := range a.Addresses {
+		if !b.hasAddress(a.Addresses[i].IP) {
+			return false
+		}
+	}
+	for i := range a.IPv6 {
+		if !containsIP(b.IPv6, a.IPv6[i]) {
+			return false
+		}
+	}
+	for k, v := range a.Labels {
+		if w, ok := b.Labels[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+func containsIP(list []net.IP, ip net.IP) bool {
+	for _, v := range list {
+		if v.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_Diff(t *testing.T) {
+	old := []Entry{
+		{MAC: mac1, IP: ip1, Online: true, LastUpdate: time.Now()},
+		{MAC: mac2, IP: ip2, Online: true},
+	}
+	new := []Entry{
+		{MAC: mac1, IP: ip1, Online: true, LastUpdate: time.Now().Add(time.Minute), Packets: 10},
+		{MAC: mac3, IP: ip3, Online: true},
+	}
+	changes := Diff(old, new)
+	if len(changes.Added) != 1 || changes.Added[0].MAC.String() != mac3.String() ||
+		len(changes.Removed) != 1 || changes.Removed[0].MAC.String() != mac2.String() || len(changes.Changed) != 0 {
+		t.Fatalf("invalid changes %+v", changes)
+	}
+
+	new[0].IPv6 = []net.IP{net.ParseIP("fe80::1")}
+	new[0].Online = false
+	changes = Diff(old[:1], new[:1])
+	if len(changes.Changed) != 1 || !changes.Changed[0].Previous.Online || changes.Changed[0].Entry.Online {
+		t.Fatalf("invalid changes %+v", changes)
+	}
+
+	if !Diff(new, new).Empty() {
+		t.Error("expected no changes")
+	}
+}
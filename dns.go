@@ -0,0 +1,129 @@
+package arp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	dnsTimeout     = time.Second * 2  // PTR lookup timeout
+	dnsCacheTTL    = time.Hour        // time a resolved name is cached
+	dnsNegativeTTL = time.Minute * 10 // time a failed lookup is cached
+	dnsRate        = 5                // maximum lookups per second
+)
+
+// reverseDNS resolves the hostname of entries; see WithReverseDNS.
+type reverseDNS struct {
+	lookup  func(ctx context.Context, addr string) ([]string, error)
+	limiter tokenBucket
+	mutex   sync.Mutex
+	cache   map[string]dnsRecord // by IP
+}
+
+type dnsRecord struct {
+	name    string
+	expires time.Time
+}
+
+// WithReverseDNS resolve the PTR record of new devices and IP changes and
+// save the name in Entry.Hostname; EventHostnameChanged is sent when the
+// name changes. Results are cached for an hour and lookups are limited to a
+// few per second. The default resolver is used if resolver is nil.
+func WithReverseDNS(resolver *net.Resolver) Option {
+	return func(c *Handler) error {
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		c.dns = &reverseDNS{lookup: resolver.LookupAddr, cache: make(map[string]dnsRecord)}
+		c.dns.limiter.set(dnsRate)
+		return nil
+	}
+}
+
+// resolve return the cached name or lookup the PTR record for ip.
+func (d *reverseDNS) resolve(ip net.IP) (string, error) {
+	key := ip.String()
+	now := time.Now()
+	d.mutex.Lock()
+	if r, ok := d.cache[key]; ok && now.Before(r.expires) {
+		d.mutex.Unlock()
+		return r.name, nil
+	}
+	d.mutex.Unlock()
+
+	d.limiter.wait()
+	ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+	defer cancel()
+	names, err := d.lookup(ctx, key)
+
+	r := dnsRecord{expires: now.Add(dnsNegativeTTL)}
+	if err == nil && len(names) > 0 {
+		r = dnsRecord{name: strings.TrimSuffix(names[0], "."), expires: now.Add(dnsCacheTTL)}
+	}
+	d.mutex.Lock()
+	if len(d.cache) >= maxTableSize {
+		d.cache = make(map[string]dnsRecord) // bound memory on large networks
+	}
+	d.cache[key] = r
+	d.mutex.Unlock()
+	return r.name, err
+}
+
+// dnsLoop resolve the hostname of entries when they are added or change IP
+// until the handler stops.
+func (c *Handler) dnsLoop(h *goroutine, sub *Subscription) {
+	defer h.End()
+	defer c.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-c.goroutinePool.StopChannel:
+			return
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			switch event.Type {
+			case EventNewDevice, EventIPChanged, EventDeviceOnline:
+			default:
+				continue
+			}
+			if event.Entry.State == StateVirtualHost || event.Entry.IP.Equal(net.IPv4zero) {
+				continue
+			}
+			name, err := c.dns.resolve(event.Entry.IP)
+			if err != nil && c.logArea(LogTable) {
+				c.log().WithFields(log.Fields{"mac": event.Entry.MAC.String(), "ip": event.Entry.IP}).Debug("ARP reverse dns error ", err)
+			}
+			c.setDiscoveredName(event.Entry.IP, name, hostnameField)
+		}
+	}
+}
+
+func hostnameField(e *Entry) *string { return &e.Hostname }
+
+// setDiscoveredName set the name field returned by field for the entry with
+// ip and notify EventHostnameChanged if the name changed.
+func (c *Handler) setDiscoveredName(ip net.IP, name string, field func(*Entry) *string) {
+	c.mutex.Lock()
+	entry := lookupIP(c.ipIndex, ip)
+	if entry == nil || *field(entry) == name {
+		c.mutex.Unlock()
+		return
+	}
+	previous := *entry
+	*field(entry) = name
+	current := *entry
+	c.mutex.Unlock()
+
+	if c.logArea(LogTable) {
+		c.log().WithFields(log.Fields{"mac": current.MAC.String(), "ip": ip, "name": name}).Debug("ARP discovered name changed")
+	}
+	c.notify(EventHostnameChanged, previous, current)
+}
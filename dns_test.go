@@ -0,0 +1,57 @@
+package arp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_ReverseDNS(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()), WithReverseDNS(nil))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	var lookups int32
+	h.dns.lookup = func(ctx context.Context, addr string) ([]string, error) {
+		atomic.AddInt32(&lookups, 1)
+		if addr == ip1.String() {
+			return []string{"printer.lan."}, nil
+		}
+		return nil, errors.New("not found")
+	}
+	s := h.Subscribe(16, DropNewest)
+	go h.dnsLoop(h.goroutinePool.Begin("ARP dnsLoop"), h.Subscribe(16, DropNewest))
+
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip3))
+	for _, want := range []EventType{EventNewDevice, EventHostnameChanged} {
+		select {
+		case e := <-s.C:
+			if e.Type != want {
+				t.Fatalf("invalid event %+v want %s", e, want)
+			}
+			if want == EventHostnameChanged && (e.Entry.Hostname != "printer.lan" || e.Previous.Hostname != "") {
+				t.Fatalf("invalid hostname %+v", e)
+			}
+		case <-time.After(time.Millisecond * 200):
+			t.Fatal("timeout waiting for event ", want)
+		}
+	}
+	if h.FindMAC(mac1).Hostname != "printer.lan" {
+		t.Fatal("hostname not set ", h.FindMAC(mac1))
+	}
+
+	// cached names and failed lookups do not query the resolver again
+	h.dns.resolve(ip1)
+	h.dns.resolve(ip2)
+	h.dns.resolve(ip2)
+	if n := atomic.LoadInt32(&lookups); n != 2 {
+		t.Errorf("invalid lookup count %d", n)
+	}
+}
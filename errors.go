@@ -0,0 +1,72 @@
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// Errors returned by the handler; use errors.Is as they are usually wrapped
+// with the MAC or IP.
+var (
+	// ErrNotFound is returned when the MAC or IP is not in the table
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidMAC is returned when a MAC is not a 6 byte ethernet address
+	ErrInvalidMAC = errors.New("invalid MAC address")
+
+	// ErrHuntNotAllowed is returned when a device is in the spoof deny list or not in a non empty allow list
+	ErrHuntNotAllowed = errors.New("device is protected from spoofing")
+
+	// ErrSocketClosed is returned when the handler socket is closed, i.e. after Stop
+	ErrSocketClosed = errors.New("socket is closed")
+
+	// ErrTableFull is returned when an entry cannot be added because the table reached the size of the LAN
+	ErrTableFull = errors.New("table is full")
+
+	// ErrInvalidConfig is returned when the handler configuration is not valid; see Config.Validate
+	ErrInvalidConfig = errors.New("invalid configuration")
+
+	// ErrAddressConflict is returned by Healthy when another host uses the host IP; see WithConflictDetection
+	ErrAddressConflict = errors.New("host IP address conflict")
+
+	// ErrFilterNotSupported is returned when the packet source cannot attach a socket filter; see WithSocketFilter
+	ErrFilterNotSupported = errors.New("socket filter not supported")
+
+	// ErrPromiscuousNotSupported is returned when the packet source cannot enable promiscuous mode; see WithPromiscuous
+	ErrPromiscuousNotSupported = errors.New("promiscuous mode not supported")
+
+	// ErrPassive is returned when a passive handler is asked to transmit; see WithPassive
+	ErrPassive = errors.New("handler is passive")
+
+	// ErrAlreadyHunting is returned when a hunt is started for a device that is already being hunted
+	ErrAlreadyHunting = errors.New("device is already being hunted")
+
+	// ErrNotHunting is returned when a hunt is stopped for a device that is not being hunted
+	ErrNotHunting = errors.New("device is not being hunted")
+
+	// ErrInvalidTransition is returned when an entry cannot move to the requested state; see Transition
+	ErrInvalidTransition = errors.New("invalid state transition")
+)
+
+// checkMAC return ErrInvalidMAC if mac is not an ethernet address.
+func checkMAC(mac net.HardwareAddr) error {
+	if len(mac) != 6 {
+		return fmt.Errorf("%w %q", ErrInvalidMAC, mac.String())
+	}
+	return nil
+}
+
+// errMACNotFound return ErrNotFound for mac.
+func errMACNotFound(mac net.HardwareAddr) error {
+	return fmt.Errorf("mac %s %w", mac.String(), ErrNotFound)
+}
+
+// writeError return ErrSocketClosed if err is a write to a closed socket.
+func writeError(err error) error {
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, os.ErrClosed) {
+		return fmt.Errorf("%w: %v", ErrSocketClosed, err)
+	}
+	return err
+}
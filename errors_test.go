@@ -0,0 +1,49 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func Test_Errors(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(29, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	if err := h.StartHunt(mac1); !errors.Is(err, ErrNotFound) {
+		t.Error("expected not found ", err)
+	}
+	if err := h.SetName(mac1, "name"); !errors.Is(err, ErrNotFound) {
+		t.Error("expected not found ", err)
+	}
+	if err := h.StopHunt(net.HardwareAddr{0x01}); !errors.Is(err, ErrInvalidMAC) {
+		t.Error("expected invalid mac ", err)
+	}
+	if err := h.SetHomeLAN(net.IPNet{IP: net.IPv4(192, 168, 0, 0)}); !errors.Is(err, ErrInvalidConfig) {
+		t.Error("expected invalid config ", err)
+	}
+	if err := h.ImportCSV(strings.NewReader("invalid\n")); !errors.Is(err, ErrInvalidMAC) {
+		t.Error("expected invalid mac ", err)
+	}
+
+	h.SetSpoofDenyList([]net.HardwareAddr{mac1}, nil)
+	h.mutex.Lock()
+	h.arpTableAppendLocked(StateNormal, mac1, ip1)
+	h.mutex.Unlock()
+	if err := h.StartHunt(mac1); !errors.Is(err, ErrHuntNotAllowed) || err != ErrSpoofDenied {
+		t.Error("expected hunt not allowed ", err)
+	}
+
+	// fill the table; the limit is the minimum table size
+	var csv strings.Builder
+	for i := 0; i <= minTableSize; i++ {
+		csv.WriteString(net.HardwareAddr{0x02, 0, 0, 0, byte(i >> 8), byte(i)}.String() + "\n")
+	}
+	if err := h.ImportCSV(strings.NewReader(csv.String())); !errors.Is(err, ErrTableFull) {
+		t.Error("expected table full ", err)
+	}
+}
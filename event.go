@@ -0,0 +1,96 @@
+package arp
+
+import (
+	"time"
+)
+
+// EventType identifies the change that generated an Event.
+type EventType string
+
+const (
+	// EventNewDevice when a MAC is seen for the first time
+	EventNewDevice EventType = "new"
+
+	// EventDeviceOnline when an existing MAC switch from offline to online
+	EventDeviceOnline EventType = "online"
+
+	// EventDeviceOffline when a MAC stops responding to ARP requests
+	EventDeviceOffline EventType = "offline"
+
+	// EventDeviceSeen is sent periodically while a MAC remains online
+	EventDeviceSeen EventType = "seen"
+
+	// EventIPChanged when a MAC changes its IPv4 address, an IPv4 address expires or it gains an IPv6 address
+	EventIPChanged EventType = "ipchanged"
+
+	// EventHuntStarted when ForceIPChange starts hunting a MAC
+	EventHuntStarted EventType = "huntstarted"
+
+	// EventHuntStopped when the hunt ends; Entry.IP holds the new IP if the client changed it
+	EventHuntStopped EventType = "huntstopped"
+
+	// EventHuntTimeout when a hunt exceeds the hunt timeout; EventHuntStopped follows
+	EventHuntTimeout EventType = "hunttimeout"
+
+	// EventSpoofDetected when another host is suspected of ARP spoofing; Alert holds the evidence
+	EventSpoofDetected EventType = "spoofdetected"
+
+	// EventHostnameChanged when a discovered name changes; see WithReverseDNS, ListenAndServeMDNS,
+	// WithNetBIOS and ListenAndServeDHCP
+	EventHostnameChanged EventType = "hostname"
+
+	// EventDeviceClassChanged when the DHCP fingerprint identifies the device type; see ListenAndServeDHCP
+	EventDeviceClassChanged EventType = "class"
+
+	// EventDeviceEvicted when an entry is deleted from the table; see SetRetention
+	EventDeviceEvicted EventType = "evicted"
+
+	// EventStormDetected when a MAC exceeds the packet rate threshold; Alert holds the rate
+	EventStormDetected EventType = "stormdetected"
+
+	// EventHandlerDegraded when the socket fails and ListenAndServe starts reconnecting; Err holds the read error
+	EventHandlerDegraded EventType = "degraded"
+
+	// EventHandlerRecovered when the socket is open again after EventHandlerDegraded
+	EventHandlerRecovered EventType = "recovered"
+
+	// EventHostConflict when another MAC uses the host IP or an IP claimed with ClaimIP; Entry holds
+	// the MAC. See WithConflictDetection
+	EventHostConflict EventType = "hostconflict"
+
+	// EventGroupOnline when the first device of a group goes online; Group holds the name. See CreateGroup
+	EventGroupOnline EventType = "grouponline"
+
+	// EventGroupOffline when the last online device of a group goes offline; Group holds the name
+	EventGroupOffline EventType = "groupoffline"
+
+	// EventDeviceLinked when a randomised MAC is linked to a previous MAC of the device; Previous holds the previous MAC entry. See WithDeviceLinking
+	EventDeviceLinked EventType = "devicelinked"
+
+	// EventIPConflict when two MACs claim the same IP; Alert holds the claimants. See WithIPConflictDetection
+	EventIPConflict EventType = "ipconflict"
+
+	// EventFlapping when an IP changes MAC or a MAC changes IP too often; Alert holds the owners. See WithFlapDetection
+	EventFlapping EventType = "flapping"
+
+	// EventGatewayImpersonation when another MAC claims the router IP; Alert holds the offending frame. See WithGatewayGuard
+	EventGatewayImpersonation EventType = "gatewayimpersonation"
+)
+
+// Event describes a change to an Entry.
+//
+// Previous is a copy of the entry before the change and is empty for EventNewDevice.
+// Entry is a copy of the entry after the change. NIC is the interface of the
+// handler that generated the event. Alert is only set for EventSpoofDetected, EventStormDetected,
+// EventGatewayImpersonation, EventIPConflict and EventFlapping.
+// Err is only set for EventHandlerDegraded; handler events have an empty Entry.
+type Event struct {
+	Type     EventType
+	Time     time.Time
+	NIC      string
+	Previous Entry
+	Entry    Entry
+	Alert    *SpoofAlert
+	Err      error
+	Group    string // group name for EventGroupOnline and EventGroupOffline
+}
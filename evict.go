@@ -0,0 +1,68 @@
+package arp
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultRetention is the time an entry is kept in the table after the device was
+// last seen unless SetRetention is called.
+const DefaultRetention = time.Hour
+
+// SetRetention set the time an entry is kept after the device was last seen;
+// i.e. 30 days to remember devices that visit the network once a month. Zero
+// restores DefaultRetention.
+//
+// Expired entries are deleted and an EventDeviceEvicted event is sent. When
+// the table is full, the offline entry not seen for the longest time is
+// evicted to make room for a new device (i.e. guests with random MACs).
+func (c *Handler) SetRetention(retention time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if retention < 0 {
+		retention = 0
+	}
+	c.retention = retention
+}
+
+// Retention return the time an entry is kept after the device was last seen.
+func (c *Handler) Retention() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.retention == 0 {
+		return DefaultRetention
+	}
+	return c.retention
+}
+
+// evictOldestLocked delete the offline entry not seen for the longest time if
+// the table is full; it returns nil if there is room or all entries are in use.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) evictOldestLocked() (evicted *Entry) {
+	if len(c.table) < c.tableLimitLocked() {
+		return nil
+	}
+	for _, e := range c.table {
+		if e == nil {
+			return nil // will reuse the empty slot
+		}
+		if e.Online || e.State != StateNormal {
+			continue
+		}
+		if evicted == nil || e.LastUpdate.Before(evicted.LastUpdate) {
+			evicted = e
+		}
+	}
+	if evicted != nil {
+		c.deleteLocked(evicted)
+	}
+	return evicted
+}
+
+// notifyEvicted send EventDeviceEvicted for an entry deleted from the table.
+func (c *Handler) notifyEvicted(entry Entry) {
+	c.log().WithFields(log.Fields{"mac": entry.MAC.String(), "ip": entry.IP, "lastupdate": entry.LastUpdate}).Info("ARP entry evicted")
+	c.notify(EventDeviceEvicted, entry, entry)
+}
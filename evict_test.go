@@ -0,0 +1,72 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_Retention(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropNewest)
+
+	h.mutex.Lock()
+	entry := h.arpTableAppendLocked(StateNormal, mac1, ip1)
+	entry.LastUpdate = time.Now().Add(-time.Hour * 2)
+	h.mutex.Unlock()
+
+	h.SetRetention(time.Hour * 24 * 30)
+	h.confirmIsActive()
+	if h.FindMAC(mac1) == nil {
+		t.Fatal("entry deleted before retention period")
+	}
+
+	h.SetRetention(0)
+	if h.Retention() != DefaultRetention {
+		t.Fatal("invalid retention ", h.Retention())
+	}
+	h.confirmIsActive()
+	if h.FindMAC(mac1) != nil {
+		t.Fatal("entry not deleted after retention period")
+	}
+	select {
+	case e := <-s.C:
+		if e.Type != EventDeviceEvicted || e.Entry.MAC.String() != mac1.String() {
+			t.Errorf("invalid event %+v", e)
+		}
+	case <-time.After(time.Millisecond * 100):
+		t.Error("timeout waiting for evicted event")
+	}
+}
+
+func Test_EvictOldest(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	// fill the table; mac1 is the oldest offline entry
+	h.mutex.Lock()
+	h.arpTableAppendLocked(StateNormal, mac1, ip1).LastUpdate = time.Now().Add(-time.Hour)
+	for i := 0; len(h.table) < cap(h.table); i++ {
+		e := h.arpTableAppendLocked(StateNormal, net.HardwareAddr{0x02, 0, 0, 0, byte(i >> 8), byte(i)}, net.IPv4(10, 0, byte(i>>8), byte(i)))
+		e.Online = i%2 == 0
+	}
+	h.mutex.Unlock()
+
+	s := h.Subscribe(16, DropNewest)
+	h.handlePacket(newTestPacket(marp.OperationReply, mac2, ip2, mac3, ip3))
+	if h.FindMAC(mac2) == nil || h.FindMAC(mac1) != nil {
+		t.Fatal("oldest entry not evicted")
+	}
+	if e := <-s.C; e.Type != EventDeviceEvicted || e.Entry.MAC.String() != mac1.String() {
+		t.Errorf("invalid event %+v", e)
+	}
+}
@@ -0,0 +1,153 @@
+// Package exechook runs a command on arp.Handler events for users who script
+// everything in shell.
+//
+// The command runs once per event, in the order of the events, with the
+// environment of the process and:
+//
+//	EVENT  event type: new, online or offline by default
+//	MAC    device MAC
+//	IP     device IP
+//	NAME   most friendly name known for the device or empty
+//	NIC    interface of the handler
+//
+// The command is killed if it runs longer than the timeout; its output is
+// logged when it fails.
+//
+// Usage:
+//
+//	hook := exechook.New(handler, "/usr/local/bin/arp-event", exechook.WithArgs("--notify"))
+//	go hook.Run(ctx)
+package exechook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/irai/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// QueueSize is the subscription queue size; the oldest events are dropped
+// if the command is slower than the event rate.
+const QueueSize = 256
+
+// DefaultTimeout is the maximum run time of the command
+const DefaultTimeout = time.Second * 30
+
+// Hook runs a command on the handler events
+type Hook struct {
+	handler *arp.Handler
+	command string
+	args    []string
+	timeout time.Duration
+	types   map[arp.EventType]bool
+}
+
+// Option configures the Hook
+type Option func(*Hook)
+
+// WithArgs pass args to the command.
+func WithArgs(args ...string) Option {
+	return func(h *Hook) { h.args = args }
+}
+
+// WithTimeout set the maximum run time of the command; the default is DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *Hook) { h.timeout = timeout }
+}
+
+// WithEventTypes run the command for the listed event types only. By default
+// the command runs for arp.EventNewDevice, arp.EventDeviceOnline and
+// arp.EventDeviceOffline.
+func WithEventTypes(types ...arp.EventType) Option {
+	return func(h *Hook) {
+		h.types = make(map[arp.EventType]bool, len(types))
+		for _, t := range types {
+			h.types[t] = true
+		}
+	}
+}
+
+// New return a Hook running command on the events of h.
+func New(h *arp.Handler, command string, opts ...Option) *Hook {
+	hook := &Hook{handler: h, command: command, timeout: DefaultTimeout,
+		types: map[arp.EventType]bool{arp.EventNewDevice: true, arp.EventDeviceOnline: true, arp.EventDeviceOffline: true}}
+	for _, opt := range opts {
+		opt(hook)
+	}
+	return hook
+}
+
+// Run execute the command for the handler events until ctx is done or the
+// handler stops.
+func (h *Hook) Run(ctx context.Context) error {
+	sub := h.handler.Subscribe(QueueSize, arp.DropOldest)
+	defer h.handler.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if !h.types[event.Type] {
+				continue
+			}
+			if err := h.Exec(ctx, event); err != nil {
+				log.WithFields(log.Fields{"command": h.command, "type": event.Type, "mac": event.Entry.MAC.String()}).Error("EXEC command failed ", err)
+			}
+		}
+	}
+}
+
+// Exec run the command for event and wait for it to exit; the error includes
+// the command output.
+func (h *Hook) Exec(ctx context.Context, event arp.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.command, h.args...)
+	cmd.Env = append(os.Environ(), Environ(event)...)
+	cmd.WaitDelay = time.Second // children of a killed command may keep the output open
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
+
+// Environ return the variables set for event in NAME=value form.
+func Environ(event arp.Event) []string {
+	ip := ""
+	if event.Entry.IP != nil {
+		ip = event.Entry.IP.String()
+	}
+	return []string{
+		"EVENT=" + string(event.Type),
+		"MAC=" + event.Entry.MAC.String(),
+		"IP=" + ip,
+		"NAME=" + displayName(event.Entry),
+		"NIC=" + event.NIC,
+	}
+}
+
+// displayName return the most friendly name known for the entry or "".
+func displayName(e arp.Entry) string {
+	for _, name := range []string{e.Name, e.MDNSName, e.Hostname, e.NetBIOSName} {
+		if name != "" {
+			return name
+		}
+	}
+	if e.DHCP != nil {
+		return e.DHCP.Hostname
+	}
+	return ""
+}
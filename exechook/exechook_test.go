@@ -0,0 +1,51 @@
+package exechook
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/irai/arp"
+)
+
+func Test_Exec(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+	out := filepath.Join(t.TempDir(), "env")
+
+	h := New(&arp.Handler{}, sh, WithArgs("-c", `echo "$EVENT $MAC $IP $NAME $NIC" > `+out))
+	event := arp.Event{Type: arp.EventDeviceOnline, Time: time.Now(), NIC: "eth0",
+		Entry: arp.Entry{MAC: net.HardwareAddr{0x02, 0, 0, 0, 0, 1}, IP: net.IPv4(192, 168, 0, 1).To4(), MDNSName: "printer"}}
+	if err := h.Exec(context.Background(), event); err != nil {
+		t.Fatal("exec error ", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(b)); got != "online 02:00:00:00:00:01 192.168.0.1 printer eth0" {
+		t.Errorf("invalid environment %q", got)
+	}
+
+	// the output is returned on failure
+	h = New(&arp.Handler{}, sh, WithArgs("-c", "echo failed; exit 1"))
+	if err := h.Exec(context.Background(), event); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Error("expected error with output ", err)
+	}
+
+	h = New(&arp.Handler{}, sh, WithArgs("-c", "sleep 5"), WithTimeout(time.Millisecond*50))
+	if err := h.Exec(context.Background(), event); err == nil {
+		t.Error("expected timeout error")
+	}
+
+	if h.types[arp.EventDeviceSeen] || !h.types[arp.EventNewDevice] {
+		t.Error("invalid default event types")
+	}
+}
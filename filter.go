@@ -0,0 +1,78 @@
+package arp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/mdlayher/ethernet"
+	"golang.org/x/net/bpf"
+)
+
+// socketFilterer is implemented by the live interface connections that can
+// attach a classic BPF program to the socket.
+type socketFilterer interface {
+	SetBPF(filter []bpf.RawInstruction) error
+}
+
+// WithSocketFilter attach the classic BPF program filter to the live interface
+// socket so the kernel drops frames before they reach ListenAndServe. The
+// program sees the ethernet frame and replaces the default ARP filter; it is
+// attached again when the socket is reopened.
+//
+// NewHandler returns ErrFilterNotSupported if the packet source cannot attach
+// a filter, i.e. a capture file or a PacketConn without a SetBPF method.
+func WithSocketFilter(filter []bpf.RawInstruction) Option {
+	return func(c *Handler) error {
+		if len(filter) == 0 {
+			return fmt.Errorf("%w: empty socket filter", ErrInvalidConfig)
+		}
+		c.socketFilter = filter
+		return nil
+	}
+}
+
+// WithHomeLANFilter attach a socket filter accepting ARP frames from senders
+// in the home LAN and ARP probes with sender IP 0.0.0.0; see HomeLANFilter and
+// WithSocketFilter.
+func WithHomeLANFilter() Option {
+	return func(c *Handler) (err error) {
+		c.socketFilter, err = HomeLANFilter(c.config.HomeLAN)
+		return err
+	}
+}
+
+// HomeLANFilter return a classic BPF program accepting untagged ARP frames
+// whose sender IP is in lan or is 0.0.0.0 (i.e. a probe). RARP and 802.1Q
+// tagged frames are dropped; the linux kernel removes the tag before running
+// the filter so VLAN frames are accepted on linux.
+func HomeLANFilter(lan net.IPNet) ([]bpf.RawInstruction, error) {
+	ip := lan.IP.To4()
+	if ip == nil || len(lan.Mask) != net.IPv4len {
+		return nil, fmt.Errorf("%w: invalid home LAN %s", ErrInvalidConfig, lan.String())
+	}
+	mask := binary.BigEndian.Uint32(lan.Mask)
+
+	return bpf.Assemble([]bpf.Instruction{
+		bpf.LoadAbsolute{Off: 12, Size: 2}, // ethertype
+		bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: uint32(ethernet.EtherTypeARP), SkipTrue: 5},
+		bpf.LoadAbsolute{Off: 28, Size: 4}, // sender IP
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0, SkipTrue: 2},
+		bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: mask},
+		bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: binary.BigEndian.Uint32(ip) & mask, SkipTrue: 1},
+		bpf.RetConstant{Val: 0xffff},
+		bpf.RetConstant{Val: 0},
+	})
+}
+
+// setSocketFilter attach filter to conn or return ErrFilterNotSupported.
+func setSocketFilter(conn PacketConn, filter []bpf.RawInstruction) error {
+	f, ok := conn.(socketFilterer)
+	if !ok {
+		return ErrFilterNotSupported
+	}
+	if err := f.SetBPF(filter); err != nil {
+		return fmt.Errorf("cannot attach socket filter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	marp "github.com/mdlayher/arp"
+	"golang.org/x/net/bpf"
+)
+
+func Test_HomeLANFilter(t *testing.T) {
+	lan := net.IPNet{IP: net.IPv4(192, 168, 0, 0).To4(), Mask: net.CIDRMask(24, 32)}
+	raw, err := HomeLANFilter(lan)
+	if err != nil {
+		t.Fatal("cannot assemble filter ", err)
+	}
+	program, ok := bpf.Disassemble(raw)
+	if !ok {
+		t.Fatal("cannot disassemble filter")
+	}
+	vm, err := bpf.NewVM(program)
+	if err != nil {
+		t.Fatal("invalid filter ", err)
+	}
+
+	rarp := arpFrame(t, marp.OperationRequest, mac1, ip1, mac2, ip2)
+	rarp[12], rarp[13] = 0x80, 0x35
+
+	tests := []struct {
+		name   string
+		frame  []byte
+		accept bool
+	}{
+		{"lan", arpFrame(t, marp.OperationReply, mac1, ip1, mac2, ip2), true},
+		{"probe", arpFrame(t, marp.OperationRequest, mac1, net.IPv4zero.To4(), EthernetBroadcast, ip2), true},
+		{"other lan", arpFrame(t, marp.OperationRequest, mac1, net.IPv4(10, 0, 0, 1).To4(), mac2, ip2), false},
+		{"rarp", rarp, false},
+	}
+	for _, tt := range tests {
+		n, err := vm.Run(tt.frame)
+		if err != nil {
+			t.Fatal(tt.name, err)
+		}
+		if (n > 0) != tt.accept {
+			t.Errorf("%s: invalid filter result %d", tt.name, n)
+		}
+	}
+
+	_, err = NewHandler("eth0", mac3, ip3, ip3, lan, WithPacketConn(newFakeConn()), WithHomeLANFilter())
+	if !errors.Is(err, ErrFilterNotSupported) {
+		t.Error("expected ErrFilterNotSupported ", err)
+	}
+}
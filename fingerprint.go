@@ -0,0 +1,89 @@
+package arp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DeviceClass is the device type guessed from the DHCP fingerprint; see ListenAndServeDHCP.
+type DeviceClass string
+
+// Device classes
+const (
+	DeviceUnknown DeviceClass = ""
+	DeviceIOS     DeviceClass = "ios"
+	DeviceMacOS   DeviceClass = "macos"
+	DeviceAndroid DeviceClass = "android"
+	DeviceWindows DeviceClass = "windows"
+	DeviceLinux   DeviceClass = "linux"
+	DevicePrinter DeviceClass = "printer"
+	DeviceCamera  DeviceClass = "camera"
+)
+
+// fingerprintRule match the DHCP vendor class (option 60) or the parameter
+// request list (option 55) of a device class.
+type fingerprintRule struct {
+	class  DeviceClass
+	vendor string // lower case substring of the vendor class
+	params string // exact parameter request list
+}
+
+// fingerprintRules are checked in order; vendor classes first as they are
+// more specific than the parameter list.
+var fingerprintRules = []fingerprintRule{
+	{class: DevicePrinter, vendor: "jetdirect"},
+	{class: DevicePrinter, vendor: "hewlett-packard"},
+	{class: DevicePrinter, vendor: "canon"},
+	{class: DevicePrinter, vendor: "epson"},
+	{class: DevicePrinter, vendor: "brother"},
+	{class: DevicePrinter, vendor: "xerox"},
+	{class: DeviceCamera, vendor: "hikvision"},
+	{class: DeviceCamera, vendor: "dahua"},
+	{class: DeviceCamera, vendor: "axis"},
+	{class: DeviceCamera, vendor: "reolink"},
+	{class: DeviceAndroid, vendor: "android-dhcp"},
+	{class: DeviceWindows, vendor: "msft"},
+	{class: DeviceLinux, vendor: "udhcp"},
+	{class: DeviceLinux, vendor: "dhcpcd"},
+
+	{class: DeviceIOS, params: "1,121,3,6,15,119,252"},
+	{class: DeviceIOS, params: "1,121,3,6,15,108,114,119,252"},
+	{class: DeviceMacOS, params: "1,121,3,6,15,119,252,95,44,46"},
+	{class: DeviceMacOS, params: "1,121,3,6,15,114,119,252,95,44,46"},
+	{class: DeviceAndroid, params: "1,3,6,15,26,28,51,58,59"},
+	{class: DeviceAndroid, params: "1,3,6,15,26,28,51,58,59,43"},
+	{class: DeviceAndroid, params: "1,3,6,15,26,28,51,58,59,43,114,108"},
+	{class: DeviceWindows, params: "1,3,6,15,31,33,43,44,46,47,119,121,249,252"},
+	{class: DeviceWindows, params: "1,15,3,6,44,46,47,31,33,121,249,43"},
+	{class: DeviceWindows, params: "1,15,3,6,44,46,47,31,33,121,249,43,252"},
+	{class: DeviceLinux, params: "1,28,2,3,15,6,119,12,44,47,26,121,42"},
+	{class: DeviceLinux, params: "1,28,2,121,15,6,12,40,41,42,26,119,3,121,249,33,252,42"},
+}
+
+// dhcpFingerprint return the parameter request list as a comma separated
+// list of option codes, the format used by fingerprint databases.
+func dhcpFingerprint(params []byte) string {
+	var b strings.Builder
+	for i, p := range params {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(int(p)))
+	}
+	return b.String()
+}
+
+// classifyDevice return the device class for the vendor class and fingerprint
+// or DeviceUnknown if there is no matching rule.
+func classifyDevice(vendorClass string, fingerprint string) DeviceClass {
+	vendorClass = strings.ToLower(vendorClass)
+	for _, r := range fingerprintRules {
+		if r.vendor != "" && vendorClass != "" && strings.Contains(vendorClass, r.vendor) {
+			return r.class
+		}
+		if r.params != "" && r.params == fingerprint {
+			return r.class
+		}
+	}
+	return DeviceUnknown
+}
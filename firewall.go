@@ -0,0 +1,99 @@
+package arp
+
+import (
+	"fmt"
+	"net"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FirewallAction is the traffic a hunted device loses; see WithFirewall.
+type FirewallAction int
+
+const (
+	// FirewallDrop drop the traffic from the device, including the traffic to the host
+	FirewallDrop FirewallAction = iota
+
+	// FirewallRestrict drop the traffic the host forwards for the device; the device can still reach the host, i.e. a captive portal
+	FirewallRestrict
+)
+
+func (a FirewallAction) String() string {
+	switch a {
+	case FirewallDrop:
+		return "drop"
+	case FirewallRestrict:
+		return "restrict"
+	}
+	return fmt.Sprintf("FirewallAction(%d)", int(a))
+}
+
+// firewall installs the rules for hunted devices; see WithFirewall.
+type firewall interface {
+	block(mac net.HardwareAddr) error
+	unblock(mac net.HardwareAddr) error
+	close() error // remove every rule
+}
+
+// WithFirewall install a firewall rule when a device is hunted and remove it
+// when the hunt stops, so the traffic redirected to the host is dropped
+// instead of forwarded to the router.
+//
+// The rules match the device source MAC and live in their own nftables table,
+// or iptables chain if nft is not installed, named after the interface; they
+// are removed when the handler stops. Errors installing a rule are logged and
+// do not stop the hunt. It is only supported on linux and requires
+// CAP_NET_ADMIN.
+func WithFirewall(action FirewallAction) Option {
+	return func(c *Handler) error {
+		if action != FirewallDrop && action != FirewallRestrict {
+			return fmt.Errorf("%w: invalid firewall action %s", ErrInvalidConfig, action)
+		}
+		fw, err := newFirewall(c.config.NIC, action)
+		if err != nil {
+			return err
+		}
+		c.firewall = fw
+		return nil
+	}
+}
+
+// firewallBlock install the firewall rule for the hunted mac
+func (c *Handler) firewallBlock(mac net.HardwareAddr) {
+	if c.firewall == nil {
+		return
+	}
+	if err := c.firewall.block(mac); err != nil {
+		c.log().WithFields(log.Fields{"mac": mac.String()}).Error("ARP cannot install firewall rule ", err)
+		return
+	}
+	if c.logArea(LogSpoof) {
+		c.log().WithFields(log.Fields{"mac": mac.String()}).Debug("ARP firewall rule installed")
+	}
+}
+
+// firewallUnblock remove the firewall rule for mac when the hunt stops
+func (c *Handler) firewallUnblock(mac net.HardwareAddr) {
+	if c.firewall == nil {
+		return
+	}
+	if err := c.firewall.unblock(mac); err != nil {
+		c.log().WithFields(log.Fields{"mac": mac.String()}).Error("ARP cannot remove firewall rule ", err)
+		return
+	}
+	if c.logArea(LogSpoof) {
+		c.log().WithFields(log.Fields{"mac": mac.String()}).Debug("ARP firewall rule removed")
+	}
+}
+
+// closeFirewall remove the firewall rules of the handler
+func (c *Handler) closeFirewall() error {
+	if c.firewall == nil {
+		return nil
+	}
+	if err := c.firewall.close(); err != nil {
+		c.log().Error("ARP cannot remove firewall rules ", err)
+		return err
+	}
+	return nil
+}
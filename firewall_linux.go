@@ -0,0 +1,187 @@
+//go:build linux
+// +build linux
+
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// runFunc run the command name with stdin as input
+type runFunc func(stdin string, name string, args ...string) error
+
+func runCommand(stdin string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func newFirewall(nic string, action FirewallAction) (firewall, error) {
+	name := firewallName(nic)
+	if _, err := exec.LookPath("nft"); err == nil {
+		return &nftables{table: name, action: action, run: runCommand}, nil
+	}
+	if _, err := exec.LookPath("iptables"); err == nil {
+		return &iptables{chain: name, action: action, run: runCommand}, nil
+	}
+	return nil, errors.New("firewall requires nft or iptables")
+}
+
+// firewallName return the table or chain name for nic
+func firewallName(nic string) string {
+	return "arp_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, nic)
+}
+
+// nftables keeps the hunted MACs in a set of an inet table; the table is
+// created with the first rule.
+type nftables struct {
+	mutex  sync.Mutex
+	table  string
+	action FirewallAction
+	run    runFunc
+	ready  bool // the table exists
+}
+
+// script return the nft script creating the table; an existing table is replaced.
+func (n *nftables) script() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "add table inet %s\ndelete table inet %s\n", n.table, n.table)
+	fmt.Fprintf(&b, "table inet %s {\n", n.table)
+	b.WriteString("\tset hunted {\n\t\ttype ether_addr\n\t}\n")
+	b.WriteString("\tchain forward {\n\t\ttype filter hook forward priority 0; policy accept;\n\t\tether saddr @hunted drop\n\t}\n")
+	if n.action == FirewallDrop {
+		b.WriteString("\tchain input {\n\t\ttype filter hook input priority 0; policy accept;\n\t\tether saddr @hunted drop\n\t}\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func (n *nftables) block(mac net.HardwareAddr) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if !n.ready {
+		if err := n.run(n.script(), "nft", "-f", "-"); err != nil {
+			return err
+		}
+		n.ready = true
+	}
+	return n.run("", "nft", "add", "element", "inet", n.table, "hunted", "{ "+mac.String()+" }")
+}
+
+func (n *nftables) unblock(mac net.HardwareAddr) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if !n.ready {
+		return nil
+	}
+	return n.run("", "nft", "delete", "element", "inet", n.table, "hunted", "{ "+mac.String()+" }")
+}
+
+func (n *nftables) close() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if !n.ready {
+		return nil
+	}
+	n.ready = false
+	return n.run("", "nft", "delete", "table", "inet", n.table)
+}
+
+// iptables keeps a rule per hunted MAC in a chain jumped to from FORWARD, and
+// INPUT for FirewallDrop; the chain is created with the first rule.
+type iptables struct {
+	mutex  sync.Mutex
+	chain  string
+	action FirewallAction
+	run    runFunc
+	ready  bool // the chain exists
+}
+
+// hooks return the built-in chains jumping to the chain
+func (t *iptables) hooks() []string {
+	if t.action == FirewallDrop {
+		return []string{"FORWARD", "INPUT"}
+	}
+	return []string{"FORWARD"}
+}
+
+func (t *iptables) setup() error {
+	t.run("", "iptables", "-N", t.chain) // fails if the chain exists
+	if err := t.run("", "iptables", "-F", t.chain); err != nil {
+		return err
+	}
+	for _, hook := range t.hooks() {
+		if t.run("", "iptables", "-C", hook, "-j", t.chain) == nil {
+			continue // jump left by a previous run that did not close
+		}
+		if err := t.run("", "iptables", "-I", hook, "-j", t.chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxHookRules limit the jumps deleted from a built-in chain on close
+const maxHookRules = 16
+
+func (t *iptables) rule(op string, mac net.HardwareAddr) []string {
+	return []string{op, t.chain, "-m", "mac", "--mac-source", mac.String(), "-j", "DROP"}
+}
+
+func (t *iptables) block(mac net.HardwareAddr) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.ready {
+		if err := t.setup(); err != nil {
+			return err
+		}
+		t.ready = true
+	}
+	return t.run("", "iptables", t.rule("-A", mac)...)
+}
+
+func (t *iptables) unblock(mac net.HardwareAddr) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.ready {
+		return nil
+	}
+	return t.run("", "iptables", t.rule("-D", mac)...)
+}
+
+func (t *iptables) close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.ready {
+		return nil
+	}
+	t.ready = false
+	var errs []error
+	for _, hook := range t.hooks() {
+		// delete every jump to the chain; -C fails when there is none left
+		for i := 0; i < maxHookRules && t.run("", "iptables", "-C", hook, "-j", t.chain) == nil; i++ {
+			if err := t.run("", "iptables", "-D", hook, "-j", t.chain); err != nil {
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+	errs = append(errs, t.run("", "iptables", "-F", t.chain))
+	errs = append(errs, t.run("", "iptables", "-X", t.chain))
+	return errors.Join(errs...)
+}
@@ -0,0 +1,69 @@
+package arp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_FirewallCommands(t *testing.T) {
+	var cmds []string
+	run := func(stdin string, name string, args ...string) error {
+		cmds = append(cmds, name+" "+strings.Join(args, " "))
+		if stdin != "" && !strings.Contains(stdin, "table inet arp_eth0_10 {") {
+			t.Errorf("invalid nft script %s", stdin)
+		}
+		return nil
+	}
+
+	if name := firewallName("eth0.10"); name != "arp_eth0_10" {
+		t.Fatalf("invalid firewall name %s", name)
+	}
+	n := &nftables{table: "arp_eth0_10", action: FirewallRestrict, run: run}
+	if strings.Contains(n.script(), "hook input") {
+		t.Error("restrict must not drop traffic to the host")
+	}
+	n.unblock(mac1) // no table yet
+	n.block(mac1)
+	n.unblock(mac1)
+	n.close()
+	want := []string{
+		"nft -f -",
+		"nft add element inet arp_eth0_10 hunted { " + mac1.String() + " }",
+		"nft delete element inet arp_eth0_10 hunted { " + mac1.String() + " }",
+		"nft delete table inet arp_eth0_10",
+	}
+	if strings.Join(cmds, "\n") != strings.Join(want, "\n") {
+		t.Errorf("invalid nft commands\n%s", strings.Join(cmds, "\n"))
+	}
+
+	// iptables jumps from the built-in chains; -C fails when there is none
+	cmds = nil
+	jumps := map[string]int{"INPUT": 1} // left by a previous run
+	run = func(stdin string, name string, args ...string) error {
+		cmds = append(cmds, name+" "+strings.Join(args, " "))
+		if len(args) == 4 && args[2] == "-j" {
+			switch args[0] {
+			case "-C":
+				if jumps[args[1]] == 0 {
+					return errors.New("bad rule")
+				}
+			case "-I":
+				jumps[args[1]]++
+			case "-D":
+				jumps[args[1]]--
+			}
+		}
+		return nil
+	}
+	ipt := &iptables{chain: "arp_eth0", action: FirewallDrop, run: run}
+	ipt.block(mac1)
+	if jumps["FORWARD"] != 1 || jumps["INPUT"] != 1 || cmds[len(cmds)-1] != "iptables -A arp_eth0 -m mac --mac-source "+mac1.String()+" -j DROP" {
+		t.Errorf("invalid iptables setup %v\n%s", jumps, strings.Join(cmds, "\n"))
+	}
+	jumps["FORWARD"]++ // duplicate inserted by another process
+	ipt.close()
+	if jumps["FORWARD"] != 0 || jumps["INPUT"] != 0 || cmds[len(cmds)-1] != "iptables -X arp_eth0" {
+		t.Errorf("invalid iptables teardown %v\n%s", jumps, strings.Join(cmds, "\n"))
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package arp
+
+import "errors"
+
+func newFirewall(nic string, action FirewallAction) (firewall, error) {
+	return nil, errors.New("firewall is not supported on this platform")
+}
@@ -0,0 +1,90 @@
+package arp
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFirewall records the blocked MACs
+type fakeFirewall struct {
+	mutex   sync.Mutex
+	blocked map[string]bool
+	closed  bool
+}
+
+func (f *fakeFirewall) block(mac net.HardwareAddr) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.blocked[string(mac)] = true
+	return nil
+}
+
+func (f *fakeFirewall) unblock(mac net.HardwareAddr) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.blocked, string(mac))
+	return nil
+}
+
+func (f *fakeFirewall) close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *fakeFirewall) isBlocked(mac net.HardwareAddr) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.blocked[string(mac)]
+}
+
+func Test_Firewall(t *testing.T) {
+	if _, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()), WithFirewall(FirewallAction(9))); err == nil {
+		t.Error("invalid firewall action should fail")
+	}
+
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	fw := &fakeFirewall{blocked: make(map[string]bool)}
+	h.firewall = fw
+	s := h.Subscribe(16, DropNewest)
+
+	h.mutex.Lock()
+	h.arpTableAppendLocked(StateNormal, mac1, ip1).Online = true
+	h.mutex.Unlock()
+
+	if err := h.StartHunt(mac1); err != nil {
+		t.Fatal("StartHunt error ", err)
+	}
+	if !fw.isBlocked(mac1) {
+		t.Error("hunted device not blocked")
+	}
+	if err := h.StopHunt(mac1); err != nil {
+		t.Fatal("StopHunt error ", err)
+	}
+	for _, want := range []EventType{EventHuntStarted, EventHuntStopped} {
+		select {
+		case e := <-s.C:
+			if e.Type != want {
+				t.Errorf("invalid event %s want %s", e.Type, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", want)
+		}
+	}
+	if fw.isBlocked(mac1) {
+		t.Error("device still blocked after the hunt")
+	}
+
+	h.Stop()
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+	if !fw.closed {
+		t.Error("firewall rules not removed on stop")
+	}
+}
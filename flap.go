@@ -0,0 +1,135 @@
+package arp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// AlertMACFlap when a MAC changes IP more than the flap threshold in the window; see WithFlapDetection
+const AlertMACFlap AlertType = "macflap"
+
+// flapWindow is the window of WithFlapDetection
+var flapWindow = time.Minute
+
+type flapDetector struct {
+	changes int // alert threshold
+	mutex   sync.Mutex
+	ips     map[string]*flapRecord // owner MAC by IP
+	macs    map[string]*flapRecord // owner IP by MAC
+}
+
+// flapRecord counts the owner changes of an address in the window
+type flapRecord struct {
+	start   time.Time
+	owner   string   // current owner
+	owners  []string // owners seen in the window
+	changes int
+	alerted bool
+}
+
+// WithFlapDetection send EventFlapping when an IP changes MAC or a MAC
+// changes IP more than changes times per minute, i.e. two hosts configured
+// with the same IP or an attacker rotating bindings. The event Alert has type
+// AlertBindingFlap with the MACs seen for the IP or AlertMACFlap with the IPs
+// seen for the MAC; each alert is sent once per minute.
+func WithFlapDetection(changes int) Option {
+	return func(c *Handler) error {
+		if changes <= 0 {
+			return errors.New("invalid flap detection threshold")
+		}
+		c.flap = &flapDetector{changes: changes, ips: make(map[string]*flapRecord), macs: make(map[string]*flapRecord)}
+		return nil
+	}
+}
+
+// detectFlap count the binding changes of the packet sender.
+func (c *Handler) detectFlap(packet *marp.Packet) {
+	d := c.flap
+	if packet.SenderIP.Equal(net.IPv4zero) || bytes.Equal(packet.SenderHardwareAddr, c.config.HostMAC) {
+		return
+	}
+	c.mutex.RLock()
+	e := c.findMACLocked(packet.SenderHardwareAddr)
+	virtual := e != nil && e.State == StateVirtualHost
+	c.mutex.RUnlock()
+	if virtual {
+		return
+	}
+
+	now := time.Now()
+	ip := packet.SenderIP.To4()
+	var alerts []SpoofAlert
+
+	d.mutex.Lock()
+	if r := d.record(d.ips, string(ip), string(packet.SenderHardwareAddr), now); r != nil {
+		macs := make([]net.HardwareAddr, len(r.owners))
+		for i := range r.owners {
+			macs[i] = net.HardwareAddr(r.owners[i])
+		}
+		alerts = append(alerts, SpoofAlert{Type: AlertBindingFlap, Severity: SeverityMedium, MAC: dupMAC(packet.SenderHardwareAddr), IP: dupIP(ip),
+			MACs: macs, Count: r.changes, Window: flapWindow})
+	}
+	if r := d.record(d.macs, string(packet.SenderHardwareAddr), string(ip), now); r != nil {
+		ips := make([]net.IP, len(r.owners))
+		for i := range r.owners {
+			ips[i] = net.IP(r.owners[i])
+		}
+		alerts = append(alerts, SpoofAlert{Type: AlertMACFlap, Severity: SeverityMedium, MAC: dupMAC(packet.SenderHardwareAddr), IP: dupIP(ip),
+			MACs: []net.HardwareAddr{dupMAC(packet.SenderHardwareAddr)}, IPs: ips, Count: r.changes, Window: flapWindow})
+	}
+	d.mutex.Unlock()
+
+	for i := range alerts {
+		alert := &alerts[i]
+		c.log().WithFields(log.Fields{"type": alert.Type, "mac": alert.MAC.String(), "ip": alert.IP, "count": alert.Count}).Warn("ARP binding flapping")
+		c.publish(Event{Type: EventFlapping, Time: now, NIC: c.config.NIC, Entry: Entry{MAC: alert.MAC, IP: alert.IP}, Alert: alert})
+	}
+}
+
+// record set the owner of key and return the record if the changes exceed
+// the threshold for the first time in the window.
+func (d *flapDetector) record(records map[string]*flapRecord, key string, owner string, now time.Time) *flapRecord {
+	r, ok := records[key]
+	if !ok {
+		if len(records) >= spoofDetectorMaxRecords {
+			for k, r := range records {
+				if now.Sub(r.start) > flapWindow {
+					delete(records, k)
+				}
+			}
+		}
+		records[key] = &flapRecord{start: now, owner: owner, owners: []string{owner}}
+		return nil
+	}
+	if now.Sub(r.start) > flapWindow {
+		r.start, r.changes, r.alerted, r.owners = now, 0, false, []string{r.owner}
+	}
+	if r.owner == owner {
+		return nil
+	}
+	r.changes++
+	r.owner = owner
+	if !containsString(r.owners, owner) {
+		r.owners = append(r.owners, owner)
+	}
+	if r.alerted || r.changes <= d.changes {
+		return nil
+	}
+	r.alerted = true
+	return r
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
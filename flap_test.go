@@ -0,0 +1,51 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_FlapDetection(t *testing.T) {
+	if _, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()), WithFlapDetection(0)); err == nil {
+		t.Error("zero threshold should fail")
+	}
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()), WithFlapDetection(2))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropNewest)
+
+	// ip1 owner changes 3 times
+	for _, mac := range []net.HardwareAddr{mac1, mac2, mac1, mac2} {
+		h.detectFlap(newTestPacket(marp.OperationRequest, mac, ip1, EthernetBroadcast, ip3))
+	}
+	// mac1 IP changes 3 times
+	for _, ip := range []net.IP{ip2, ip1, ip2} {
+		h.detectFlap(newTestPacket(marp.OperationRequest, mac1, ip, EthernetBroadcast, ip3))
+	}
+
+	var alerts []*SpoofAlert
+	for done := false; !done; {
+		select {
+		case e := <-s.C:
+			if e.Type == EventFlapping {
+				alerts = append(alerts, e.Alert)
+			}
+		case <-time.After(time.Millisecond * 100):
+			done = true
+		}
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("invalid flapping alerts %+v", alerts)
+	}
+	if a := alerts[0]; a.Type != AlertBindingFlap || !a.IP.Equal(ip1) || a.Count != 3 || len(a.MACs) != 2 {
+		t.Errorf("invalid IP flap alert %+v", a)
+	}
+	if a := alerts[1]; a.Type != AlertMACFlap || a.MAC.String() != mac1.String() || a.Count != 3 || len(a.IPs) != 2 || !a.IPs[0].Equal(ip1) {
+		t.Errorf("invalid MAC flap alert %+v", a)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package arp
+
+import (
+	"errors"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/route"
+)
+
+// defaultGateway read the default gateway of the interface from the routing table
+func defaultGateway(ifi *net.Interface) (net.IP, error) {
+	rib, err := route.FetchRIB(syscall.AF_INET, route.RIBTypeRoute, 0)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := route.ParseRIB(route.RIBTypeRoute, rib)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, msg := range msgs {
+		m, ok := msg.(*route.RouteMessage)
+		if !ok || m.Index != ifi.Index || m.Flags&syscall.RTF_GATEWAY == 0 || len(m.Addrs) <= syscall.RTAX_GATEWAY {
+			continue
+		}
+		dst, ok := m.Addrs[syscall.RTAX_DST].(*route.Inet4Addr)
+		if !ok || dst.IP != [4]byte{} {
+			continue
+		}
+		if gw, ok := m.Addrs[syscall.RTAX_GATEWAY].(*route.Inet4Addr); ok {
+			return net.IPv4(gw.IP[0], gw.IP[1], gw.IP[2], gw.IP[3]).To4(), nil
+		}
+	}
+	return nil, errors.New("default route not found")
+}
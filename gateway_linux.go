@@ -0,0 +1,60 @@
+package arp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultGateway read the default gateway of the interface from the linux route file
+func defaultGateway(ifi *net.Interface) (net.IP, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseProcNetRoute(file, ifi.Name)
+}
+
+// parseProcNetRoute return the default gateway of the interface with the lowest metric
+//
+// file: /proc/net/route file:
+//
+//	Iface   Destination Gateway     Flags   RefCnt  Use Metric  Mask
+//	eth0    00000000    C900A8C0    0003    0   0   100 00000000    0   00
+func parseProcNetRoute(r io.Reader, nic string) (gw net.IP, err error) {
+	metric := uint64(0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) < 7 || tokens[0] != nic || tokens[1] != "00000000" {
+			continue // skip header, other interfaces and non default routes
+		}
+
+		d, err := strconv.ParseUint(tokens[2], 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		m, err := strconv.ParseUint(tokens[6], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		if gw != nil && m >= metric {
+			continue
+		}
+		gw, metric = make(net.IP, 4), m
+		binary.LittleEndian.PutUint32(gw, uint32(d))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if gw == nil {
+		return nil, errors.New("default route not found")
+	}
+	return gw, nil
+}
@@ -0,0 +1,41 @@
+package arp
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func Test_ParseProcNetRoute(t *testing.T) {
+	const route = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+wlan0	00000000	0101A8C0	0003	0	0	600	00000000	0	0	0
+eth0	00000000	FE00A8C0	0003	0	0	200	00000000	0	0	0
+eth0	00000000	0100A8C0	0003	0	0	100	00000000	0	0	0
+eth0	0000A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+`
+	gw, err := parseProcNetRoute(strings.NewReader(route), "eth0")
+	if err != nil || !gw.Equal(net.IPv4(192, 168, 0, 1)) {
+		t.Errorf("invalid gateway %s error %v", gw, err)
+	}
+	gw, err = parseProcNetRoute(strings.NewReader(route), "wlan0")
+	if err != nil || !gw.Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("invalid gateway %s error %v", gw, err)
+	}
+	if _, err = parseProcNetRoute(strings.NewReader(route), "eth1"); err == nil {
+		t.Error("expected error for interface without default route")
+	}
+}
+
+func Test_InterfaceIPv4(t *testing.T) {
+	ifi, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("no loopback interface ", err)
+	}
+	ip, lan, err := interfaceIPv4(ifi)
+	if err != nil {
+		t.Fatal("interfaceIPv4 error ", err)
+	}
+	if !ip.Equal(net.IPv4(127, 0, 0, 1)) || lan.String() != "127.0.0.0/16" {
+		t.Errorf("invalid config ip=%s lan=%s", ip, lan.String())
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package arp
+
+import (
+	"errors"
+	"net"
+)
+
+// defaultGateway is not supported on this platform; use NewHandler with the router IP
+func defaultGateway(ifi *net.Interface) (net.IP, error) {
+	return nil, errors.New("default gateway detection not supported on this platform")
+}
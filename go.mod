@@ -1,9 +1,30 @@
 module github.com/irai/arp
 
+go 1.20
+
 require (
 	github.com/mdlayher/arp v0.0.0-20181025151936-a1263dc4682b
-	github.com/mdlayher/ethernet v0.0.0-20181025151932-d5c0834fe478 // indirect
-	github.com/mdlayher/raw v0.0.0-20181016155347-fa5ef3332ca9 // indirect
+	github.com/mdlayher/ethernet v0.0.0-20181025151932-d5c0834fe478
+	github.com/mdlayher/raw v0.0.0-20181016155347-fa5ef3332ca9
+	github.com/prometheus/client_golang v1.20.5
 	github.com/sirupsen/logrus v1.2.0
-	golang.org/x/net v0.0.0-20181220203305-927f97764cc3 // indirect
+	go.etcd.io/bbolt v1.3.9
+	golang.org/x/net v0.26.0
+	golang.org/x/sys v0.22.0
+	google.golang.org/grpc v1.64.1
+	google.golang.org/protobuf v1.34.2
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	golang.org/x/crypto v0.24.0 // indirect
+	golang.org/x/term v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
 )
@@ -1,7 +1,9 @@
 package arp
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
 
@@ -10,14 +12,21 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// goroutinePool runs the goroutines of a handler under a single context:
+// Start creates the context, Stop cancels it and Wait returns when all
+// goroutines have exited with their errors. A goroutine that fails cancels
+// the context so the other goroutines exit too.
 type goroutinePool struct {
-	// goroutines should wait on StopChannel with "<- StopChannel"
-	StopChannel    chan struct{}
-	stoppedChannel chan *goroutine
-	n              int32 // atomic value
-	stopping       int32 // atomic value
-	name           string
-	mutex          sync.Mutex
+	// goroutines should wait on StopChannel with "<- StopChannel"; it is the
+	// pool context Done channel
+	StopChannel <-chan struct{}
+	ctx         context.Context
+	cancel      context.CancelFunc
+	n           int32 // atomic value; changed with mutex locked
+	name        string
+	mutex       sync.Mutex
+	idle        chan struct{} // closed when n drops to zero
+	errs        []error
 }
 
 type goroutine struct {
@@ -27,11 +36,14 @@ type goroutine struct {
 
 // GoroutinePool tracks background goroutines and enable termination.
 //
+// Call Begin before the go statement, or use Go, so Wait never misses a
+// goroutine that has not started yet.
+//
 // Usage:
 //
-// go {
-//     h := GoroutinePool.Begin("name")
-//     defer h.End()}
+// h := GoroutinePool.Begin("name")
+// go func() {
+//     defer h.End()
 //
 //     for {
 //      select {
@@ -42,18 +54,27 @@ type goroutine struct {
 //  }()
 //
 // GoroutinePool.Stop()
-var GoroutinePool = &goroutinePool{name: "default", stopping: 0, StopChannel: make(chan struct{}), stoppedChannel: make(chan *goroutine)}
+var GoroutinePool = (&goroutinePool{name: "default"}).Start(context.Background())
 
 // new create a new go routine pool
 // do want to export this yet
 func (h *goroutinePool) new(name string) (ret *goroutinePool) {
-	ret = &goroutinePool{}
-	ret.name = name
-	ret.stopping = 0
-	ret.StopChannel = make(chan struct{})
-	ret.stoppedChannel = make(chan *goroutine)
+	ret = &goroutinePool{name: name}
+	return ret.Start(context.Background())
+}
+
+// Start derive the pool context from parent; the pool stops when parent is done.
+func (h *goroutinePool) Start(parent context.Context) *goroutinePool {
+	h.ctx, h.cancel = context.WithCancel(parent)
+	h.StopChannel = h.ctx.Done()
+	h.idle = make(chan struct{})
+	close(h.idle)
+	return h
+}
 
-	return ret
+// Context return the pool context; it is done when the pool is stopping.
+func (h *goroutinePool) Context() context.Context {
+	return h.ctx
 }
 
 // Begin records the begining of a new goroutine in the pool.
@@ -61,58 +82,106 @@ func (h *goroutinePool) new(name string) (ret *goroutinePool) {
 // server - set to true if this is a background goroutine that should never end
 func (h *goroutinePool) Begin(name string) *goroutine {
 	g := goroutine{name: name, pool: h}
-	atomic.AddInt32(&h.n, 1)
+	h.mutex.Lock()
+	if atomic.AddInt32(&h.n, 1) == 1 {
+		h.idle = make(chan struct{})
+	}
+	h.mutex.Unlock()
 	if LogAll {
 		log.Debugf("%s goroutine started", g.name)
 	}
 	return &g
 }
 
+// Go run f in a new goroutine of the pool; an error is returned by Wait and
+// stops the pool.
+func (h *goroutinePool) Go(name string, f func(ctx context.Context) error) {
+	g := h.Begin(name)
+	go func() {
+		defer g.End()
+		g.Fail(f(h.ctx))
+	}()
+}
+
 func (h *goroutinePool) Stopping() bool {
-	if atomic.LoadInt32(&h.stopping) != 0 {
-		return true
-	}
-	return false
+	return h.ctx.Err() != nil
 }
 
 func (g *goroutine) End() {
-	atomic.AddInt32(&g.pool.n, -1)
-	stopping := atomic.LoadInt32(&g.pool.stopping)
+	h := g.pool
+	h.mutex.Lock()
+	n := atomic.AddInt32(&h.n, -1)
+	if n == 0 {
+		close(h.idle)
+	}
+	h.mutex.Unlock()
 	if LogAll {
-		log.Debugf("%s goroutine finished - remaining %d", g.name, atomic.LoadInt32(&g.pool.n))
+		log.Debugf("%s goroutine finished - remaining %d", g.name, n)
 	}
-	if stopping != 0 {
-		g.pool.stoppedChannel <- g
+}
+
+// Fail record err for Wait and stop the pool; it does nothing if err is nil.
+func (g *goroutine) Fail(err error) {
+	if err == nil {
+		return
 	}
+	h := g.pool
+	h.mutex.Lock()
+	h.errs = append(h.errs, fmt.Errorf("%s: %w", g.name, err))
+	h.mutex.Unlock()
+	log.Errorf("%s goroutine failed: %s", g.name, err)
+	h.cancel()
 }
 
-// Stop send a channel msg to stop running goroutines
-func (h *goroutinePool) Stop() error {
-	// closing stopChannel will cause all waiting goroutines to exit
-	atomic.StoreInt32(&h.stopping, 1)
-	close(h.StopChannel)
+// signal cancel the pool context; it can be called more than once.
+func (h *goroutinePool) signal() {
+	h.cancel()
+}
 
+// Wait return when the pool is stopping and all goroutines have finished or
+// when ctx is done. It returns the goroutine errors joined or ctx.Err().
+func (h *goroutinePool) Wait(ctx context.Context) error {
+	select {
+	case <-h.ctx.Done():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 	for {
-		n := atomic.LoadInt32(&h.n)
-		if n <= 0 {
-			return nil
+		h.mutex.Lock()
+		if atomic.LoadInt32(&h.n) == 0 {
+			err := errors.Join(h.errs...)
+			h.mutex.Unlock()
+			return err
 		}
+		idle := h.idle
+		h.mutex.Unlock()
 
 		select {
-		// wait for n goroutines to finish
-		case <-h.stoppedChannel:
-
-		case <-time.After(5 * time.Second):
+		case <-idle: // a goroutine may Begin after the last End; check again
+		case <-ctx.Done():
 			log.Errorf("%s stop timed out", h.name)
+			return ctx.Err()
+		}
+	}
+}
+
+// Stop cancel the pool context and wait up to 5 seconds for the goroutines to
+// exit; it returns the goroutine errors joined or a timeout error.
+func (h *goroutinePool) Stop() error {
+	h.signal()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.Wait(ctx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
 			return errors.New("timeout")
 		}
+		return err
 	}
+	return nil
 }
 
 // Stopping is true if the pool is attempting to stop all goroutines.
 func (g *goroutine) Stopping() bool {
-	if stopping := atomic.LoadInt32(&g.pool.stopping); stopping != 0 {
-		return true
-	}
-	return false
+	return g.pool.Stopping()
 }
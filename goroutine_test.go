@@ -0,0 +1,90 @@
+package arp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_GoroutinePoolFail(t *testing.T) {
+	pool := GoroutinePool.new("pooltest")
+
+	errFail := errors.New("fail")
+	pool.Go("loop", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	pool.Go("fail", func(ctx context.Context) error { return errFail })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.Wait(ctx); !errors.Is(err, errFail) {
+		t.Fatal("expected goroutine error got ", err)
+	}
+	if !pool.Stopping() {
+		t.Error("expected pool stopping")
+	}
+	if err := pool.Stop(); !errors.Is(err, errFail) {
+		t.Error("expected goroutine error in Stop got ", err)
+	}
+}
+
+func Test_HandlerContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(newFakeConn()), WithContext(ctx))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		h.ListenAndServe(0)
+		close(done)
+	}()
+	time.Sleep(time.Millisecond * 10)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ListenAndServe did not return after the context was cancelled")
+	}
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), time.Second)
+	defer waitCancel()
+	if err := h.Wait(waitCtx); err != nil {
+		t.Fatal("expected clean exit got ", err)
+	}
+	if err := h.Stop(); err != nil {
+		t.Error("Stop error ", err)
+	}
+}
+
+func Test_HandlerGoroutinesTracked(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+
+	// the goroutines are in the pool before they are scheduled
+	h.AddNotificationChannel(make(chan Entry, 16))
+	h.IPChanged(mac1, ip1)
+	h.FakeIPConflict(mac1, ip1)
+	if n := h.Stats().Goroutines; n != 4 {
+		t.Error("expected 4 goroutines got ", n)
+	}
+
+	// sleeping goroutines exit when the handler stops
+	start := time.Now()
+	if err := h.Stop(); err != nil {
+		t.Fatal("Stop error ", err)
+	}
+	if d := time.Since(start); d > time.Millisecond*500 {
+		t.Error("Stop waited for sleeping goroutines ", d)
+	}
+	if n := h.Stats().Goroutines; n != 0 {
+		t.Error("expected no goroutines got ", n)
+	}
+}
@@ -0,0 +1,259 @@
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// group is a named set of MACs; see CreateGroup
+type group struct {
+	members []net.HardwareAddr
+	online  bool // at least one member is online
+}
+
+// errGroupNotFound return ErrNotFound for the group name
+func errGroupNotFound(name string) error {
+	return fmt.Errorf("group %q %w", name, ErrNotFound)
+}
+
+// CreateGroup create an empty group of devices, i.e. the devices of a child.
+//
+// EventGroupOnline is sent when the first device of the group goes online and
+// EventGroupOffline when the last online device goes offline; Event.Group holds
+// the group name and Event.Entry the device.
+func (c *Handler) CreateGroup(name string) error {
+	if name == "" {
+		return errors.New("empty group name")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.groups[name] != nil {
+		return fmt.Errorf("group %q already exists", name)
+	}
+	if c.groups == nil {
+		c.groups = make(map[string]*group)
+	}
+	c.groups[name] = &group{}
+	return nil
+}
+
+// DeleteGroup delete the group; the devices are not changed.
+func (c *Handler) DeleteGroup(name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.groups[name] == nil {
+		return errGroupNotFound(name)
+	}
+	delete(c.groups, name)
+	return nil
+}
+
+// AddToGroup add mac to the group; the device does not need to be in the table.
+func (c *Handler) AddToGroup(name string, mac net.HardwareAddr) error {
+	if err := checkMAC(mac); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	g := c.groups[name]
+	if g == nil {
+		return errGroupNotFound(name)
+	}
+	if g.index(mac) < 0 {
+		g.members = append(g.members, dupMAC(mac))
+		g.online = c.groupOnlineLocked(g)
+	}
+	return nil
+}
+
+// RemoveFromGroup remove mac from the group.
+func (c *Handler) RemoveFromGroup(name string, mac net.HardwareAddr) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	g := c.groups[name]
+	if g == nil {
+		return errGroupNotFound(name)
+	}
+	i := g.index(mac)
+	if i < 0 {
+		return fmt.Errorf("mac %s not in group %q: %w", mac, name, ErrNotFound)
+	}
+	g.members = append(g.members[:i:i], g.members[i+1:]...) // copy; GroupMembers may hold the previous slice
+	g.online = c.groupOnlineLocked(g)
+	return nil
+}
+
+// Groups return the group names in alphabetical order.
+func (c *Handler) Groups() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	names := make([]string, 0, len(c.groups))
+	for name := range c.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GroupMembers return the MACs in the group; do not modify.
+func (c *Handler) GroupMembers(name string) ([]net.HardwareAddr, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	g := c.groups[name]
+	if g == nil {
+		return nil, errGroupNotFound(name)
+	}
+	return g.members, nil
+}
+
+// GroupOnline return true if a device of the group is online.
+func (c *Handler) GroupOnline(name string) (bool, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	g := c.groups[name]
+	if g == nil {
+		return false, errGroupNotFound(name)
+	}
+	return g.online, nil
+}
+
+// HuntGroup hunt every device of the group in the table; see StartHunt.
+// Devices not in the table or already hunted are skipped; the other errors are
+// joined.
+func (c *Handler) HuntGroup(name string) error {
+	members, err := c.GroupMembers(name)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, mac := range members {
+		entry := c.FindMAC(mac)
+		if entry == nil {
+			continue
+		}
+		c.mutex.RLock()
+		state := entry.State
+		c.mutex.RUnlock()
+		if state == StateHunt {
+			continue
+		}
+		if err := c.StartHunt(mac); err != nil && !errors.Is(err, ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StopHuntGroup stop hunting the devices of the group; see StopHunt.
+func (c *Handler) StopHuntGroup(name string) error {
+	members, err := c.GroupMembers(name)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, mac := range members {
+		entry := c.FindMAC(mac)
+		if entry == nil {
+			continue
+		}
+		c.mutex.RLock()
+		state := entry.State
+		c.mutex.RUnlock()
+		if state != StateHunt {
+			continue
+		}
+		if err := c.StopHunt(mac); err != nil && !errors.Is(err, ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ProbeGroup send an ARP request to every device of the group in the table
+// so the online state is refreshed straight away.
+func (c *Handler) ProbeGroup(name string) error {
+	members, err := c.GroupMembers(name)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, mac := range members {
+		entry := c.FindMAC(mac)
+		if entry == nil {
+			continue
+		}
+		c.mutex.RLock()
+		ip := entry.IP
+		c.mutex.RUnlock()
+		if ip.Equal(net.IPv4zero) {
+			continue // IPv6 only
+		}
+		if err := c.request(c.config.HostMAC, c.config.HostIP, mac, ip); err != nil {
+			errs = append(errs, fmt.Errorf("mac %s: %w", mac, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// index return the position of mac in the group or -1
+func (g *group) index(mac net.HardwareAddr) int {
+	for i := range g.members {
+		if string(g.members[i]) == string(mac) {
+			return i
+		}
+	}
+	return -1
+}
+
+// groupOnlineLocked return true if a member of g is online
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) groupOnlineLocked(g *group) bool {
+	for _, mac := range g.members {
+		if e := c.findMACLocked(mac); e != nil && e.Online {
+			return true
+		}
+	}
+	return false
+}
+
+// updateGroups send the group events when the device in event changes the
+// online state of its groups.
+func (c *Handler) updateGroups(event Event) {
+	switch event.Type {
+	case EventNewDevice, EventDeviceOnline, EventDeviceOffline, EventDeviceEvicted:
+	default:
+		return
+	}
+
+	var events []Event
+	c.mutex.Lock()
+	for name, g := range c.groups {
+		if g.index(event.Entry.MAC) < 0 {
+			continue
+		}
+		online := c.groupOnlineLocked(g)
+		if online == g.online {
+			continue
+		}
+		g.online = online
+		e := Event{Type: EventGroupOffline, Time: time.Now(), NIC: c.config.NIC, Group: name, Entry: event.Entry}
+		if online {
+			e.Type = EventGroupOnline
+		}
+		events = append(events, e)
+	}
+	c.mutex.Unlock()
+
+	for _, e := range events {
+		if c.logArea(LogTable) {
+			c.log().WithFields(log.Fields{"group": e.Group, "mac": e.Entry.MAC.String()}).Debugf("ARP group %s", e.Type)
+		}
+		c.publish(e)
+	}
+}
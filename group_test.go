@@ -0,0 +1,74 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_Group(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropOldest)
+
+	if err := h.AddToGroup("kids", mac1); !errors.Is(err, ErrNotFound) {
+		t.Error("expected ErrNotFound for missing group ", err)
+	}
+	if err := h.CreateGroup("kids"); err != nil {
+		t.Fatal("CreateGroup error ", err)
+	}
+	h.AddToGroup("kids", mac1)
+	h.AddToGroup("kids", mac2)
+
+	groupEvent := func() Event {
+		t.Helper()
+		for {
+			select {
+			case e := <-s.C:
+				if e.Type == EventGroupOnline || e.Type == EventGroupOffline {
+					return e
+				}
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for group event")
+			}
+		}
+	}
+
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip3))
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac2, ip2, EthernetBroadcast, ip3))
+	if e := groupEvent(); e.Type != EventGroupOnline || e.Group != "kids" || e.Entry.MAC.String() != mac1.String() {
+		t.Errorf("invalid group event %+v", e)
+	}
+
+	if err := h.ProbeGroup("kids"); err != nil {
+		t.Fatal("ProbeGroup error ", err)
+	}
+	for _, want := range []net.IP{ip1, ip2} {
+		if p := <-conn.out; !p.TargetIP.Equal(want) {
+			t.Error("invalid probe ", p.TargetIP, want)
+		}
+	}
+
+	// the group goes offline with the last device
+	for _, mac := range []net.HardwareAddr{mac1, mac2} {
+		h.mutex.Lock()
+		e := h.findMACLocked(mac)
+		e.Online = false
+		local := *e
+		h.mutex.Unlock()
+		h.notify(EventDeviceOffline, local, local)
+	}
+	if e := groupEvent(); e.Type != EventGroupOffline || e.Entry.MAC.String() != mac2.String() {
+		t.Errorf("invalid group event %+v", e)
+	}
+	if online, _ := h.GroupOnline("kids"); online {
+		t.Error("group is online")
+	}
+}
@@ -0,0 +1,145 @@
+// Package grpcapi exposes an arp.Handler as a gRPC service.
+//
+// The service is defined in arp.proto; arp.pb.go and arp_grpc.pb.go are
+// generated with protoc-gen-go and protoc-gen-go-grpc. Clients use the
+// generated NewARPClient.
+//
+// Usage:
+//
+//	l, _ := net.Listen("tcp", ":9090")
+//	s := grpcapi.NewServer(handler)
+//	s.Serve(l)
+package grpcapi
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative arp.proto
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/irai/arp"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName is the fully qualified gRPC service name in arp.proto
+const ServiceName = "arp.ARP"
+
+// StreamQueueSize is the subscription queue size used by StreamEvents
+const StreamQueueSize = 256
+
+// Server implements ARPServer for an arp.Handler
+type Server struct {
+	UnimplementedARPServer
+	handler *arp.Handler
+}
+
+// New return an ARPServer for h
+func New(h *arp.Handler) *Server {
+	return &Server{handler: h}
+}
+
+// NewServer return a grpc.Server with the ARP service for h registered.
+func NewServer(h *arp.Handler, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+	Register(s, New(h))
+	return s
+}
+
+// Register add the ARP service to s; use it when s hosts other services.
+func Register(s grpc.ServiceRegistrar, srv ARPServer) {
+	RegisterARPServer(s, srv)
+}
+
+// ListDevices implements ARPServer
+func (s *Server) ListDevices(ctx context.Context, in *ListDevicesRequest) (*ListDevicesResponse, error) {
+	table := s.handler.Snapshot()
+	out := &ListDevicesResponse{Devices: make([]*Device, 0, len(table))}
+	for _, e := range table {
+		out.Devices = append(out.Devices, newDevice(e))
+	}
+	return out, nil
+}
+
+// StartHunt implements ARPServer
+func (s *Server) StartHunt(ctx context.Context, in *HuntRequest) (*HuntResponse, error) {
+	mac, err := s.findDevice(in.Mac)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.handler.StartHunt(mac); err != nil {
+		return nil, huntError(err)
+	}
+	return s.huntResponse(mac)
+}
+
+// StopHunt implements ARPServer
+func (s *Server) StopHunt(ctx context.Context, in *HuntRequest) (*HuntResponse, error) {
+	mac, err := s.findDevice(in.Mac)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.handler.StopHunt(mac); err != nil {
+		return nil, huntError(err)
+	}
+	return s.huntResponse(mac)
+}
+
+// huntResponse return the device after a hunt change; the entry may have been
+// deleted in the meantime.
+func (s *Server) huntResponse(mac net.HardwareAddr) (*HuntResponse, error) {
+	entry, ok := s.handler.SnapshotMAC(mac)
+	if !ok {
+		return nil, status.Error(codes.NotFound, "mac not found "+mac.String())
+	}
+	return &HuntResponse{Device: newDevice(entry)}, nil
+}
+
+// huntError return the gRPC status for a StartHunt or StopHunt error
+func huntError(err error) error {
+	switch {
+	case errors.Is(err, arp.ErrHuntNotAllowed):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, arp.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.FailedPrecondition, err.Error())
+}
+
+// StreamEvents implements ARPServer; events are sent until the client cancels the stream.
+func (s *Server) StreamEvents(in *StreamEventsRequest, stream ARP_StreamEventsServer) error {
+	sub := s.handler.Subscribe(StreamQueueSize, arp.DropOldest)
+	defer s.handler.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "subscription closed")
+			}
+			if err := stream.Send(newEvent(event)); err != nil {
+				log.Debug("GRPC error sending event ", err)
+				return err
+			}
+		}
+	}
+}
+
+// findDevice return the MAC of the entry in the table
+func (s *Server) findDevice(value string) (net.HardwareAddr, error) {
+	mac, err := net.ParseMAC(value)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	entry, ok := s.handler.SnapshotMAC(mac)
+	if !ok {
+		return nil, status.Error(codes.NotFound, "mac not found "+mac.String())
+	}
+	return entry.MAC, nil
+}
@@ -0,0 +1,110 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/irai/arp"
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_EventMarshal(t *testing.T) {
+	device := &Device{Mac: "01:02:03:04:05:06", Ip: "192.168.0.10", Ipv6: []string{"fe80::1", "fe80::2"},
+		State: "hunt", Online: true, LastUpdateUnixNano: 1234567890, Addresses: []string{"192.168.0.10", "192.168.0.11"}, Hostname: "printer.lan", MdnsName: "Printer", NetbiosName: "PRINTER"}
+	in := &Event{Type: "online", TimeUnixNano: 42, Previous: &Device{Mac: device.Mac}, Device: device}
+
+	b, err := proto.Marshal(in)
+	if err != nil {
+		t.Fatal("marshal error ", err)
+	}
+	out := &Event{}
+	if err := proto.Unmarshal(b, out); err != nil {
+		t.Fatal("unmarshal error ", err)
+	}
+	if !proto.Equal(in, out) {
+		t.Errorf("invalid event got %+v want %+v", out, in)
+	}
+
+	if err := proto.Unmarshal([]byte{0x0a, 0x10}, out); err == nil {
+		t.Error("expected error for truncated message")
+	}
+}
+
+// nullConn is a PacketConn that never receives packets and discards writes
+type nullConn struct {
+	closed chan struct{}
+}
+
+func (c nullConn) Read() (*marp.Packet, *ethernet.Frame, error) {
+	<-c.closed
+	return nil, nil, io.EOF
+}
+func (c nullConn) WriteTo(p *marp.Packet, addr net.HardwareAddr) error { return nil }
+func (c nullConn) Close() error                                        { return nil }
+func (c nullConn) SetReadDeadline(t time.Time) error                   { return nil }
+
+func Test_Server(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	h, err := arp.NewHandler("eth0", mac, net.IPv4(192, 168, 0, 1), net.IPv4(192, 168, 0, 254),
+		net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, arp.WithPacketConn(nullConn{closed: make(chan struct{})}))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	if err := h.ImportCSV(strings.NewReader("02:00:00:00:00:10,192.168.0.10\n")); err != nil {
+		t.Fatal("ImportCSV error ", err)
+	}
+
+	l := bufconn.Listen(1024 * 1024)
+	s := NewServer(h)
+	go s.Serve(l)
+	defer s.Stop()
+
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return l.DialContext(ctx)
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal("dial error ", err)
+	}
+	defer conn.Close()
+	client := NewARPClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	list, err := client.ListDevices(ctx, &ListDevicesRequest{})
+	if err != nil || len(list.Devices) != 1 || list.Devices[0].Mac != "02:00:00:00:00:10" || list.Devices[0].Ip != "192.168.0.10" {
+		t.Errorf("ListDevices got %v %v", list, err)
+	}
+
+	if _, err := client.StartHunt(ctx, &HuntRequest{Mac: "invalid"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("StartHunt invalid mac got %v", err)
+	}
+	if _, err := client.StopHunt(ctx, &HuntRequest{Mac: "01:02:03:04:05:06"}); status.Code(err) != codes.NotFound {
+		t.Errorf("StopHunt unknown mac got %v", err)
+	}
+	if _, err := client.StopHunt(ctx, &HuntRequest{Mac: "02:00:00:00:00:10"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("StopHunt not hunting got %v", err)
+	}
+
+	streamCtx, streamCancel := context.WithCancel(ctx)
+	events, err := client.StreamEvents(streamCtx, &StreamEventsRequest{})
+	if err != nil {
+		t.Fatal("StreamEvents error ", err)
+	}
+	streamCancel()
+	if _, err := events.Recv(); status.Code(err) != codes.Canceled {
+		t.Errorf("Recv after cancel got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package grpcapi
+
+import (
+	"time"
+
+	"github.com/irai/arp"
+)
+
+func newDevice(e arp.Entry) *Device {
+	d := &Device{Mac: e.MAC.String(), Ip: e.IP.String(), State: string(e.State), Online: e.Online, LastUpdateUnixNano: unixNano(e.LastUpdate),
+		Hostname: e.Hostname, MdnsName: e.MDNSName, NetbiosName: e.NetBIOSName, DeviceId: e.DeviceID}
+	for _, ip := range e.IPv6 {
+		d.Ipv6 = append(d.Ipv6, ip.String())
+	}
+	for _, a := range e.Addresses {
+		d.Addresses = append(d.Addresses, a.IP.String())
+	}
+	return d
+}
+
+func newEvent(e arp.Event) *Event {
+	return &Event{Type: string(e.Type), TimeUnixNano: unixNano(e.Time), Previous: newDevice(e.Previous), Device: newDevice(e.Entry)}
+}
+
+// unixNano return zero for the zero time; time.Time{}.UnixNano() is undefined.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,130 @@
+package arp
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+	log "github.com/sirupsen/logrus"
+)
+
+// gatewayAlertInterval is the minimum interval between two gateway alerts for the same MAC
+var gatewayAlertInterval = time.Second * 10
+
+// gatewayGuardMaxRecords limits the memory used by the guard
+const gatewayGuardMaxRecords = 1024
+
+// AlertFrame is the frame that raised a SpoofAlert.
+type AlertFrame struct {
+	Source      net.HardwareAddr // ethernet source; differs from SenderMAC if the ARP payload is forged
+	Destination net.HardwareAddr // ethernet destination
+	Operation   marp.Operation
+	SenderMAC   net.HardwareAddr
+	SenderIP    net.IP
+	TargetMAC   net.HardwareAddr
+	TargetIP    net.IP
+}
+
+// gatewayGuard records the gateway alerts sent; see WithGatewayGuard.
+type gatewayGuard struct {
+	mutex  sync.Mutex
+	claims map[string]*gatewayClaim // by offending MAC
+}
+
+type gatewayClaim struct {
+	alerted time.Time // last alert
+	count   int       // claims since the last alert
+}
+
+// WithGatewayGuard pin the router IP to routerMAC and send
+// EventGatewayImpersonation whenever another MAC claims the router IP, the
+// classic sign of an ARP man in the middle attack. The event Alert has
+// SeverityHigh and holds the offending frame; alerts for the same MAC are sent
+// at most every 10 seconds with the number of claims in between.
+//
+// If routerMAC is nil, the first MAC resolved for the router is pinned. The
+// pinned MAC does not follow router replies, so a router replacement must be
+// set with SetRouter.
+func WithGatewayGuard(routerMAC net.HardwareAddr) Option {
+	return func(c *Handler) error {
+		if routerMAC != nil {
+			if err := checkMAC(routerMAC); err != nil {
+				return err
+			}
+			c.config.RouterMAC = dupMAC(routerMAC)
+		}
+		c.guard = &gatewayGuard{claims: make(map[string]*gatewayClaim)}
+		return nil
+	}
+}
+
+// checkGateway send EventGatewayImpersonation if the frame claims the router
+// IP with a MAC other than the router MAC.
+func (c *Handler) checkGateway(packet *marp.Packet, frame *ethernet.Frame) {
+	c.mutex.RLock()
+	routerIP, routerMAC := c.config.RouterIP, c.config.RouterMAC
+	if routerMAC == nil || !packet.SenderIP.Equal(routerIP) ||
+		(bytes.Equal(packet.SenderHardwareAddr, routerMAC) && (frame == nil || bytes.Equal(frame.Source, routerMAC))) {
+		c.mutex.RUnlock()
+		return
+	}
+	// the host claims the router IP while hunting
+	if bytes.Equal(packet.SenderHardwareAddr, c.config.HostMAC) {
+		c.mutex.RUnlock()
+		return
+	}
+	if e := c.findMACLocked(packet.SenderHardwareAddr); e != nil && e.State == StateVirtualHost {
+		c.mutex.RUnlock()
+		return
+	}
+	c.mutex.RUnlock()
+
+	offender := packet.SenderHardwareAddr
+	if bytes.Equal(offender, routerMAC) {
+		offender = frame.Source // forged payload
+	}
+	now := time.Now()
+	count, ok := c.guard.claim(now, offender)
+	if !ok {
+		return
+	}
+
+	f := &AlertFrame{Operation: packet.Operation, SenderMAC: dupMAC(packet.SenderHardwareAddr), SenderIP: dupIP(packet.SenderIP),
+		TargetMAC: dupMAC(packet.TargetHardwareAddr), TargetIP: dupIP(packet.TargetIP)}
+	if frame != nil {
+		f.Source, f.Destination = dupMAC(frame.Source), dupMAC(frame.Destination)
+	}
+	alert := &SpoofAlert{Type: AlertGatewayClaim, Severity: SeverityHigh, MAC: dupMAC(offender), IP: dupIP(routerIP),
+		MACs: []net.HardwareAddr{dupMAC(routerMAC), dupMAC(offender)}, Count: count, Window: gatewayAlertInterval, Frame: f}
+	c.log().WithFields(log.Fields{"mac": alert.MAC.String(), "ip": alert.IP, "router": routerMAC.String(), "op": packet.Operation, "count": count}).Error("ARP gateway impersonation detected")
+	c.publish(Event{Type: EventGatewayImpersonation, Time: now, NIC: c.config.NIC, Entry: Entry{MAC: alert.MAC, IP: alert.IP}, Alert: alert})
+}
+
+// claim count a claim by mac and return the claims since the last alert and
+// true if an alert is due.
+func (g *gatewayGuard) claim(now time.Time, mac net.HardwareAddr) (int, bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	r, ok := g.claims[string(mac)]
+	if !ok {
+		if len(g.claims) >= gatewayGuardMaxRecords {
+			for k, r := range g.claims {
+				if now.Sub(r.alerted) > gatewayAlertInterval {
+					delete(g.claims, k)
+				}
+			}
+		}
+		r = &gatewayClaim{}
+		g.claims[string(mac)] = r
+	}
+	r.count++
+	if now.Sub(r.alerted) < gatewayAlertInterval {
+		return 0, false
+	}
+	count := r.count
+	r.alerted, r.count = now, 0
+	return count, true
+}
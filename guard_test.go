@@ -0,0 +1,61 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+func Test_GatewayGuard(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()), WithGatewayGuard(mac2))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropNewest)
+
+	h.serve(newTestPacket(marp.OperationReply, mac2, ip2, mac3, ip3), &ethernet.Frame{Source: mac2, Destination: mac3}, time.Now())
+	h.serve(newTestPacket(marp.OperationReply, mac1, ip2, mac3, ip3), &ethernet.Frame{Source: mac1, Destination: mac3}, time.Now())
+	h.serve(newTestPacket(marp.OperationReply, mac1, ip2, mac3, ip3), &ethernet.Frame{Source: mac1, Destination: mac3}, time.Now()) // within the alert interval
+	h.setRouterMAC(ip2, mac1)
+	if mac := h.RouterMAC(); mac.String() != mac2.String() {
+		t.Errorf("pinned router MAC changed to %s", mac)
+	}
+
+	var events []Event
+	for done := false; !done; {
+		select {
+		case e := <-s.C:
+			if e.Type == EventGatewayImpersonation {
+				events = append(events, e)
+			}
+		case <-time.After(time.Millisecond * 100):
+			done = true
+		}
+	}
+	if len(events) != 1 {
+		t.Fatalf("invalid gateway events %+v", events)
+	}
+	a := events[0].Alert
+	if a == nil || a.Severity != SeverityHigh || a.MAC.String() != mac1.String() || !a.IP.Equal(ip2) || a.Count != 1 ||
+		a.Frame == nil || a.Frame.Source.String() != mac1.String() || a.Frame.Operation != marp.OperationReply {
+		t.Errorf("invalid alert %+v", a)
+	}
+
+	// forged payload: the frame source is not the router
+	old := gatewayAlertInterval
+	gatewayAlertInterval = 0
+	defer func() { gatewayAlertInterval = old }()
+	h.checkGateway(newTestPacket(marp.OperationReply, mac2, ip2, mac3, ip3), &ethernet.Frame{Source: mac1, Destination: mac3})
+	select {
+	case e := <-s.C:
+		if e.Type != EventGatewayImpersonation || e.Alert.MAC.String() != mac1.String() || e.Alert.Count != 2 {
+			t.Errorf("invalid forged payload event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("no event for forged payload")
+	}
+}
@@ -1,52 +1,121 @@
 package arp
 
 import (
-	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/bpf"
 )
 
-type configuration struct {
-	NIC       string           `yaml:"-"`
-	HostMAC   net.HardwareAddr `yaml:"-"`
-	HostIP    net.IP           `yaml:"-"`
-	RouterIP  net.IP           `yaml:"-"`
-	RouterMAC net.HardwareAddr `yaml:"-"`
-	HomeLAN   net.IPNet        `yaml:"-"`
-}
-
 // Handler is used to handle ARP packets for a given interface.
 type Handler struct {
-	client       *marp.Client
-	mutex        sync.Mutex
-	table        []*Entry
-	notification chan<- Entry // notification channel for state change
+	counters             counters // first field to guarantee 64 bit alignment of atomic values
+	client               PacketConn
+	redial               *redialConn  // nil unless the handler opened the live interface
+	ndp                  *ndpConn     // nil unless ListenAndServeNDP is running
+	mdns                 *net.UDPConn // nil unless ListenAndServeMDNS is running
+	mutex                sync.RWMutex // read lock for lookups and snapshots; write lock to change entries
+	table                []*Entry
+	macIndex             map[string]*Entry // MAC lookup
+	ipIndex              map[string]*Entry // IPv4 and IPv6 lookup excluding virtual hosts
+	virtualIndex         map[string]*Entry // IP lookup for virtual hosts
+	subscriptions        []*Subscription   // notification subscribers; copy on write
+	notificationSize     int               // queue size for AddNotificationChannel
+	notificationPolicy   OverflowPolicy    // queue overflow policy for AddNotificationChannel
+	droppedNotifications uint64            // drops from subscriptions already removed
+	logger               atomic.Value      // handler Logger; see SetLogger
+	logAreas             uint32            // atomic value; see SetLogAreas
+	store                Store             // nil unless SetStore is called
+	capture              *pcapngWriter     // nil unless WithPacketCapture is set
+	vlan                 *ethernet.VLAN    // nil unless WithVLAN is set
+	scanInterval         time.Duration     // full scan interval; see SetScanInterval
+	reconfigure          chan struct{}     // signal pollingLoop the configuration changed
+	spoofDeny            spoofList         // never hunt; see SetSpoofDenyList
+	spoofAllow           spoofList         // hunt only these if not nil; see SetSpoofAllowList
+	huntTimeout          time.Duration     // maximum hunt duration; see SetHuntTimeout
+	huntAll              chan struct{}     // closed by StopAll; nil unless HuntAll is running
+	detector             *spoofDetector    // nil unless WithSpoofDetection is set
+	guard                *gatewayGuard     // nil unless WithGatewayGuard is set
+	storm                *stormDetector    // nil unless WithStormDetection is set
+	conflict             *conflictDetector // nil unless WithConflictDetection is set
+	ipConflict           *ipConflictTable  // nil unless WithIPConflictDetection is set
+	flap                 *flapDetector     // nil unless WithFlapDetection is set
+	keepalive            *keepalive        // nil unless WithKeepalive is set
+	limiter              tokenBucket       // transmit rate limit; see SetRateLimit
+	pinger               pingFunc          // nil unless WithPingFallback is set
+	offline              OfflineThreshold  // see WithOfflineThreshold
+	retention            time.Duration     // delete entries not seen for this long; see SetRetention
+	storeRetention       time.Duration     // delete stored entries not seen for this long; see SetStoreRetention
+	tableLimit           int               // maximum number of entries; see tableLimit
+	dns                  *reverseDNS       // nil unless WithReverseDNS is set
+	netbios              netbiosFunc       // nil unless WithNetBIOS is set
+	dhcp                 *dhcpSnooper      // nil unless ListenAndServeDHCP is running
+	neighbors            neighborTable     // nil unless WithNeighborSync is set
+	firewall             firewall          // nil unless WithFirewall is set
+	history              *eventHistory     // last events; nil if disabled
+	healthWindow         time.Duration     // see WithHealthWindow
+	serving              int32             // atomic value; 1 while the ListenAndServe read loop is running
+	polling              int32             // atomic value; 1 while pollingLoop is running
+	scanning             int32             // atomic value; 1 while a full scan is running; see startScan
+	degraded             int32             // atomic value; 1 while ListenAndServe is reconnecting the socket
+	watchdog             *watchdog         // nil unless WithWatchdog is set
+	tcpProbes            map[string][]int  // TCP probe ports by MAC; see SetTCPProbe
+	probeIntervals       intervalList      // probe interval by MAC; see SetProbeInterval
+	linkDevices          bool              // see WithDeviceLinking
+	connsClosed          int32             // atomic value; 1 after closeConns
+	workers              *workerPool       // nil unless WithWorkers is set
+	single               singleBatch       // see readBatch
+	updates              []batchUpdate     // see handleBatch
+	readTimes            []time.Time       // see readBatch
+	spoofBatch           spoofBatch        // see replySpoof
 	// tranChannel  chan<- Entry // notification channel for arp hunt ent
-	config        configuration
-	goroutinePool *goroutinePool // handler specific pool in case we have two instances
+	config        Config
+	goroutinePool *goroutinePool     // handler specific pool in case we have two instances
+	hunts         map[string]*hunt   // hunts in progress by MAC
+	claims        map[string]*claim  // claimed IPs by IP; see ClaimIP
+	virtualHosts  map[string]*Entry  // virtual hosts by IP; see AddVirtualHost
+	macPool       *macPool           // virtual host MACs; see macPoolLocked
+	rawChannels   []chan<- RawPacket // see AddRawPacketChannel; copy on write
+	middleware    []Middleware       // see Use; copy on write
+	plugins       []*plugin          // see Register
+	pluginsClosed bool               // set when ListenAndServe starts the plugins
+	callbacks     deviceCallbacks    // see OnDevice
+	groups        map[string]*group  // see CreateGroup
+	schedule      *scheduler         // see AddSchedule
+	transitions   transitionHooks    // see BeforeTransition
+
+	socketFilter []bpf.RawInstruction // attached to the live socket; see WithSocketFilter
+	promiscuous  bool                 // see WithPromiscuous
+	passive      bool                 // never transmit; see WithPassive
+	noScan       bool                 // never scan the LAN; see WithoutScan
+	tap          PacketTap            // see WithPacketTap
 }
 
 var (
 	// LogAll controls the level of logging required. By default we only log
 	// error and warning.
-	// Set LogAll to true to see all logs.
+	// Set LogAll to true to see all logs for all handlers; see SetLogAreas
+	// and SetLogger to control logging per handler and area.
 	LogAll bool
 )
 
-func getArpClient(nic string) (*marp.Client, error) {
+func getArpClient(nic string) (PacketConn, error) {
 	ifi, err := net.InterfaceByName(nic)
 	if err != nil {
 		log.Error("ARP Reply error in interface name", err)
 		return nil, err
 	}
 
-	// Set up ARP client with socket
-	c, err := marp.Dial(ifi)
+	// Set up ARP client with socket; see dialPacketConn for each platform
+	c, err := dialPacketConn(ifi)
 	if err != nil {
 		log.Error("ARP Reply error in dial", err)
 		return nil, err
@@ -55,59 +124,207 @@ func getArpClient(nic string) (*marp.Client, error) {
 }
 
 // NewHandler creates an ARP handler for a given interface.
-func NewHandler(nic string, hostMAC net.HardwareAddr, hostIP net.IP, routerIP net.IP, homeLAN net.IPNet) (c *Handler, err error) {
+//
+// Options are applied in order before the ARP socket is open; the socket is
+// not opened if an option sets the packet source (i.e. WithPacketConn or WithPCAPReplay).
+func NewHandler(nic string, hostMAC net.HardwareAddr, hostIP net.IP, routerIP net.IP, homeLAN net.IPNet, options ...Option) (c *Handler, err error) {
+	return NewHandlerWithConfig(Config{NIC: nic, HostMAC: hostMAC, HostIP: hostIP, RouterIP: routerIP, HomeLAN: homeLAN}, options...)
+}
+
+// NewHandlerWithConfig creates an ARP handler for config.NIC; see NewHandler.
+//
+// The configuration is validated before the ARP socket is open and a copy is
+// used by the handler.
+func NewHandlerWithConfig(config Config, options ...Option) (c *Handler, err error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	nic, homeLAN := config.NIC, config.HomeLAN
+
 	c = &Handler{}
 	c.goroutinePool = GoroutinePool.new("arppool")
-	c.client, err = getArpClient(nic)
-	if err != nil {
-		log.WithFields(log.Fields{"nic": nic}).Error("ARP error in dial", err)
+
+	// The table starts with capacity for a /24 network and grows up to the
+	// number of addresses in the LAN; see tableLimit.
+	c.table = make([]*Entry, 0, minTableSize)
+	c.tableLimit = tableLimit(homeLAN)
+	c.reconfigure = make(chan struct{}, 1)
+	c.hunts = make(map[string]*hunt)
+	c.schedule = newScheduler()
+	c.history = newEventHistory(DefaultHistorySize)
+	c.config = config.copy()
+	c.applyConfig(c.config)
+
+	for _, option := range options {
+		if err = option(c); err != nil {
+			c.closeOptions()
+			return nil, err
+		}
+	}
+	if err = c.checkPassive(); err != nil {
+		c.closeOptions()
 		return nil, err
 	}
 
-	// Set the table capacity to 256. This is the maximum number of entries
-	// in current implementation (i.e. the logic assume IPv4/24).
-	c.table = make([]*Entry, 0, 256)
-	c.config.NIC = nic
-	c.config.HostMAC = hostMAC
-	c.config.HostIP = hostIP
-	c.config.RouterIP = routerIP
-	c.config.HomeLAN = homeLAN
+	// Open the live interface unless an option set the packet source
+	if c.client == nil {
+		dial := getArpClient
+		if c.vlan != nil {
+			dial = dialVLAN
+		}
+		client, err := dial(nic)
+		if err != nil {
+			log.WithFields(log.Fields{"nic": nic}).Error("ARP error in dial", err)
+			c.closeOptions()
+			return nil, err
+		}
+		c.redial = newRedialConn(client, func() (PacketConn, error) { return dial(nic) })
+		c.client = c.redial
+	}
+	if c.socketFilter != nil {
+		if err = setSocketFilter(c.client, c.socketFilter); err != nil {
+			c.closeOptions()
+			return nil, err
+		}
+	}
+	if c.promiscuous {
+		if err = setPromiscuous(c.client); err != nil {
+			c.closeOptions()
+			return nil, err
+		}
+	}
+	if c.vlan != nil {
+		c.client = &vlanConn{conn: c.client, vlan: *c.vlan}
+	}
 
 	if LogAll {
 		log.WithFields(log.Fields{"hostinterface": c.config.NIC, "hostmac": c.config.HostMAC.String(),
-			"hostip": c.config.HostIP.String(), "lanrouter": c.config.RouterIP.String()}).Debug("ARP configuration")
+			"hostip": c.config.HostIP.String(), "lanrouter": c.config.RouterIP.String(), "vlan": c.VLAN()}).Debug("ARP configuration")
 	}
 
 	return c, nil
 }
 
+// closeOptions release the resources allocated by options when NewHandler fails.
+func (c *Handler) closeOptions() {
+	if c.client != nil {
+		c.client.Close()
+	}
+	if c.capture != nil {
+		c.capture.close()
+	}
+}
+
 // AddNotificationChannel set the notification channel for when the Entry
 // change state between online and offline.
+//
+// Notifications are queued internally and never block packet processing; if the
+// consumer falls behind, notifications are dropped according to the policy
+// set in SetNotificationQueue and counted in Stats().
+//
+// Use Subscribe when more than one consumer is required.
 func (c *Handler) AddNotificationChannel(notification chan<- Entry) {
-	c.notification = notification
+	c.mutex.RLock()
+	size, policy := c.notificationSize, c.notificationPolicy
+	c.mutex.RUnlock()
 
-	go func() {
-		time.Sleep(time.Millisecond * 50)
-		c.mutex.Lock()
-		table := c.table
-		c.mutex.Unlock()
+	s := c.Subscribe(size, policy)
+	go c.notificationLoop(c.goroutinePool.Begin("ARP notificationLoop"), s, notification)
+
+	c.goroutinePool.Go("ARP notification table", func(ctx context.Context) error {
+		if !sleepContext(ctx, time.Millisecond*50) {
+			return nil
+		}
+		c.mutex.RLock()
+		table := make([]Entry, 0, len(c.table))
+		for _, entry := range c.table {
+			if entry != nil {
+				table = append(table, *entry)
+			}
+		}
+		c.mutex.RUnlock()
 		for i := range table {
-			c.notification <- *table[i]
+			s.queue.push(Event{Type: EventDeviceSeen, Time: time.Now(), NIC: c.config.NIC, Previous: table[i], Entry: table[i]})
 		}
-	}()
+		return nil
+	})
 }
 
 // Stop will terminate the ListenAndServer goroutine as well as all other pending goroutines.
 func (c *Handler) Stop() error {
 
-	// Close the arp socket
-	go func() {
-		time.Sleep(time.Millisecond * 10)
-		c.client.Close()
-	}()
+	// cancel the pool context first so ListenAndServe does not treat the
+	// socket close as a read error; waiting goroutines exit on StopChannel
+	c.goroutinePool.signal()
+	c.closeConns()
+
+	err := c.goroutinePool.Stop()
+	c.closeFirewall()
 
-	// closing stopChannel will cause all waiting goroutines to exit
-	return c.goroutinePool.Stop()
+	if c.capture != nil {
+		c.capture.close()
+	}
+	return err
+}
+
+// StopAndWait stop the handler and return when all goroutines have exited,
+// including ListenAndServe and the polling loop.
+//
+// Unlike Stop, the sockets are closed before it returns. It returns ctx.Err()
+// if ctx is done before the goroutines exit, the errors of goroutines that
+// failed, otherwise the first error closing the sockets, removing the
+// firewall rules or closing the capture file.
+func (c *Handler) StopAndWait(ctx context.Context) error {
+	c.goroutinePool.signal() // set stopping first so ListenAndServe does not treat the close as a read error
+	err := c.closeConns()
+
+	if e := c.goroutinePool.Wait(ctx); e != nil {
+		err = e
+	}
+	if e := c.closeFirewall(); e != nil && err == nil {
+		err = e
+	}
+	if c.capture != nil {
+		if e := c.capture.close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Wait return when the handler stopped and all goroutines have exited or when
+// ctx is done. The handler stops when Stop is called, when the context set
+// with WithContext is done or when a goroutine fails (i.e. a fatal read
+// error); Wait returns the errors of the goroutines that failed or ctx.Err().
+//
+// Wait does not close the sockets or remove the firewall rules; call Stop
+// after Wait returns.
+func (c *Handler) Wait(ctx context.Context) error {
+	return c.goroutinePool.Wait(ctx)
+}
+
+// closeConns close the arp socket and the listeners; it return the first error.
+// It does nothing if the sockets are already closed.
+func (c *Handler) closeConns() (err error) {
+	if !atomic.CompareAndSwapInt32(&c.connsClosed, 0, 1) {
+		return nil
+	}
+	save := func(e error) {
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	save(c.client.Close())
+	if ndp := c.ndpConn(); ndp != nil {
+		save(ndp.close())
+	}
+	if mdns := c.mdnsConn(); mdns != nil {
+		save(mdns.Close())
+	}
+	for _, conn := range c.dhcpConns() {
+		save(conn.Close())
+	}
+	return err
 }
 
 func (c *Handler) actionUpdateClient(client *Entry, senderMAC net.HardwareAddr, senderIP net.IP) int {
@@ -116,20 +333,30 @@ func (c *Handler) actionUpdateClient(client *Entry, senderMAC net.HardwareAddr,
 	// Ignore if same IP and client is Online
 	// Ignore any router updates
 	//
-	if (client.IP.Equal(senderIP) && client.Online) ||
-		senderIP.Equal(net.IPv4zero) ||
-		bytes.Equal(senderMAC, c.config.RouterMAC) ||
+	if senderIP.Equal(net.IPv4zero) ||
+		c.isRouterMAC(senderMAC) ||
 		senderIP.Equal(c.config.HostIP) {
 		return 0
 	}
 
 	c.mutex.Lock()
-	client.IP = dupIP(senderIP)
-	client.State = StateNormal
+	if client.hasAddress(senderIP) && client.Online {
+		c.mutex.Unlock()
+		return 0
+	}
+	// check the transition first; a rejected transition must not change the IP
+	t, err := c.beginTransitionLocked(client, StateNormal, ReasonIPChanged)
+	if err != nil {
+		c.mutex.Unlock()
+		c.log().WithFields(log.Fields{"mac": client.MAC.String()}).Warn("ARP cannot end hunt on IP change ", err)
+		return 0
+	}
+	c.setIPLocked(client, senderIP)
+	c.endTransitionLocked(client, t)
 	c.mutex.Unlock()
 
-	if LogAll {
-		log.WithFields(log.Fields{"mac": client.MAC.String(), "ip": client.IP.String()}).Debugf("ARP client updated IP to %s", senderIP)
+	if c.logArea(LogTable) {
+		c.log().WithFields(log.Fields{"mac": client.MAC.String(), "ip": client.IP.String()}).Debugf("ARP client updated IP to %s", senderIP)
 	}
 
 	return 1
@@ -153,8 +380,8 @@ func (c *Handler) actionRequestInHuntState(client *Entry, senderIP net.IP, targe
 		return 0, nil
 	}
 
-	if LogAll {
-		log.WithFields(log.Fields{"mac": client.MAC, "ip": ip}).Debugf("ARP client announcement in hunt state %s", targetIP)
+	if c.logArea(LogSpoof) {
+		c.log().WithFields(log.Fields{"mac": client.MAC, "ip": ip}).Debugf("ARP client announcement in hunt state %s", targetIP)
 	}
 
 	// Record new IP in ARP table if address has changed.
@@ -164,8 +391,8 @@ func (c *Handler) actionRequestInHuntState(client *Entry, senderIP net.IP, targe
 	if !ip.Equal(targetIP) { // is this a new IP?
 		n := c.actionUpdateClient(client, client.MAC, targetIP)
 		if n != 1 {
-			if LogAll {
-				log.WithFields(log.Fields{"mac": client.MAC.String(), "ip": ip}).Debugf("ARP client failed to change IP to %s", targetIP)
+			if c.logArea(LogSpoof) {
+				c.log().WithFields(log.Fields{"mac": client.MAC.String(), "ip": ip}).Debugf("ARP client failed to change IP to %s", targetIP)
 			}
 			return 0, fmt.Errorf("error updating client: %s, %s ", client.MAC.String(), ip)
 		}
@@ -173,8 +400,8 @@ func (c *Handler) actionRequestInHuntState(client *Entry, senderIP net.IP, targe
 		return n, nil
 	}
 
-	if LogAll {
-		log.WithFields(log.Fields{"mac": client.MAC, "ip": ip}).Debugf("ARP client attempting to get same IP %s", targetIP)
+	if c.logArea(LogSpoof) {
+		c.log().WithFields(log.Fields{"mac": client.MAC, "ip": ip}).Debugf("ARP client attempting to get same IP %s", targetIP)
 	}
 
 	return 0, err
@@ -183,7 +410,8 @@ func (c *Handler) actionRequestInHuntState(client *Entry, senderIP net.IP, targe
 // ListenAndServe listen for ARP packets and action these.
 //
 // parameters:
-//   scanInterval - frequency to poll existing MACs to ensure they are online
+//   scanInterval - frequency to poll existing MACs to ensure they are online; see SetScanInterval.
+//                  Zero uses Config.ScanInterval.
 //
 // When a new MAC is detected, it is automatically added to the ARP table and marked as online.
 //
@@ -204,147 +432,306 @@ func (c *Handler) ListenAndServe(scanInterval time.Duration) {
 	defer h.End()
 
 	// Goroutine to continuosly scan for network devices
-	go c.pollingLoop(scanInterval)
+	c.mutex.Lock()
+	if scanInterval == 0 {
+		scanInterval = c.config.ScanInterval
+	}
+	c.scanInterval = scanInterval
+	c.mutex.Unlock()
+	go c.pollingLoop(c.goroutinePool.Begin("ARP pollingLoop"))
+	if c.dns != nil {
+		go c.dnsLoop(c.goroutinePool.Begin("ARP dnsLoop"), c.Subscribe(storeQueueSize, DropOldest))
+	}
+	if c.netbios != nil {
+		go c.netbiosLoop(c.goroutinePool.Begin("ARP netbiosLoop"), c.Subscribe(storeQueueSize, DropOldest))
+	}
+	if c.neighbors != nil {
+		go c.neighborLoop(c.goroutinePool.Begin("ARP neighborLoop"), c.Subscribe(storeQueueSize, DropOldest))
+	}
+	if c.linkDevices {
+		go c.linkLoop(c.goroutinePool.Begin("ARP linkLoop"), c.Subscribe(storeQueueSize, DropOldest))
+	}
+	c.startPlugins()
+	go c.scheduleLoop(c.goroutinePool.Begin("ARP scheduleLoop"))
+	if c.workers != nil {
+		c.startWorkers()
+	}
+
+	// Close the sockets if the pool stops without Stop (i.e. the handler
+	// context is done or a goroutine failed) so the read below returns
+	c.goroutinePool.Go("ARP closeConns", func(ctx context.Context) error {
+		<-ctx.Done()
+		c.closeConns()
+		return nil
+	})
 
 	// Set ZERO timeout to block forever
 	if err := c.client.SetReadDeadline(time.Time{}); err != nil {
-		log.Error("ARP error in socket:", err)
+		c.log().Error("ARP error in socket:", err)
+		h.Fail(err)
 		return
 	}
+	c.frameReceived(time.Now()) // start the health window
+	atomic.StoreInt32(&c.serving, 1)
+	defer atomic.StoreInt32(&c.serving, 0)
+	if c.watchdog != nil && c.redial != nil {
+		c.readReturned(time.Now())
+		go c.watchdogLoop(c.goroutinePool.Begin("ARP watchdogLoop"))
+	}
+	if c.conflict != nil {
+		go c.conflictLoop(c.goroutinePool.Begin("ARP conflictLoop"))
+	}
+	if c.keepalive != nil {
+		go c.keepaliveLoop(c.goroutinePool.Begin("ARP keepaliveLoop"))
+	}
 
 	// Loop and wait for ARP packets
 	for {
-		packet, _, err := c.client.Read()
+		if c.watchdog != nil {
+			if err := c.client.SetReadDeadline(time.Now().Add(c.watchdog.interval)); err != nil {
+				c.log().Error("ARP error in socket:", err)
+			}
+		}
+		packets, frames, times, err := c.readBatch()
 		if h.Stopping() { // are we stopping all goroutines?
 			return
 		}
+		if c.watchdog != nil {
+			c.readReturned(time.Now())
+			if isTimeout(err) { // no packets in the interval
+				continue
+			}
+		}
+		if err == io.EOF { // end of replay capture
+			if c.logArea(LogPackets) {
+				c.log().Debug("ARP end of packet source")
+			}
+			return
+		}
 		if err != nil {
-			log.Error("ARP read error ", err)
+			atomic.AddUint64(&c.counters.readErrors, 1)
+			c.log().Error("ARP read error ", err)
 			if err1, ok := err.(net.Error); ok && err1.Temporary() {
-				if LogAll {
-					log.Debug("ARP read error is temporary - retry", err1)
+				if c.logArea(LogPackets) {
+					c.log().Debug("ARP read error is temporary - retry", err1)
 				}
 				time.Sleep(time.Millisecond * 30) // Wait a few seconds before retrying
 				continue
 			}
+			if c.redial != nil && c.reconnect(h, err) {
+				continue
+			}
+			if !h.Stopping() {
+				h.Fail(err)
+			}
 			return
 		}
-
-		notify := 0
-
-		// skip link local packets
-		if packet.SenderIP.IsLinkLocalUnicast() ||
-			packet.TargetIP.IsLinkLocalUnicast() {
-			if LogAll {
-				log.WithFields(log.Fields{"senderip": packet.SenderIP, "targetip": packet.TargetIP}).Debug("ARP skipping link local packet")
+		atomic.AddUint64(&c.counters.packetsRead, uint64(len(packets)))
+		c.frameReceived(time.Now())
+		for i := range packets {
+			c.captureFrame(frames[i], pcapngDirectionInbound)
+			if c.tap != nil {
+				c.tap(packets[i], frames[i])
+			}
+		}
+		if c.workers != nil {
+			for i := range packets {
+				c.enqueue(packets[i], frames[i], times[i])
 			}
 			continue
 		}
+		c.serveBatch(packets, frames, times)
+	}
+}
 
-		c.mutex.Lock()
+// handlePacket update the table and notify subscribers for a received packet.
+//
+// It must not keep references to the packet; the packet conn may reuse it in
+// the next read. Steady state packets (i.e. known online device with the same IP)
+// must not allocate; see Benchmark_HandlePacket.
+func (c *Handler) handlePacket(packet *marp.Packet) {
+	c.handlePacketAt(packet, time.Now())
+}
 
-		sender := c.findMACLocked(packet.SenderHardwareAddr)
-		if sender == nil {
-			// If new client, then create a new entry in table
-			//
-			// NOTE: if this is a probe, the sender IP will be Zeros
-			//       do nothing as the sender IP is not valid yet.
-			//
-			if packet.Operation == marp.OperationRequest && packet.SenderIP.Equal(net.IPv4zero) {
-				c.mutex.Unlock()
-
-				if LogAll {
-					log.WithFields(log.Fields{"sendermac": packet.SenderHardwareAddr, "senderip": packet.SenderIP, "targetip": packet.TargetIP}).
-						Debug("ARP acd probe received")
-				}
-				continue // continue the for loop
-			}
+// handlePacketAt is handlePacket for a packet received at t; the entry
+// LastUpdate and the event time are set to t.
+func (c *Handler) handlePacketAt(packet *marp.Packet, t time.Time) {
+	if !c.checkPacket(packet) {
+		return
+	}
 
-			sender = c.arpTableAppendLocked(StateNormal, packet.SenderHardwareAddr, packet.SenderIP)
-			notify++
-		}
-		previousIP := sender.IP
+	c.mutex.Lock()
+	u, ok := c.updateSenderLocked(packet, t)
+	c.mutex.Unlock()
 
-		// Skip packets that we sent as virtual host (i.e. we sent these)
-		if sender.State == StateVirtualHost {
-			c.mutex.Unlock()
-			continue
+	if ok {
+		c.counters.updateLatency.observe(time.Since(t))
+		c.senderUpdated(packet, &u)
+	}
+}
+
+// senderUpdate is the table change made by a received packet; see updateSenderLocked.
+type senderUpdate struct {
+	sender   *Entry
+	previous Entry  // copy before changes; empty if new
+	evicted  *Entry // deleted to make room for the sender
+	notify   int
+	time     time.Time // packet receive time
+}
+
+// checkPacket count the packet and run the detectors; it returns false if the
+// packet must not update the table.
+func (c *Handler) checkPacket(packet *marp.Packet) bool {
+	// skip link local, malformed and spoofed broadcast packets
+	if err := ValidatePacket(packet); err != nil {
+		atomic.AddUint64(&c.counters.packetsInvalid, 1)
+		if c.logArea(LogPackets) {
+			c.log().WithFields(log.Fields{"sendermac": packet.SenderHardwareAddr, "senderip": packet.SenderIP, "targetip": packet.TargetIP}).Debug("ARP skipping packet: ", err)
 		}
-		sender.LastUpdate = time.Now()
+		return false
+	}
+	switch packet.Operation {
+	case marp.OperationRequest:
+		atomic.AddUint64(&c.counters.requestsRead, 1)
+	case marp.OperationReply:
+		atomic.AddUint64(&c.counters.repliesRead, 1)
+	}
 
-		c.mutex.Unlock()
+	if c.conflict != nil {
+		c.detectConflict(packet)
+	}
+	if c.ipConflict != nil {
+		c.detectIPConflict(packet)
+	}
+	if c.flap != nil {
+		c.detectFlap(packet)
+	}
+	c.checkClaims(packet)
 
-		switch packet.Operation {
+	return c.storm == nil || !c.checkStorm(packet)
+}
 
-		// Reply to ARP request if we are spoofing this host.
+// updateSenderLocked add or update the table entry of the packet sender; it
+// returns false if the packet must be ignored.
+func (c *Handler) updateSenderLocked(packet *marp.Packet, now time.Time) (u senderUpdate, ok bool) {
+	u.time = now
+	u.sender = c.findMACLocked(packet.SenderHardwareAddr)
+	if u.sender != nil {
+		u.previous = *u.sender
+	} else {
+		// If new client, then create a new entry in table
 		//
-		case marp.OperationRequest:
-			if LogAll {
-				if packet.SenderIP.Equal(packet.TargetIP) {
-					log.WithFields(log.Fields{"mac": sender.MAC, "ip": packet.SenderIP, "state": sender.State}).Debug("ARP announcement received")
-				} else {
-					log.WithFields(log.Fields{"ip": sender.IP, "mac": sender.MAC, "state": sender.State,
-						"to_ip": packet.TargetIP.String(), "to_mac": packet.TargetHardwareAddr}).Debugf("ARP request received - who is %s tell %s", packet.TargetIP.String(), sender.IP)
-				}
+		// NOTE: if this is a probe, the sender IP will be Zeros
+		//       do nothing as the sender IP is not valid yet.
+		//
+		if IsProbe(packet) {
+			if c.logArea(LogPackets) {
+				c.log().WithFields(log.Fields{"sendermac": packet.SenderHardwareAddr, "senderip": packet.SenderIP, "targetip": packet.TargetIP}).
+					Debug("ARP acd probe received")
 			}
+			return u, false
+		}
 
-			// if target is virtual host, reply and return
-			if target := c.FindVirtualIP(packet.TargetIP); target != nil {
-				if LogAll {
-					log.WithFields(log.Fields{"ip": target.IP, "mac": target.MAC}).Debug("ARP sending reply for virtual mac")
-				}
-				c.reply(target.MAC, target.IP, EthernetBroadcast, target.IP)
-				break // break the switch
-			}
+		u.evicted = c.evictOldestLocked() // make room if the table is full
+		u.sender = c.arpTableAppendLocked(StateNormal, packet.SenderHardwareAddr, packet.SenderIP)
+		if u.sender == nil {
+			return u, false
+		}
+		u.notify++
+	}
 
-			switch sender.State {
-			case StateHunt:
-				n, _ := c.actionRequestInHuntState(sender, packet.SenderIP, packet.TargetIP)
-				notify = notify + n
+	// Skip packets that we sent as virtual host (i.e. we sent these)
+	if u.sender.State == StateVirtualHost {
+		return u, false
+	}
+	u.sender.LastUpdate = now
+	u.sender.Packets++
+	u.sender.misses = 0
+	c.touchAddressLocked(u.sender, packet.SenderIP, now)
+	return u, true
+}
 
-			case StateNormal:
-				notify += c.actionUpdateClient(sender, packet.SenderHardwareAddr, packet.SenderIP)
+// senderUpdated reply, spoof and notify subscribers after the table update.
+func (c *Handler) senderUpdated(packet *marp.Packet, u *senderUpdate) {
+	sender, previous, notify := u.sender, u.previous, u.notify
+	previousIP := previous.IP
+	if previous.MAC == nil {
+		previousIP = sender.IP
+	}
 
-			default:
-				log.Error("ARP unexpected client state in request =", sender.State)
-			}
+	if u.evicted != nil {
+		c.notifyEvicted(*u.evicted)
+	}
 
-		case marp.OperationReply:
-			if LogAll {
-				log.WithFields(log.Fields{
-					"ip": sender.IP, "mac": sender.MAC, "state": sender.State,
-					"senderip": packet.SenderIP.String(), "to_mac": packet.TargetHardwareAddr, "to_ip": packet.TargetIP}).
-					Debugf("ARP reply received - %s is at %s", packet.SenderIP, sender.MAC)
-			}
+	c.signalCorrection(packet)
+	if c.detector != nil {
+		c.detectSpoof(packet)
+	}
 
-			switch sender.State {
-			case StateNormal:
-				notify += c.actionUpdateClient(sender, packet.SenderHardwareAddr, packet.SenderIP)
+	switch packet.Operation {
 
-			case StateHunt:
-				// Android does not send collision detection request,
-				// we will see a reply instead. Check if the address has changed.
-				if !packet.SenderIP.Equal(net.IPv4zero) && !packet.SenderIP.Equal(sender.IP) {
-					notify += c.actionUpdateClient(sender, packet.SenderHardwareAddr, packet.SenderIP)
-				}
+	// Reply to ARP request if we are spoofing this host.
+	//
+	case marp.OperationRequest:
+		if c.logArea(LogPackets) {
+			if packet.SenderIP.Equal(packet.TargetIP) {
+				c.log().WithFields(log.Fields{"mac": sender.MAC, "ip": packet.SenderIP, "state": sender.State}).Debug("ARP announcement received")
+			} else {
+				c.log().WithFields(log.Fields{"ip": sender.IP, "mac": sender.MAC, "state": sender.State,
+					"to_ip": packet.TargetIP.String(), "to_mac": packet.TargetHardwareAddr}).Debugf("ARP request received - who is %s tell %s", packet.TargetIP.String(), sender.IP)
+			}
+		}
 
-			default:
-				log.WithFields(log.Fields{"ip": sender.IP, "mac": sender.MAC}).Error("ARP unexpected client state in reply =", sender.State)
+		// if target is virtual host, reply and return
+		if target := c.FindVirtualIP(packet.TargetIP); target != nil {
+			if c.logArea(LogSpoof) {
+				c.log().WithFields(log.Fields{"ip": target.IP, "mac": target.MAC}).Debug("ARP sending reply for virtual mac")
 			}
+			c.reply(target.MAC, target.IP, EthernetBroadcast, target.IP)
+			break // break the switch
+		}
+
+		notify += c.stateAction(sender, packet)
 
+	case marp.OperationReply:
+		if c.logArea(LogPackets) {
+			c.log().WithFields(log.Fields{
+				"ip": sender.IP, "mac": sender.MAC, "state": sender.State,
+				"senderip": packet.SenderIP.String(), "to_mac": packet.TargetHardwareAddr, "to_ip": packet.TargetIP}).
+				Debugf("ARP reply received - %s is at %s", packet.SenderIP, sender.MAC)
 		}
 
-		if notify > 0 {
-			if sender.Online == false {
-				sender.Online = true
-				log.WithFields(log.Fields{"mac": sender.MAC, "ip": sender.IP, "previousip": previousIP, "state": sender.State}).Info("ARP device is online")
-			} else {
-				log.WithFields(log.Fields{"mac": sender.MAC, "ip": sender.IP, "previousip": previousIP, "state": sender.State}).Info("ARP device changed IP")
-			}
+		notify += c.stateAction(sender, packet)
 
-			if c.notification != nil {
-				c.notification <- *sender
-			}
+	}
+
+	if notify > 0 {
+		// flip Online and copy the entry for the event under the lock; readers
+		// such as Snapshot and Stats hold the read lock
+		c.mutex.Lock()
+		// wait for more packets if the device was offline; see OfflineThreshold
+		if sender.Online == false && previous.MAC != nil && !c.onlineConfirmedLocked(sender) {
+			c.mutex.Unlock()
+			return
 		}
+		event := EventIPChanged
+		if sender.Online == false {
+			sender.Online = true
+			event = EventDeviceOnline
+		}
+		entry := *sender
+		c.mutex.Unlock()
+
+		if event == EventDeviceOnline {
+			c.log().WithFields(log.Fields{"mac": entry.MAC, "ip": entry.IP, "previousip": previousIP, "state": entry.State, "vendor": entry.Vendor}).Info("ARP device is online")
+		} else {
+			c.log().WithFields(log.Fields{"mac": entry.MAC, "ip": entry.IP, "previousip": previousIP, "state": entry.State}).Info("ARP device changed IP")
+		}
+		if previous.MAC == nil {
+			event = EventNewDevice
+		}
+
+		c.notifyAt(event, previous, entry, u.time)
+		c.counters.notifyLatency.observe(time.Since(u.time))
 	}
 }
@@ -0,0 +1,101 @@
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultHealthWindow is the maximum time without a received frame before
+// Healthy reports an error; see WithHealthWindow.
+const DefaultHealthWindow = time.Minute * 5
+
+var (
+	errHealthNotServing = errors.New("ARP read loop is not running")
+	errHealthNotPolling = errors.New("ARP polling loop is not running")
+	errHealthDegraded   = errors.New("ARP socket is reconnecting")
+)
+
+// WithHealthWindow set the maximum time without a received frame before
+// Healthy reports an error. The default is DefaultHealthWindow.
+func WithHealthWindow(window time.Duration) Option {
+	return func(c *Handler) error {
+		if window <= 0 {
+			return errors.New("invalid health window")
+		}
+		c.healthWindow = window
+		return nil
+	}
+}
+
+// Healthy return nil if the handler is serving; suitable for liveness and
+// readiness probes.
+//
+// It returns an error if the socket is closed or reconnecting, ListenAndServe
+// or its polling loop are not running, the host IP is in conflict or no frame
+// was received in the health window. In a quiet network the polling loop
+// sends a request to the router when half of the window has passed without a
+// frame, so the reply keeps a working handler healthy.
+func (c *Handler) Healthy() error {
+	c.mutex.RLock()
+	client := c.client
+	c.mutex.RUnlock()
+
+	if client == nil || c.goroutinePool.Stopping() {
+		return ErrSocketClosed
+	}
+	if atomic.LoadInt32(&c.serving) == 0 {
+		return errHealthNotServing
+	}
+	if atomic.LoadInt32(&c.polling) == 0 {
+		return errHealthNotPolling
+	}
+	if atomic.LoadInt32(&c.degraded) != 0 {
+		return errHealthDegraded
+	}
+	if err := c.hostConflict(c.getHealthWindow()); err != nil {
+		return err
+	}
+	if idle := c.idle(); idle > c.getHealthWindow() {
+		return fmt.Errorf("ARP no frame received in %v", idle.Truncate(time.Second))
+	}
+	return nil
+}
+
+func (c *Handler) getHealthWindow() time.Duration {
+	if c.healthWindow <= 0 {
+		return DefaultHealthWindow
+	}
+	return c.healthWindow
+}
+
+// frameReceived record the time of the last frame read.
+func (c *Handler) frameReceived(now time.Time) {
+	atomic.StoreInt64(&c.counters.lastFrame, now.UnixNano())
+}
+
+// idle return the time since the last frame read.
+func (c *Handler) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&c.counters.lastFrame)))
+}
+
+// selfProbe send a request to the router if no frame was received in half the
+// health window.
+func (c *Handler) selfProbe() {
+	if c.passive || c.idle() < c.getHealthWindow()/2 {
+		return
+	}
+	routerIP := c.routerIP()
+	if routerIP == nil {
+		return
+	}
+	if c.logArea(LogScan) {
+		c.log().WithFields(log.Fields{"ip": routerIP}).Debug("ARP no frames received - probing router")
+	}
+	if err := c.request(c.config.HostMAC, c.config.HostIP, EthernetBroadcast, routerIP); err != nil {
+		c.log().Error("ARP request error ", err)
+	}
+}
@@ -0,0 +1,37 @@
+package arp
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Healthy(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(newFakeConn()), WithHealthWindow(time.Minute))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	if err := h.Healthy(); err != errHealthNotServing {
+		t.Error("expected not serving error ", err)
+	}
+
+	go h.ListenAndServe(0)
+	for i := 0; h.Healthy() != nil; i++ {
+		if i > 100 {
+			t.Fatal("handler not healthy ", h.Healthy())
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	atomic.StoreInt64(&h.counters.lastFrame, time.Now().Add(-time.Minute*2).UnixNano())
+	if err := h.Healthy(); err == nil {
+		t.Error("expected idle error")
+	}
+
+	h.Stop()
+	if err := h.Healthy(); err != ErrSocketClosed {
+		t.Error("expected closed error ", err)
+	}
+}
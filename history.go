@@ -0,0 +1,76 @@
+package arp
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize is the number of events kept unless WithHistorySize is set.
+const DefaultHistorySize = 256
+
+// eventHistory is a ring buffer with the last published events
+type eventHistory struct {
+	mutex  sync.Mutex
+	events []Event
+	next   int  // index of the next write
+	full   bool // the buffer wrapped around
+}
+
+func newEventHistory(size int) *eventHistory {
+	return &eventHistory{events: make([]Event, size)}
+}
+
+func (h *eventHistory) add(event Event) {
+	h.mutex.Lock()
+	h.events[h.next] = event
+	if h.next++; h.next == len(h.events) {
+		h.next, h.full = 0, true
+	}
+	h.mutex.Unlock()
+}
+
+// since return the events after t, oldest first.
+func (h *eventHistory) since(t time.Time) []Event {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	start, n := 0, h.next
+	if h.full {
+		start, n = h.next, len(h.events)
+	}
+	list := make([]Event, 0, n)
+	for i := 0; i < n; i++ {
+		event := h.events[(start+i)%len(h.events)]
+		if event.Time.After(t) {
+			list = append(list, event)
+		}
+	}
+	return list
+}
+
+// WithHistorySize keep the last size events for History; zero disables the
+// history. The default is DefaultHistorySize.
+func WithHistorySize(size int) Option {
+	return func(c *Handler) error {
+		if size < 0 {
+			return errors.New("invalid history size")
+		}
+		c.history = nil
+		if size > 0 {
+			c.history = newEventHistory(size)
+		}
+		return nil
+	}
+}
+
+// History return the events published after since, oldest first, so a
+// consumer attaching after startup can show recent activity. Periodic
+// EventDeviceSeen events are not kept. Entries in the returned events are
+// shared with other subscribers and must not be modified.
+func (c *Handler) History(since time.Time) []Event {
+	if c.history == nil {
+		return nil
+	}
+	return c.history.since(since)
+}
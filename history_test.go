@@ -0,0 +1,33 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_History(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()), WithHistorySize(2))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	start := time.Now()
+	h.notify(EventNewDevice, Entry{}, Entry{MAC: mac1})
+	h.notify(EventNewDevice, Entry{}, Entry{MAC: mac2})
+	middle := time.Now()
+	h.notify(EventDeviceOffline, Entry{}, Entry{MAC: mac1})
+
+	list := h.History(start)
+	if len(list) != 2 || list[0].Entry.MAC.String() != mac2.String() || list[1].Type != EventDeviceOffline {
+		t.Fatalf("invalid history %+v", list)
+	}
+	if list := h.History(middle); len(list) != 1 || list[0].Type != EventDeviceOffline {
+		t.Fatalf("invalid history since %+v", list)
+	}
+
+	if _, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{}, WithPacketConn(newFakeConn()), WithHistorySize(-1)); err == nil {
+		t.Error("expected error for negative size")
+	}
+}
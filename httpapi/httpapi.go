@@ -0,0 +1,268 @@
+// Package httpapi exposes an arp.Handler as a JSON HTTP API.
+//
+// Endpoints:
+//
+//	GET    /devices            list the ARP table
+//	GET    /devices/{mac}      get a single device
+//	POST   /devices/{mac}/hunt start hunting the device (see arp.Handler.StartHunt); the optional
+//	                           direction query is both, client or router (see arp.HuntDirection)
+//	DELETE /devices/{mac}/hunt stop hunting the device
+//	GET    /status             handler counters
+//	GET    /history?since=     recent events; since is an optional RFC3339 time
+//	GET    /healthz            200 if the handler is healthy, 503 otherwise (see arp.Handler.Healthy)
+//	GET    /debug/pprof/       runtime profiles if WithPprof is set (see net/http/pprof)
+//
+// Hunting a device protected by the spoof deny or allow list returns 403.
+// {mac} may also be the stable device ID (see arp.Entry.DeviceID).
+//
+// Usage:
+//
+//	http.ListenAndServe(":8080", httpapi.New(handler))
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"github.com/irai/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// Device is the JSON representation of an arp.Entry
+type Device struct {
+	MAC         string    `json:"mac"`
+	IP          string    `json:"ip"`
+	Addresses   []string  `json:"addresses,omitempty"`
+	Hostname    string    `json:"hostname,omitempty"`
+	MDNSName    string    `json:"mdnsName,omitempty"`
+	NetBIOSName string    `json:"netbiosName,omitempty"`
+	DeviceID    string    `json:"deviceId,omitempty"`
+	IPv6        []string  `json:"ipv6,omitempty"`
+	State       string    `json:"state"`
+	Online      bool      `json:"online"`
+	LastUpdate  time.Time `json:"lastUpdate"`
+}
+
+func newDevice(e *arp.Entry) Device {
+	d := Device{MAC: e.MAC.String(), IP: e.IP.String(), State: string(e.State), Online: e.Online, LastUpdate: e.LastUpdate,
+		Hostname: e.Hostname, MDNSName: e.MDNSName, NetBIOSName: e.NetBIOSName, DeviceID: e.DeviceID}
+	for _, a := range e.Addresses {
+		d.Addresses = append(d.Addresses, a.IP.String())
+	}
+	for _, ip := range e.IPv6 {
+		d.IPv6 = append(d.IPv6, ip.String())
+	}
+	return d
+}
+
+// Event is the JSON representation of an arp.Event in the history
+type Event struct {
+	Type   string    `json:"type"`
+	Time   time.Time `json:"time"`
+	Device Device    `json:"device"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server is a http.Handler serving the API for an arp.Handler
+type Server struct {
+	handler *arp.Handler
+	pprof   bool
+}
+
+// Option configures the Server
+type Option func(*Server)
+
+// WithPprof serve the net/http/pprof profiles under /debug/pprof/ to profile
+// the handler in place, i.e.
+//
+//	go tool pprof http://host:8080/debug/pprof/profile?seconds=30
+//
+// The profiles expose the process internals; do not enable it on a public
+// address.
+func WithPprof() Option {
+	return func(s *Server) { s.pprof = true }
+}
+
+// New return a http.Handler serving the API for h
+func New(h *arp.Handler, opts ...Option) *Server {
+	s := &Server{handler: h}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case s.pprof && len(path) >= 2 && path[0] == "debug" && path[1] == "pprof":
+		servePprof(w, r, path[2:])
+
+	case len(path) == 1 && path[0] == "status":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, http.StatusOK, s.handler.Stats())
+
+	case len(path) == 1 && path[0] == "healthz":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		if err := s.handler.Healthy(); err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, struct{}{})
+
+	case len(path) == 1 && path[0] == "history":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		var since time.Time
+		if s := r.URL.Query().Get("since"); s != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339, s); err != nil {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+				return
+			}
+		}
+		history := s.handler.History(since)
+		events := make([]Event, 0, len(history))
+		for i := range history {
+			events = append(events, Event{Type: string(history[i].Type), Time: history[i].Time, Device: newDevice(&history[i].Entry)})
+		}
+		writeJSON(w, http.StatusOK, events)
+
+	case len(path) == 1 && path[0] == "devices":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		table := s.handler.Snapshot()
+		devices := make([]Device, 0, len(table))
+		for i := range table {
+			devices = append(devices, newDevice(&table[i]))
+		}
+		writeJSON(w, http.StatusOK, devices)
+
+	case len(path) == 2 && path[0] == "devices":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		entry, ok := s.findDevice(w, path[1])
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, newDevice(&entry))
+
+	case len(path) == 3 && path[0] == "devices" && path[2] == "hunt":
+		if !allowMethod(w, r, http.MethodPost, http.MethodDelete) {
+			return
+		}
+		entry, ok := s.findDevice(w, path[1])
+		if !ok {
+			return
+		}
+
+		var err error
+		if r.Method == http.MethodPost {
+			var direction arp.HuntDirection
+			if direction, err = arp.ParseHuntDirection(r.URL.Query().Get("direction")); err != nil {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+				return
+			}
+			err = s.handler.StartHuntDirection(entry.MAC, direction)
+		} else {
+			err = s.handler.StopHunt(entry.MAC)
+		}
+		if errors.Is(err, arp.ErrHuntNotAllowed) {
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: err.Error()})
+			return
+		}
+		if errors.Is(err, arp.ErrNotFound) { // deleted after findDevice
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+			return
+		}
+		mac := entry.MAC
+		if entry, ok = s.handler.SnapshotMAC(mac); !ok { // deleted after the hunt changed
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: "mac not found " + mac.String()})
+			return
+		}
+		writeJSON(w, http.StatusOK, newDevice(&entry))
+
+	default:
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "not found"})
+	}
+}
+
+// findDevice return a copy of the entry with the MAC or device ID in value; it
+// writes the error response and returns false if not found.
+func (s *Server) findDevice(w http.ResponseWriter, value string) (arp.Entry, bool) {
+	mac, err := net.ParseMAC(value)
+	if err != nil {
+		if entry, ok := s.handler.SnapshotDevice(value); ok { // stable device ID
+			return entry, true
+		}
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return arp.Entry{}, false
+	}
+	entry, ok := s.handler.SnapshotMAC(mac)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "mac not found " + mac.String()})
+		return arp.Entry{}, false
+	}
+	return entry, true
+}
+
+// servePprof serve the net/http/pprof handler of the profile in path; Index
+// serves the named runtime profiles, i.e. heap and goroutine.
+func servePprof(w http.ResponseWriter, r *http.Request, path []string) {
+	name := ""
+	if len(path) > 0 {
+		name = path[0]
+	}
+	switch name {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Index(w, r)
+	}
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("HTTP error encoding response ", err)
+	}
+}
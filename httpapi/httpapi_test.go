@@ -0,0 +1,122 @@
+package httpapi
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/irai/arp"
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+func Test_ServeHTTP(t *testing.T) {
+	s := New(&arp.Handler{})
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{http.MethodGet, "/devices", http.StatusOK, "[]"},
+		{http.MethodGet, "/status", http.StatusOK, "PacketsRead"},
+		{http.MethodGet, "/history", http.StatusOK, "[]"},
+		{http.MethodGet, "/history?since=yesterday", http.StatusBadRequest, "error"},
+		{http.MethodGet, "/healthz", http.StatusServiceUnavailable, "socket is closed"},
+		{http.MethodGet, "/devices/01:02:03:04:05:06", http.StatusNotFound, "mac not found"},
+		{http.MethodGet, "/devices/invalid", http.StatusBadRequest, "error"},
+		{http.MethodPost, "/devices", http.StatusMethodNotAllowed, "method not allowed"},
+		{http.MethodGet, "/devices/01:02:03:04:05:06/hunt", http.StatusMethodNotAllowed, "method not allowed"},
+		{http.MethodGet, "/unknown", http.StatusNotFound, "not found"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s %s: got %d %q", tt.method, tt.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func Test_Pprof(t *testing.T) {
+	w := httptest.NewRecorder()
+	New(&arp.Handler{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Error("expected pprof disabled by default ", w.Code)
+	}
+
+	s := New(&arp.Handler{}, WithPprof())
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/debug/pprof/", "goroutine"},
+		{"/debug/pprof/goroutine?debug=1", "goroutine profile"},
+		{"/debug/pprof/cmdline", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s: got %d %q", tt.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+// nullConn is a PacketConn that never receives packets and discards writes
+type nullConn struct {
+	closed chan struct{}
+}
+
+func (c nullConn) Read() (*marp.Packet, *ethernet.Frame, error) {
+	<-c.closed
+	return nil, nil, io.EOF
+}
+func (c nullConn) WriteTo(p *marp.Packet, addr net.HardwareAddr) error { return nil }
+func (c nullConn) Close() error                                        { return nil }
+func (c nullConn) SetReadDeadline(t time.Time) error                   { return nil }
+
+func Test_ServeDevice(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	h, err := arp.NewHandler("eth0", mac, net.IPv4(192, 168, 0, 1), net.IPv4(192, 168, 0, 254),
+		net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, arp.WithPacketConn(nullConn{closed: make(chan struct{})}))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	if err := h.ImportCSV(strings.NewReader("02:00:00:00:00:10,192.168.0.10\n")); err != nil {
+		t.Fatal("ImportCSV error ", err)
+	}
+	entry, ok := h.SnapshotMAC(net.HardwareAddr{0x02, 0, 0, 0, 0, 0x10})
+	if !ok {
+		t.Fatal("device not imported")
+	}
+	s := New(h)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{http.MethodGet, "/devices", http.StatusOK, "192.168.0.10"},
+		{http.MethodGet, "/devices/02:00:00:00:00:10", http.StatusOK, `"state":"normal"`},
+		{http.MethodGet, "/devices/" + entry.DeviceID, http.StatusOK, "02:00:00:00:00:10"},
+		{http.MethodPost, "/devices/02:00:00:00:00:10/hunt", http.StatusOK, `"state":"hunt"`},
+		{http.MethodDelete, "/devices/02:00:00:00:00:10/hunt", http.StatusOK, `"state":"normal"`},
+		{http.MethodPost, "/devices/02:00:00:00:00:11/hunt", http.StatusNotFound, "mac not found"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s %s: got %d %q", tt.method, tt.path, w.Code, w.Body.String())
+		}
+	}
+}
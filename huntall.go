@@ -0,0 +1,141 @@
+package arp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The hunt all loop looks for new devices every huntAllInterval and starts
+// the hunts huntAllStagger apart so the re-poisoning of the subnet is spread
+// over the spoof interval instead of bursting every cycle.
+var (
+	huntAllInterval = time.Second * 10
+	huntAllStagger  = time.Millisecond * 250
+)
+
+// HuntAll hunt every online device in the table that is allowed by the spoof
+// lists, except the router and the host; see ForceIPChange.
+//
+// Devices that come online or become allowed later are hunted when found and
+// a device that leaves hunt state, i.e. after a hunt timeout or an IP change,
+// is hunted again. Each hunt has its own virtual host and spoof interval.
+// It returns an error if HuntAll is already running; call StopAll to end it.
+func (c *Handler) HuntAll() error {
+	if c.passive {
+		return ErrPassive
+	}
+	c.mutex.Lock()
+	if c.huntAll != nil {
+		c.mutex.Unlock()
+		return errors.New("hunt all already running")
+	}
+	stop := make(chan struct{})
+	c.huntAll = stop
+	c.mutex.Unlock()
+
+	c.log().WithFields(log.Fields{"nic": c.config.NIC}).Info("ARP hunt all start")
+	go c.huntAllLoop(c.goroutinePool.Begin("ARP huntAllLoop"), stop)
+	return nil
+}
+
+// StopAll end HuntAll and stop every hunt in progress, including the hunts
+// started with StartHunt or ForceIPChange. The client caches are restored as
+// for StopHunt.
+func (c *Handler) StopAll() {
+	c.mutex.Lock()
+	if c.huntAll != nil {
+		close(c.huntAll)
+		c.huntAll = nil
+		c.log().WithFields(log.Fields{"nic": c.config.NIC}).Info("ARP hunt all stop")
+	}
+	macs := make([]net.HardwareAddr, 0, len(c.hunts))
+	for _, entry := range c.table {
+		if entry != nil && entry.State == StateHunt {
+			macs = append(macs, entry.MAC)
+		}
+	}
+	c.mutex.Unlock()
+
+	for _, mac := range macs {
+		c.StopIPChange(mac)
+	}
+}
+
+// HuntingAll is true while HuntAll is running.
+func (c *Handler) HuntingAll() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.huntAll != nil
+}
+
+// huntAllLoop hunt the devices returned by huntAllCandidates until StopAll is called.
+func (c *Handler) huntAllLoop(h *goroutine, stop chan struct{}) {
+	defer h.End()
+
+	ticker := time.NewTicker(huntAllInterval)
+	defer ticker.Stop()
+	for {
+		for i, entry := range c.huntAllCandidates() {
+			if i > 0 {
+				select {
+				case <-time.After(huntAllStagger):
+				case <-stop:
+					return
+				case <-c.goroutinePool.StopChannel:
+					return
+				}
+			}
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := c.ForceIPChange(entry.MAC, entry.IP); err != nil {
+				if c.logArea(LogSpoof) {
+					c.log().WithFields(log.Fields{"mac": entry.MAC.String(), "ip": entry.IP}).Debug("ARP hunt all skipped device ", err)
+				}
+				continue
+			}
+			select {
+			case <-stop: // StopAll listed the hunts before this one started
+				c.StopIPChange(entry.MAC)
+				return
+			default:
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		case <-c.goroutinePool.StopChannel:
+			return
+		}
+	}
+}
+
+// huntAllCandidates return a copy of the online devices that can be hunted.
+func (c *Handler) huntAllCandidates() []Entry {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	list := []Entry{}
+	for _, entry := range c.table {
+		if entry == nil || entry.State != StateNormal || !entry.Online || entry.IP.Equal(net.IPv4zero) {
+			continue
+		}
+		if entry.IP.Equal(c.config.RouterIP) || entry.IP.Equal(c.config.HostIP) ||
+			bytes.Equal(entry.MAC, c.config.RouterMAC) || bytes.Equal(entry.MAC, c.config.HostMAC) {
+			continue
+		}
+		if c.checkSpoofLocked(entry.MAC, entry.IP) != nil {
+			continue
+		}
+		list = append(list, entry.copy())
+	}
+	return list
+}
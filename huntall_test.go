@@ -0,0 +1,44 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_HuntAll(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	h.mutex.Lock()
+	h.arpTableAppendLocked(StateNormal, mac1, ip1).Online = true
+	h.arpTableAppendLocked(StateNormal, mac2, ip2).Online = true
+	h.arpTableAppendLocked(StateNormal, net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x04}, net.IPv4(192, 168, 0, 4).To4()) // offline
+	h.mutex.Unlock()
+	h.SetSpoofDenyList([]net.HardwareAddr{mac2}, nil)
+
+	if err := h.HuntAll(); err != nil {
+		t.Fatal("HuntAll error ", err)
+	}
+	if err := h.HuntAll(); err == nil {
+		t.Error("HuntAll twice should fail")
+	}
+
+	for i := 0; len(h.HuntList()) == 0 && i < 100; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if list := h.HuntList(); len(list) != 1 || list[0].MAC.String() != mac1.String() {
+		t.Errorf("invalid hunt list %+v", list)
+	}
+
+	h.StopAll()
+	if h.HuntingAll() {
+		t.Error("hunt all still running")
+	}
+	if list := h.HuntList(); len(list) != 0 {
+		t.Errorf("invalid hunt list %+v", list)
+	}
+}
@@ -0,0 +1,128 @@
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HuntDirection selects the ARP caches poisoned by a hunt.
+type HuntDirection int
+
+const (
+	// HuntBoth spoof the client view of the router and claim the client IP
+	// with a virtual host; this is the ForceIPChange behaviour.
+	HuntBoth HuntDirection = iota
+
+	// HuntClient spoof only the client view of the router so the traffic
+	// from the client comes to the host, i.e. for outbound filtering.
+	HuntClient
+
+	// HuntRouter spoof only the router view of the client so the traffic
+	// to the client comes to the host. It requires the router MAC.
+	HuntRouter
+)
+
+// String return the direction name used by ParseHuntDirection
+func (d HuntDirection) String() string {
+	switch d {
+	case HuntBoth:
+		return "both"
+	case HuntClient:
+		return "client"
+	case HuntRouter:
+		return "router"
+	}
+	return fmt.Sprintf("HuntDirection(%d)", int(d))
+}
+
+// ParseHuntDirection return the direction named "both", "client" or "router".
+func ParseHuntDirection(s string) (HuntDirection, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "both", "":
+		return HuntBoth, nil
+	case "client":
+		return HuntClient, nil
+	case "router":
+		return HuntRouter, nil
+	}
+	return HuntBoth, fmt.Errorf("invalid hunt direction %q", s)
+}
+
+// StartHuntDirection start hunting the device in one direction only; see StartHunt.
+//
+// HuntClient and HuntRouter do not create a virtual host hence the device is
+// not forced to change IP; the hunt ends with StopHunt, a hunt timeout or when
+// the device changes IP by itself. The cache spoofed is restored when the hunt ends.
+func (c *Handler) StartHuntDirection(mac net.HardwareAddr, direction HuntDirection) error {
+	if direction < HuntBoth || direction > HuntRouter {
+		return fmt.Errorf("invalid hunt direction %d", int(direction))
+	}
+	if err := checkMAC(mac); err != nil {
+		return err
+	}
+	entry := c.FindMAC(mac)
+	if entry == nil || entry.State == StateVirtualHost {
+		return errMACNotFound(mac)
+	}
+	if direction == HuntRouter && c.routerMAC() == nil {
+		return errors.New("router mac unknown")
+	}
+	return c.forceIPChange(entry.MAC, entry.IP, direction)
+}
+
+// routerMAC return the router MAC or nil if unknown; the slice is replaced and never modified by SetRouter
+func (c *Handler) routerMAC() net.HardwareAddr {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.config.RouterMAC
+}
+
+// forceSpoofRouter send ARP packets to spoof the router MAC table to send client packets to host instead of the client
+// i.e.  192.168.0.10->ClientMAC becomes 192.168.0.10->HostMAC
+func (c *Handler) forceSpoofRouter(mac net.HardwareAddr, ip net.IP) error {
+	if err := c.CheckSpoof(mac, ip); err != nil {
+		c.logSpoofDenied(mac, ip)
+		return err
+	}
+
+	routerMAC := c.routerMAC()
+	if routerMAC == nil {
+		err := errors.New("router mac unknown")
+		c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Error("ARP spoof router error ", err)
+		return err
+	}
+
+	// Announce to router that we own the client IP
+	err := c.announceUnicast(c.config.HostMAC, ip, routerMAC)
+	if err != nil {
+		c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Error("ARP error send announcement packet", err)
+		return err
+	}
+
+	routerIP := c.routerIP()
+	for i := 0; i < 2; i++ {
+		err = c.replySpoof(c.config.HostMAC, ip, routerMAC, routerIP)
+		if err != nil {
+			c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Error("ARP spoof router error", err)
+			return err
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return nil
+}
+
+// restoreRouter announce the client IP to the router after a HuntRouter hunt
+func (c *Handler) restoreRouter(ip net.IP) {
+	routerMAC := c.routerMAC()
+	if routerMAC == nil {
+		return
+	}
+	if err := c.AnnounceTo(routerMAC, ip); err != nil && c.logArea(LogSpoof) {
+		c.log().WithFields(log.Fields{"ip": ip}).Debug("ARP cannot restore router cache ", err)
+	}
+}
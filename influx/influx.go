@@ -0,0 +1,252 @@
+// Package influx periodically writes the arp.Handler devices and counters in
+// InfluxDB line protocol so the handler plugs into existing TIG stacks.
+//
+// Each interval it writes one line per device and one line with the handler
+// counters:
+//
+//	arp_device,mac=02:00:00:00:00:01,ip=192.168.0.1,name=laptop online=1i,packets=42i 1700000000000000000
+//	arp_handler packets_read=1234i,requests_sent=300i,replies_sent=2i,read_errors=0i,... 1700000000000000000
+//
+// The destination is a URL: udp://host:8089 and tcp://host:8094 for the
+// Telegraf socket_listener input, or an http(s) write endpoint such as
+// http://influxdb:8086/api/v2/write?org=home&bucket=arp.
+//
+// Usage:
+//
+//	e, err := influx.New(handler, "udp://telegraf:8089", influx.WithTag("nic", "eth0"))
+//	go e.Run(ctx)
+package influx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/irai/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultInterval is the write interval unless WithInterval is set
+const DefaultInterval = time.Second * 10
+
+// maxDatagram is the maximum UDP payload; lines are split across datagrams
+const maxDatagram = 1400
+
+// Exporter writes the handler devices and counters in line protocol
+type Exporter struct {
+	handler  *arp.Handler
+	url      *url.URL
+	interval time.Duration
+	prefix   string
+	tags     map[string]string
+	tagList  string // sorted and escaped tags starting with a comma
+	token    string
+	client   *http.Client
+	conn     net.Conn // udp and tcp
+}
+
+// Option configures the Exporter
+type Option func(*Exporter)
+
+// WithInterval set the write interval.
+func WithInterval(d time.Duration) Option {
+	return func(e *Exporter) { e.interval = d }
+}
+
+// WithPrefix set the measurement prefix; the default is "arp".
+func WithPrefix(prefix string) Option {
+	return func(e *Exporter) { e.prefix = prefix }
+}
+
+// WithTag add a tag to every line, i.e. the host or the interface name.
+func WithTag(key string, value string) Option {
+	return func(e *Exporter) { e.tags[key] = value }
+}
+
+// WithToken set the token sent in the Authorization header of http writes.
+func WithToken(token string) Option {
+	return func(e *Exporter) { e.token = token }
+}
+
+// New return an Exporter writing the devices of h to rawURL.
+func New(h *arp.Handler, rawURL string, opts ...Option) (*Exporter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "udp", "tcp", "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported influx url scheme %q", u.Scheme)
+	}
+	e := &Exporter{handler: h, url: u, interval: DefaultInterval, prefix: "arp", client: &http.Client{Timeout: time.Second * 10},
+		tags: map[string]string{}}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.tagList = formatTags(e.tags)
+	return e, nil
+}
+
+// Run write the lines every interval until ctx is done.
+func (e *Exporter) Run(ctx context.Context) error {
+	defer e.close()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			if err := e.write(ctx, e.Lines(now)); err != nil {
+				log.WithFields(log.Fields{"url": e.url.Redacted()}).Error("INFLUX cannot write ", err)
+				e.close() // reconnect on the next interval
+			}
+		}
+	}
+}
+
+// Lines return the device and handler lines for the time now.
+func (e *Exporter) Lines(now time.Time) []byte {
+	var b bytes.Buffer
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+
+	for _, entry := range e.handler.Snapshot() {
+		if entry.State != arp.StateVirtualHost {
+			e.writeDevice(&b, entry, ts)
+		}
+	}
+
+	s := e.handler.Stats()
+	fmt.Fprintf(&b, "%s_handler%s packets_read=%di,requests_sent=%di,replies_sent=%di,read_errors=%di,packets_ignored=%di,"+
+		"notifications_dropped=%di,devices_online=%di,hunts_active=%di %s\n",
+		e.prefix, e.tagList, s.PacketsRead, s.RequestsSent, s.RepliesSent, s.ReadErrors, s.PacketsIgnored,
+		s.NotificationsDropped, s.DevicesOnline, s.HuntsActive, ts)
+	return b.Bytes()
+}
+
+// writeDevice write the device line for entry.
+func (e *Exporter) writeDevice(b *bytes.Buffer, entry arp.Entry, ts string) {
+	b.WriteString(e.prefix)
+	b.WriteString("_device,mac=")
+	b.WriteString(entry.MAC.String())
+	b.WriteString(",ip=")
+	b.WriteString(entry.IP.String())
+	if name := displayName(entry); name != "" {
+		b.WriteString(",name=")
+		b.WriteString(escape(name))
+	}
+	b.WriteString(e.tagList)
+	online := 0
+	if entry.Online {
+		online = 1
+	}
+	fmt.Fprintf(b, " online=%di,packets=%di %s\n", online, entry.Packets, ts)
+}
+
+// write send the lines to the destination.
+func (e *Exporter) write(ctx context.Context, lines []byte) error {
+	if e.url.Scheme == "http" || e.url.Scheme == "https" {
+		return e.post(ctx, lines)
+	}
+
+	if e.conn == nil {
+		d := net.Dialer{Timeout: time.Second * 10}
+		conn, err := d.DialContext(ctx, e.url.Scheme, e.url.Host)
+		if err != nil {
+			return err
+		}
+		e.conn = conn
+	}
+	e.conn.SetWriteDeadline(time.Now().Add(time.Second * 10))
+	if e.url.Scheme == "tcp" {
+		_, err := e.conn.Write(lines)
+		return err
+	}
+
+	// udp; split at line boundaries
+	for len(lines) > 0 {
+		n := len(lines)
+		if n > maxDatagram {
+			if n = bytes.LastIndexByte(lines[:maxDatagram], '\n') + 1; n == 0 {
+				n = bytes.IndexByte(lines, '\n') + 1 // a single long line
+			}
+		}
+		if _, err := e.conn.Write(lines[:n]); err != nil {
+			return err
+		}
+		lines = lines[n:]
+	}
+	return nil
+}
+
+func (e *Exporter) post(ctx context.Context, lines []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url.String(), bytes.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if e.token != "" {
+		req.Header.Set("Authorization", "Token "+e.token)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("influx returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (e *Exporter) close() {
+	if e.conn != nil {
+		e.conn.Close()
+		e.conn = nil
+	}
+}
+
+// displayName return the most friendly name known for the entry or "".
+func displayName(e arp.Entry) string {
+	for _, name := range []string{e.Name, e.MDNSName, e.Hostname, e.NetBIOSName} {
+		if name != "" {
+			return name
+		}
+	}
+	if e.DHCP != nil {
+		return e.DHCP.Hostname
+	}
+	return ""
+}
+
+// escape a tag key or value; commas, equal signs and spaces are escaped.
+func escape(s string) string {
+	return tagEscaper.Replace(s)
+}
+
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", "")
+
+// formatTags return the escaped tags sorted by key as recommended by InfluxDB.
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString("," + escape(k) + "=" + escape(tags[k]))
+	}
+	return b.String()
+}
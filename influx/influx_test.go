@@ -0,0 +1,73 @@
+package influx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/irai/arp"
+)
+
+func Test_Lines(t *testing.T) {
+	e, err := New(&arp.Handler{}, "udp://127.0.0.1:8089", WithTag("nic", "eth0"), WithTag("host", "nas 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	entry := arp.Entry{MAC: net.HardwareAddr{0x02, 0, 0, 0, 0, 1}, IP: net.IPv4(192, 168, 0, 1).To4(), Online: true, Packets: 42, Name: "living room,tv"}
+	e.writeDevice(&b, entry, "1")
+	if want := `arp_device,mac=02:00:00:00:00:01,ip=192.168.0.1,name=living\ room\,tv,host=nas\ 1,nic=eth0 online=1i,packets=42i 1` + "\n"; b.String() != want {
+		t.Errorf("invalid device line got %q want %q", b.String(), want)
+	}
+
+	lines := string(e.Lines(time.Unix(0, 5)))
+	if !strings.HasPrefix(lines, "arp_handler,host=nas\\ 1,nic=eth0 packets_read=0i,") || !strings.HasSuffix(lines, " 5\n") {
+		t.Errorf("invalid handler line %q", lines)
+	}
+
+	if _, err := New(&arp.Handler{}, "ftp://host"); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
+
+func Test_Write(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen udp ", err)
+	}
+	defer conn.Close()
+
+	// long batches are split at line boundaries
+	e, _ := New(&arp.Handler{}, "udp://"+conn.LocalAddr().String())
+	defer e.close()
+	line := strings.Repeat("x", 999) + "\n"
+	if err := e.write(context.Background(), []byte(line+line)); err != nil {
+		t.Fatal("write error ", err)
+	}
+	buf := make([]byte, 4096)
+	for i := 0; i < 2; i++ {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if n, _, err := conn.ReadFrom(buf); err != nil || string(buf[:n]) != line {
+			t.Fatalf("invalid datagram %d %v", n, err)
+		}
+	}
+
+	var body, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body, auth = string(b), r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	e, _ = New(&arp.Handler{}, server.URL+"/api/v2/write?bucket=arp", WithToken("secret"))
+	if err := e.write(context.Background(), []byte(line)); err != nil || body != line || auth != "Token secret" {
+		t.Errorf("invalid http write %v %q", err, auth)
+	}
+}
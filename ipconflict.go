@@ -0,0 +1,152 @@
+package arp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// AlertIPConflict when two MACs claim the same IP; see WithIPConflictDetection
+const AlertIPConflict AlertType = "ipconflict"
+
+// defaultIPConflictWindow is the window used when WithIPConflictDetection is passed zero
+const defaultIPConflictWindow = time.Minute
+
+// IPConflict is an IP claimed by more than one MAC; see WithIPConflictDetection.
+type IPConflict struct {
+	IP    net.IP
+	MACs  []net.HardwareAddr // claimants; the first is the owner kept in the table
+	Count int                // packets from the other claimants
+	First time.Time
+	Last  time.Time
+}
+
+type ipConflictTable struct {
+	window    time.Duration
+	mutex     sync.Mutex
+	conflicts map[string]*IPConflict // by IP
+}
+
+// WithIPConflictDetection detect two MACs claiming the same IP within window
+// and send EventIPConflict with a SpoofAlert listing both claimants; zero
+// window is one minute.
+//
+// While the conflict lasts the IP stays with the MAC that held it in the table
+// instead of moving to whichever MAC spoke last; FindIP returns the owner and
+// the other claimant keeps the IP in its own entry. The event is sent once per
+// claimant until the conflict is not seen for a window. IPConflicts returns
+// the evidence.
+func WithIPConflictDetection(window time.Duration) Option {
+	return func(c *Handler) error {
+		if window < 0 {
+			return errors.New("invalid IP conflict window")
+		}
+		if window == 0 {
+			window = defaultIPConflictWindow
+		}
+		c.ipConflict = &ipConflictTable{window: window, conflicts: make(map[string]*IPConflict)}
+		return nil
+	}
+}
+
+// IPConflicts return the IP conflicts seen in the window sorted by IP.
+func (c *Handler) IPConflicts() []IPConflict {
+	d := c.ipConflict
+	if d == nil {
+		return nil
+	}
+	now := time.Now()
+	d.mutex.Lock()
+	list := make([]IPConflict, 0, len(d.conflicts))
+	for _, r := range d.conflicts {
+		if now.Sub(r.Last) < d.window {
+			list = append(list, r.copy())
+		}
+	}
+	d.mutex.Unlock()
+	sort.Slice(list, func(i, j int) bool { return bytes.Compare(list[i].IP, list[j].IP) < 0 })
+	return list
+}
+
+func (r *IPConflict) copy() IPConflict {
+	v := *r
+	v.IP = dupIP(r.IP)
+	v.MACs = make([]net.HardwareAddr, len(r.MACs))
+	for i := range r.MACs {
+		v.MACs[i] = dupMAC(r.MACs[i])
+	}
+	return v
+}
+
+// owns return true if entry holds ip and was seen in the window, so the IP
+// must not move to another MAC.
+func (d *ipConflictTable) owns(entry *Entry, ip net.IP, now time.Time) bool {
+	return entry.Online && entry.IP.Equal(ip) && now.Sub(entry.LastUpdate) < d.window
+}
+
+// conflictOwnerLocked return true if another entry owns ip; see WithIPConflictDetection.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) conflictOwnerLocked(entry *Entry, ip net.IP) bool {
+	if c.ipConflict == nil || entry.State == StateVirtualHost {
+		return false
+	}
+	other := lookupIP(c.ipIndex, ip)
+	return other != nil && other != entry && c.ipConflict.owns(other, ip, time.Now())
+}
+
+// detectIPConflict check if the packet claims an IP owned by another MAC.
+func (c *Handler) detectIPConflict(packet *marp.Packet) {
+	d := c.ipConflict
+	if packet.SenderIP.Equal(net.IPv4zero) || bytes.Equal(packet.SenderHardwareAddr, c.config.HostMAC) {
+		return
+	}
+	now := time.Now()
+	c.mutex.RLock()
+	owner := lookupIP(c.ipIndex, packet.SenderIP)
+	if owner == nil || bytes.Equal(owner.MAC, packet.SenderHardwareAddr) || !d.owns(owner, packet.SenderIP, now) {
+		c.mutex.RUnlock()
+		return
+	}
+	if e := c.findMACLocked(packet.SenderHardwareAddr); e != nil && e.State == StateVirtualHost {
+		c.mutex.RUnlock()
+		return
+	}
+	ownerMAC := owner.MAC
+	c.mutex.RUnlock()
+
+	d.mutex.Lock()
+	key := ipKey(packet.SenderIP)
+	r := d.conflicts[key]
+	if r == nil || now.Sub(r.Last) >= d.window {
+		if r == nil && len(d.conflicts) >= spoofDetectorMaxRecords {
+			for k, r := range d.conflicts {
+				if now.Sub(r.Last) >= d.window {
+					delete(d.conflicts, k)
+				}
+			}
+		}
+		r = &IPConflict{IP: dupIP(packet.SenderIP).To4(), MACs: []net.HardwareAddr{dupMAC(ownerMAC)}, First: now}
+		d.conflicts[key] = r
+	}
+	r.Count++
+	r.Last = now
+	if containsMAC(r.MACs, packet.SenderHardwareAddr) {
+		d.mutex.Unlock()
+		return
+	}
+	r.MACs = append(r.MACs, dupMAC(packet.SenderHardwareAddr))
+	conflict := r.copy()
+	d.mutex.Unlock()
+
+	alert := &SpoofAlert{Type: AlertIPConflict, Severity: SeverityMedium, MAC: dupMAC(packet.SenderHardwareAddr), IP: conflict.IP,
+		MACs: conflict.MACs, Count: conflict.Count, Window: d.window}
+	c.log().WithFields(log.Fields{"ip": conflict.IP, "mac": alert.MAC.String(), "owner": ownerMAC.String()}).Warn("ARP IP address conflict")
+	c.publish(Event{Type: EventIPConflict, Time: now, NIC: c.config.NIC, Entry: Entry{MAC: alert.MAC, IP: alert.IP}, Alert: alert})
+}
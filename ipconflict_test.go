@@ -0,0 +1,46 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_IPConflict(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()), WithIPConflictDetection(0))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropNewest)
+
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip3))
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac2, ip1, EthernetBroadcast, ip3))
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac2, ip1, EthernetBroadcast, ip3))
+
+	if e := h.FindIP(ip1); e == nil || e.MAC.String() != mac1.String() {
+		t.Errorf("IP moved to the last claimant %+v", e)
+	}
+	list := h.IPConflicts()
+	if len(list) != 1 || !list[0].IP.Equal(ip1) || len(list[0].MACs) != 2 || list[0].MACs[0].String() != mac1.String() ||
+		list[0].MACs[1].String() != mac2.String() || list[0].Count != 2 {
+		t.Fatalf("invalid conflicts %+v", list)
+	}
+
+	var events []Event
+	for done := false; !done; {
+		select {
+		case e := <-s.C:
+			if e.Type == EventIPConflict {
+				events = append(events, e)
+			}
+		case <-time.After(time.Millisecond * 100):
+			done = true
+		}
+	}
+	if len(events) != 1 || events[0].Alert == nil || events[0].Alert.MAC.String() != mac2.String() || len(events[0].Alert.MACs) != 2 {
+		t.Errorf("invalid conflict events %+v", events)
+	}
+}
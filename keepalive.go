@@ -0,0 +1,106 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// keepaliveLinkCheck is the interval between interface state checks
+var keepaliveLinkCheck = time.Second * 2
+
+type keepalive struct {
+	interval time.Duration
+	flap     chan struct{} // signal the socket was reopened
+}
+
+// WithKeepalive send a gratuitous ARP for the host and for the virtual hosts
+// added with AddVirtualHost or ClaimIP every interval, so switches and peers
+// keep the bindings, i.e. after an IP takeover.
+//
+// The announcements are also sent when the interface comes back up and when
+// ListenAndServe reopens the socket.
+func WithKeepalive(interval time.Duration) Option {
+	return func(c *Handler) error {
+		if interval <= 0 {
+			return errors.New("invalid keepalive interval")
+		}
+		c.keepalive = &keepalive{interval: interval, flap: make(chan struct{}, 1)}
+		return nil
+	}
+}
+
+// signalKeepalive wake up keepaliveLoop to announce the bindings now; it never blocks
+func (c *Handler) signalKeepalive() {
+	if c.keepalive == nil {
+		return
+	}
+	select {
+	case c.keepalive.flap <- struct{}{}:
+	default:
+	}
+}
+
+// keepaliveLoop announce the host and the virtual hosts every interval and after interface flaps.
+func (c *Handler) keepaliveLoop(h *goroutine) {
+	defer h.End()
+
+	ticker := time.NewTicker(c.keepalive.interval)
+	defer ticker.Stop()
+	link := time.NewTicker(keepaliveLinkCheck)
+	defer link.Stop()
+	up := c.linkUp()
+
+	c.sendKeepalive()
+	for {
+		select {
+		case <-c.goroutinePool.StopChannel:
+			return
+
+		case <-ticker.C:
+			c.sendKeepalive()
+
+		case <-c.keepalive.flap:
+			c.sendKeepalive()
+
+		case <-link.C:
+			previous := up
+			if up = c.linkUp(); up && !previous {
+				c.log().WithFields(log.Fields{"nic": c.config.NIC}).Info("ARP interface is up - announcing")
+				c.sendKeepalive()
+			}
+		}
+	}
+}
+
+// linkUp return true if the interface is up or its state is unknown.
+func (c *Handler) linkUp() bool {
+	ifi, err := net.InterfaceByName(c.config.NIC)
+	if err != nil {
+		return true
+	}
+	return ifi.Flags&net.FlagUp != 0
+}
+
+// sendKeepalive send a gratuitous ARP for the host and each virtual host.
+func (c *Handler) sendKeepalive() {
+	c.mutex.RLock()
+	hosts := make([]Entry, 0, len(c.virtualHosts))
+	for _, entry := range c.virtualHosts {
+		hosts = append(hosts, Entry{MAC: entry.MAC, IP: entry.IP})
+	}
+	c.mutex.RUnlock()
+	hosts = append(hosts, Entry{MAC: c.config.HostMAC, IP: c.config.HostIP})
+
+	if c.logArea(LogScan) {
+		c.log().WithFields(log.Fields{"hosts": len(hosts)}).Debug("ARP keepalive announcements")
+	}
+	for _, host := range hosts {
+		if err := c.Gratuitous(host.IP, host.MAC); err != nil {
+			c.log().WithFields(log.Fields{"ip": host.IP, "mac": host.MAC.String()}).Error("ARP keepalive error ", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_Keepalive(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(conn), WithKeepalive(time.Hour))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	vip := net.IPv4(192, 168, 0, 100).To4()
+	if err := h.AddVirtualHost(vip, nil); err != nil {
+		t.Fatal("AddVirtualHost error ", err)
+	}
+
+	announced := func() (ips []string) {
+		for len(ips) < 2 {
+			select {
+			case p := <-conn.out:
+				if p.Operation == marp.OperationReply && p.SenderIP.Equal(p.TargetIP) {
+					ips = append(ips, p.SenderIP.String())
+				}
+			case <-time.After(time.Second):
+				return ips
+			}
+		}
+		return ips
+	}
+
+	go h.keepaliveLoop(h.goroutinePool.Begin("ARP keepaliveLoop"))
+	if ips := announced(); len(ips) != 2 || ips[0] != vip.String() || ips[1] != ip3.String() {
+		t.Fatalf("invalid start announcements %v", ips)
+	}
+	h.signalKeepalive() // socket reopened
+	if ips := announced(); len(ips) != 2 {
+		t.Fatalf("invalid flap announcements %v", ips)
+	}
+}
@@ -0,0 +1,56 @@
+package arp
+
+import (
+	"net"
+)
+
+// SetLabel set a label on the entry (i.e. "owner", "room" or a policy tag)
+// and save it to the store if there is one; an empty value removes the label.
+//
+// Labels are copied to the entries in events and snapshots. The labels map
+// is copy on write so entries returned by FindMAC and GetTable must not
+// modify it.
+func (c *Handler) SetLabel(mac net.HardwareAddr, key string, value string) error {
+	c.mutex.Lock()
+	entry := c.findMACLocked(mac)
+	if entry == nil {
+		c.mutex.Unlock()
+		return errMACNotFound(mac)
+	}
+
+	labels := make(map[string]string, len(entry.Labels)+1)
+	for k, v := range entry.Labels {
+		labels[k] = v
+	}
+	if value == "" {
+		delete(labels, key)
+	} else {
+		labels[key] = value
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+	entry.Labels = labels
+	saved := entry.copy()
+	s := c.store
+	c.mutex.Unlock()
+
+	if s == nil {
+		return nil
+	}
+	saved.State = StateNormal
+	return s.Save(saved)
+}
+
+// GetLabel return the label value and true if the entry has the label.
+func (c *Handler) GetLabel(mac net.HardwareAddr, key string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entry := c.findMACLocked(mac)
+	if entry == nil {
+		return "", false
+	}
+	value, ok := entry.Labels[key]
+	return value, ok
+}
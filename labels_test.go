@@ -0,0 +1,45 @@
+package arp
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_Labels(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	if err := h.SetLabel(mac1, "room", "kitchen"); err == nil {
+		t.Error("expected error for unknown mac")
+	}
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip3))
+	if err := h.SetLabel(mac1, "room", "kitchen"); err != nil {
+		t.Fatal("SetLabel error ", err)
+	}
+	h.SetLabel(mac1, "owner", "daniel")
+	snapshot := h.Snapshot()
+
+	// the snapshot is not affected by later changes
+	h.SetLabel(mac1, "room", "")
+	if v, ok := h.GetLabel(mac1, "owner"); !ok || v != "daniel" {
+		t.Error("invalid owner label ", v)
+	}
+	if _, ok := h.GetLabel(mac1, "room"); ok {
+		t.Error("room label not removed")
+	}
+	if len(snapshot) != 1 || snapshot[0].Labels["room"] != "kitchen" || snapshot[0].Labels["owner"] != "daniel" {
+		t.Fatalf("invalid snapshot %+v", snapshot)
+	}
+
+	b, _ := json.Marshal(snapshot[0])
+	var e Entry
+	if err := json.Unmarshal(b, &e); err != nil || e.Labels["room"] != "kitchen" {
+		t.Errorf("invalid json %s %v", b, err)
+	}
+}
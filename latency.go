@@ -0,0 +1,84 @@
+package arp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the latency histogram buckets.
+var latencyBounds = [...]time.Duration{
+	time.Microsecond, 5 * time.Microsecond, 10 * time.Microsecond, 50 * time.Microsecond,
+	100 * time.Microsecond, 500 * time.Microsecond, time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 500 * time.Millisecond,
+	time.Second,
+}
+
+// latencyHistogram count durations in latencyBounds buckets; all values are
+// atomic and observe does not allocate.
+type latencyHistogram struct {
+	counts [len(latencyBounds) + 1]uint64 // the last bucket is above the largest bound
+	sum    int64                          // nanoseconds
+}
+
+// observe add the duration d; a negative value, i.e. the wall clock moved
+// back, is counted as zero.
+func (h *latencyHistogram) observe(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+// snapshot return the cumulative histogram.
+func (h *latencyHistogram) snapshot() (s Histogram) {
+	s.Buckets = make([]Bucket, len(latencyBounds))
+	for i := range h.counts {
+		s.Count += atomic.LoadUint64(&h.counts[i])
+		if i < len(latencyBounds) {
+			s.Buckets[i] = Bucket{UpperBound: latencyBounds[i], Count: s.Count}
+		}
+	}
+	s.Sum = time.Duration(atomic.LoadInt64(&h.sum))
+	return s
+}
+
+// Histogram is a latency distribution; see Stats.UpdateLatency.
+type Histogram struct {
+	Count   uint64        // number of observations
+	Sum     time.Duration // total of all observations
+	Buckets []Bucket      // cumulative counts in increasing UpperBound order
+}
+
+// Bucket is the number of observations less than or equal to UpperBound.
+type Bucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// add merge the observations of o into h; both use the latencyBounds buckets.
+func (h *Histogram) add(o Histogram) {
+	if h.Buckets == nil {
+		h.Buckets = make([]Bucket, len(latencyBounds))
+		for i := range h.Buckets {
+			h.Buckets[i].UpperBound = latencyBounds[i]
+		}
+	}
+	h.Count += o.Count
+	h.Sum += o.Sum
+	for i := range o.Buckets {
+		h.Buckets[i].Count += o.Buckets[i].Count
+	}
+}
+
+// Mean return the average latency or zero if there are no observations.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
@@ -0,0 +1,47 @@
+package arp
+
+import (
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_LatencyHistogram(t *testing.T) {
+	var h latencyHistogram
+	h.observe(-time.Second)
+	h.observe(3 * time.Microsecond)
+	h.observe(time.Millisecond)
+	h.observe(time.Minute)
+
+	s := h.snapshot()
+	if s.Count != 4 || s.Sum != time.Minute+time.Millisecond+3*time.Microsecond || len(s.Buckets) != len(latencyBounds) {
+		t.Fatalf("invalid histogram %+v", s)
+	}
+	counts := map[time.Duration]uint64{time.Microsecond: 1, 5 * time.Microsecond: 2, 500 * time.Microsecond: 2, time.Millisecond: 3, time.Second: 3}
+	for _, b := range s.Buckets {
+		if n, ok := counts[b.UpperBound]; ok && b.Count != n {
+			t.Errorf("invalid count %v in bucket %v", b.Count, b.UpperBound)
+		}
+	}
+	if s.Mean() != s.Sum/4 || (Histogram{}).Mean() != 0 {
+		t.Error("invalid mean ", s.Mean())
+	}
+	if n := testing.AllocsPerRun(100, func() { h.observe(time.Millisecond) }); n != 0 {
+		t.Errorf("observe allocates %v times", n)
+	}
+}
+
+func Test_PacketLatency(t *testing.T) {
+	h := newBenchHandler(t)
+	p := newTestPacket(marp.OperationReply, mac2, ip2, mac3, ip3)
+	h.handlePacketAt(p, time.Now().Add(-time.Millisecond*2))
+
+	stats := h.Stats()
+	if stats.UpdateLatency.Count != 1 || stats.UpdateLatency.Sum < time.Millisecond*2 {
+		t.Errorf("invalid update latency %+v", stats.UpdateLatency)
+	}
+	if stats.NotifyLatency.Count != 1 || stats.NotifyLatency.Sum < stats.UpdateLatency.Sum {
+		t.Errorf("invalid notify latency %+v", stats.NotifyLatency)
+	}
+}
@@ -0,0 +1,104 @@
+package arp
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Logger is the logging interface used by the handler.
+//
+// It is satisfied by *logrus.Logger and *logrus.Entry so embedders can route the
+// handler logs to their own logrus instance, with its own level, formatter and hooks,
+// or write an adapter for a different logging stack.
+type Logger interface {
+	log.FieldLogger
+}
+
+// loggerValue wraps the Logger so atomic.Value always stores the same concrete type
+type loggerValue struct {
+	Logger
+}
+
+// SetLogger set the logger for this handler. The default is the logrus standard logger.
+func (c *Handler) SetLogger(logger Logger) {
+	c.logger.Store(loggerValue{logger})
+}
+
+// LogArea is a group of handler debug logs; see SetLogAreas.
+type LogArea uint32
+
+const (
+	// LogPackets logs every packet read and sent; very verbose on large networks
+	LogPackets LogArea = 1 << iota
+
+	// LogTable logs table changes: new devices, IP and name changes, deletions and the store
+	LogTable
+
+	// LogSpoof logs hunts, virtual hosts and spoof and storm detection
+	LogSpoof
+
+	// LogScan logs network scans, device probes and router resolution
+	LogScan
+
+	// LogAllAreas enable all debug logs
+	LogAllAreas = LogPackets | LogTable | LogSpoof | LogScan
+)
+
+var logAreaNames = map[string]LogArea{"packets": LogPackets, "table": LogTable, "spoof": LogSpoof, "scan": LogScan, "all": LogAllAreas}
+
+// ParseLogAreas return the areas in a comma separated list of packets, table,
+// spoof, scan or all; i.e. "spoof,table".
+func ParseLogAreas(s string) (areas LogArea, err error) {
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		area, ok := logAreaNames[strings.ToLower(name)]
+		if !ok {
+			return 0, fmt.Errorf("invalid log area %q", name)
+		}
+		areas |= area
+	}
+	return areas, nil
+}
+
+// SetLogAreas enable debug logs for the areas of this handler only, i.e.
+// LogSpoof|LogTable to debug hunts without the per packet logs. Zero disables
+// the debug logs.
+//
+// The logger level must also allow debug logs; see SetLogger.
+func (c *Handler) SetLogAreas(areas LogArea) {
+	atomic.StoreUint32(&c.logAreas, uint32(areas))
+}
+
+// LogAreas return the debug log areas enabled for this handler.
+func (c *Handler) LogAreas() LogArea {
+	return LogArea(atomic.LoadUint32(&c.logAreas))
+}
+
+// SetLogAll enable all debug logs for this handler only; see SetLogAreas.
+func (c *Handler) SetLogAll(enable bool) {
+	if enable {
+		c.SetLogAreas(LogAllAreas)
+		return
+	}
+	c.SetLogAreas(0)
+}
+
+// log return the handler logger.
+//
+// It does not lock the mutex so it is safe to call from locked locations.
+func (c *Handler) log() Logger {
+	if v, ok := c.logger.Load().(loggerValue); ok && v.Logger != nil {
+		return v.Logger
+	}
+	return log.StandardLogger()
+}
+
+// logArea is true if debug logs are enabled globally or for the area in this handler
+func (c *Handler) logArea(area LogArea) bool {
+	return LogAll || atomic.LoadUint32(&c.logAreas)&uint32(area) != 0
+}
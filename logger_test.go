@@ -0,0 +1,43 @@
+package arp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	marp "github.com/mdlayher/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+func Test_LogAreas(t *testing.T) {
+	if areas, err := ParseLogAreas("spoof, Table"); err != nil || areas != LogSpoof|LogTable {
+		t.Error("invalid areas ", areas, err)
+	}
+	if _, err := ParseLogAreas("spoof,invalid"); err == nil {
+		t.Error("expected invalid area error")
+	}
+
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	var buf bytes.Buffer
+	logger := log.New()
+	logger.Out = &buf
+	logger.SetLevel(log.DebugLevel)
+	h.SetLogger(logger)
+
+	h.SetLogAreas(LogTable)
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2))
+	if s := buf.String(); !strings.Contains(s, "new mac detected") || strings.Contains(s, "who is") {
+		t.Error("expected table logs only ", s)
+	}
+
+	buf.Reset()
+	h.SetLogAll(false)
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac2, ip2, EthernetBroadcast, ip1))
+	if strings.Contains(buf.String(), "level=debug") {
+		t.Error("expected no debug logs ", buf.String())
+	}
+}
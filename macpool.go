@@ -0,0 +1,122 @@
+package arp
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"net"
+)
+
+// virtualMACPoolSize is the number of pool addresses tried before the pool is exhausted.
+const virtualMACPoolSize = 4096
+
+// errMACPoolExhausted is returned when all pool addresses are reserved or in the table
+var errMACPoolExhausted = errors.New("virtual MAC pool exhausted")
+
+// macPool generate locally administered unicast MACs for virtual hosts.
+//
+// The addresses are a deterministic sequence for the seed so a restarted
+// handler reuses the same MACs, and switches and peers do not learn a new MAC
+// every time; the first free address in the sequence is always returned.
+type macPool struct {
+	seed     uint64
+	reserved map[string]bool // reserved MACs; true if reserved by ReserveVirtualMAC
+}
+
+func newMACPool(seed uint64) *macPool {
+	return &macPool{seed: seed, reserved: make(map[string]bool)}
+}
+
+// WithVirtualMACSeed set the seed of the virtual MAC pool; handlers with the
+// same seed generate the same MACs. The default seed is derived from the
+// interface name and the host MAC.
+func WithVirtualMACSeed(seed int64) Option {
+	return func(c *Handler) error {
+		c.macPool = newMACPool(uint64(seed))
+		return nil
+	}
+}
+
+// mac return the address i of the sequence
+func (p *macPool) mac(i int) net.HardwareAddr {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], p.seed)
+	binary.BigEndian.PutUint64(buf[8:], uint64(i))
+	h := fnv.New64a()
+	h.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:8], h.Sum64())
+
+	mac := make(net.HardwareAddr, 6)
+	copy(mac, buf[:6])
+	mac[0] = (mac[0] | 2) & 0xfe // Set local bit, ensure unicast address
+	return mac
+}
+
+// macPoolLocked return the handler pool; the default seed is derived from the nic and host MAC.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) macPoolLocked() *macPool {
+	if c.macPool == nil {
+		h := fnv.New64a()
+		h.Write([]byte(c.config.NIC))
+		h.Write(c.config.HostMAC)
+		c.macPool = newMACPool(h.Sum64())
+	}
+	return c.macPool
+}
+
+// reserveMACLocked return the first pool address that is not reserved and not in the table.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) reserveMACLocked(user bool) (net.HardwareAddr, error) {
+	p := c.macPoolLocked()
+	for i := 0; i < virtualMACPoolSize; i++ {
+		mac := p.mac(i)
+		if _, ok := p.reserved[string(mac)]; ok || c.findMACLocked(mac) != nil {
+			continue
+		}
+		p.reserved[string(mac)] = user
+		return mac, nil
+	}
+	return nil, errMACPoolExhausted
+}
+
+// releaseMACLocked return a MAC reserved by the handler to the pool; MACs
+// reserved by ReserveVirtualMAC or not from the pool are ignored.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) releaseMACLocked(mac net.HardwareAddr) {
+	if c.macPool == nil {
+		return
+	}
+	if user, ok := c.macPool.reserved[string(mac)]; ok && !user {
+		delete(c.macPool.reserved, string(mac))
+	}
+}
+
+// ReserveVirtualMAC reserve a locally administered MAC from the virtual MAC
+// pool, i.e. to pass to AddVirtualHost or ClaimIP. The MAC is not used for
+// hunts or virtual hosts until ReleaseVirtualMAC is called.
+//
+// The pool generates a deterministic sequence for the handler seed, see
+// WithVirtualMACSeed, and skips addresses reserved or in the table.
+func (c *Handler) ReserveVirtualMAC() (net.HardwareAddr, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.reserveMACLocked(true)
+}
+
+// ReleaseVirtualMAC return a MAC reserved with ReserveVirtualMAC to the pool.
+//
+// It returns ErrNotFound if the MAC is not reserved. A released MAC in use by
+// a virtual host is not reused until the virtual host is removed.
+func (c *Handler) ReleaseVirtualMAC(mac net.HardwareAddr) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if user, ok := c.macPoolLocked().reserved[string(mac)]; !ok || !user {
+		return errMACNotFound(mac)
+	}
+	delete(c.macPool.reserved, string(mac))
+	return nil
+}
@@ -0,0 +1,59 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func Test_MACPool(t *testing.T) {
+	newHandler := func() *Handler {
+		h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+			WithPacketConn(newFakeConn()), WithVirtualMACSeed(42))
+		if err != nil {
+			t.Fatal("NewHandler error ", err)
+		}
+		return h
+	}
+	h1, h2 := newHandler(), newHandler()
+	defer h1.Stop()
+	defer h2.Stop()
+
+	m1, err := h1.ReserveVirtualMAC()
+	if err != nil {
+		t.Fatal("ReserveVirtualMAC error ", err)
+	}
+	if m1[0]&2 == 0 || m1[0]&1 != 0 {
+		t.Errorf("mac %s is not local unicast", m1)
+	}
+	if m2, _ := h2.ReserveVirtualMAC(); m2.String() != m1.String() {
+		t.Errorf("same seed generated %s and %s", m1, m2)
+	}
+
+	// the next address skips the reserved MAC and the MACs in the table
+	h1.mutex.Lock()
+	h1.arpTableAppendLocked(StateNormal, h1.macPool.mac(1), ip1)
+	h1.mutex.Unlock()
+	if m, _ := h1.ReserveVirtualMAC(); m.String() != h1.macPool.mac(2).String() {
+		t.Errorf("invalid reserved mac %s", m)
+	}
+
+	// virtual hosts use and release the pool
+	vip := net.IPv4(192, 168, 0, 100)
+	if err := h1.AddVirtualHost(vip, nil); err != nil {
+		t.Fatal("AddVirtualHost error ", err)
+	}
+	if entry := h1.FindVirtualIP(vip); entry == nil || entry.MAC.String() != h1.macPool.mac(3).String() {
+		t.Errorf("invalid virtual host %+v", entry)
+	}
+	h1.RemoveVirtualHost(vip)
+	if err := h1.ReleaseVirtualMAC(m1); err != nil {
+		t.Fatal("ReleaseVirtualMAC error ", err)
+	}
+	if err := h1.ReleaseVirtualMAC(m1); !errors.Is(err, ErrNotFound) {
+		t.Error("expected ErrNotFound ", err)
+	}
+	if m, _ := h1.ReserveVirtualMAC(); m.String() != m1.String() {
+		t.Errorf("released mac not reused %s", m)
+	}
+}
@@ -0,0 +1,136 @@
+package arp
+
+import (
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// mdnsGroup is the mDNS IPv4 multicast group and port
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+const mdnsDeviceInfo = "._device-info._tcp.local."
+
+// mdnsName is a name announced for ip
+type mdnsName struct {
+	ip       net.IP
+	name     string
+	instance bool // from a _device-info service instance
+}
+
+// mdnsConn return the mDNS socket or nil if ListenAndServeMDNS is not running.
+func (c *Handler) mdnsConn() *net.UDPConn {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.mdns
+}
+
+// ListenAndServeMDNS listen for mDNS (Bonjour) announcements and save the
+// announced device names in Entry.MDNSName; EventHostnameChanged is sent when
+// the name changes.
+//
+// The _device-info service instance name (i.e. "Daniel's iPhone") is preferred;
+// the A record hostname (i.e. "Daniels-iPhone") is used for devices that do
+// not announce the service. It runs alongside ListenAndServe and never sends
+// queries; devices announce themselves when they join the network and answer
+// queries from other hosts.
+func (c *Handler) ListenAndServeMDNS() error {
+	ifi, err := net.InterfaceByName(c.config.NIC)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", ifi, mdnsGroup)
+	if err != nil {
+		c.log().WithFields(log.Fields{"nic": c.config.NIC}).Error("mDNS error in socket:", err)
+		return err
+	}
+
+	c.mutex.Lock()
+	c.mdns = conn
+	c.mutex.Unlock()
+
+	// Goroutine pool
+	h := c.goroutinePool.Begin("mDNS ListenAndServe")
+	defer h.End()
+
+	buf := make([]byte, 9000)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if h.Stopping() { // are we stopping all goroutines?
+			return nil
+		}
+		if err != nil {
+			atomic.AddUint64(&c.counters.readErrors, 1)
+			c.log().Error("mDNS read error ", err)
+			if err1, ok := err.(net.Error); ok && err1.Temporary() {
+				time.Sleep(time.Millisecond * 30)
+				continue
+			}
+			return err
+		}
+		c.handleMDNS(addr.IP, buf[:n])
+	}
+}
+
+// handleMDNS save the names announced in the mDNS packet sent by src.
+func (c *Handler) handleMDNS(src net.IP, b []byte) {
+	for _, n := range parseMDNS(src, b) {
+		if !n.instance {
+			// do not replace the device-info name with the hostname
+			c.mutex.RLock()
+			entry := lookupIP(c.ipIndex, n.ip)
+			skip := entry == nil || entry.MDNSName != ""
+			c.mutex.RUnlock()
+			if skip {
+				continue
+			}
+		}
+		c.setDiscoveredName(n.ip, n.name, mdnsNameField)
+	}
+}
+
+func mdnsNameField(e *Entry) *string { return &e.MDNSName }
+
+// parseMDNS return the IPv4 hostnames and the device-info instance names in
+// the mDNS response sent by src; instance names are announced for src.
+func parseMDNS(src net.IP, b []byte) (names []mdnsName) {
+	var p dnsmessage.Parser
+	header, err := p.Start(b)
+	if err != nil || !header.Response {
+		return nil
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return nil
+	}
+	// additional records are optional; ignore malformed ones
+	if err := p.SkipAllAuthorities(); err == nil {
+		additionals, _ := p.AllAdditionals()
+		answers = append(answers, additionals...)
+	}
+
+	for _, r := range answers {
+		switch body := r.Body.(type) {
+		case *dnsmessage.AResource:
+			if name := strings.TrimSuffix(r.Header.Name.String(), ".local."); name != r.Header.Name.String() && name != "" {
+				names = append(names, mdnsName{ip: net.IP(body.A[:]), name: name})
+			}
+		case *dnsmessage.PTRResource:
+			if name := strings.TrimSuffix(body.PTR.String(), mdnsDeviceInfo); name != body.PTR.String() && name != "" && src.To4() != nil {
+				names = append(names, mdnsName{ip: src, name: name, instance: true})
+			}
+		case *dnsmessage.TXTResource:
+			if name := strings.TrimSuffix(r.Header.Name.String(), mdnsDeviceInfo); name != r.Header.Name.String() && name != "" && src.To4() != nil {
+				names = append(names, mdnsName{ip: src, name: name, instance: true})
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,84 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func newMDNSResponse(t *testing.T, resources ...dnsmessage.Resource) []byte {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true, Authoritative: true})
+	if err := b.StartAnswers(); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range resources {
+		var err error
+		switch body := r.Body.(type) {
+		case *dnsmessage.AResource:
+			err = b.AResource(r.Header, *body)
+		case *dnsmessage.PTRResource:
+			err = b.PTRResource(r.Header, *body)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func Test_MDNS(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip3))
+
+	hostname := newMDNSResponse(t, dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName("Daniels-iPhone.local."), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET},
+		Body:   &dnsmessage.AResource{A: [4]byte{192, 168, 0, 1}},
+	})
+	instance := newMDNSResponse(t, dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName("_device-info._tcp.local."), Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET},
+		Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("Daniel’s iPhone._device-info._tcp.local.")},
+	})
+
+	s := h.Subscribe(16, DropNewest)
+	h.handleMDNS(ip2, hostname)
+	if name := h.FindMAC(mac1).MDNSName; name != "Daniels-iPhone" {
+		t.Fatalf("invalid hostname %q", name)
+	}
+	h.handleMDNS(ip1, instance)
+	if name := h.FindMAC(mac1).MDNSName; name != "Daniel’s iPhone" {
+		t.Fatalf("invalid instance name %q", name)
+	}
+
+	// the hostname does not replace the instance name
+	h.handleMDNS(ip2, hostname)
+	for _, want := range []string{"Daniels-iPhone", "Daniel’s iPhone"} {
+		select {
+		case e := <-s.C:
+			if e.Type != EventHostnameChanged || e.Entry.MDNSName != want {
+				t.Fatalf("invalid event %+v want %s", e, want)
+			}
+		case <-time.After(time.Millisecond * 100):
+			t.Fatal("timeout waiting for event ", want)
+		}
+	}
+	select {
+	case e := <-s.C:
+		t.Fatalf("unexpected event %+v", e)
+	default:
+	}
+
+	if names := parseMDNS(ip1, []byte{0, 1, 2}); names != nil {
+		t.Error("invalid names for malformed packet ", names)
+	}
+}
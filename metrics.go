@@ -0,0 +1,118 @@
+package arp
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricPacketsRead = prometheus.NewDesc("arp_packets_read_total",
+		"Number of ARP and NDP packets read.", []string{"nic"}, nil)
+	metricRequestsSent = prometheus.NewDesc("arp_requests_sent_total",
+		"Number of ARP requests sent.", []string{"nic"}, nil)
+	metricRepliesSent = prometheus.NewDesc("arp_replies_sent_total",
+		"Number of ARP replies sent.", []string{"nic"}, nil)
+	metricReadErrors = prometheus.NewDesc("arp_read_errors_total",
+		"Number of socket read errors.", []string{"nic"}, nil)
+	metricPacketsIgnored = prometheus.NewDesc("arp_packets_ignored_total",
+		"Number of packets ignored from MACs exceeding the storm threshold.", []string{"nic"}, nil)
+	metricNotificationsDropped = prometheus.NewDesc("arp_notifications_dropped_total",
+		"Number of notifications discarded because a subscription queue was full.", []string{"nic"}, nil)
+	metricDevicesOnline = prometheus.NewDesc("arp_devices_online",
+		"Number of devices currently online.", []string{"nic"}, nil)
+	metricHuntsActive = prometheus.NewDesc("arp_hunts_active",
+		"Number of devices currently in hunt state.", []string{"nic"}, nil)
+	metricRequestsRead = prometheus.NewDesc("arp_requests_read_total",
+		"Number of ARP requests read.", []string{"nic"}, nil)
+	metricRepliesRead = prometheus.NewDesc("arp_replies_read_total",
+		"Number of ARP replies read.", []string{"nic"}, nil)
+	metricPacketsInvalid = prometheus.NewDesc("arp_packets_invalid_total",
+		"Number of ARP packets dropped as invalid.", []string{"nic"}, nil)
+	metricNotificationsSent = prometheus.NewDesc("arp_notifications_sent_total",
+		"Number of events published to subscribers.", []string{"nic"}, nil)
+	metricGoroutines = prometheus.NewDesc("arp_goroutines",
+		"Number of handler goroutines running.", []string{"nic"}, nil)
+	metricTableEntries = prometheus.NewDesc("arp_table_entries",
+		"Number of entries in the table including virtual hosts.", []string{"nic"}, nil)
+	metricWatchdogResets = prometheus.NewDesc("arp_watchdog_resets_total",
+		"Number of wedged sockets closed by the watchdog.", []string{"nic"}, nil)
+	metricPacketsDropped = prometheus.NewDesc("arp_packets_dropped_total",
+		"Number of packets discarded because a worker queue was full.", []string{"nic"}, nil)
+	metricKernelDrops = prometheus.NewDesc("arp_kernel_drops_total",
+		"Number of frames dropped by the kernel because the socket receive queue was full.", []string{"nic"}, nil)
+	metricUpdateLatency = prometheus.NewDesc("arp_update_latency_seconds",
+		"Time from packet receipt to the table update.", []string{"nic"}, nil)
+	metricNotifyLatency = prometheus.NewDesc("arp_notify_latency_seconds",
+		"Time from packet receipt to the event publication.", []string{"nic"}, nil)
+)
+
+// metricsCollector is a prometheus collector reading the handler Stats.
+type metricsCollector struct {
+	handler *Handler
+}
+
+// Metrics return a prometheus collector for the handler counters.
+//
+// Register it with a prometheus registry:
+//
+//	prometheus.MustRegister(c.Metrics())
+func (c *Handler) Metrics() prometheus.Collector {
+	return &metricsCollector{handler: c}
+}
+
+// Describe implements prometheus.Collector
+func (m *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metricPacketsRead
+	ch <- metricRequestsSent
+	ch <- metricRepliesSent
+	ch <- metricReadErrors
+	ch <- metricPacketsIgnored
+	ch <- metricNotificationsDropped
+	ch <- metricDevicesOnline
+	ch <- metricHuntsActive
+	ch <- metricRequestsRead
+	ch <- metricRepliesRead
+	ch <- metricPacketsInvalid
+	ch <- metricNotificationsSent
+	ch <- metricGoroutines
+	ch <- metricTableEntries
+	ch <- metricWatchdogResets
+	ch <- metricPacketsDropped
+	ch <- metricKernelDrops
+	ch <- metricUpdateLatency
+	ch <- metricNotifyLatency
+}
+
+// Collect implements prometheus.Collector
+func (m *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := m.handler.Stats()
+	nic := m.handler.config.NIC
+
+	ch <- prometheus.MustNewConstMetric(metricPacketsRead, prometheus.CounterValue, float64(stats.PacketsRead), nic)
+	ch <- prometheus.MustNewConstMetric(metricRequestsSent, prometheus.CounterValue, float64(stats.RequestsSent), nic)
+	ch <- prometheus.MustNewConstMetric(metricRepliesSent, prometheus.CounterValue, float64(stats.RepliesSent), nic)
+	ch <- prometheus.MustNewConstMetric(metricReadErrors, prometheus.CounterValue, float64(stats.ReadErrors), nic)
+	ch <- prometheus.MustNewConstMetric(metricPacketsIgnored, prometheus.CounterValue, float64(stats.PacketsIgnored), nic)
+	ch <- prometheus.MustNewConstMetric(metricNotificationsDropped, prometheus.CounterValue, float64(stats.NotificationsDropped), nic)
+	ch <- prometheus.MustNewConstMetric(metricDevicesOnline, prometheus.GaugeValue, float64(stats.DevicesOnline), nic)
+	ch <- prometheus.MustNewConstMetric(metricHuntsActive, prometheus.GaugeValue, float64(stats.HuntsActive), nic)
+	ch <- prometheus.MustNewConstMetric(metricRequestsRead, prometheus.CounterValue, float64(stats.RequestsRead), nic)
+	ch <- prometheus.MustNewConstMetric(metricRepliesRead, prometheus.CounterValue, float64(stats.RepliesRead), nic)
+	ch <- prometheus.MustNewConstMetric(metricPacketsInvalid, prometheus.CounterValue, float64(stats.PacketsInvalid), nic)
+	ch <- prometheus.MustNewConstMetric(metricNotificationsSent, prometheus.CounterValue, float64(stats.NotificationsSent), nic)
+	ch <- prometheus.MustNewConstMetric(metricGoroutines, prometheus.GaugeValue, float64(stats.Goroutines), nic)
+	ch <- prometheus.MustNewConstMetric(metricTableEntries, prometheus.GaugeValue, float64(stats.TableSize), nic)
+	ch <- prometheus.MustNewConstMetric(metricWatchdogResets, prometheus.CounterValue, float64(stats.WatchdogResets), nic)
+	ch <- prometheus.MustNewConstMetric(metricPacketsDropped, prometheus.CounterValue, float64(stats.PacketsDropped), nic)
+	ch <- prometheus.MustNewConstMetric(metricKernelDrops, prometheus.CounterValue, float64(stats.KernelDrops), nic)
+	ch <- constHistogram(metricUpdateLatency, stats.UpdateLatency, nic)
+	ch <- constHistogram(metricNotifyLatency, stats.NotifyLatency, nic)
+}
+
+// constHistogram return the latency histogram in seconds.
+func constHistogram(desc *prometheus.Desc, h Histogram, nic string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(h.Buckets))
+	for _, b := range h.Buckets {
+		buckets[b.UpperBound.Seconds()] = b.Count
+	}
+	return prometheus.MustNewConstHistogram(desc, h.Count, h.Sum.Seconds(), buckets, nic)
+}
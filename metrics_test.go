@@ -0,0 +1,29 @@
+package arp
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func Test_Metrics(t *testing.T) {
+
+	h := &Handler{table: make([]*Entry, 0, 256)}
+	h.arpTableAppendLocked(StateNormal, mac1, ip1).Online = true
+	h.arpTableAppendLocked(StateHunt, mac2, ip2).Online = true
+	h.arpTableAppendLocked(StateVirtualHost, mac3, ip2).Online = true
+
+	stats := h.Stats()
+	if stats.DevicesOnline != 2 || stats.HuntsActive != 1 || stats.TableSize != 3 || stats.TableLimit != minTableSize {
+		t.Errorf("invalid stats %+v", stats)
+	}
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(h.Metrics()); err != nil {
+		t.Fatal("cannot register collector ", err)
+	}
+	families, err := registry.Gather()
+	if err != nil || len(families) != 19 {
+		t.Error("expected 19 metric families ", len(families), err)
+	}
+}
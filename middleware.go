@@ -0,0 +1,102 @@
+package arp
+
+import (
+	"net"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+	log "github.com/sirupsen/logrus"
+)
+
+// PacketDirection is the direction of a Packet in the middleware chain
+type PacketDirection int
+
+// Packet directions
+const (
+	Inbound  PacketDirection = iota // read from the socket
+	Outbound                        // written to the socket
+)
+
+// String return inbound or outbound
+func (d PacketDirection) String() string {
+	if d == Outbound {
+		return "outbound"
+	}
+	return "inbound"
+}
+
+// Packet is an ARP packet passed through the middleware chain; see Use.
+type Packet struct {
+	Direction   PacketDirection
+	Packet      *marp.Packet
+	Frame       *ethernet.Frame  // received frame; nil for outbound packets
+	Destination net.HardwareAddr // ethernet destination of outbound packets
+	Time        time.Time        // receive time of inbound packets; the kernel timestamp if supported by the packet conn
+}
+
+// Middleware process a packet and call next to pass it down the chain. A
+// middleware drops the packet by returning without calling next and may pass a
+// different packet to next; the error is returned by the send function for
+// outbound packets and logged for inbound packets.
+type Middleware func(p Packet, next func(Packet) error) error
+
+// Use append m to the middleware chain for inbound and outbound packets, i.e.
+// for logging, rate limiting or filtering. Middleware run in the order added.
+//
+// Inbound packets go through the chain after the packet capture and the packet
+// tap and before the table is updated; outbound packets before the rate limit
+// and the packet capture. Middleware must not keep references to inbound
+// packets as the packet conn may reuse them.
+func (c *Handler) Use(m Middleware) {
+	c.mutex.Lock()
+	// copy on write; the read loop and send may be iterating over the previous slice
+	chain := make([]Middleware, 0, len(c.middleware)+1)
+	c.middleware = append(append(chain, c.middleware...), m)
+	c.mutex.Unlock()
+}
+
+// middlewareChain return the current chain; nil if Use was not called
+func (c *Handler) middlewareChain() []Middleware {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.middleware
+}
+
+// runMiddleware pass p through chain[i:] and then to last.
+func runMiddleware(chain []Middleware, i int, p Packet, last func(Packet) error) error {
+	if i == len(chain) {
+		return last(p)
+	}
+	return chain[i](p, func(p Packet) error { return runMiddleware(chain, i+1, p, last) })
+}
+
+// dispatch send a received packet to the raw packet channels or the table
+func (c *Handler) dispatch(p Packet) error {
+	if isRawPacket(p.Packet, p.Frame) {
+		c.handleRawPacket(p.Packet, p.Frame)
+		return nil
+	}
+	if c.guard != nil {
+		c.checkGateway(p.Packet, p.Frame)
+	}
+	t := p.Time
+	if t.IsZero() { // i.e. a packet created by middleware
+		t = time.Now()
+	}
+	c.handlePacketAt(p.Packet, t)
+	return nil
+}
+
+// serve pass a packet received at t through the middleware chain and dispatch it
+func (c *Handler) serve(packet *marp.Packet, frame *ethernet.Frame, t time.Time) {
+	p := Packet{Direction: Inbound, Packet: packet, Frame: frame, Time: t}
+	chain := c.middlewareChain()
+	if len(chain) == 0 {
+		c.dispatch(p)
+		return
+	}
+	if err := runMiddleware(chain, 0, p, c.dispatch); err != nil && c.logArea(LogPackets) {
+		c.log().WithFields(log.Fields{"mac": packet.SenderHardwareAddr, "ip": packet.SenderIP}).Debug("ARP packet middleware error ", err)
+	}
+}
@@ -0,0 +1,48 @@
+package arp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_Middleware(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	var order []string
+	h.Use(func(p Packet, next func(Packet) error) error {
+		order = append(order, "first "+p.Direction.String())
+		return next(p)
+	})
+	h.Use(func(p Packet, next func(Packet) error) error {
+		order = append(order, "second "+p.Direction.String())
+		if p.Direction == Inbound && bytes.Equal(p.Packet.SenderHardwareAddr, mac2) {
+			return nil // drop
+		}
+		return next(p)
+	})
+
+	h.serve(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip3), nil, time.Now())
+	h.serve(newTestPacket(marp.OperationRequest, mac2, ip2, EthernetBroadcast, ip3), nil, time.Now())
+	if h.FindMAC(mac1) == nil || h.FindMAC(mac2) != nil {
+		t.Error("inbound middleware did not filter packets")
+	}
+
+	if err := h.request(mac3, ip3, EthernetBroadcast, ip1); err != nil {
+		t.Fatal("request error ", err)
+	}
+	if p := <-conn.out; !p.TargetIP.Equal(ip1) {
+		t.Error("invalid outbound packet ", p)
+	}
+	if len(order) != 6 || order[0] != "first inbound" || order[1] != "second inbound" || order[4] != "first outbound" || order[5] != "second outbound" {
+		t.Errorf("invalid middleware order %v", order)
+	}
+}
@@ -0,0 +1,334 @@
+// Package mqtt publishes the online state of arp.Handler devices to an MQTT
+// broker, including Home Assistant MQTT discovery topics so every device
+// shows up as a device_tracker entity.
+//
+// Topics, with the default prefixes:
+//
+//	arp/status                                   "online" or "offline" (last will)
+//	arp/{id}/state                               "home" or "not_home"
+//	arp/{id}/attributes                          JSON with ip, mac, names and vendor
+//	homeassistant/device_tracker/arp_{id}/config Home Assistant discovery
+//
+// The id is the MAC in lower case hex without separators. All messages are
+// published with QoS 0 and retained.
+//
+// Usage:
+//
+//	p := mqtt.New(handler, "broker:1883", mqtt.WithCredentials("user", "password"))
+//	go p.Run(ctx)
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/irai/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// QueueSize is the subscription queue size; events are queued while the
+// broker is unavailable.
+const QueueSize = 256
+
+var (
+	keepAlive       = time.Second * 60 // MQTT keep alive
+	dialTimeout     = time.Second * 10
+	reconnectMin    = time.Second * 5
+	reconnectMax    = time.Minute
+	errConnRefused  = errors.New("mqtt connection refused")
+	errNotConnected = errors.New("mqtt not connected")
+)
+
+// Publisher publishes the handler devices to an MQTT broker
+type Publisher struct {
+	handler   *arp.Handler
+	addr      string
+	prefix    string
+	discovery string
+	clientID  string
+	username  string
+	password  string
+
+	mutex sync.Mutex // serialise writes
+	conn  net.Conn
+}
+
+// Option configures the Publisher
+type Option func(*Publisher)
+
+// WithTopicPrefix set the state topic prefix; the default is "arp".
+func WithTopicPrefix(prefix string) Option {
+	return func(p *Publisher) { p.prefix = prefix }
+}
+
+// WithDiscoveryPrefix set the Home Assistant discovery prefix; the default
+// is "homeassistant". An empty prefix disables discovery.
+func WithDiscoveryPrefix(prefix string) Option {
+	return func(p *Publisher) { p.discovery = prefix }
+}
+
+// WithCredentials set the broker username and password.
+func WithCredentials(username string, password string) Option {
+	return func(p *Publisher) { p.username, p.password = username, password }
+}
+
+// WithClientID set the MQTT client identifier; the default is "arp-" followed
+// by the host name.
+func WithClientID(id string) Option {
+	return func(p *Publisher) { p.clientID = id }
+}
+
+// New return a Publisher for the devices of h; addr is the broker host:port.
+func New(h *arp.Handler, addr string, opts ...Option) *Publisher {
+	hostname, _ := os.Hostname()
+	p := &Publisher{handler: h, addr: addr, prefix: "arp", discovery: "homeassistant", clientID: "arp-" + hostname}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Run publish the devices until ctx is done or the handler stops; it
+// reconnects when the connection to the broker fails.
+func (p *Publisher) Run(ctx context.Context) error {
+	sub := p.handler.Subscribe(QueueSize, arp.DropOldest)
+	defer p.handler.Unsubscribe(sub)
+
+	backoff := reconnectMin
+	for {
+		err := p.serve(ctx, sub)
+		if ctx.Err() != nil || err == nil {
+			return ctx.Err()
+		}
+		log.WithFields(log.Fields{"broker": p.addr}).Error("MQTT connection error ", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > reconnectMax {
+			backoff = reconnectMax
+		}
+	}
+}
+
+// serve connect, publish all devices and then the events until an error;
+// it returns nil if the subscription is closed.
+func (p *Publisher) serve(ctx context.Context, sub *arp.Subscription) error {
+	readErr, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.close()
+
+	for _, e := range p.handler.Snapshot() {
+		if err := p.publishEntry(e); err != nil {
+			return err
+		}
+	}
+
+	ping := time.NewTicker(keepAlive / 2)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.write(publishPacket(p.statusTopic(), []byte("offline"), true))
+			p.write([]byte{packetDisconnect << 4, 0})
+			return nil
+
+		case err := <-readErr:
+			return err
+
+		case <-ping.C:
+			if err := p.write([]byte{packetPingReq << 4, 0}); err != nil {
+				return err
+			}
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			switch event.Type {
+			case arp.EventNewDevice, arp.EventDeviceOnline, arp.EventDeviceOffline, arp.EventIPChanged, arp.EventHostnameChanged:
+				if err := p.publishEntry(event.Entry); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// connect open the connection, send CONNECT and wait for CONNACK. The
+// returned channel receives the read error when the connection fails.
+func (p *Publisher) connect(ctx context.Context) (<-chan error, error) {
+	d := net.Dialer{Timeout: dialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	connect := connectPacket{clientID: p.clientID, keepAlive: uint16(keepAlive / time.Second),
+		willTopic: p.statusTopic(), willMessage: "offline", username: p.username, password: p.password}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	r := bufio.NewReader(conn)
+	if _, err := conn.Write(connect.marshal()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	packetType, _, body, err := readPacket(r)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if packetType != packetConnAck || len(body) != 2 || body[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %v", errConnRefused, body)
+	}
+	conn.SetDeadline(time.Time{})
+
+	p.mutex.Lock()
+	p.conn = conn
+	p.mutex.Unlock()
+
+	// discard PINGRESP; the broker sends nothing else as we do not subscribe
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, _, err := readPacket(r); err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+	return readErr, p.write(publishPacket(p.statusTopic(), []byte("online"), true))
+}
+
+func (p *Publisher) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+func (p *Publisher) write(b []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.conn == nil {
+		return errNotConnected
+	}
+	p.conn.SetWriteDeadline(time.Now().Add(dialTimeout))
+	_, err := p.conn.Write(b)
+	return err
+}
+
+func (p *Publisher) statusTopic() string { return p.prefix + "/status" }
+
+// deviceID return the MAC in lower case hex without separators.
+func deviceID(mac net.HardwareAddr) string {
+	return strings.ReplaceAll(mac.String(), ":", "")
+}
+
+// displayName return the most friendly name known for the entry.
+func displayName(e arp.Entry) string {
+	for _, name := range []string{e.Name, e.MDNSName, e.Hostname, e.NetBIOSName} {
+		if name != "" {
+			return name
+		}
+	}
+	if e.DHCP != nil && e.DHCP.Hostname != "" {
+		return e.DHCP.Hostname
+	}
+	return e.MAC.String()
+}
+
+// discoveryConfig is the Home Assistant MQTT device_tracker configuration
+type discoveryConfig struct {
+	Name                string          `json:"name"`
+	UniqueID            string          `json:"unique_id"`
+	ObjectID            string          `json:"object_id"`
+	StateTopic          string          `json:"state_topic"`
+	AttributesTopic     string          `json:"json_attributes_topic"`
+	AvailabilityTopic   string          `json:"availability_topic"`
+	PayloadAvailable    string          `json:"payload_available"`
+	PayloadNotAvailable string          `json:"payload_not_available"`
+	PayloadHome         string          `json:"payload_home"`
+	PayloadNotHome      string          `json:"payload_not_home"`
+	SourceType          string          `json:"source_type"`
+	Device              discoveryDevice `json:"device"`
+}
+
+type discoveryDevice struct {
+	Name         string      `json:"name"`
+	Identifiers  []string    `json:"identifiers"`
+	Connections  [][2]string `json:"connections"`
+	Manufacturer string      `json:"manufacturer,omitempty"`
+}
+
+// attributes is the JSON attributes of the device_tracker
+type attributes struct {
+	MAC       string `json:"mac"`
+	IP        string `json:"ip"`
+	Hostname  string `json:"host_name,omitempty"`
+	Vendor    string `json:"vendor,omitempty"`
+	Class     string `json:"device_class,omitempty"`
+	LastSeen  string `json:"last_seen"`
+	FirstSeen string `json:"first_seen,omitempty"`
+}
+
+// publishEntry publish the discovery configuration, the state and the
+// attributes of the entry.
+func (p *Publisher) publishEntry(e arp.Entry) error {
+	if e.State == arp.StateVirtualHost {
+		return nil
+	}
+	id := deviceID(e.MAC)
+	base := p.prefix + "/" + id
+
+	if p.discovery != "" {
+		config := discoveryConfig{
+			Name: displayName(e), UniqueID: "arp_" + id, ObjectID: "arp_" + id,
+			StateTopic: base + "/state", AttributesTopic: base + "/attributes", AvailabilityTopic: p.statusTopic(),
+			PayloadHome: "home", PayloadNotHome: "not_home", PayloadAvailable: "online", PayloadNotAvailable: "offline",
+			SourceType: "router",
+			Device: discoveryDevice{Name: displayName(e), Identifiers: []string{"arp_" + id},
+				Connections: [][2]string{{"mac", e.MAC.String()}}, Manufacturer: e.Vendor},
+		}
+		b, err := json.Marshal(config)
+		if err != nil {
+			return err
+		}
+		if err := p.write(publishPacket(p.discovery+"/device_tracker/arp_"+id+"/config", b, true)); err != nil {
+			return err
+		}
+	}
+
+	attr := attributes{MAC: e.MAC.String(), IP: e.IP.String(), Hostname: displayName(e), Vendor: e.Vendor,
+		Class: string(e.DeviceClass), LastSeen: e.LastUpdate.Format(time.RFC3339)}
+	if !e.FirstSeen.IsZero() {
+		attr.FirstSeen = e.FirstSeen.Format(time.RFC3339)
+	}
+	b, err := json.Marshal(attr)
+	if err != nil {
+		return err
+	}
+	if err := p.write(publishPacket(base+"/attributes", b, true)); err != nil {
+		return err
+	}
+
+	state := "not_home"
+	if e.Online {
+		state = "home"
+	}
+	return p.write(publishPacket(base+"/state", []byte(state), true))
+}
@@ -0,0 +1,105 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/irai/arp"
+)
+
+type message struct {
+	topic   string
+	payload string
+	retain  bool
+}
+
+// fakeBroker accept one connection and send the published messages to the channel.
+func fakeBroker(t *testing.T, l net.Listener, messages chan<- message) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	packetType, _, body, err := readPacket(r)
+	if err != nil || packetType != packetConnect || !strings.Contains(string(body), "arp/status") {
+		t.Errorf("invalid connect %v %q", err, body)
+		return
+	}
+	conn.Write([]byte{packetConnAck << 4, 2, 0, 0})
+
+	for {
+		packetType, flags, body, err := readPacket(r)
+		if err != nil {
+			close(messages)
+			return
+		}
+		if packetType == packetPublish {
+			n := int(binary.BigEndian.Uint16(body))
+			messages <- message{topic: string(body[2 : 2+n]), payload: string(body[2+n:]), retain: flags&0x01 != 0}
+		}
+	}
+}
+
+func Test_Publisher(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	messages := make(chan message, 16)
+	go fakeBroker(t, l, messages)
+
+	p := New(&arp.Handler{}, l.Addr().String(), WithClientID("test"))
+	if _, err := p.connect(context.Background()); err != nil {
+		t.Fatal("connect error ", err)
+	}
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x0a}
+	entry := arp.Entry{MAC: mac, IP: net.IPv4(192, 168, 0, 10).To4(), Online: true, MDNSName: "Daniel’s iPhone", LastUpdate: time.Now()}
+	if err := p.publishEntry(entry); err != nil {
+		t.Fatal("publish error ", err)
+	}
+	p.close()
+
+	want := []string{"arp/status", "homeassistant/device_tracker/arp_02000000000a/config", "arp/02000000000a/attributes", "arp/02000000000a/state"}
+	for _, topic := range want {
+		select {
+		case m := <-messages:
+			if m.topic != topic || !m.retain {
+				t.Fatalf("invalid message %+v want %s", m, topic)
+			}
+			switch topic {
+			case "arp/status":
+				if m.payload != "online" {
+					t.Error("invalid status ", m.payload)
+				}
+			case "arp/02000000000a/state":
+				if m.payload != "home" {
+					t.Error("invalid state ", m.payload)
+				}
+			case want[1]:
+				var config discoveryConfig
+				if err := json.Unmarshal([]byte(m.payload), &config); err != nil || config.Name != "Daniel’s iPhone" || config.StateTopic != "arp/02000000000a/state" {
+					t.Errorf("invalid config %v %s", err, m.payload)
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for ", topic)
+		}
+	}
+}
+
+func Test_PacketLength(t *testing.T) {
+	b := publishPacket("t", make([]byte, 200), false)
+	packetType, _, body, err := readPacket(bufio.NewReader(strings.NewReader(string(b))))
+	if err != nil || packetType != packetPublish || len(body) != 203 {
+		t.Errorf("invalid packet %d %d %v", packetType, len(body), err)
+	}
+}
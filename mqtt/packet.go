@@ -0,0 +1,128 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types; only the packets needed to publish are implemented.
+const (
+	packetConnect    = 1
+	packetConnAck    = 2
+	packetPublish    = 3
+	packetPingReq    = 12
+	packetPingResp   = 13
+	packetDisconnect = 14
+)
+
+// connect flags
+const (
+	flagCleanSession = 0x02
+	flagWill         = 0x04
+	flagWillRetain   = 0x20
+	flagPassword     = 0x40
+	flagUsername     = 0x80
+)
+
+var errPacketTooLarge = errors.New("mqtt packet too large")
+
+// connectPacket is the CONNECT packet payload
+type connectPacket struct {
+	clientID    string
+	keepAlive   uint16 // seconds
+	willTopic   string
+	willMessage string
+	username    string
+	password    string
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// appendPacket append the fixed header and the body.
+func appendPacket(b []byte, header byte, body []byte) []byte {
+	b = append(b, header)
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(b, body...)
+}
+
+func (p connectPacket) marshal() []byte {
+	body := appendString(nil, "MQTT")
+	flags := byte(flagCleanSession)
+	if p.willTopic != "" {
+		flags |= flagWill | flagWillRetain
+	}
+	if p.username != "" {
+		flags |= flagUsername
+	}
+	if p.password != "" {
+		flags |= flagPassword
+	}
+	body = append(body, 4, flags) // protocol level 3.1.1
+	body = binary.BigEndian.AppendUint16(body, p.keepAlive)
+
+	body = appendString(body, p.clientID)
+	if p.willTopic != "" {
+		body = appendString(body, p.willTopic)
+		body = appendString(body, p.willMessage)
+	}
+	if p.username != "" {
+		body = appendString(body, p.username)
+	}
+	if p.password != "" {
+		body = appendString(body, p.password)
+	}
+	return appendPacket(nil, packetConnect<<4, body)
+}
+
+// publishPacket return a QoS 0 PUBLISH packet.
+func publishPacket(topic string, payload []byte, retain bool) []byte {
+	header := byte(packetPublish << 4)
+	if retain {
+		header |= 0x01
+	}
+	return appendPacket(nil, header, append(appendString(nil, topic), payload...))
+}
+
+// readPacket return the type, flags and body of the next packet.
+func readPacket(r *bufio.Reader) (packetType byte, flags byte, body []byte, err error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, 0, nil, errPacketTooLarge
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+	body = make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, 0, nil, err
+	}
+	return header >> 4, header & 0x0f, body, nil
+}
@@ -0,0 +1,258 @@
+package arp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// MultiHandler coordinates one Handler per interface, for example to monitor
+// eth0 and wlan0 segments from the same process.
+//
+// Each handler keeps its own socket and table; MultiHandler provides a merged
+// view of the tables, routes hunt requests to the handler that owns the MAC and
+// delivers the events of all handlers in a single subscription. Event.NIC
+// identifies the originating interface.
+type MultiHandler struct {
+	handlers []*Handler
+	mutex    sync.Mutex
+	sources  map[*Subscription]multiSubscription
+}
+
+// multiSubscription holds the goroutines feeding a unified subscription; the
+// handler subscriptions are in Subscription.sources.
+type multiSubscription struct {
+	wg *sync.WaitGroup
+}
+
+// NewMultiHandler return a MultiHandler for the handlers; create each handler
+// with NewHandler for its interface.
+func NewMultiHandler(handlers ...*Handler) *MultiHandler {
+	return &MultiHandler{handlers: handlers, sources: make(map[*Subscription]multiSubscription)}
+}
+
+// Handlers return the handlers in the order given to NewMultiHandler.
+func (m *MultiHandler) Handlers() []*Handler {
+	return append([]*Handler(nil), m.handlers...)
+}
+
+// ListenAndServe run ListenAndServe for all handlers and return when all of them return.
+func (m *MultiHandler) ListenAndServe(scanInterval time.Duration) {
+	var wg sync.WaitGroup
+	for _, h := range m.handlers {
+		wg.Add(1)
+		go func(h *Handler) {
+			defer wg.Done()
+			h.ListenAndServe(scanInterval)
+		}(h)
+	}
+	wg.Wait()
+}
+
+// Stop stop all handlers; it return the first error.
+func (m *MultiHandler) Stop() (err error) {
+	for _, h := range m.handlers {
+		if e := h.Stop(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// StopAndWait stop all handlers and wait for their goroutines to exit; it
+// return the first error. See Handler.StopAndWait.
+func (m *MultiHandler) StopAndWait(ctx context.Context) (err error) {
+	for _, h := range m.handlers {
+		if e := h.StopAndWait(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// GetTable return the merged tables of all handlers; a MAC seen on more than
+// one interface is returned once per interface.
+func (m *MultiHandler) GetTable() (table []*Entry) {
+	for _, h := range m.handlers {
+		table = append(table, h.GetTable()...)
+	}
+	return table
+}
+
+// Snapshot return a copy of the merged tables; see Handler.Snapshot.
+func (m *MultiHandler) Snapshot() (table []Entry) {
+	for _, h := range m.handlers {
+		table = append(table, h.Snapshot()...)
+	}
+	return table
+}
+
+// FindMAC return the entry and the handler of the first interface where the MAC is found.
+func (m *MultiHandler) FindMAC(mac net.HardwareAddr) (*Entry, *Handler) {
+	for _, h := range m.handlers {
+		if entry := h.FindMAC(mac); entry != nil {
+			return entry, h
+		}
+	}
+	return nil, nil
+}
+
+// FindIP return the entry and the handler of the first interface where the IP is found.
+func (m *MultiHandler) FindIP(ip net.IP) (*Entry, *Handler) {
+	for _, h := range m.handlers {
+		if entry := h.FindIP(ip); entry != nil {
+			return entry, h
+		}
+	}
+	return nil, nil
+}
+
+// ForceIPChange start hunting the MAC on the interface where it is found.
+func (m *MultiHandler) ForceIPChange(mac net.HardwareAddr, ip net.IP) error {
+	_, h := m.FindMAC(mac)
+	if h == nil {
+		return errMACNotFound(mac)
+	}
+	return h.ForceIPChange(mac, ip)
+}
+
+// StopIPChange stop hunting the MAC on the interface where it is found.
+func (m *MultiHandler) StopIPChange(mac net.HardwareAddr) error {
+	_, h := m.FindMAC(mac)
+	if h == nil {
+		return errMACNotFound(mac)
+	}
+	return h.StopIPChange(mac)
+}
+
+// StartHunt start hunting the MAC on the interface where it is found; see Handler.StartHunt.
+func (m *MultiHandler) StartHunt(mac net.HardwareAddr) error {
+	_, h := m.FindMAC(mac)
+	if h == nil {
+		return errMACNotFound(mac)
+	}
+	return h.StartHunt(mac)
+}
+
+// StartHuntDirection start hunting the MAC in one direction on the interface where it is found; see Handler.StartHuntDirection.
+func (m *MultiHandler) StartHuntDirection(mac net.HardwareAddr, direction HuntDirection) error {
+	_, h := m.FindMAC(mac)
+	if h == nil {
+		return errMACNotFound(mac)
+	}
+	return h.StartHuntDirection(mac, direction)
+}
+
+// StopHunt stop hunting the MAC on the interface where it is found; see Handler.StopHunt.
+func (m *MultiHandler) StopHunt(mac net.HardwareAddr) error {
+	_, h := m.FindMAC(mac)
+	if h == nil {
+		return errMACNotFound(mac)
+	}
+	return h.StopHunt(mac)
+}
+
+// HuntAll hunt every device on all interfaces; see Handler.HuntAll.
+//
+// It returns the first error but runs HuntAll on every handler.
+func (m *MultiHandler) HuntAll() (err error) {
+	for _, h := range m.handlers {
+		if e := h.HuntAll(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// StopAll stop every hunt on all interfaces; see Handler.StopAll.
+func (m *MultiHandler) StopAll() {
+	for _, h := range m.handlers {
+		h.StopAll()
+	}
+}
+
+// HuntList return a copy of the entries being hunted on all interfaces.
+func (m *MultiHandler) HuntList() []Entry {
+	list := []Entry{}
+	for _, h := range m.handlers {
+		list = append(list, h.HuntList()...)
+	}
+	return list
+}
+
+// Stats return the sum of the counters of all handlers; the latency
+// histograms include the observations of all handlers.
+func (m *MultiHandler) Stats() (stats Stats) {
+	for _, h := range m.handlers {
+		s := h.Stats()
+		stats.PacketsRead += s.PacketsRead
+		stats.RequestsSent += s.RequestsSent
+		stats.RepliesSent += s.RepliesSent
+		stats.ReadErrors += s.ReadErrors
+		stats.PacketsIgnored += s.PacketsIgnored
+		stats.NotificationsDropped += s.NotificationsDropped
+		stats.DevicesOnline += s.DevicesOnline
+		stats.HuntsActive += s.HuntsActive
+		stats.RequestsRead += s.RequestsRead
+		stats.RepliesRead += s.RepliesRead
+		stats.PacketsInvalid += s.PacketsInvalid
+		stats.NotificationsSent += s.NotificationsSent
+		stats.Goroutines += s.Goroutines
+		stats.TableSize += s.TableSize
+		stats.TableLimit += s.TableLimit
+		stats.WatchdogResets += s.WatchdogResets
+		stats.PacketsRaw += s.PacketsRaw
+		stats.PacketsDropped += s.PacketsDropped
+		stats.KernelDrops += s.KernelDrops
+		stats.UpdateLatency.add(s.UpdateLatency)
+		stats.NotifyLatency.add(s.NotifyLatency)
+	}
+	return stats
+}
+
+// Subscribe return a subscription to the events of all handlers.
+//
+// size and policy apply to the unified queue; see Handler.Subscribe. Each
+// handler queue has the same size and drops the oldest events; Dropped counts
+// the events discarded by all queues. Call Unsubscribe when no longer interested.
+func (m *MultiHandler) Subscribe(size int, policy OverflowPolicy) *Subscription {
+	q := newNotificationQueue(size, policy)
+	s := &Subscription{C: q.queue, queue: q}
+
+	source := multiSubscription{wg: &sync.WaitGroup{}}
+	for _, h := range m.handlers {
+		sub := h.Subscribe(size, DropOldest)
+		s.sources = append(s.sources, sub)
+
+		source.wg.Add(1)
+		go func(sub *Subscription) {
+			defer source.wg.Done()
+			for event := range sub.C {
+				q.push(event)
+			}
+		}(sub)
+	}
+
+	m.mutex.Lock()
+	m.sources[s] = source
+	m.mutex.Unlock()
+	return s
+}
+
+// Unsubscribe remove the subscription and close its channel.
+func (m *MultiHandler) Unsubscribe(s *Subscription) {
+	m.mutex.Lock()
+	source, ok := m.sources[s]
+	delete(m.sources, s)
+	m.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	for i, sub := range s.sources {
+		m.handlers[i].Unsubscribe(sub)
+	}
+	source.wg.Wait()
+	s.queue.close()
+}
@@ -0,0 +1,95 @@
+package arp
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_MultiHandler(t *testing.T) {
+	hostMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	hostIP := net.IPv4(192, 168, 0, 100).To4()
+	lan := net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}
+
+	conn0, conn1 := newFakeConn(), newFakeConn()
+	h0, err := NewHandler("eth0", hostMAC, hostIP, ip3, lan, WithPacketConn(conn0))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	h1, err := NewHandler("wlan0", hostMAC, hostIP, ip3, lan, WithPacketConn(conn1))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+
+	m := NewMultiHandler(h0, h1)
+	s := m.Subscribe(16, DropNewest)
+	done := make(chan struct{})
+	go func() {
+		m.ListenAndServe(0)
+		close(done)
+	}()
+
+	p, _ := marp.NewPacket(marp.OperationReply, mac1, ip1, hostMAC, hostIP)
+	conn0.in <- p
+	p, _ = marp.NewPacket(marp.OperationReply, mac2, ip2, hostMAC, hostIP)
+	conn1.in <- p
+
+	nics := make(map[string]string)
+	for len(nics) < 2 {
+		select {
+		case e := <-s.C:
+			if e.Type == EventNewDevice {
+				nics[e.Entry.MAC.String()] = e.NIC
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+	}
+	if nics[mac1.String()] != "eth0" || nics[mac2.String()] != "wlan0" {
+		t.Errorf("invalid event nic %v", nics)
+	}
+
+	// the update latency is observed before the event is published
+	if stats := m.Stats(); stats.UpdateLatency.Count != 2 || len(stats.UpdateLatency.Buckets) != len(latencyBounds) ||
+		stats.UpdateLatency.Sum != h0.Stats().UpdateLatency.Sum+h1.Stats().UpdateLatency.Sum {
+		t.Errorf("invalid merged latency %+v", stats.UpdateLatency)
+	}
+
+	if n := len(m.Snapshot()); n != 2 {
+		t.Errorf("invalid table len=%d want 2", n)
+	}
+	if _, h := m.FindMAC(mac2); h != h1 {
+		t.Error("mac2 not found on wlan0")
+	}
+	if err := m.ForceIPChange(mac2, ip2); err != nil {
+		t.Fatal("ForceIPChange error ", err)
+	}
+	if entry := h1.FindMAC(mac2); entry == nil || entry.State != StateHunt {
+		t.Errorf("mac2 not in hunt state %+v", entry)
+	}
+	if err := m.ForceIPChange(mac3, ip2); err == nil {
+		t.Error("ForceIPChange unknown mac should fail")
+	}
+
+	// events dropped by the handler queues are counted
+	atomic.AddUint64(&s.sources[1].queue.dropped, 2)
+	if n := s.Dropped(); n != 2 {
+		t.Errorf("invalid dropped count %d want 2", n)
+	}
+
+	m.Unsubscribe(s)
+	if _, ok := <-s.C; ok {
+		for range s.C {
+		}
+	}
+
+	m.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout waiting for ListenAndServe")
+	}
+}
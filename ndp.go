@@ -0,0 +1,266 @@
+package arp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ICMPv6 message types used by Neighbor Discovery (RFC 4861)
+const (
+	icmpv6EchoRequest               = 128
+	icmpv6EchoReply                 = 129
+	ndpRouterSolicitation           = 133
+	ndpRouterAdvertisement          = 134
+	ndpNeighborSolicitation         = 135
+	ndpNeighborAdvertisement        = 136
+	ndpOptionSourceLinkAddress      = 1
+	ndpOptionTargetLinkAddress      = 2
+	ndpFlagRouter              byte = 0x80
+	ndpFlagSolicited           byte = 0x40
+	ndpFlagOverride            byte = 0x20
+)
+
+var (
+	// IPv6AllNodes defines the link local all nodes multicast address
+	IPv6AllNodes = net.ParseIP("ff02::1")
+
+	errInvalidNDPPacket = errors.New("invalid NDP packet")
+)
+
+// ndpPacket holds the fields of an ICMPv6 neighbor discovery message we care about.
+//
+// LinkAddr is the source link-layer address option for solicitations and
+// router advertisements and the target link-layer address option for advertisements.
+type ndpPacket struct {
+	Type     uint8
+	Flags    byte
+	TargetIP net.IP
+	LinkAddr net.HardwareAddr
+}
+
+// marshal encodes the packet as an ICMPv6 message.
+// The checksum is left as zero; the kernel computes it for ICMPv6 raw sockets.
+func (p *ndpPacket) marshal() ([]byte, error) {
+	switch p.Type {
+	case icmpv6EchoRequest:
+		b := make([]byte, 8)
+		b[0] = p.Type
+		binary.BigEndian.PutUint16(b[4:6], uint16(time.Now().Unix()))
+		return b, nil
+
+	case ndpNeighborSolicitation, ndpNeighborAdvertisement:
+		if p.TargetIP.To16() == nil || p.TargetIP.To4() != nil {
+			return nil, errInvalidNDPPacket
+		}
+		b := make([]byte, 24, 32)
+		b[0] = p.Type
+		if p.Type == ndpNeighborAdvertisement {
+			b[4] = p.Flags
+		}
+		copy(b[8:24], p.TargetIP.To16())
+
+		if len(p.LinkAddr) == 6 {
+			option := byte(ndpOptionSourceLinkAddress)
+			if p.Type == ndpNeighborAdvertisement {
+				option = ndpOptionTargetLinkAddress
+			}
+			b = append(b, option, 1) // option length is in units of 8 bytes
+			b = append(b, p.LinkAddr...)
+		}
+		return b, nil
+	}
+
+	return nil, errInvalidNDPPacket
+}
+
+// unmarshal decodes an ICMPv6 message. Messages that are not used by the
+// package return errInvalidNDPPacket.
+func (p *ndpPacket) unmarshal(b []byte) error {
+	if len(b) < 8 || b[1] != 0 {
+		return errInvalidNDPPacket
+	}
+	p.Type = b[0]
+	p.Flags = 0
+	p.TargetIP = nil
+	p.LinkAddr = nil
+
+	var options []byte
+	switch p.Type {
+	case icmpv6EchoReply:
+		return nil
+
+	case ndpRouterAdvertisement:
+		if len(b) < 16 {
+			return errInvalidNDPPacket
+		}
+		options = b[16:]
+
+	case ndpNeighborSolicitation, ndpNeighborAdvertisement:
+		if len(b) < 24 {
+			return errInvalidNDPPacket
+		}
+		p.Flags = b[4] & (ndpFlagRouter | ndpFlagSolicited | ndpFlagOverride)
+		p.TargetIP = dupIPv6(b[8:24])
+		options = b[24:]
+
+	default:
+		return errInvalidNDPPacket
+	}
+
+	for len(options) >= 8 {
+		length := int(options[1]) * 8
+		if length == 0 || length > len(options) {
+			return errInvalidNDPPacket
+		}
+		if options[0] == ndpOptionSourceLinkAddress || options[0] == ndpOptionTargetLinkAddress {
+			p.LinkAddr = dupMAC(options[2:8])
+		}
+		options = options[length:]
+	}
+	return nil
+}
+
+// solicitedNodeMulticast returns the solicited-node multicast address for ip.
+// i.e. ff02::1:ffXX:XXXX where XX:XXXX are the last 24 bits of ip
+func solicitedNodeMulticast(ip net.IP) net.IP {
+	addr := net.ParseIP("ff02::1:ff00:0")
+	ip = ip.To16()
+	copy(addr[13:], ip[13:16])
+	return addr
+}
+
+// ndpConn is an ICMPv6 raw socket bound to an interface.
+type ndpConn struct {
+	conn *net.IPConn
+	ifi  *net.Interface
+}
+
+func dialNDP(nic string) (*ndpConn, error) {
+	ifi, err := net.InterfaceByName(nic)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenIP("ip6:ipv6-icmp", &net.IPAddr{IP: net.IPv6unspecified})
+	if err != nil {
+		return nil, err
+	}
+
+	// NDP packets must be sent with hop limit 255 or the receiver will discard them
+	if err := setNDPSocketOptions(conn, ifi); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &ndpConn{conn: conn, ifi: ifi}, nil
+}
+
+func (n *ndpConn) close() error {
+	return n.conn.Close()
+}
+
+// read returns the next NDP packet and its source address.
+func (n *ndpConn) read(b []byte) (p *ndpPacket, srcIP net.IP, err error) {
+	for {
+		l, addr, err := n.conn.ReadFromIP(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		p = &ndpPacket{}
+		if err := p.unmarshal(b[:l]); err != nil {
+			continue // not interested
+		}
+		return p, dupIPv6(addr.IP), nil
+	}
+}
+
+func (n *ndpConn) write(p *ndpPacket, dstIP net.IP) error {
+	b, err := p.marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := n.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+
+	_, err = n.conn.WriteToIP(b, &net.IPAddr{IP: dstIP, Zone: n.ifi.Name})
+	return err
+}
+
+// NeighborSolicitation send a NDP neighbor solicitation to get the MAC address for the IPv6 address.
+//
+// The solicitation is sent to the solicited-node multicast address of the target and
+// carries the host MAC as the source link-layer address.
+func (c *Handler) NeighborSolicitation(ip net.IP) error {
+	ndp := c.ndpConn()
+	if ndp == nil {
+		return errNDPNotRunning
+	}
+	if c.logArea(LogPackets) {
+		c.log().WithFields(log.Fields{"ip": ip}).Debugf("NDP send solicitation - who is %s", ip)
+	}
+	p := &ndpPacket{Type: ndpNeighborSolicitation, TargetIP: ip, LinkAddr: c.config.HostMAC}
+	return ndp.write(p, solicitedNodeMulticast(ip))
+}
+
+// NeighborAdvertisement send a NDP neighbor advertisement claiming ip is at mac.
+//
+// Call with dstIP = IPv6AllNodes to advertise to all.
+func (c *Handler) NeighborAdvertisement(mac net.HardwareAddr, ip net.IP, dstIP net.IP) error {
+	if c.logArea(LogPackets) {
+		c.log().WithFields(log.Fields{"dstip": dstIP}).Debugf("NDP send advertisement - host %s is at %s", ip, mac)
+	}
+	return c.advertise(mac, ip, dstIP, ndpFlagOverride)
+}
+
+func (c *Handler) advertise(mac net.HardwareAddr, ip net.IP, dstIP net.IP, flags byte) error {
+	ndp := c.ndpConn()
+	if ndp == nil {
+		return errNDPNotRunning
+	}
+	p := &ndpPacket{Type: ndpNeighborAdvertisement, Flags: flags, TargetIP: ip, LinkAddr: mac}
+	return ndp.write(p, dstIP)
+}
+
+// forceSpoofNDP is the IPv6 equivalent of forceSpoof and forceAnnouncement.
+//
+// It sends an unsolicited neighbor advertisement to each client address claiming
+// the router IPv6 is at the host MAC and advertises to all nodes that the
+// virtual MAC owns the client IPv6 addresses.
+func (c *Handler) forceSpoofNDP(client *Entry, virtual *Entry) error {
+	c.mutex.RLock()
+	routerIP := c.config.RouterIPv6
+	clientIPs := client.IPv6
+	var virtualIPs []net.IP // HuntClient does not claim the client addresses
+	if virtual != nil {
+		virtualIPs = virtual.IPv6
+	}
+	c.mutex.RUnlock()
+
+	if routerIP != nil {
+		for _, ip := range clientIPs {
+			if err := c.advertise(c.config.HostMAC, routerIP, ip, ndpFlagRouter|ndpFlagOverride); err != nil {
+				c.log().WithFields(log.Fields{"mac": client.MAC.String(), "ip": ip}).Error("NDP spoof client error", err)
+				return err
+			}
+		}
+	}
+
+	for _, ip := range virtualIPs {
+		if err := c.advertise(virtual.MAC, ip, IPv6AllNodes, ndpFlagOverride); err != nil {
+			c.log().WithFields(log.Fields{"mac": virtual.MAC.String(), "ip": ip}).Error("NDP error send advertisement packet", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func dupIPv6(srcIP net.IP) net.IP {
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, srcIP.To16())
+	return ip
+}
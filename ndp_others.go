@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package arp
+
+import (
+	"errors"
+	"net"
+)
+
+func setNDPSocketOptions(conn *net.IPConn, ifi *net.Interface) error {
+	return errors.New("NDP is not supported on this platform")
+}
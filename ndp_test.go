@@ -0,0 +1,65 @@
+package arp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+var (
+	ip6_1 = net.ParseIP("fe80::1")
+	ip6_2 = net.ParseIP("2001:db8::2")
+)
+
+func Test_NDPMarshal(t *testing.T) {
+
+	for _, typ := range []uint8{ndpNeighborSolicitation, ndpNeighborAdvertisement} {
+		p := &ndpPacket{Type: typ, Flags: ndpFlagOverride, TargetIP: ip6_2, LinkAddr: mac1}
+		b, err := p.marshal()
+		if err != nil {
+			t.Fatal("unexpected marshal error ", err)
+		}
+
+		p2 := &ndpPacket{}
+		if err := p2.unmarshal(b); err != nil {
+			t.Fatal("unexpected unmarshal error ", err)
+		}
+		if p2.Type != typ || !p2.TargetIP.Equal(ip6_2) || !bytes.Equal(p2.LinkAddr, mac1) {
+			t.Errorf("invalid packet %+v", p2)
+		}
+		if typ == ndpNeighborAdvertisement && p2.Flags != ndpFlagOverride {
+			t.Errorf("invalid flags %x", p2.Flags)
+		}
+	}
+
+	if _, err := (&ndpPacket{Type: ndpNeighborSolicitation, TargetIP: ip1}).marshal(); err == nil {
+		t.Error("expected error for IPv4 target")
+	}
+	if err := (&ndpPacket{}).unmarshal([]byte{ndpNeighborAdvertisement, 0, 0, 0}); err == nil {
+		t.Error("expected error for short packet")
+	}
+}
+
+func Test_SolicitedNodeMulticast(t *testing.T) {
+	if ip := solicitedNodeMulticast(net.ParseIP("2001:db8::1:2:3")); !ip.Equal(net.ParseIP("ff02::1:ff02:3")) {
+		t.Error("invalid solicited node address ", ip)
+	}
+}
+
+func Test_UpdateIPv6(t *testing.T) {
+
+	h := &Handler{table: make([]*Entry, 0, 256)}
+	entry := h.arpTableAppendLocked(StateNormal, mac1, ip1)
+
+	if e, _, n := h.actionUpdateIPv6(mac1, ip6_1); e != entry || n != 1 || h.FindIPv6(ip6_1) != entry {
+		t.Error("expected IPv6 added to existing entry ", n)
+	}
+	if _, _, n := h.actionUpdateIPv6(mac1, ip6_1); n != 0 {
+		t.Error("expected no change for same IPv6 ", n)
+	}
+
+	e, previous, n := h.actionUpdateIPv6(mac2, ip6_2)
+	if e == nil || n != 2 || previous.MAC != nil || !e.IP.Equal(net.IPv4zero) || h.FindIPv6(ip6_2) != e {
+		t.Error("expected new IPv6 only entry ", n)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package arp
+
+import (
+	"net"
+	"syscall"
+)
+
+func setNDPSocketOptions(conn *net.IPConn, ifi *net.Interface) (err error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	cerr := raw.Control(func(fd uintptr) {
+		if err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, 255); err != nil {
+			return
+		}
+		if err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_HOPS, 255); err != nil {
+			return
+		}
+		err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_IF, ifi.Index)
+	})
+	if cerr != nil {
+		return cerr
+	}
+	return err
+}
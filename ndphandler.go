@@ -0,0 +1,231 @@
+package arp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var errNDPNotRunning = errors.New("NDP handler is not running")
+
+// ndpConn return the NDP socket or nil if ListenAndServeNDP is not running.
+func (c *Handler) ndpConn() *ndpConn {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.ndp
+}
+
+// FindIPv6 return the entry or nil if not found.
+func (c *Handler) FindIPv6(ip net.IP) *Entry {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return lookupIP(c.ipIndex, ip)
+}
+
+// findVirtualIPv6Locked return the virtual entry claiming the IPv6 address or nil if not found.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) findVirtualIPv6Locked(ip net.IP) *Entry {
+	return lookupIP(c.virtualIndex, ip)
+}
+
+func (e *Entry) hasIPv6(ip net.IP) bool {
+	for _, v := range e.IPv6 {
+		if v.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// actionUpdateIPv6 records the IPv6 address for the mac. It will create a new entry if
+// the mac is not in the table yet.
+//
+// Return the entry before the change and the number of changes to notify.
+func (c *Handler) actionUpdateIPv6(mac net.HardwareAddr, ip net.IP) (entry *Entry, previous Entry, n int) {
+	if ip.IsUnspecified() || ip.IsMulticast() ||
+		bytes.Equal(mac, c.config.HostMAC) {
+		return nil, previous, 0
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry = c.findMACLocked(mac)
+	if entry != nil {
+		previous = *entry
+	} else {
+		// IPv6 only device; IPv4 will be filled in when we see an ARP packet
+		if entry = c.arpTableAppendLocked(StateNormal, mac, net.IPv4zero); entry == nil {
+			return nil, previous, 0
+		}
+		n++
+	}
+
+	// Skip packets that we sent as virtual host
+	if entry.State == StateVirtualHost {
+		return nil, previous, 0
+	}
+	entry.LastUpdate = time.Now()
+
+	if !entry.hasIPv6(ip) {
+		// copy on write; other goroutines may hold the previous slice
+		ips := make([]net.IP, 0, len(entry.IPv6)+1)
+		ips = append(ips, entry.IPv6...)
+		c.setIPv6Locked(entry, append(ips, dupIPv6(ip)))
+		n++
+
+		if c.logArea(LogTable) {
+			c.log().WithFields(log.Fields{"mac": entry.MAC.String(), "ip": entry.IP}).Debugf("NDP client added IPv6 %s", ip)
+		}
+	}
+	return entry, previous, n
+}
+
+// ListenAndServeNDP listen for IPv6 neighbor discovery packets and action these.
+//
+// It runs alongside ListenAndServe and shares the same table: IPv6 addresses are
+// recorded in Entry.IPv6 for the MAC advertised in the packet link-layer option.
+//
+// When a client is in hunt state, the spoof goroutine will also send neighbor advertisements
+// to claim the client IPv6 addresses using the virtual MAC and ListenAndServeNDP will
+// reply to neighbor solicitations on behalf of virtual MACs.
+//
+// Limitations: the socket only sees multicast sent to all nodes and packets sent to
+// the host, so devices are mostly discovered via the periodic all nodes echo request.
+func (c *Handler) ListenAndServeNDP(scanInterval time.Duration) error {
+	ndp, err := dialNDP(c.config.NIC)
+	if err != nil {
+		c.log().WithFields(log.Fields{"nic": c.config.NIC}).Error("NDP error in socket:", err)
+		return err
+	}
+
+	c.mutex.Lock()
+	c.ndp = ndp
+	c.mutex.Unlock()
+
+	// Goroutine pool
+	h := c.goroutinePool.Begin("NDP ListenAndServe")
+	defer h.End()
+
+	if !c.passive {
+		go c.ndpPollingLoop(c.goroutinePool.Begin("NDP pollingLoop"), scanInterval)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		packet, srcIP, err := ndp.read(buf)
+		if h.Stopping() { // are we stopping all goroutines?
+			return nil
+		}
+		if err != nil {
+			atomic.AddUint64(&c.counters.readErrors, 1)
+			c.log().Error("NDP read error ", err)
+			if err1, ok := err.(net.Error); ok && err1.Temporary() {
+				time.Sleep(time.Millisecond * 30)
+				continue
+			}
+			return err
+		}
+		atomic.AddUint64(&c.counters.packetsRead, 1)
+
+		var entry *Entry
+		var previous Entry
+		notify := 0
+
+		switch packet.Type {
+		case icmpv6EchoReply:
+			// reply to our all nodes echo; solicit the mac if we don't know this address
+			if c.FindIPv6(srcIP) == nil {
+				c.NeighborSolicitation(srcIP)
+			}
+
+		case ndpRouterAdvertisement:
+			c.mutex.Lock()
+			c.config.RouterIPv6 = srcIP
+			c.mutex.Unlock()
+			if packet.LinkAddr != nil {
+				entry, previous, notify = c.actionUpdateIPv6(packet.LinkAddr, srcIP)
+			}
+
+		case ndpNeighborSolicitation:
+			if c.logArea(LogPackets) {
+				c.log().WithFields(log.Fields{"ip": srcIP, "mac": packet.LinkAddr}).Debugf("NDP solicitation received - who is %s", packet.TargetIP)
+			}
+
+			// if target is virtual host, reply
+			c.mutex.RLock()
+			target := c.findVirtualIPv6Locked(packet.TargetIP)
+			c.mutex.RUnlock()
+			if target != nil {
+				dstIP := srcIP
+				if srcIP.IsUnspecified() { // DAD probe
+					dstIP = IPv6AllNodes
+				}
+				c.advertise(target.MAC, packet.TargetIP, dstIP, ndpFlagSolicited|ndpFlagOverride)
+			}
+
+			if packet.LinkAddr != nil {
+				entry, previous, notify = c.actionUpdateIPv6(packet.LinkAddr, srcIP)
+			}
+
+		case ndpNeighborAdvertisement:
+			if c.logArea(LogPackets) {
+				c.log().WithFields(log.Fields{"ip": srcIP, "mac": packet.LinkAddr}).Debugf("NDP advertisement received - %s is at %s", packet.TargetIP, packet.LinkAddr)
+			}
+			if packet.LinkAddr != nil {
+				entry, previous, notify = c.actionUpdateIPv6(packet.LinkAddr, packet.TargetIP)
+			}
+			// routers advertise with the router flag; use the link local address as the next hop
+			if packet.Flags&ndpFlagRouter != 0 && packet.TargetIP.IsLinkLocalUnicast() {
+				c.mutex.Lock()
+				c.config.RouterIPv6 = packet.TargetIP
+				c.mutex.Unlock()
+			}
+		}
+
+		if notify > 0 && entry != nil {
+			c.mutex.Lock()
+			entry.Online = true
+			local := *entry
+			c.mutex.Unlock()
+
+			c.log().WithFields(log.Fields{"mac": local.MAC, "ip": local.IP, "ipv6": local.IPv6}).Info("NDP device IPv6 changed")
+
+			event := EventIPChanged
+			if previous.MAC == nil {
+				event = EventNewDevice
+			}
+			c.notify(event, previous, local)
+		}
+	}
+}
+
+// ndpPollingLoop send an echo request to all nodes to discover IPv6 devices.
+func (c *Handler) ndpPollingLoop(h *goroutine, scanInterval time.Duration) {
+	defer h.End()
+
+	if scanInterval <= 0 {
+		scanInterval = time.Minute * 60 * 24 * 365 * 20 // will never expire
+	}
+
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+	for {
+		if ndp := c.ndpConn(); ndp != nil {
+			if err := ndp.write(&ndpPacket{Type: icmpv6EchoRequest}, IPv6AllNodes); err != nil {
+				c.log().Error("NDP error in all nodes echo request ", err)
+			}
+		}
+
+		select {
+		case <-c.goroutinePool.StopChannel:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package arp
+
+import (
+	"net"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// neighbor is an IPv4 entry in the kernel neighbor table
+type neighbor struct {
+	ip  net.IP
+	mac net.HardwareAddr
+}
+
+// neighborTable is the kernel neighbor (ARP) table of the interface; see
+// WithNeighborSync.
+type neighborTable interface {
+	read() ([]neighbor, error)
+	write(n neighbor) error
+}
+
+// WithNeighborSync mirror the table into the kernel neighbor table of the
+// interface and import the kernel entries when ListenAndServe starts.
+//
+// Imported entries start offline and are probed by the polling loop like
+// entries loaded from a Store. Entries are written in stale state when a
+// device is added, changes IP or goes online, so the kernel confirms them on
+// first use. It is only supported on linux and requires CAP_NET_ADMIN.
+func WithNeighborSync() Option {
+	return func(c *Handler) error {
+		t, err := newNeighborTable(c.config.NIC)
+		if err != nil {
+			return err
+		}
+		c.neighbors = t
+		return nil
+	}
+}
+
+// neighborLoop import the kernel neighbor table and write entry changes to it
+// until the handler stops.
+func (c *Handler) neighborLoop(h *goroutine, sub *Subscription) {
+	defer h.End()
+	defer c.Unsubscribe(sub)
+
+	if list, err := c.neighbors.read(); err != nil {
+		c.log().WithFields(log.Fields{"nic": c.config.NIC}).Error("ARP cannot read kernel neighbor table ", err)
+	} else {
+		c.importNeighbors(list)
+	}
+
+	for {
+		select {
+		case <-c.goroutinePool.StopChannel:
+			return
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			switch event.Type {
+			case EventNewDevice, EventDeviceOnline, EventIPChanged:
+			default:
+				continue
+			}
+			if event.Entry.State == StateVirtualHost || event.Entry.IP.Equal(net.IPv4zero) {
+				continue
+			}
+			if err := c.neighbors.write(neighbor{ip: event.Entry.IP, mac: event.Entry.MAC}); err != nil {
+				c.log().WithFields(log.Fields{"mac": event.Entry.MAC.String(), "ip": event.Entry.IP}).Error("ARP cannot write kernel neighbor ", err)
+			}
+		}
+	}
+}
+
+// importNeighbors add the kernel entries in the home LAN that are not in the
+// table yet.
+func (c *Handler) importNeighbors(list []neighbor) {
+	n := 0
+	c.mutex.Lock()
+	for _, v := range list {
+		if !c.config.HomeLAN.Contains(v.ip) || v.ip.Equal(c.config.HostIP) || c.findMACLocked(v.mac) != nil {
+			continue
+		}
+		if c.arpTableAppendLocked(StateNormal, v.mac, v.ip) != nil {
+			n++
+		}
+	}
+	c.mutex.Unlock()
+
+	if c.logArea(LogTable) {
+		c.log().Debugf("ARP imported %d entries from kernel neighbor table", n)
+	}
+}
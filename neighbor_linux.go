@@ -0,0 +1,136 @@
+//go:build linux
+// +build linux
+
+package arp
+
+import (
+	"net"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// neighborStates are the states of valid neighbor entries
+const neighborStates = unix.NUD_REACHABLE | unix.NUD_STALE | unix.NUD_DELAY | unix.NUD_PROBE | unix.NUD_PERMANENT
+
+// neighborSeq is the sequence number of the last netlink request
+var neighborSeq uint32
+
+// kernelNeighbors read and write the neighbor table via netlink
+type kernelNeighbors struct {
+	ifi *net.Interface
+}
+
+func newNeighborTable(nic string) (neighborTable, error) {
+	ifi, err := net.InterfaceByName(nic)
+	if err != nil {
+		return nil, err
+	}
+	return &kernelNeighbors{ifi: ifi}, nil
+}
+
+func (k *kernelNeighbors) read() ([]neighbor, error) {
+	b, err := syscall.NetlinkRIB(unix.RTM_GETNEIGH, unix.AF_INET)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		return nil, err
+	}
+	return parseNeighbors(msgs, k.ifi.Index), nil
+}
+
+func (k *kernelNeighbors) write(n neighbor) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &unix.Timeval{Sec: 1}); err != nil {
+		return err
+	}
+	addr := &unix.SockaddrNetlink{Family: unix.AF_NETLINK}
+	if err := unix.Bind(fd, addr); err != nil {
+		return err
+	}
+	if err := unix.Sendto(fd, neighborRequest(k.ifi.Index, n, atomic.AddUint32(&neighborSeq, 1)), 0, addr); err != nil {
+		return err
+	}
+
+	// wait for the acknowledgement; it carries the error code
+	buf := make([]byte, 4096)
+	l, _, err := unix.Recvfrom(fd, buf, 0)
+	if err != nil {
+		return err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(buf[:l])
+	if err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		if m.Header.Type == unix.NLMSG_ERROR && len(m.Data) >= 4 {
+			if errno := *(*int32)(unsafe.Pointer(&m.Data[0])); errno != 0 {
+				return syscall.Errno(-errno)
+			}
+		}
+	}
+	return nil
+}
+
+// neighborRequest return a RTM_NEWNEIGH request that creates or replaces the
+// neighbor in stale state.
+func neighborRequest(index int, n neighbor, seq uint32) []byte {
+	const size = unix.SizeofNlMsghdr + unix.SizeofNdMsg + unix.SizeofRtAttr + 4 + unix.SizeofRtAttr + 8
+	b := make([]byte, size)
+	*(*unix.NlMsghdr)(unsafe.Pointer(&b[0])) = unix.NlMsghdr{Len: size, Type: unix.RTM_NEWNEIGH,
+		Flags: unix.NLM_F_REQUEST | unix.NLM_F_ACK | unix.NLM_F_CREATE | unix.NLM_F_REPLACE, Seq: seq}
+	*(*unix.NdMsg)(unsafe.Pointer(&b[unix.SizeofNlMsghdr])) = unix.NdMsg{Family: unix.AF_INET, Ifindex: int32(index), State: unix.NUD_STALE}
+
+	i := unix.SizeofNlMsghdr + unix.SizeofNdMsg
+	*(*unix.RtAttr)(unsafe.Pointer(&b[i])) = unix.RtAttr{Len: unix.SizeofRtAttr + 4, Type: unix.NDA_DST}
+	copy(b[i+unix.SizeofRtAttr:], n.ip.To4())
+	i += unix.SizeofRtAttr + 4
+	*(*unix.RtAttr)(unsafe.Pointer(&b[i])) = unix.RtAttr{Len: unix.SizeofRtAttr + 6, Type: unix.NDA_LLADDR}
+	copy(b[i+unix.SizeofRtAttr:], n.mac)
+	return b
+}
+
+// parseNeighbors return the valid IPv4 neighbors of the interface index.
+func parseNeighbors(msgs []syscall.NetlinkMessage, index int) (list []neighbor) {
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWNEIGH || len(m.Data) < unix.SizeofNdMsg {
+			continue
+		}
+		msg := (*unix.NdMsg)(unsafe.Pointer(&m.Data[0]))
+		if msg.Family != unix.AF_INET || int(msg.Ifindex) != index || msg.State&neighborStates == 0 {
+			continue
+		}
+
+		var n neighbor
+		for b := m.Data[unix.SizeofNdMsg:]; len(b) >= unix.SizeofRtAttr; {
+			attr := (*unix.RtAttr)(unsafe.Pointer(&b[0]))
+			l := int(attr.Len)
+			if l < unix.SizeofRtAttr || l > len(b) {
+				break
+			}
+			value := b[unix.SizeofRtAttr:l]
+			switch {
+			case attr.Type == unix.NDA_DST && len(value) == net.IPv4len:
+				n.ip = dupIP(value)
+			case attr.Type == unix.NDA_LLADDR && len(value) == 6:
+				n.mac = dupMAC(value)
+			}
+			if l = (l + unix.NLMSG_ALIGNTO - 1) &^ (unix.NLMSG_ALIGNTO - 1); l > len(b) {
+				break
+			}
+			b = b[l:]
+		}
+		if n.ip != nil && n.mac != nil {
+			list = append(list, n)
+		}
+	}
+	return list
+}
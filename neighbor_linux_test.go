@@ -0,0 +1,27 @@
+package arp
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+func Test_NeighborRequest(t *testing.T) {
+	n := neighbor{ip: ip1, mac: mac1}
+	msgs, err := syscall.ParseNetlinkMessage(neighborRequest(2, n, 1))
+	if err != nil {
+		t.Fatal("parse error ", err)
+	}
+	list := parseNeighbors(msgs, 2)
+	if len(list) != 1 || !list[0].ip.Equal(ip1) || list[0].mac.String() != mac1.String() {
+		t.Fatalf("invalid neighbors %+v", list)
+	}
+	if list := parseNeighbors(msgs, 3); len(list) != 0 {
+		t.Errorf("invalid neighbors for other interface %+v", list)
+	}
+	if _, err := net.InterfaceByName("lo"); err == nil {
+		if _, err := newNeighborTable("lo"); err != nil {
+			t.Error("cannot open neighbor table ", err)
+		}
+	}
+}
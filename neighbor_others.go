@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package arp
+
+import "errors"
+
+func newNeighborTable(nic string) (neighborTable, error) {
+	return nil, errors.New("kernel neighbor sync is not supported on this platform")
+}
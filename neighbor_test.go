@@ -0,0 +1,51 @@
+package arp
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+type fakeNeighbors struct {
+	mutex   sync.Mutex
+	list    []neighbor
+	written []neighbor
+}
+
+func (f *fakeNeighbors) read() ([]neighbor, error) { return f.list, nil }
+
+func (f *fakeNeighbors) write(n neighbor) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.written = append(f.written, n)
+	return nil
+}
+
+func Test_NeighborSync(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	// the host and addresses outside the LAN are not imported
+	fake := &fakeNeighbors{list: []neighbor{{ip: ip1, mac: mac1}, {ip: ip3, mac: mac3}, {ip: net.IPv4(10, 0, 0, 1).To4(), mac: mac2}}}
+	h.neighbors = fake
+	go h.neighborLoop(h.goroutinePool.Begin("ARP neighborLoop"), h.Subscribe(16, DropOldest))
+
+	time.Sleep(time.Millisecond * 20)
+	if e := h.FindMAC(mac1); e == nil || e.Online || h.FindMAC(mac2) != nil || h.FindMAC(mac3) != nil {
+		t.Fatalf("invalid import %+v", h.Snapshot())
+	}
+
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac2, ip2, EthernetBroadcast, ip3))
+	time.Sleep(time.Millisecond * 20)
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	if len(fake.written) != 1 || !fake.written[0].ip.Equal(ip2) || fake.written[0].mac.String() != mac2.String() {
+		t.Errorf("invalid neighbors written %+v", fake.written)
+	}
+}
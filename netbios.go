@@ -0,0 +1,149 @@
+package arp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// netbiosFunc return the NetBIOS machine name of ip
+type netbiosFunc func(ip net.IP, timeout time.Duration) (string, error)
+
+var (
+	netbiosTimeout = time.Second // time to wait for the node status response
+	netbiosPort    = 137         // NetBIOS name service port
+)
+
+// netbiosID is the transaction ID of the last node status request
+var netbiosID uint32
+
+var errNetBIOSInvalid = errors.New("invalid netbios response")
+
+// WithNetBIOS send a NetBIOS node status request to new devices and save the
+// machine name in Entry.NetBIOSName; EventHostnameChanged is sent when the
+// name changes. Most Windows devices and Samba servers answer the request.
+func WithNetBIOS() Option {
+	return func(c *Handler) error {
+		c.netbios = netbiosQuery
+		return nil
+	}
+}
+
+// netbiosLoop query the machine name of entries when they are added or
+// change IP until the handler stops. Queries are sequential so devices that
+// do not answer limit the rate to one query per timeout.
+func (c *Handler) netbiosLoop(h *goroutine, sub *Subscription) {
+	defer h.End()
+	defer c.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-c.goroutinePool.StopChannel:
+			return
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			switch event.Type {
+			case EventNewDevice, EventIPChanged:
+			default:
+				continue
+			}
+			if event.Entry.State == StateVirtualHost || event.Entry.IP.Equal(net.IPv4zero) {
+				continue
+			}
+			name, err := c.netbios(event.Entry.IP, netbiosTimeout)
+			if err != nil {
+				if c.logArea(LogTable) {
+					c.log().WithFields(log.Fields{"mac": event.Entry.MAC.String(), "ip": event.Entry.IP}).Debug("ARP netbios query error ", err)
+				}
+				continue
+			}
+			c.setDiscoveredName(event.Entry.IP, name, netbiosNameField)
+		}
+	}
+}
+
+func netbiosNameField(e *Entry) *string { return &e.NetBIOSName }
+
+// netbiosQuery send a node status request to ip and return the machine name.
+func netbiosQuery(ip net.IP, timeout time.Duration) (string, error) {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: ip, Port: netbiosPort})
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return "", err
+	}
+
+	id := uint16(atomic.AddUint32(&netbiosID, 1))
+	if _, err := conn.Write(netbiosRequest(id)); err != nil {
+		return "", err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+			return parseNodeStatus(buf[:n])
+		}
+	}
+}
+
+// netbiosRequest return a node status request for the wildcard name "*".
+func netbiosRequest(id uint16) []byte {
+	b := make([]byte, 12, 50)
+	binary.BigEndian.PutUint16(b[0:], id)
+	binary.BigEndian.PutUint16(b[4:], 1) // one question
+
+	// first level encoding of "*" padded with zeros to 16 bytes
+	b = append(b, 32, 'C', 'K')
+	for i := 0; i < 15; i++ {
+		b = append(b, 'A', 'A')
+	}
+	return append(b, 0, 0x00, 0x21, 0x00, 0x01) // NBSTAT, IN
+}
+
+// parseNodeStatus return the unique workstation name in the node status response.
+func parseNodeStatus(b []byte) (string, error) {
+	if len(b) < 12 || b[2]&0x80 == 0 || binary.BigEndian.Uint16(b[6:]) == 0 {
+		return "", errNetBIOSInvalid
+	}
+
+	// skip the question name; it is either a compression pointer or labels
+	i := 12
+	for i < len(b) && b[i] != 0 {
+		if b[i]&0xc0 == 0xc0 {
+			i++
+			break
+		}
+		i += int(b[i]) + 1
+	}
+	i++
+	i += 10 // type, class, ttl and rdlength
+	if i >= len(b) {
+		return "", errNetBIOSInvalid
+	}
+
+	count := int(b[i])
+	i++
+	for ; count > 0 && i+18 <= len(b); count, i = count-1, i+18 {
+		suffix, flags := b[i+15], binary.BigEndian.Uint16(b[i+16:])
+		if suffix == 0x00 && flags&0x8000 == 0 { // workstation and not a group
+			if name := strings.TrimRight(string(b[i:i+15]), " \x00"); name != "" {
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: no workstation name", errNetBIOSInvalid)
+}
@@ -0,0 +1,49 @@
+package arp
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// newNodeStatus return a node status response with a group and a workstation name.
+func newNodeStatus(id uint16) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint16(b[0:], id)
+	binary.BigEndian.PutUint16(b[2:], 0x8400) // response, authoritative
+	binary.BigEndian.PutUint16(b[6:], 1)      // one answer
+	b = append(b, netbiosRequest(id)[12:12+34]...)
+	b = append(b, 0x00, 0x21, 0x00, 0x01, 0, 0, 0, 0, 0, 37, 2)
+	b = append(b, []byte("WORKGROUP      \x00\x84\x00")...)
+	b = append(b, []byte("DESKTOP-1234   \x00\x04\x00")...)
+	return b
+}
+
+func Test_NetBIOS(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip("cannot listen udp ", err)
+	}
+	defer conn.Close()
+	netbiosPort = conn.LocalAddr().(*net.UDPAddr).Port
+	defer func() { netbiosPort = 137 }()
+
+	go func() {
+		buf := make([]byte, 1500)
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil || n < 50 {
+			return
+		}
+		conn.WriteToUDP(newNodeStatus(binary.BigEndian.Uint16(buf)), addr)
+	}()
+
+	name, err := netbiosQuery(net.IPv4(127, 0, 0, 1), time.Second)
+	if err != nil || name != "DESKTOP-1234" {
+		t.Fatalf("invalid name %q error %v", name, err)
+	}
+
+	if _, err := parseNodeStatus(newNodeStatus(1)[:60]); err == nil {
+		t.Error("expected error for truncated response")
+	}
+}
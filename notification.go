@@ -0,0 +1,266 @@
+package arp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy defines what to do when the notification queue is full.
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the notification being queued when the queue is full
+	DropNewest OverflowPolicy = iota
+
+	// DropOldest discards the oldest queued notification to make room for the new one
+	DropOldest
+)
+
+// DefaultNotificationQueueSize is the queue size used unless SetNotificationQueue is called.
+const DefaultNotificationQueueSize = 64
+
+// notificationQueue decouples the packet processing goroutines from the
+// notification consumer. A slow consumer will cause notifications to be
+// dropped instead of blocking ListenAndServe.
+type notificationQueue struct {
+	dropped uint64 // atomic value; first field to guarantee 64 bit alignment
+	queue   chan Event
+	policy  OverflowPolicy
+	closed  bool
+	mutex   sync.Mutex
+}
+
+func newNotificationQueue(size int, policy OverflowPolicy) *notificationQueue {
+	if size <= 0 {
+		size = DefaultNotificationQueueSize
+	}
+	return &notificationQueue{queue: make(chan Event, size), policy: policy}
+}
+
+// push add the event to the queue; it never blocks.
+func (q *notificationQueue) push(event Event) {
+	// serialise producers so drop oldest frees a slot for this entry
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	for {
+		select {
+		case q.queue <- event:
+			return
+		default:
+		}
+
+		atomic.AddUint64(&q.dropped, 1)
+		if q.policy == DropNewest {
+			return
+		}
+
+		select {
+		case <-q.queue: // drop oldest and retry
+		default:
+		}
+	}
+}
+
+func (q *notificationQueue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+}
+
+func (q *notificationQueue) droppedCount() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
+
+// Subscription is an independent stream of entry events.
+//
+// Each subscription has its own queue so a slow consumer does not affect
+// other subscribers or packet processing.
+type Subscription struct {
+	// C receives an Event every time an entry changes. It is closed by Unsubscribe.
+	C <-chan Event
+
+	queue   *notificationQueue
+	filter  *EventFilter    // nil for all events
+	sources []*Subscription // handler subscriptions feeding a MultiHandler subscription
+}
+
+// EventFilter selects the events delivered to a subscription; see
+// SubscribeFilter. Empty fields match every event and the event must match
+// all the non empty fields.
+type EventFilter struct {
+	Types  []EventType
+	MACs   []net.HardwareAddr // Entry.MAC or Previous.MAC is in the list
+	Prefix *net.IPNet         // Entry.IP or Previous.IP is in the prefix
+}
+
+// Match return true if the event matches the filter.
+func (f *EventFilter) Match(e Event) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == e.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.MACs) > 0 && !containsMAC(f.MACs, e.Entry.MAC) && (len(e.Previous.MAC) == 0 || !containsMAC(f.MACs, e.Previous.MAC)) {
+		return false
+	}
+	if f.Prefix != nil && !(e.Entry.IP != nil && f.Prefix.Contains(e.Entry.IP)) && !(e.Previous.IP != nil && f.Prefix.Contains(e.Previous.IP)) {
+		return false
+	}
+	return true
+}
+
+// Dropped return the number of notifications discarded because the subscription queue was full.
+//
+// For a MultiHandler subscription it includes the notifications discarded by
+// the queue of each handler.
+func (s *Subscription) Dropped() uint64 {
+	n := s.queue.droppedCount()
+	for _, source := range s.sources {
+		n += source.Dropped()
+	}
+	return n
+}
+
+// Subscribe return a new subscription to entry events.
+//
+// size is the subscription queue size and policy defines what to do when the
+// queue is full. Call Unsubscribe when no longer interested.
+func (c *Handler) Subscribe(size int, policy OverflowPolicy) *Subscription {
+	return c.subscribe(size, policy, nil)
+}
+
+// subscribe add a subscription delivering the events matching filter; nil for all events
+func (c *Handler) subscribe(size int, policy OverflowPolicy, filter *EventFilter) *Subscription {
+	q := newNotificationQueue(size, policy)
+	s := &Subscription{C: q.queue, queue: q, filter: filter}
+
+	c.mutex.Lock()
+	c.subscriptions = append(c.subscriptions, s)
+	c.mutex.Unlock()
+
+	return s
+}
+
+// SubscribeFilter return a new subscription to the events matching filter,
+// i.e. the events of three devices out of hundreds; see Subscribe. Events
+// not matching the filter are not queued and are not counted as dropped.
+func (c *Handler) SubscribeFilter(size int, policy OverflowPolicy, filter EventFilter) *Subscription {
+	f := &EventFilter{Types: append([]EventType(nil), filter.Types...)}
+	for _, mac := range filter.MACs {
+		f.MACs = append(f.MACs, dupMAC(mac))
+	}
+	if filter.Prefix != nil {
+		f.Prefix = &net.IPNet{IP: dupIP(filter.Prefix.IP), Mask: append(net.IPMask(nil), filter.Prefix.Mask...)}
+	}
+	return c.subscribe(size, policy, f)
+}
+
+// Unsubscribe remove the subscription and close its channel.
+func (c *Handler) Unsubscribe(s *Subscription) {
+	c.mutex.Lock()
+	for i := range c.subscriptions {
+		if c.subscriptions[i] == s {
+			// copy on write; notify may be iterating over the previous slice
+			subscriptions := make([]*Subscription, 0, len(c.subscriptions)-1)
+			subscriptions = append(subscriptions, c.subscriptions[:i]...)
+			c.subscriptions = append(subscriptions, c.subscriptions[i+1:]...)
+			c.droppedNotifications += s.Dropped()
+			break
+		}
+	}
+	c.mutex.Unlock()
+
+	s.queue.close()
+}
+
+// SetNotificationQueue set the size and overflow policy of the queue used by AddNotificationChannel.
+//
+// It must be called before AddNotificationChannel.
+func (c *Handler) SetNotificationQueue(size int, policy OverflowPolicy) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.notificationSize = size
+	c.notificationPolicy = policy
+}
+
+// notify queue an event for delivery to all subscriptions.
+func (c *Handler) notify(eventType EventType, previous Entry, entry Entry) {
+	c.notifyAt(eventType, previous, entry, time.Now())
+}
+
+// notifyAt is notify for a change made at t, i.e. the receive time of a packet.
+func (c *Handler) notifyAt(eventType EventType, previous Entry, entry Entry, t time.Time) {
+	c.publish(Event{Type: eventType, Time: t, NIC: c.config.NIC, Previous: previous, Entry: entry})
+}
+
+// publish queue the event for delivery to all subscriptions.
+func (c *Handler) publish(event Event) {
+	c.mutex.RLock()
+	subscriptions := c.subscriptions
+	grouped := len(c.groups) > 0
+	c.mutex.RUnlock()
+
+	atomic.AddUint64(&c.counters.notificationsSent, 1)
+	if c.history != nil {
+		c.history.add(event)
+	}
+	for _, s := range subscriptions {
+		if s.filter != nil && !s.filter.Match(event) {
+			continue
+		}
+		s.queue.push(event)
+	}
+	if grouped {
+		c.updateGroups(event)
+	}
+}
+
+// notificationLoop deliver the subscription events to the notification channel.
+//
+// Only the entry is delivered; hunt, spoof, eviction, hostname, class, conflict and handler events are skipped as the notification
+// channel is used to track online and offline changes.
+func (c *Handler) notificationLoop(h *goroutine, s *Subscription, notification chan<- Entry) {
+	defer h.End()
+
+	for {
+		select {
+		case <-c.goroutinePool.StopChannel:
+			return
+
+		case event, ok := <-s.C:
+			if !ok {
+				return
+			}
+			switch event.Type {
+			case EventHuntStarted, EventHuntStopped, EventHuntTimeout, EventSpoofDetected, EventStormDetected, EventDeviceEvicted,
+				EventHostnameChanged, EventDeviceClassChanged, EventHandlerDegraded, EventHandlerRecovered, EventHostConflict,
+				EventGroupOnline, EventGroupOffline, EventGatewayImpersonation, EventIPConflict,
+				EventFlapping, EventDeviceLinked:
+				continue
+			}
+			select {
+			case notification <- event.Entry:
+			case <-c.goroutinePool.StopChannel:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package arp
+
+import (
+	"net"
+	"testing"
+)
+
+func Test_NotificationQueueOverflow(t *testing.T) {
+
+	q := newNotificationQueue(2, DropNewest)
+	q.push(Event{Entry: Entry{MAC: mac1}})
+	q.push(Event{Entry: Entry{MAC: mac2}})
+	q.push(Event{Entry: Entry{MAC: mac3}})
+	if q.droppedCount() != 1 || (<-q.queue).Entry.MAC.String() != mac1.String() {
+		t.Error("expected newest entry dropped ", q.droppedCount())
+	}
+
+	q = newNotificationQueue(2, DropOldest)
+	q.push(Event{Entry: Entry{MAC: mac1}})
+	q.push(Event{Entry: Entry{MAC: mac2}})
+	q.push(Event{Entry: Entry{MAC: mac3}})
+	if q.droppedCount() != 1 || (<-q.queue).Entry.MAC.String() != mac2.String() || (<-q.queue).Entry.MAC.String() != mac3.String() {
+		t.Error("expected oldest entry dropped ", q.droppedCount())
+	}
+}
+
+func Test_Subscribe(t *testing.T) {
+
+	h := &Handler{}
+	s1 := h.Subscribe(4, DropNewest)
+	s2 := h.Subscribe(1, DropNewest)
+
+	h.notify(EventNewDevice, Entry{}, Entry{MAC: mac1})
+	h.notify(EventNewDevice, Entry{}, Entry{MAC: mac2})
+
+	if e := <-s1.C; e.Entry.MAC.String() != mac1.String() {
+		t.Error("expected first notification in s1 ", e.Entry.MAC)
+	}
+	if e := <-s2.C; e.Entry.MAC.String() != mac1.String() || s2.Dropped() != 1 {
+		t.Error("expected s2 to drop second notification ", e.Entry.MAC, s2.Dropped())
+	}
+
+	h.Unsubscribe(s2)
+	if _, ok := <-s2.C; ok {
+		t.Error("expected s2 channel closed")
+	}
+	h.notify(EventNewDevice, Entry{}, Entry{MAC: mac3})
+	if len(h.subscriptions) != 1 || h.Stats().NotificationsDropped != 1 {
+		t.Error("expected one subscription and one drop ", len(h.subscriptions), h.Stats().NotificationsDropped)
+	}
+}
+
+func Test_SubscribeFilter(t *testing.T) {
+	h := &Handler{}
+	_, prefix, _ := net.ParseCIDR("192.168.0.2/32")
+	byType := h.SubscribeFilter(8, DropNewest, EventFilter{Types: []EventType{EventDeviceOffline}})
+	byMAC := h.SubscribeFilter(8, DropNewest, EventFilter{MACs: []net.HardwareAddr{mac1}})
+	byPrefix := h.SubscribeFilter(8, DropNewest, EventFilter{Prefix: prefix, Types: []EventType{EventIPChanged}})
+
+	h.notify(EventNewDevice, Entry{}, Entry{MAC: mac1, IP: ip1})
+	h.notify(EventDeviceOffline, Entry{}, Entry{MAC: mac2, IP: ip2})
+	h.notify(EventIPChanged, Entry{MAC: mac3, IP: ip2}, Entry{MAC: mac3, IP: ip3}) // leaves the prefix
+
+	want := map[*Subscription][]EventType{
+		byType:   {EventDeviceOffline},
+		byMAC:    {EventNewDevice},
+		byPrefix: {EventIPChanged},
+	}
+	for s, types := range want {
+		if len(s.C) != len(types) {
+			t.Errorf("invalid queue length %d want %v", len(s.C), types)
+			continue
+		}
+		for _, typ := range types {
+			if e := <-s.C; e.Type != typ {
+				t.Errorf("invalid event %s want %s", e.Type, typ)
+			}
+		}
+		if s.Dropped() != 0 {
+			t.Error("filtered events counted as dropped")
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package arp
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// OfflineThreshold configures when a device goes offline and back online; see WithOfflineThreshold.
+type OfflineThreshold struct {
+	MissedProbes int           // unanswered probes before the device goes offline
+	Duration     time.Duration // minimum time without updates before the device goes offline
+	OnlineCount  int           // packets required for an offline device to go back online
+}
+
+// WithOfflineThreshold declare a device offline after threshold.MissedProbes
+// unanswered probes and threshold.Duration without updates, and require
+// threshold.OnlineCount packets without a missed probe before the device goes
+// back online. It stops flapping notifications for power saving phones.
+//
+// A zero MissedProbes or Duration is not checked; if both are zero the device
+// goes offline after 8/3 of its probe interval (4 minutes by default).
+func WithOfflineThreshold(threshold OfflineThreshold) Option {
+	return func(c *Handler) error {
+		if threshold.MissedProbes < 0 || threshold.Duration < 0 || threshold.OnlineCount < 0 {
+			return fmt.Errorf("invalid offline threshold %+v", threshold)
+		}
+		c.offline = threshold
+		return nil
+	}
+}
+
+// offlineDue return true if the device must go offline; local.misses is the
+// number of probes not answered before the current probe.
+func (c *Handler) offlineDue(local *Entry, offlineDeadline time.Time, now time.Time) bool {
+	t := c.offline
+	if t.MissedProbes == 0 && t.Duration == 0 {
+		return local.LastUpdate.Before(offlineDeadline)
+	}
+	return local.misses >= t.MissedProbes && !local.LastUpdate.After(now.Add(-t.Duration))
+}
+
+// onlineConfirmedLocked count a packet from an offline device and return true
+// when the device can go back online.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) onlineConfirmedLocked(entry *Entry) bool {
+	entry.hits++
+	if entry.hits < c.offline.OnlineCount {
+		if c.logArea(LogTable) {
+			c.log().WithFields(log.Fields{"mac": entry.MAC, "ip": entry.IP, "count": entry.hits}).Debug("ARP waiting for packets to confirm device is online")
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package arp
+
+import "context"
+
+// Option configures a Handler in NewHandler.
+type Option func(*Handler) error
+
+// WithContext derive the handler goroutines from ctx instead of the
+// background context. When ctx is done the goroutines exit and the sockets are
+// closed as if the handler failed; Wait returns and Stop removes the firewall
+// rules and closes the capture file.
+func WithContext(ctx context.Context) Option {
+	return func(c *Handler) error {
+		c.goroutinePool.cancel()
+		c.goroutinePool.Start(ctx)
+		return nil
+	}
+}
+
+// WithPacketConn use conn as the packet source and sink instead of the live
+// interface. The handler closes conn in Stop.
+func WithPacketConn(conn PacketConn) Option {
+	return func(c *Handler) error {
+		c.client = conn
+		return nil
+	}
+}
+
+// WithPacketCapture write every ARP frame received and transmitted by the
+// handler to a pcapng file at path. The file is truncated if it exists and
+// closed by Stop.
+//
+// Frames are recorded with the capture time and direction. Received frames are
+// re-encoded from the parsed frame so trailing bytes past the ethernet
+// minimum length are not preserved.
+func WithPacketCapture(path string) Option {
+	return func(c *Handler) (err error) {
+		c.capture, err = createPcapng(path, c.config.NIC)
+		return err
+	}
+}
+
+// WithPCAPReplay use the pcap or pcapng capture file at path as the packet
+// source instead of the live interface.
+//
+// ListenAndServe processes the received ARP frames in the capture as fast as
+// possible and returns at the end of the capture, so table changes and
+// notifications can be reproduced deterministically. Frames marked as
+// transmitted in a pcapng capture (i.e. created with WithPacketCapture) are
+// skipped and packets sent by the handler are discarded.
+func WithPCAPReplay(path string) Option {
+	return func(c *Handler) error {
+		conn, err := newReplayConn(path)
+		if err != nil {
+			return err
+		}
+		c.client = conn
+		return nil
+	}
+}
+
+// WithRingBuffer read packets from an AF_PACKET TPACKET_V3 ring buffer shared
+// with the kernel instead of one read syscall per packet. It reduces the per
+// packet cost on large networks and during ARP storms.
+//
+// It is only supported on linux and requires the same privileges as the
+// default socket.
+func WithRingBuffer() Option {
+	return func(c *Handler) error {
+		nic := c.config.NIC
+		conn, err := dialRing(nic)
+		if err != nil {
+			return err
+		}
+		c.redial = newRedialConn(conn, func() (PacketConn, error) { return dialRing(nic) })
+		c.client = c.redial
+		return nil
+	}
+}
@@ -0,0 +1,102 @@
+package arp
+
+import (
+	"bufio"
+	_ "embed" // embedded OUI subset
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+//go:embed oui.txt
+var ouiEmbedded string
+
+// ouiDatabase maps the first three bytes of a MAC to the vendor name.
+var ouiDatabase struct {
+	once    sync.Once
+	mutex   sync.RWMutex
+	vendors map[[3]byte]string
+}
+
+// LookupVendor return the vendor of the MAC from the IEEE OUI registry or ""
+// if unknown. Locally administered MACs (i.e. randomised by phones) have no vendor.
+//
+// A subset of the registry is embedded; call LoadOUI with the full registry
+// for complete coverage.
+func LookupVendor(mac net.HardwareAddr) string {
+	if len(mac) < 3 || mac[0]&0x02 != 0 {
+		return ""
+	}
+	ouiDatabase.once.Do(func() {
+		vendors, _ := parseOUI(strings.NewReader(ouiEmbedded))
+		ouiDatabase.mutex.Lock()
+		if ouiDatabase.vendors == nil {
+			ouiDatabase.vendors = vendors
+		}
+		ouiDatabase.mutex.Unlock()
+	})
+
+	ouiDatabase.mutex.RLock()
+	defer ouiDatabase.mutex.RUnlock()
+	return ouiDatabase.vendors[[3]byte{mac[0], mac[1], mac[2]}]
+}
+
+// LoadOUI replace the vendor database with the IEEE registry in r; both the
+// oui.txt and oui.csv formats are accepted. The database is shared by all handlers
+// and applies to entries created after the call.
+func LoadOUI(r io.Reader) error {
+	vendors, err := parseOUI(r)
+	if err != nil {
+		return err
+	}
+	if len(vendors) == 0 {
+		return fmt.Errorf("no OUI records found")
+	}
+	ouiDatabase.once.Do(func() {}) // do not load the embedded subset
+	ouiDatabase.mutex.Lock()
+	ouiDatabase.vendors = vendors
+	ouiDatabase.mutex.Unlock()
+	return nil
+}
+
+// LoadOUIFile replace the vendor database with the IEEE registry file; see LoadOUI.
+func LoadOUIFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return LoadOUI(f)
+}
+
+// parseOUI read "00-03-93   (hex)  Apple, Inc." lines from oui.txt and
+// "MA-L,000393,Apple, Inc.,address" lines from oui.csv; other lines are skipped.
+func parseOUI(r io.Reader) (map[[3]byte]string, error) {
+	vendors := make(map[[3]byte]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		var prefix, name string
+		if i := strings.Index(line, "(hex)"); i > 0 {
+			prefix = strings.ReplaceAll(strings.TrimSpace(line[:i]), "-", "")
+			name = strings.TrimSpace(line[i+len("(hex)"):])
+		} else if strings.HasPrefix(line, "MA-L,") {
+			if fields, err := csv.NewReader(strings.NewReader(line)).Read(); err == nil && len(fields) >= 3 {
+				prefix, name = fields[1], strings.TrimSpace(fields[2])
+			}
+		}
+
+		b, err := hex.DecodeString(prefix)
+		if err != nil || len(b) != 3 || name == "" {
+			continue
+		}
+		vendors[[3]byte{b[0], b[1], b[2]}] = name
+	}
+	return vendors, scanner.Err()
+}
@@ -0,0 +1,34 @@
+package arp
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func Test_LookupVendor(t *testing.T) {
+	if v := LookupVendor(net.HardwareAddr{0xb8, 0x27, 0xeb, 0x01, 0x02, 0x03}); v != "Raspberry Pi Foundation" {
+		t.Errorf("invalid vendor %q", v)
+	}
+	if v := LookupVendor(net.HardwareAddr{0x02, 0x27, 0xeb, 0x01, 0x02, 0x03}); v != "" {
+		t.Errorf("locally administered mac has vendor %q", v)
+	}
+
+	h := &Handler{table: make([]*Entry, 0, 256)}
+	if e := h.arpTableAppendLocked(StateNormal, net.HardwareAddr{0x00, 0x50, 0x56, 0x01, 0x02, 0x03}, ip1); e.Vendor != "VMware, Inc." {
+		t.Errorf("invalid entry vendor %q", e.Vendor)
+	}
+
+	csv := "Registry,Assignment,Organization Name,Organization Address\n" +
+		"MA-L,0017F2,\"Apple, Inc.\",1 Infinite Loop Cupertino CA US 95014\n"
+	if err := LoadOUI(strings.NewReader(csv)); err != nil {
+		t.Fatal("LoadOUI error ", err)
+	}
+	defer LoadOUI(strings.NewReader(ouiEmbedded))
+	if v := LookupVendor(net.HardwareAddr{0x00, 0x17, 0xf2, 0x01, 0x02, 0x03}); v != "Apple, Inc." {
+		t.Errorf("invalid csv vendor %q", v)
+	}
+	if err := LoadOUI(strings.NewReader("invalid")); err == nil {
+		t.Error("expected error for empty registry")
+	}
+}
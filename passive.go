@@ -0,0 +1,41 @@
+package arp
+
+import (
+	"fmt"
+)
+
+// WithPassive never transmit a frame: the network is not scanned, devices are
+// not probed and the handler does not reply, announce or spoof. The table is
+// learned from the traffic observed only, so idle devices go offline after 8/3
+// of their probe interval without a packet; WithPromiscuous and a mirror port
+// increase the traffic observed.
+//
+// Hunts, ClaimIP, AddVirtualHost and the send functions return ErrPassive.
+// NewHandler returns ErrInvalidConfig if an option that transmits is used, i.e.
+// WithKeepalive, WithConflictDetection, WithPingFallback, WithNetBIOS or
+// WithReverseDNS.
+func WithPassive() Option {
+	return func(c *Handler) error {
+		c.passive = true
+		return nil
+	}
+}
+
+// checkPassive return an error if an option transmits on a passive handler
+func (c *Handler) checkPassive() error {
+	if !c.passive {
+		return nil
+	}
+	for option, set := range map[string]bool{
+		"WithKeepalive":         c.keepalive != nil,
+		"WithConflictDetection": c.conflict != nil,
+		"WithPingFallback":      c.pinger != nil,
+		"WithNetBIOS":           c.netbios != nil,
+		"WithReverseDNS":        c.dns != nil,
+	} {
+		if set {
+			return fmt.Errorf("%w: %s transmits on a passive handler", ErrInvalidConfig, option)
+		}
+	}
+	return nil
+}
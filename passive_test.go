@@ -0,0 +1,46 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_Passive(t *testing.T) {
+	lan := net.IPNet{IP: net.IPv4(192, 168, 0, 0).To4(), Mask: net.CIDRMask(24, 32)}
+	if _, err := NewHandler("eth0", mac3, ip3, ip3, lan, WithPacketConn(newFakeConn()), WithPassive(), WithKeepalive(time.Minute)); !errors.Is(err, ErrInvalidConfig) {
+		t.Error("expected ErrInvalidConfig ", err)
+	}
+
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, lan, WithPacketConn(conn), WithPassive())
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2))
+	if e := h.FindMAC(mac1); e == nil {
+		t.Fatal("device not learned from traffic")
+	}
+
+	if err := h.ForceIPChange(mac1, ip1); !errors.Is(err, ErrPassive) {
+		t.Error("expected ErrPassive in ForceIPChange ", err)
+	}
+	if err := h.AddVirtualHost(ip2, nil); !errors.Is(err, ErrPassive) {
+		t.Error("expected ErrPassive in AddVirtualHost ", err)
+	}
+	if err := h.SendRequest(EthernetBroadcast, mac3, ip3, EthernetBroadcast, ip1); !errors.Is(err, ErrPassive) {
+		t.Error("expected ErrPassive in SendRequest ", err)
+	}
+
+	h.confirmEntries(time.Millisecond, false)
+	select {
+	case p := <-conn.out:
+		t.Fatal("passive handler sent a packet ", p)
+	default:
+	}
+}
@@ -0,0 +1,174 @@
+package arp
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// pcapng block types and options; see https://www.ietf.org/archive/id/draft-tuexen-opsawg-pcapng-05.html
+const (
+	pcapngSectionHeader     = 0x0a0d0d0a
+	pcapngInterfaceDesc     = 0x00000001
+	pcapngEnhancedPacket    = 0x00000006
+	pcapngByteOrderMagic    = 0x1a2b3c4d
+	pcapngLinkTypeEthernet  = 1
+	pcapngOptEnd            = 0
+	pcapngOptIfName         = 2
+	pcapngOptEPBFlags       = 2
+	pcapngDirectionInbound  = 1
+	pcapngDirectionOutbound = 2
+)
+
+// pcapngWriter writes ethernet frames to a pcapng file with a single interface.
+// Timestamps use the default microsecond resolution.
+type pcapngWriter struct {
+	mutex  sync.Mutex
+	w      io.WriteCloser
+	closed bool
+}
+
+func newPcapngWriter(w io.WriteCloser, nic string) (*pcapngWriter, error) {
+	p := &pcapngWriter{w: w}
+
+	shb := make([]byte, 0, 28)
+	shb = appendUint32(shb, pcapngByteOrderMagic)
+	shb = appendUint16(shb, 1) // major version
+	shb = appendUint16(shb, 0) // minor version
+	shb = appendUint32(shb, 0xffffffff)
+	shb = appendUint32(shb, 0xffffffff) // section length not specified
+	if err := p.writeBlock(pcapngSectionHeader, shb); err != nil {
+		return nil, err
+	}
+
+	idb := make([]byte, 0, 32)
+	idb = appendUint16(idb, pcapngLinkTypeEthernet)
+	idb = appendUint16(idb, 0) // reserved
+	idb = appendUint32(idb, 0) // no snap length limit
+	idb = appendOption(idb, pcapngOptIfName, []byte(nic))
+	idb = appendOption(idb, pcapngOptEnd, nil)
+	if err := p.writeBlock(pcapngInterfaceDesc, idb); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// createPcapng create the file at path and write the pcapng headers.
+func createPcapng(path string, nic string) (*pcapngWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := newPcapngWriter(f, nic)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// writeFrame append an enhanced packet block with the frame; direction is
+// pcapngDirectionInbound or pcapngDirectionOutbound.
+func (p *pcapngWriter) writeFrame(t time.Time, frame []byte, direction uint32) error {
+	ts := uint64(t.UnixNano() / int64(time.Microsecond))
+
+	epb := make([]byte, 0, 20+len(frame)+3+12+4)
+	epb = appendUint32(epb, 0) // interface id
+	epb = appendUint32(epb, uint32(ts>>32))
+	epb = appendUint32(epb, uint32(ts))
+	epb = appendUint32(epb, uint32(len(frame))) // captured length
+	epb = appendUint32(epb, uint32(len(frame))) // original length
+	epb = appendPadded(epb, frame)
+	epb = appendOption(epb, pcapngOptEPBFlags, appendUint32(nil, direction))
+	epb = appendOption(epb, pcapngOptEnd, nil)
+	return p.writeBlock(pcapngEnhancedPacket, epb)
+}
+
+// writeBlock write the block with type and length fields; body must be 32 bit aligned.
+func (p *pcapngWriter) writeBlock(blockType uint32, body []byte) error {
+	length := uint32(12 + len(body))
+	b := make([]byte, 0, length)
+	b = appendUint32(b, blockType)
+	b = appendUint32(b, length)
+	b = append(b, body...)
+	b = appendUint32(b, length)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return os.ErrClosed
+	}
+	_, err := p.w.Write(b)
+	return err
+}
+
+func (p *pcapngWriter) close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	return p.w.Close()
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return binary.LittleEndian.AppendUint16(b, v)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(b, v)
+}
+
+// appendPadded append v padded with zeros to 32 bits
+func appendPadded(b []byte, v []byte) []byte {
+	b = append(b, v...)
+	for i := len(v); i%4 != 0; i++ {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	b = appendUint16(b, code)
+	b = appendUint16(b, uint16(len(value)))
+	return appendPadded(b, value)
+}
+
+// captureFrame write the frame to the capture file if WithPacketCapture is set.
+func (c *Handler) captureFrame(frame *ethernet.Frame, direction uint32) {
+	if c.capture == nil {
+		return
+	}
+	b, err := frame.MarshalBinary()
+	if err == nil {
+		err = c.capture.writeFrame(time.Now(), b, direction)
+	}
+	if err != nil && err != os.ErrClosed {
+		c.log().Error("ARP error writing packet capture ", err)
+	}
+}
+
+// captureSent write a transmitted packet; the frame is built the same way as marp.Client.WriteTo.
+func (c *Handler) captureSent(p *marp.Packet) {
+	if c.capture == nil {
+		return
+	}
+	pb, err := p.MarshalBinary()
+	if err != nil {
+		c.log().Error("ARP error writing packet capture ", err)
+		return
+	}
+	c.captureFrame(&ethernet.Frame{
+		Destination: p.TargetHardwareAddr,
+		Source:      p.SenderHardwareAddr,
+		VLAN:        c.vlan,
+		EtherType:   ethernet.EtherTypeARP,
+		Payload:     pb,
+	}, pcapngDirectionOutbound)
+}
@@ -0,0 +1,58 @@
+package arp
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_PacketCapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arp.pcapng")
+	h := &Handler{}
+	h.config.NIC = "eth0"
+	if err := WithPacketCapture(path)(h); err != nil {
+		t.Fatal("capture error ", err)
+	}
+
+	p, err := marp.NewPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.captureSent(p)
+	h.capture.close()
+	h.captureSent(p) // ignored after close
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// walk the blocks: section header, interface description and one packet
+	types := []uint32{}
+	for len(b) >= 12 {
+		length := binary.LittleEndian.Uint32(b[4:])
+		if length%4 != 0 || int(length) > len(b) || binary.LittleEndian.Uint32(b[length-4:]) != length {
+			t.Fatal("invalid block length ", length)
+		}
+		blockType := binary.LittleEndian.Uint32(b)
+		types = append(types, blockType)
+
+		if blockType == pcapngEnhancedPacket {
+			ts := int64(binary.LittleEndian.Uint32(b[12:]))<<32 | int64(binary.LittleEndian.Uint32(b[16:]))
+			if time.Since(time.Unix(0, ts*int64(time.Microsecond))) > time.Minute {
+				t.Error("invalid timestamp ", ts)
+			}
+			if n := binary.LittleEndian.Uint32(b[20:]); n != 60 || b[28+12] != 0x08 || b[28+13] != 0x06 {
+				t.Error("invalid frame ", n, b[28:28+n])
+			}
+		}
+		b = b[length:]
+	}
+	if len(b) != 0 || len(types) != 3 || types[0] != pcapngSectionHeader || types[1] != pcapngInterfaceDesc || types[2] != pcapngEnhancedPacket {
+		t.Error("invalid blocks ", types, len(b))
+	}
+}
@@ -0,0 +1,266 @@
+package arp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// pcap file magic numbers; see https://wiki.wireshark.org/Development/LibpcapFileFormat
+const (
+	pcapMagicMicroseconds = 0xa1b2c3d4
+	pcapMagicNanoseconds  = 0xa1b23c4d
+	pcapngSimplePacket    = 0x00000003
+)
+
+var errInvalidCapture = errors.New("invalid capture file")
+
+// captureReader return the ethernet frames in a pcap or pcapng file.
+type captureReader interface {
+	// next return the next frame or io.EOF; outbound is true if the frame
+	// is marked as transmitted by the capturing host.
+	next() (frame []byte, outbound bool, err error)
+}
+
+// openCapture detect the file format and return the matching reader.
+func openCapture(r io.Reader) (captureReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidCapture, err)
+	}
+
+	if binary.LittleEndian.Uint32(magic) == pcapngSectionHeader {
+		return &pcapngReader{r: br}, nil
+	}
+
+	p := &pcapReader{r: br}
+	if err := p.readHeader(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// pcapReader reads the classic libpcap format written by tcpdump.
+type pcapReader struct {
+	r     io.Reader
+	order binary.ByteOrder
+}
+
+func (p *pcapReader) readHeader() error {
+	b := make([]byte, 24)
+	if _, err := io.ReadFull(p.r, b); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidCapture, err)
+	}
+
+	switch {
+	case isMagic(binary.LittleEndian.Uint32(b)):
+		p.order = binary.LittleEndian
+	case isMagic(binary.BigEndian.Uint32(b)):
+		p.order = binary.BigEndian
+	default:
+		return fmt.Errorf("%w: unknown magic %x", errInvalidCapture, b[:4])
+	}
+
+	if linkType := p.order.Uint32(b[20:]) & 0xffff; linkType != pcapngLinkTypeEthernet {
+		return fmt.Errorf("%w: link type %d is not ethernet", errInvalidCapture, linkType)
+	}
+	return nil
+}
+
+func isMagic(v uint32) bool {
+	return v == pcapMagicMicroseconds || v == pcapMagicNanoseconds
+}
+
+func (p *pcapReader) next() ([]byte, bool, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(p.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("%w: truncated record", errInvalidCapture)
+		}
+		return nil, false, err
+	}
+
+	frame := make([]byte, p.order.Uint32(header[8:]))
+	if _, err := io.ReadFull(p.r, frame); err != nil {
+		return nil, false, fmt.Errorf("%w: truncated record", errInvalidCapture)
+	}
+	return frame, false, nil
+}
+
+// pcapngReader reads packet blocks from a pcapng file; non ethernet interfaces
+// and all other block types are skipped.
+type pcapngReader struct {
+	r          io.Reader
+	order      binary.ByteOrder
+	interfaces []uint16 // link type per interface in the current section
+}
+
+func (p *pcapngReader) next() ([]byte, bool, error) {
+	for {
+		blockType, body, err := p.readBlock()
+		if err != nil {
+			return nil, false, err
+		}
+
+		switch blockType {
+		case pcapngSectionHeader:
+			p.interfaces = p.interfaces[:0]
+
+		case pcapngInterfaceDesc:
+			if len(body) < 8 {
+				return nil, false, fmt.Errorf("%w: short interface block", errInvalidCapture)
+			}
+			p.interfaces = append(p.interfaces, p.order.Uint16(body))
+
+		case pcapngEnhancedPacket:
+			if len(body) < 20 {
+				return nil, false, fmt.Errorf("%w: short packet block", errInvalidCapture)
+			}
+			id, length := p.order.Uint32(body), p.order.Uint32(body[12:])
+			if int(length) > len(body)-20 {
+				return nil, false, fmt.Errorf("%w: invalid packet length", errInvalidCapture)
+			}
+			if int(id) >= len(p.interfaces) || p.interfaces[id] != pcapngLinkTypeEthernet {
+				continue
+			}
+			outbound := p.flags(body[20+pad4(int(length)):])&0x3 == pcapngDirectionOutbound
+			return body[20 : 20+length], outbound, nil
+
+		case pcapngSimplePacket:
+			if len(body) < 4 || len(p.interfaces) == 0 || p.interfaces[0] != pcapngLinkTypeEthernet {
+				continue
+			}
+			length := p.order.Uint32(body)
+			if int(length) > len(body)-4 {
+				length = uint32(len(body) - 4) // snapped
+			}
+			return body[4 : 4+length], false, nil
+		}
+	}
+}
+
+// readBlock return the next block type and body; the section header sets the byte order.
+func (p *pcapngReader) readBlock() (blockType uint32, body []byte, err error) {
+	header := make([]byte, 8)
+	if _, err = io.ReadFull(p.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("%w: truncated block", errInvalidCapture)
+		}
+		return 0, nil, err
+	}
+
+	// the section header type is a palindrome; the byte order magic follows the length
+	var magic []byte
+	if binary.LittleEndian.Uint32(header) == pcapngSectionHeader {
+		magic = make([]byte, 4)
+		if _, err = io.ReadFull(p.r, magic); err != nil {
+			return 0, nil, fmt.Errorf("%w: truncated block", errInvalidCapture)
+		}
+		switch {
+		case binary.LittleEndian.Uint32(magic) == pcapngByteOrderMagic:
+			p.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(magic) == pcapngByteOrderMagic:
+			p.order = binary.BigEndian
+		default:
+			return 0, nil, fmt.Errorf("%w: invalid byte order magic", errInvalidCapture)
+		}
+	}
+	if p.order == nil {
+		return 0, nil, fmt.Errorf("%w: missing section header", errInvalidCapture)
+	}
+
+	blockType, length := p.order.Uint32(header), int(p.order.Uint32(header[4:]))
+	if length < 12+len(magic) || length%4 != 0 || length > 1<<24 {
+		return 0, nil, fmt.Errorf("%w: invalid block length %d", errInvalidCapture, length)
+	}
+
+	// read the remaining body and the trailing length
+	rest := make([]byte, length-len(header)-len(magic))
+	if _, err = io.ReadFull(p.r, rest); err != nil {
+		return 0, nil, fmt.Errorf("%w: truncated block", errInvalidCapture)
+	}
+	return blockType, append(magic, rest[:len(rest)-4]...), nil
+}
+
+// flags return the epb_flags option value or zero
+func (p *pcapngReader) flags(options []byte) uint32 {
+	for len(options) >= 4 {
+		code, length := p.order.Uint16(options), int(p.order.Uint16(options[2:]))
+		if code == pcapngOptEnd || len(options) < 4+length {
+			break
+		}
+		if code == pcapngOptEPBFlags && length == 4 {
+			return p.order.Uint32(options[4:])
+		}
+		options = options[4+pad4(length):]
+	}
+	return 0
+}
+
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// replayConn is a PacketConn that reads packets from a capture file.
+//
+// Read return the received ARP packets in the capture as fast as possible and
+// io.EOF at the end of the capture. Transmitted frames in a pcapng capture and
+// non ARP frames are skipped. WriteTo and WriteToVLAN discard the packet.
+type replayConn struct {
+	mutex   sync.Mutex
+	file    io.Closer
+	reader  captureReader
+	decoder frameDecoder
+}
+
+func newReplayConn(path string) (*replayConn, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := openCapture(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &replayConn{file: f, reader: reader}, nil
+}
+
+func (r *replayConn) Read() (*marp.Packet, *ethernet.Frame, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for {
+		b, outbound, err := r.reader.next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if outbound {
+			continue
+		}
+
+		if packet, frame, err := r.decoder.decode(b); err == nil {
+			return packet, frame, nil
+		}
+	}
+}
+
+func (r *replayConn) WriteTo(p *marp.Packet, addr net.HardwareAddr) error { return nil }
+
+func (r *replayConn) WriteToVLAN(p *marp.Packet, addr net.HardwareAddr, vlan *ethernet.VLAN) error {
+	return nil
+}
+
+func (r *replayConn) Close() error { return r.file.Close() }
+
+func (r *replayConn) SetReadDeadline(t time.Time) error { return nil }
@@ -0,0 +1,92 @@
+package arp
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+func arpFrame(t testing.TB, op marp.Operation, srcMAC net.HardwareAddr, srcIP net.IP, dstMAC net.HardwareAddr, dstIP net.IP) []byte {
+	p, err := marp.NewPacket(op, srcMAC, srcIP, dstMAC, dstIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb, _ := p.MarshalBinary()
+	fb, err := (&ethernet.Frame{Destination: dstMAC, Source: srcMAC, EtherType: ethernet.EtherTypeARP, Payload: pb}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fb
+}
+
+func Test_PCAPReplay(t *testing.T) {
+	hostMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	hostIP := net.IPv4(192, 168, 0, 100).To4()
+	dir := t.TempDir()
+
+	// pcapng capture as written by WithPacketCapture
+	pcapng := filepath.Join(dir, "replay.pcapng")
+	f, err := os.Create(pcapng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := newPcapngWriter(f, "eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	w.writeFrame(now, arpFrame(t, marp.OperationReply, mac1, ip1, hostMAC, hostIP), pcapngDirectionInbound)
+	w.writeFrame(now, arpFrame(t, marp.OperationRequest, hostMAC, hostIP, EthernetBroadcast, ip3), pcapngDirectionOutbound)
+	w.writeFrame(now, arpFrame(t, marp.OperationReply, mac1, ip2, hostMAC, hostIP), pcapngDirectionInbound)
+	w.close()
+
+	// classic pcap with the same received frames
+	pcap := filepath.Join(dir, "replay.pcap")
+	b := binary.LittleEndian.AppendUint32(nil, pcapMagicMicroseconds)
+	b = append(b, 2, 0, 4, 0)          // version 2.4
+	b = append(b, make([]byte, 12)...) // timezone, accuracy and snap length
+	b = append(b, pcapngLinkTypeEthernet, 0, 0, 0)
+	for _, ip := range []net.IP{ip1, ip2} {
+		frame := arpFrame(t, marp.OperationReply, mac1, ip, hostMAC, hostIP)
+		b = append(b, make([]byte, 8)...) // timestamp
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(frame)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(frame)))
+		b = append(b, frame...)
+	}
+	if err := os.WriteFile(pcap, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{pcapng, pcap} {
+		h, err := NewHandler("eth0", hostMAC, hostIP, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPCAPReplay(path))
+		if err != nil {
+			t.Fatal("NewHandler error ", err)
+		}
+		s := h.Subscribe(16, DropNewest)
+
+		h.ListenAndServe(0) // returns at the end of the capture
+		h.Stop()
+
+		if stats := h.Stats(); stats.PacketsRead != 2 {
+			t.Errorf("%s: invalid packets read %+v", path, stats)
+		}
+		for _, want := range []EventType{EventNewDevice, EventIPChanged} {
+			if e := <-s.C; e.Type != want || e.Entry.MAC.String() != mac1.String() {
+				t.Errorf("%s: invalid event got %s want %s", path, e.Type, want)
+			}
+		}
+		if e := h.FindMAC(mac1); e == nil || !e.IP.Equal(ip2) {
+			t.Errorf("%s: invalid entry %v", path, e)
+		}
+	}
+
+	if _, err := NewHandler("eth0", hostMAC, hostIP, ip3, net.IPNet{}, WithPCAPReplay(filepath.Join(dir, "missing"))); err == nil {
+		t.Error("expected error for missing capture")
+	}
+}
@@ -0,0 +1,96 @@
+package arp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// pingFunc send an echo request to ip and return nil if the device replied within timeout
+type pingFunc func(ip net.IP, timeout time.Duration) error
+
+// pingTimeout is the time to wait for an ICMP echo reply
+var pingTimeout = time.Second
+
+// pingSeq is the sequence number of the last ICMP echo request
+var pingSeq uint32
+
+// WithPingFallback send an ICMP echo request to a device before declaring
+// it offline; the device stays online if it replies. Some devices (i.e.
+// sleeping iPhones) ignore unicast ARP requests but answer ICMP.
+//
+// It requires a raw ICMP socket (root) or unprivileged ICMP sockets (linux
+// net.ipv4.ping_group_range).
+func WithPingFallback() Option {
+	return func(c *Handler) error {
+		c.pinger = ping
+		return nil
+	}
+}
+
+// ping send an ICMP echo request to ip and wait for the reply.
+func ping(ip net.IP, timeout time.Duration) error {
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	privileged := err == nil
+	if !privileged {
+		if conn, err = icmp.ListenPacket("udp4", "0.0.0.0"); err != nil {
+			return fmt.Errorf("cannot open icmp socket: %w", err)
+		}
+	}
+	defer conn.Close()
+
+	// the kernel replaces the ID of unprivileged sockets; match the sequence only
+	id, seq := os.Getpid()&0xffff, int(atomic.AddUint32(&pingSeq, 1)&0xffff)
+	msg := icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("arp")}}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if !privileged {
+		dst = &net.UDPAddr{IP: ip}
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if _, err := conn.WriteTo(b, dst); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, src, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		reply, err := icmp.ParseMessage(ipv4.ICMPTypeEcho.Protocol(), buf[:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || (privileged && echo.ID != id) {
+			continue
+		}
+		if srcIP := addrIP(src); srcIP == nil || !srcIP.Equal(ip) {
+			continue
+		}
+		return nil
+	}
+}
+
+// addrIP return the IP of an IP or UDP address
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Plugin is a protocol extension hooked into the handler lifecycle, i.e. a DHCP
+// snooper, a name resolver or a metrics exporter shipped as a separate package;
+// see Register.
+//
+// OnStart is called when ListenAndServe starts; a plugin returning an error is
+// not called again. OnPacket is called with every inbound and outbound packet
+// in the read loop or the sending goroutine, so it must return quickly and not
+// keep references to the packet. OnEntryChange is called with the table events
+// in the plugin goroutine and OnStop when the handler stops.
+type Plugin interface {
+	OnStart(h *Handler) error
+	OnPacket(p Packet)
+	OnEntryChange(e Event)
+	OnStop()
+}
+
+// plugin is a registered Plugin
+type plugin struct {
+	Plugin
+	started int32 // atomic; 1 after OnStart returned with no error
+}
+
+// Register add p to the handler; it must be called before ListenAndServe.
+func (c *Handler) Register(p Plugin) error {
+	c.mutex.Lock()
+	if c.pluginsClosed {
+		c.mutex.Unlock()
+		return errors.New("register plugins before ListenAndServe")
+	}
+	r := &plugin{Plugin: p}
+	c.plugins = append(c.plugins, r)
+	c.mutex.Unlock()
+
+	c.Use(func(packet Packet, next func(Packet) error) error {
+		if atomic.LoadInt32(&r.started) == 1 {
+			r.OnPacket(packet)
+		}
+		return next(packet)
+	})
+	return nil
+}
+
+// startPlugins call OnStart and start the event loop of every plugin
+func (c *Handler) startPlugins() {
+	c.mutex.Lock()
+	c.pluginsClosed = true
+	plugins := c.plugins
+	c.mutex.Unlock()
+
+	for _, p := range plugins {
+		if err := p.OnStart(c); err != nil {
+			c.log().WithFields(log.Fields{"plugin": fmt.Sprintf("%T", p.Plugin)}).Error("ARP plugin failed to start ", err)
+			continue
+		}
+		atomic.StoreInt32(&p.started, 1)
+		go c.pluginLoop(c.goroutinePool.Begin("ARP pluginLoop"), p, c.Subscribe(storeQueueSize, DropOldest))
+	}
+}
+
+// pluginLoop send the table events to the plugin until the handler stops
+func (c *Handler) pluginLoop(h *goroutine, p *plugin, sub *Subscription) {
+	defer h.End()
+	defer c.Unsubscribe(sub)
+	defer p.OnStop()
+
+	for {
+		select {
+		case <-c.goroutinePool.StopChannel:
+			return
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			p.OnEntryChange(event)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+type testPlugin struct {
+	calls chan string
+}
+
+func (p *testPlugin) OnStart(h *Handler) error { p.calls <- "start"; return nil }
+func (p *testPlugin) OnPacket(pk Packet)       { p.calls <- "packet " + pk.Direction.String() }
+func (p *testPlugin) OnEntryChange(e Event)    { p.calls <- "event " + string(e.Type) }
+func (p *testPlugin) OnStop()                  { p.calls <- "stop" }
+
+func Test_Plugin(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	p := &testPlugin{calls: make(chan string, 64)}
+	if err := h.Register(p); err != nil {
+		t.Fatal("Register error ", err)
+	}
+
+	next := func(want string) {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case got := <-p.calls:
+				if got == want {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("timeout waiting for %q", want)
+			}
+		}
+	}
+
+	go h.ListenAndServe(0)
+	next("start")
+	conn.in <- newTestPacket(marp.OperationReply, mac1, ip1, mac3, ip3)
+	next("packet inbound")
+	next("event " + string(EventNewDevice))
+
+	if err := h.Register(&testPlugin{}); err == nil {
+		t.Error("expected error registering after ListenAndServe")
+	}
+	h.Stop()
+	next("stop")
+}
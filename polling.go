@@ -1,69 +1,110 @@
 package arp
 
 import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
 	"net"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
 // pollingLoop detect new MACs and also when existing MACs are no longer online.
-// Send ARP request to all 255 IP addresses first time then send ARP request every so many minutes.
+// Send ARP request to all IP addresses in the LAN first time then send ARP request every so many minutes.
 // Probe known macs more often in case they left the network.
 //
-// checkNewDevicesInterval is the the duration between full scans
-func (c *Handler) pollingLoop(checkNewDevicesInterval time.Duration) (err error) {
-	// Goroutine pool
-	h := c.goroutinePool.Begin("ARP pollingLoop")
+// The duration between full scans is set by ListenAndServe and SetScanInterval.
+func (c *Handler) pollingLoop(h *goroutine) (err error) {
 	defer h.End()
+	atomic.StoreInt32(&c.polling, 1)
+	defer atomic.StoreInt32(&c.polling, 0)
 
+	checkNewDevicesInterval := c.getScanInterval()
+	if c.passive || c.noScan {
+		checkNewDevicesInterval = 0 // learn from the traffic observed only
+	}
 	if checkNewDevicesInterval > 0 {
-		c.scanNetwork()
+		c.startScan()
 	} else {
 		checkNewDevicesInterval = time.Minute * 60 * 24 * 365 * 20 // will never expire
 	}
 
-	// Retrieve router mac if available
-	time.Sleep(time.Millisecond * 300)
-	if router := c.FindIP(c.config.RouterIP); router != nil {
-		c.config.RouterMAC = router.MAC
-	}
+	// Retrieve router mac
+	c.resolveRouter()
 
 	// Ticker used to perform full scan
-	checkNewDevices := time.NewTicker(checkNewDevicesInterval).C
-	checkDeviceIsActive := time.NewTicker(time.Second * 30).C // Check every 30 seconds
+	checkNewDevices := time.NewTicker(checkNewDevicesInterval)
+	defer checkNewDevices.Stop()
+	checkDeviceIsActive := time.NewTicker(activeCheckInterval).C // Check every 30 seconds
+	customInterval := c.minProbeInterval()
+	checkCustom := time.NewTicker(customInterval)
+	defer checkCustom.Stop()
+	checkRouter := time.NewTicker(routerRefreshInterval).C
 	for {
 		// timer for probing known macs
 		select {
-		case <-checkNewDevices:
-			c.scanNetwork()
-			// update router mac in case it has changed
-			if router := c.FindIP(c.config.RouterIP); router != nil {
-				c.config.RouterMAC = router.MAC
+		case <-checkNewDevices.C:
+			c.startScan()
+
+		case <-c.reconfigure:
+			if interval := c.getScanInterval(); interval > 0 && interval != checkNewDevicesInterval && !c.passive && !c.noScan {
+				checkNewDevicesInterval = interval
+				checkNewDevices.Reset(interval)
+			}
+			if c.RouterMAC() == nil {
+				c.resolveRouter()
+			}
+			if interval := c.minProbeInterval(); interval != customInterval {
+				customInterval = interval
+				checkCustom.Reset(interval)
 			}
 
+		case <-checkRouter:
+			// update router mac in case it has changed
+			c.resolveRouter()
+
 		case <-c.goroutinePool.StopChannel:
 			return nil
 
 		case <-checkDeviceIsActive:
 			c.confirmIsActive()
+			c.selfProbe()
+
+		case <-checkCustom.C:
+			c.confirmCustomIsActive()
 		}
 	}
 }
 
 func (c *Handler) confirmIsActive() {
+	c.confirmEntries(activeCheckInterval, false)
+}
 
-	c.mutex.Lock()
+// confirmCustomIsActive probe only the entries with a probe interval; see SetProbeInterval
+func (c *Handler) confirmCustomIsActive() {
+	c.confirmEntries(c.minProbeInterval(), true)
+}
+
+// confirmEntries probe the idle entries; the probes are spread over the first
+// half of period.
+func (c *Handler) confirmEntries(period time.Duration, customOnly bool) {
+
+	c.mutex.RLock()
 	table := c.table // fix the table slice; c.table may change
-	c.mutex.Unlock()
+	c.mutex.RUnlock()
 
 	now := time.Now()
-	refreshDeadline := now.Add(time.Second * 90 * -1) // Refresh entries last updated before this time
-	offlineDeadline := now.Add(time.Minute * 4 * -1)  // Mark offline entries last updated before this time
-	deleteDeadline := now.Add(time.Minute * 60 * -1)  // Delete entries that have not responded in last hour
+	probeDeadline := now.Add(time.Minute * 60 * -1) // Stop probing offline entries that have not responded in last hour
+	deleteDeadline := now.Add(-c.Retention())       // Delete entries that have not responded in the retention period
 
-	if LogAll {
-		log.Debug("ARP scan online devices")
+	spacing := period / 2 / time.Duration(countEntries(table))
+	probed := 0
+
+	if c.logArea(LogScan) {
+		c.log().Debug("ARP scan online devices")
 	}
 	for i, e := range table {
 
@@ -72,29 +113,50 @@ func (c *Handler) confirmIsActive() {
 			continue
 		}
 
-		c.mutex.Lock()
+		c.mutex.RLock()
 		local := &Entry{}
 		*local = *e // local copy to avoid race
-		c.mutex.Unlock()
+		interval, custom := c.probeIntervals[string(local.MAC)]
+		c.mutex.RUnlock()
+
+		if customOnly && !custom {
+			continue
+		}
+		if !custom {
+			interval = defaultProbeInterval
+		}
+		refreshDeadline := now.Add(-interval)         // Refresh entries last updated before this time
+		offlineDeadline := now.Add(-interval * 8 / 3) // Mark offline entries last updated before this time
 
 		// Don't probe virtual entries - these are always online until deletion
 		if local.State == StateVirtualHost {
 			continue
 		}
 
-		// Delete from ARP table if the device was not seen for the last hour
+		// Delete from ARP table if the device was not seen in the retention period
 		if local.LastUpdate.Before(deleteDeadline) {
 			if local.Online == true {
-				log.Warn("ARP device is not offline during delete", local.MAC)
+				c.log().Warn("ARP device is not offline during delete", local.MAC)
 			}
-			if LogAll {
-				log.WithFields(log.Fields{"mac": local.MAC, "ip": local.IP}).
+			if c.logArea(LogTable) {
+				c.log().WithFields(log.Fields{"mac": local.MAC, "ip": local.IP}).
 					Infof("ARP delete entry online %5v state %10s", local.Online, local.State)
 			}
 
 			c.mutex.Lock()
-			table[i] = nil // use the index to set the array to nil
+			c.deleteLocked(e)
 			c.mutex.Unlock()
+			c.notifyEvicted(*local)
+			continue
+		}
+
+		// Remove addresses no longer in use; i.e. the device changed IP
+		if previous, current, changed := c.expireAddresses(e, offlineDeadline); changed {
+			c.notify(EventIPChanged, previous, current)
+		}
+
+		// IPv6 only entries are refreshed by the NDP handler
+		if local.IP.Equal(net.IPv4zero) {
 			continue
 		}
 
@@ -102,61 +164,190 @@ func (c *Handler) confirmIsActive() {
 		//   1) device is online and have not received an update recently; or
 		//   2) device is offline and no more than one hour has passed.
 		//
+		if !local.Online && local.LastUpdate.Before(probeDeadline) {
+			continue
+		}
 		if local.LastUpdate.Before(refreshDeadline) {
-			if LogAll {
-				log.WithFields(log.Fields{"mac": local.MAC, "ip": local.IP}).Debug("Is device online? requesting...")
+			// a passive handler counts the check as a missed probe
+			if !c.passive {
+				if probed > 0 && !c.sleep(jitter(spacing)) {
+					return
+				}
+				probed++
+				if c.logArea(LogScan) {
+					c.log().WithFields(log.Fields{"mac": local.MAC, "ip": local.IP}).Debug("Is device online? requesting...")
+				}
+				if err := c.request(c.config.HostMAC, c.config.HostIP, local.MAC, local.IP); err != nil {
+					c.log().WithFields(log.Fields{"mac": local.MAC, "ip": local.IP}).Error("Error ARP request: ", err)
+				}
 			}
-			if err := c.request(c.config.HostMAC, c.config.HostIP, local.MAC, local.IP); err != nil {
-				log.WithFields(log.Fields{"mac": local.MAC, "ip": local.IP}).Error("Error ARP request: ", err)
+			c.mutex.Lock()
+			if e.misses > 0 {
+				e.hits = 0 // the previous probe was missed
 			}
+			e.misses++
+			c.mutex.Unlock()
 
 			// Give it a chance to update
-			time.Sleep(time.Millisecond * 15)
+			if !c.passive {
+				time.Sleep(time.Millisecond * 15)
+			}
 
 			// Set to offline if no updates since the offline deadline
-			if local.Online && local.LastUpdate.Before(offlineDeadline) {
-				log.WithFields(log.Fields{"mac": local.MAC, "ip": local.IP}).Info("ARP device is offline")
+			if local.Online && c.offlineDue(local, offlineDeadline, now) && (c.passive || !c.probeFallback(e, local)) {
+				c.log().WithFields(log.Fields{"mac": local.MAC, "ip": local.IP}).Info("ARP device is offline")
 
 				c.mutex.Lock()
 				table[i].Online = false
-				table[i].State = StateNormal // Stop hunt if in progress
+				if err := c.setStateLocked(table[i], StateNormal, ReasonOffline); err != nil { // Stop hunt if in progress
+					c.log().WithFields(log.Fields{"mac": local.MAC}).Warn("ARP cannot stop hunt of offline device ", err)
+				}
+				state := table[i].State
+				table[i].hits = 0
 				c.mutex.Unlock()
 
 				// Notify upstream the device changed to offline
 				// use local to avoid race
-				if c.notification != nil {
-					local.Online = false
-					local.State = StateNormal
-					c.notification <- *local
-				}
+				previous := *local
+				local.Online = false
+				local.State = state
+				c.notify(EventDeviceOffline, previous, *local)
 			}
 		} else {
 			// Notify upstream the device is still online
 			// This will send an update every 30 seconds aprox
 			// Update last seen upstream
-			if table[i].Online && c.notification != nil {
-				c.notification <- *local
+			if local.Online {
+				c.notify(EventDeviceSeen, *local, *local)
 			}
 		}
 	}
 }
 
+// countEntries return the number of entries in the table; at least one
+func countEntries(table []*Entry) (n int) {
+	for _, e := range table {
+		if e != nil {
+			n++
+		}
+	}
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+// jitter return a random duration between 0.5 and 1.5 of d so devices are
+// not probed at the same time on every check
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+// sleep wait for d; it returns false if the handler is stopping.
+func (c *Handler) sleep(d time.Duration) bool {
+	return sleepContext(c.goroutinePool.Context(), d)
+}
+
+// sleepContext wait for d; it returns false if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// probeFallback return true if the device answered an ICMP echo request or a
+// TCP probe; the entry is updated as if the device answered the ARP request.
+func (c *Handler) probeFallback(e *Entry, local *Entry) bool {
+	if !c.pingAlive(local) && !c.tcpAlive(local) {
+		return false
+	}
+
+	c.mutex.Lock()
+	e.LastUpdate = time.Now()
+	e.misses = 0
+	c.mutex.Unlock()
+	return true
+}
+
+// pingAlive return true if the device answered an ICMP echo request; see WithPingFallback
+func (c *Handler) pingAlive(local *Entry) bool {
+	if c.pinger == nil {
+		return false
+	}
+	if err := c.pinger(local.IP, pingTimeout); err != nil {
+		if c.logArea(LogScan) {
+			c.log().WithFields(log.Fields{"mac": local.MAC, "ip": local.IP}).Debug("ARP device did not answer ping: ", err)
+		}
+		return false
+	}
+	if c.logArea(LogScan) {
+		c.log().WithFields(log.Fields{"mac": local.MAC, "ip": local.IP}).Debug("ARP device answered ping")
+	}
+	return true
+}
+
+// scanRange return the first and last host address in the LAN; networks
+// larger than /16 are limited to the /16 containing the host IP.
+func scanRange(lan net.IPNet, hostIP net.IP) (first uint32, last uint32) {
+	mask := lan.Mask
+	if ones, bits := mask.Size(); bits != 32 || ones < 16 {
+		mask = net.CIDRMask(16, 32)
+		lan.IP = hostIP
+	}
+	network := binary.BigEndian.Uint32(lan.IP.To4().Mask(mask))
+	broadcast := network | ^binary.BigEndian.Uint32(mask)
+	if broadcast-network < 2 {
+		return network, broadcast // /31 and /32 have no network and broadcast addresses
+	}
+	return network + 1, broadcast - 1
+}
+
+// startScan run scanNetwork in a pool goroutine so the probes of known
+// entries are not delayed by a full scan; it does nothing if the previous
+// scan is still running.
+func (c *Handler) startScan() {
+	if !atomic.CompareAndSwapInt32(&c.scanning, 0, 1) {
+		if c.logArea(LogScan) {
+			c.log().Debug("ARP scan still running - skip")
+		}
+		return
+	}
+	h := c.goroutinePool.Begin("ARP scan")
+	go func() {
+		defer h.End()
+		defer atomic.StoreInt32(&c.scanning, 0)
+		if err := c.scanNetwork(); err != nil {
+			c.log().Error("ARP scan error ", err)
+		}
+	}()
+}
+
 func (c *Handler) scanNetwork() error {
 
-	// Copy underneath array so we can modify value.
-	ip := dupIP(c.config.HomeLAN.IP)
+	c.mutex.RLock()
+	first, last := scanRange(c.config.HomeLAN, c.config.HostIP)
+	c.mutex.RUnlock()
 
-	if LogAll {
-		log.Debug("ARP Discovering IP - sending 254 ARP requests")
+	if c.logArea(LogScan) {
+		c.log().Debugf("ARP Discovering IP - sending %d ARP requests", last-first+1)
 	}
-	for host := 1; host < 255; host++ {
-		ip[3] = byte(host)
+	ip := make(net.IP, 4)
+	for host := uint64(first); host <= uint64(last); host++ {
+		binary.BigEndian.PutUint32(ip, uint32(host))
 
 		// Skip entries that are online; these will be checked somewhere else
 		//
 		if entry := c.FindIP(ip); entry != nil && entry.Online {
-			if LogAll {
-				log.WithFields(log.Fields{"mac": entry.MAC, "ip": entry.IP}).Debug("ARP skip request for online device")
+			if c.logArea(LogScan) {
+				c.log().WithFields(log.Fields{"mac": entry.MAC, "ip": entry.IP}).Debug("ARP skip request for online device")
 			}
 			continue
 		}
@@ -166,19 +357,81 @@ func (c *Handler) scanNetwork() error {
 			return nil
 		}
 		if err != nil {
-			log.Error("ARP request error ", err)
+			c.log().Error("ARP request error ", err)
 			if err1, ok := err.(net.Error); ok && err1.Temporary() {
-				if LogAll {
-					log.Debug("ARP error in read socket is temporary - retry", err1)
+				if c.logArea(LogScan) {
+					c.log().Debug("ARP error in read socket is temporary - retry", err1)
+				}
+				if !c.sleep(time.Millisecond * 100) { // Wait before retrying
+					return nil
 				}
-				time.Sleep(time.Millisecond * 100) // Wait before retrying
 				continue
 			}
 
 			return err
 		}
-		time.Sleep(time.Millisecond * 25)
+		if !c.sleep(time.Millisecond * 25) {
+			return nil
+		}
 	}
 
 	return nil
 }
+
+// WithoutScan disable the full LAN scans regardless of the ListenAndServe
+// interval, Config.ScanInterval and SetScanInterval. Unlike WithPassive the
+// handler still transmits: known entries are probed and WhoIs, hunts and the
+// send functions work. Use it for short lived handlers that only need the
+// devices seen on the wire.
+func WithoutScan() Option {
+	return func(c *Handler) error {
+		c.noScan = true
+		return nil
+	}
+}
+
+// SetScanInterval change the interval between full network scans set in ListenAndServe.
+//
+// The next scan runs interval after the call; zero or a negative interval is ignored.
+func (c *Handler) SetScanInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	c.mutex.Lock()
+	c.scanInterval = interval
+	c.mutex.Unlock()
+	c.signalReconfigure()
+}
+
+func (c *Handler) getScanInterval() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.scanInterval
+}
+
+// SetHomeLAN change the network scanned for devices, i.e. after a DHCP change.
+//
+// The table is preserved; the new network is scanned on the next full scan.
+func (c *Handler) SetHomeLAN(homeLAN net.IPNet) error {
+	ip := homeLAN.IP.To4()
+	if ones, bits := homeLAN.Mask.Size(); ip == nil || bits != 32 || ones == 0 {
+		return fmt.Errorf("%w: invalid home LAN %s", ErrInvalidConfig, homeLAN.String())
+	}
+
+	c.mutex.Lock()
+	c.config.HomeLAN = net.IPNet{IP: ip.Mask(homeLAN.Mask), Mask: homeLAN.Mask}
+	if limit := tableLimit(c.config.HomeLAN); limit > c.tableLimit {
+		c.tableLimit = limit // never shrink; the table may hold entries from the previous network
+	}
+	c.mutex.Unlock()
+	c.signalReconfigure()
+	return nil
+}
+
+// signalReconfigure wake up pollingLoop to apply a configuration change; it never blocks
+func (c *Handler) signalReconfigure() {
+	select {
+	case c.reconfigure <- struct{}{}:
+	default:
+	}
+}
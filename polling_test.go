@@ -0,0 +1,293 @@
+package arp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_PingFallback(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	h.mutex.Lock()
+	entry := h.arpTableAppendLocked(StateNormal, mac1, ip1)
+	entry.Online = true
+	entry.LastUpdate = time.Now().Add(-time.Minute * 5)
+	h.mutex.Unlock()
+
+	var pinged net.IP
+	h.pinger = func(ip net.IP, timeout time.Duration) error {
+		pinged = ip
+		return nil
+	}
+	h.confirmIsActive()
+	if e := h.FindMAC(mac1); !e.Online || time.Since(e.LastUpdate) > time.Minute || !pinged.Equal(ip1) {
+		t.Fatalf("device answering ping is offline %+v", e)
+	}
+
+	h.mutex.Lock()
+	entry.LastUpdate = time.Now().Add(-time.Minute * 5)
+	h.mutex.Unlock()
+	h.pinger = func(ip net.IP, timeout time.Duration) error { return errors.New("timeout") }
+	h.confirmIsActive()
+	if e := h.FindMAC(mac1); e.Online {
+		t.Fatalf("device not answering ping is online %+v", e)
+	}
+}
+
+func Test_TCPProbe(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen ", err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	h.mutex.Lock()
+	entry := h.arpTableAppendLocked(StateNormal, mac1, net.IPv4(127, 0, 0, 1))
+	entry.Online = true
+	entry.LastUpdate = time.Now().Add(-time.Minute * 5)
+	h.mutex.Unlock()
+
+	if err := h.SetTCPProbe(mac1, 70000); err == nil {
+		t.Error("expected invalid port error")
+	}
+	if err := h.SetTCPProbe(mac1, port); err != nil {
+		t.Fatal("SetTCPProbe error ", err)
+	}
+	h.confirmIsActive()
+	if e := h.FindMAC(mac1); !e.Online {
+		t.Fatalf("device answering tcp probe is offline %+v", e)
+	}
+
+	h.mutex.Lock()
+	entry.LastUpdate = time.Now().Add(-time.Minute * 5)
+	h.mutex.Unlock()
+	h.SetTCPProbe(mac1)
+	h.confirmIsActive()
+	if e := h.FindMAC(mac1); e.Online {
+		t.Fatalf("device without probe is online %+v", e)
+	}
+}
+
+func Test_ProbeInterval(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	h.mutex.Lock()
+	for _, e := range []*Entry{h.arpTableAppendLocked(StateNormal, mac1, ip1), h.arpTableAppendLocked(StateNormal, mac2, ip2)} {
+		e.Online = true
+		e.LastUpdate = time.Now().Add(-time.Second * 30)
+	}
+	h.mutex.Unlock()
+
+	if err := h.SetProbeInterval(mac1, time.Millisecond); err == nil {
+		t.Error("expected invalid interval error")
+	}
+	if err := h.SetProbeInterval(mac1, time.Second*10); err != nil {
+		t.Fatal("SetProbeInterval error ", err)
+	}
+	if h.ProbeInterval(mac1) != time.Second*10 || h.ProbeInterval(mac2) != defaultProbeInterval || h.minProbeInterval() != time.Second*10 {
+		t.Fatal("invalid probe intervals")
+	}
+
+	// only mac1 is probed; it goes offline after 8/3 of the interval
+	h.confirmIsActive()
+	if e := h.FindMAC(mac1); e.Online {
+		t.Errorf("mac1 is online %+v", e)
+	}
+	if e := h.FindMAC(mac2); !e.Online {
+		t.Errorf("mac2 is offline %+v", e)
+	}
+	for len(conn.out) > 0 {
+		if p := <-conn.out; !p.TargetIP.Equal(ip1) {
+			t.Errorf("unexpected probe to %s", p.TargetIP)
+		}
+	}
+
+	h.SetProbeInterval(mac1, 0)
+	if h.ProbeInterval(mac1) != defaultProbeInterval {
+		t.Error("probe interval not removed")
+	}
+}
+
+func Test_ProbeJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if d := jitter(time.Second); d < time.Second/2 || d >= time.Second*3/2 {
+			t.Fatalf("invalid jitter %v", d)
+		}
+	}
+
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	h.mutex.Lock()
+	for _, e := range []*Entry{h.arpTableAppendLocked(StateNormal, mac1, ip1), h.arpTableAppendLocked(StateNormal, mac2, ip2),
+		h.arpTableAppendLocked(StateNormal, mac3, ip3)} {
+		e.Online = true
+		e.LastUpdate = time.Now().Add(-time.Minute * 2)
+	}
+	h.mutex.Unlock()
+
+	// three probes spaced 66ms +/- 50% over the first half of the period
+	start := time.Now()
+	h.confirmEntries(time.Millisecond*400, false)
+	if d := time.Since(start); d < time.Millisecond*50 || d > time.Millisecond*400/2*3/2+time.Millisecond*100 {
+		t.Errorf("probes not spread %v", d)
+	}
+	if len(conn.out) != 3 {
+		t.Errorf("expected 3 probes got %d", len(conn.out))
+	}
+}
+
+func Test_OfflineThreshold(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(newFakeConn()), WithOfflineThreshold(OfflineThreshold{MissedProbes: 2, Duration: time.Minute, OnlineCount: 3}))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	h.mutex.Lock()
+	entry := h.arpTableAppendLocked(StateNormal, mac1, ip1)
+	entry.Online = true
+	entry.LastUpdate = time.Now().Add(-time.Minute * 5)
+	h.mutex.Unlock()
+
+	// offline on the third probe
+	for i := 0; i < 3; i++ {
+		if e := h.FindMAC(mac1); !e.Online {
+			t.Fatalf("device offline after %d probes", i)
+		}
+		h.confirmIsActive()
+	}
+	if e := h.FindMAC(mac1); e.Online {
+		t.Fatal("device online after 3 probes")
+	}
+
+	// online on the third packet
+	s := h.Subscribe(16, DropNewest)
+	for i := 0; i < 3; i++ {
+		if e := h.FindMAC(mac1); e.Online {
+			t.Fatalf("device online after %d packets", i)
+		}
+		h.handlePacket(newTestPacket(marp.OperationReply, mac1, ip1, mac3, ip3))
+	}
+	select {
+	case e := <-s.C:
+		if e.Type != EventDeviceOnline || !h.FindMAC(mac1).Online {
+			t.Errorf("invalid event %+v", e)
+		}
+	case <-time.After(time.Millisecond * 100):
+		t.Error("timeout waiting for online event")
+	}
+
+	if _, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(newFakeConn()), WithOfflineThreshold(OfflineThreshold{MissedProbes: -1})); err == nil {
+		t.Error("expected invalid threshold error")
+	}
+}
+
+func Test_ScanRange(t *testing.T) {
+	tests := []struct {
+		lan   string
+		first string
+		last  string
+	}{
+		{"192.168.0.0/24", "192.168.0.1", "192.168.0.254"},
+		{"192.168.0.0/23", "192.168.0.1", "192.168.1.254"},
+		{"172.16.0.0/16", "172.16.0.1", "172.16.255.254"},
+		{"10.0.0.0/8", "10.1.0.1", "10.1.255.254"}, // limited to the host /16
+	}
+	ip := make(net.IP, 4)
+	for _, tt := range tests {
+		_, lan, _ := net.ParseCIDR(tt.lan)
+		first, last := scanRange(*lan, net.IPv4(10, 1, 2, 3))
+		binary.BigEndian.PutUint32(ip, first)
+		if ip.String() != tt.first {
+			t.Errorf("%s invalid first %s", tt.lan, ip)
+		}
+		binary.BigEndian.PutUint32(ip, last)
+		if ip.String() != tt.last {
+			t.Errorf("%s invalid last %s", tt.lan, ip)
+		}
+	}
+}
+
+func Test_ScanAsync(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+
+	start := time.Now()
+	h.startScan()
+	if time.Since(start) > time.Millisecond*20 {
+		t.Error("startScan blocked the caller")
+	}
+	select {
+	case <-conn.out:
+	case <-time.After(time.Second):
+		t.Fatal("scan did not send requests")
+	}
+	h.startScan() // previous scan still running; must not start another
+	if n := atomic.LoadInt32(&h.goroutinePool.n); n != 1 {
+		t.Errorf("expected 1 scan goroutine got %d", n)
+	}
+
+	start = time.Now()
+	if err := h.Stop(); err != nil {
+		t.Fatal("Stop error ", err)
+	}
+	if time.Since(start) > time.Millisecond*200 || atomic.LoadInt32(&h.scanning) != 0 {
+		t.Error("scan did not stop with the handler")
+	}
+}
+
+func Test_WithoutScan(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn), WithoutScan())
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	go h.ListenAndServe(time.Hour) // the first full scan would be immediate
+
+	time.Sleep(time.Millisecond * 100)
+	h.SetScanInterval(time.Millisecond * 10)
+	time.Sleep(time.Millisecond * 100)
+	// only the router is resolved; scan packets share the target IP buffer so count them
+	n := 0
+	for len(conn.out) > 0 {
+		if p := <-conn.out; !p.TargetIP.Equal(ip3) {
+			n++
+		}
+	}
+	if n != 0 || atomic.LoadInt32(&h.scanning) != 0 {
+		t.Fatalf("handler without scan sent %d requests", n)
+	}
+}
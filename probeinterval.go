@@ -0,0 +1,77 @@
+package arp
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+var (
+	// activeCheckInterval is the period between checks of idle devices; the
+	// probes are spread over the period.
+	activeCheckInterval = time.Second * 30
+
+	// defaultProbeInterval is the time since the last update before a device is
+	// probed; the device is offline if there is no update after 8/3 of the interval.
+	defaultProbeInterval = time.Second * 90
+
+	// probeIntervalMin is the shortest interval accepted by SetProbeInterval
+	probeIntervalMin = time.Second
+)
+
+// intervalList holds a duration by MAC
+type intervalList map[string]time.Duration
+
+// SetProbeInterval set how often the device is probed when idle instead of
+// the default 90 seconds; i.e. probe a phone every 10 seconds and a smart
+// fridge every 5 minutes. Call with zero to restore the default.
+//
+// The device goes offline if it does not answer for 8/3 of the interval, so
+// shorter intervals also detect offline devices sooner.
+func (c *Handler) SetProbeInterval(mac net.HardwareAddr, interval time.Duration) error {
+	if interval != 0 && interval < probeIntervalMin {
+		return fmt.Errorf("probe interval %v is shorter than %v", interval, probeIntervalMin)
+	}
+
+	c.mutex.Lock()
+	if interval == 0 {
+		delete(c.probeIntervals, string(mac))
+	} else {
+		if c.probeIntervals == nil {
+			c.probeIntervals = make(intervalList)
+		}
+		c.probeIntervals[string(mac)] = interval
+	}
+	c.mutex.Unlock()
+
+	c.signalReconfigure()
+	return nil
+}
+
+// ProbeInterval return the probe interval of the device.
+func (c *Handler) ProbeInterval(mac net.HardwareAddr) time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if interval, ok := c.probeIntervals[string(mac)]; ok {
+		return interval
+	}
+	return defaultProbeInterval
+}
+
+// minProbeInterval return the shortest device probe interval used by
+// pollingLoop to check these devices; it never expires if none is set.
+func (c *Handler) minProbeInterval() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	min := time.Duration(0)
+	for _, interval := range c.probeIntervals {
+		if min == 0 || interval < min {
+			min = interval
+		}
+	}
+	if min == 0 {
+		return time.Minute * 60 * 24 * 365 * 20 // will never expire
+	}
+	return min
+}
@@ -0,0 +1,35 @@
+package arp
+
+import "fmt"
+
+// promiscuousSetter is implemented by the live interface connections that can
+// enable promiscuous mode on the interface.
+type promiscuousSetter interface {
+	SetPromiscuous(b bool) error
+}
+
+// WithPromiscuous enable promiscuous mode on the interface so the handler also
+// reads ARP frames not addressed to the host MAC, i.e. unicast replies between
+// two other devices seen on a hub or a mirror port. Promiscuous mode is tied to
+// the socket and enabled again when the socket is reopened.
+//
+// NewHandler returns ErrPromiscuousNotSupported if the packet source cannot
+// enable promiscuous mode, i.e. a capture file or Npcap on Windows.
+func WithPromiscuous() Option {
+	return func(c *Handler) error {
+		c.promiscuous = true
+		return nil
+	}
+}
+
+// setPromiscuous enable promiscuous mode on conn or return ErrPromiscuousNotSupported.
+func setPromiscuous(conn PacketConn) error {
+	p, ok := conn.(promiscuousSetter)
+	if !ok {
+		return ErrPromiscuousNotSupported
+	}
+	if err := p.SetPromiscuous(true); err != nil {
+		return fmt.Errorf("cannot enable promiscuous mode: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// promiscConn is a fakeConn supporting promiscuous mode
+type promiscConn struct {
+	*fakeConn
+	promisc bool
+}
+
+func (p *promiscConn) SetPromiscuous(b bool) error {
+	p.promisc = b
+	return nil
+}
+
+func Test_Promiscuous(t *testing.T) {
+	lan := net.IPNet{IP: net.IPv4(192, 168, 0, 0).To4(), Mask: net.CIDRMask(24, 32)}
+	_, err := NewHandler("eth0", mac3, ip3, ip3, lan, WithPacketConn(newFakeConn()), WithPromiscuous())
+	if !errors.Is(err, ErrPromiscuousNotSupported) {
+		t.Error("expected ErrPromiscuousNotSupported ", err)
+	}
+
+	conn := &promiscConn{fakeConn: newFakeConn()}
+	if _, err = NewHandler("eth0", mac3, ip3, ip3, lan, WithPacketConn(conn), WithPromiscuous()); err != nil || !conn.promisc {
+		t.Fatal("promiscuous mode not set ", err)
+	}
+
+	// the reopened socket is promiscuous too
+	next := &promiscConn{fakeConn: newFakeConn()}
+	redial := newRedialConn(conn, func() (PacketConn, error) { return next, nil })
+	if err := redial.SetPromiscuous(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := redial.redial(); err != nil || !next.promisc {
+		t.Error("promiscuous mode not set after redial ", err)
+	}
+}
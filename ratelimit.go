@@ -0,0 +1,76 @@
+package arp
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket limits the rate of transmitted frames. The zero value does not
+// limit the rate.
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64 // tokens per second; zero means unlimited
+	burst  float64
+	tokens float64 // negative when callers are waiting for tokens
+	last   time.Time
+}
+
+// set change the rate; burst is a tenth of a second worth of tokens but at least one.
+func (b *tokenBucket) set(packetsPerSecond int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if packetsPerSecond <= 0 {
+		b.rate = 0
+		return
+	}
+	b.rate = float64(packetsPerSecond)
+	b.burst = b.rate / 10
+	if b.burst < 1 {
+		b.burst = 1
+	}
+	b.tokens, b.last = b.burst, time.Now()
+}
+
+// wait take a token and sleep until the token is available. Concurrent
+// callers reserve consecutive tokens so the rate holds across goroutines.
+func (b *tokenBucket) wait() {
+	b.mutex.Lock()
+	if b.rate == 0 {
+		b.mutex.Unlock()
+		return
+	}
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mutex.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// WithRateLimit limit the transmitted ARP frames (scans, probes, spoofs and
+// virtual host replies) to packetsPerSecond; see SetRateLimit.
+func WithRateLimit(packetsPerSecond int) Option {
+	return func(c *Handler) error {
+		c.limiter.set(packetsPerSecond)
+		return nil
+	}
+}
+
+// SetRateLimit change the maximum number of transmitted ARP frames per
+// second; zero removes the limit. Frames beyond the limit are delayed, not
+// dropped, so the handler never floods constrained links (i.e. Wi-Fi) during
+// a full scan.
+func (c *Handler) SetRateLimit(packetsPerSecond int) {
+	c.limiter.set(packetsPerSecond)
+}
@@ -0,0 +1,81 @@
+package arp
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+	log "github.com/sirupsen/logrus"
+)
+
+// EtherTypeRARP is the EtherType of Reverse ARP frames (RFC 903)
+const EtherTypeRARP ethernet.EtherType = 0x8035
+
+// RARP operations
+const (
+	OperationReverseRequest marp.Operation = 3
+	OperationReverseReply   marp.Operation = 4
+)
+
+// RawPacket is a frame that is not an ARP request or reply, i.e. RARP or an
+// ARP packet with a non standard operation; see AddRawPacketChannel.
+//
+// Raw packets do not update the table.
+type RawPacket struct {
+	Time      time.Time
+	EtherType ethernet.EtherType
+	Operation marp.Operation
+	SenderMAC net.HardwareAddr
+	SenderIP  net.IP
+	TargetMAC net.HardwareAddr
+	TargetIP  net.IP
+}
+
+// AddRawPacketChannel send the raw packets to channel.
+//
+// The packets are sent without blocking; packets are discarded if the channel
+// is full. RARP frames are only received if the socket delivers them; on Linux
+// only the WithRingBuffer socket receives them.
+func (c *Handler) AddRawPacketChannel(channel chan<- RawPacket) {
+	c.mutex.Lock()
+	// copy on write; handleRawPacket may be iterating over the previous slice
+	channels := make([]chan<- RawPacket, 0, len(c.rawChannels)+1)
+	c.rawChannels = append(append(channels, c.rawChannels...), channel)
+	c.mutex.Unlock()
+}
+
+// isRawPacket is true if the packet is not an ARP request or reply.
+func isRawPacket(packet *marp.Packet, frame *ethernet.Frame) bool {
+	if frame != nil && frame.EtherType == EtherTypeRARP {
+		return true
+	}
+	return packet.Operation != marp.OperationRequest && packet.Operation != marp.OperationReply
+}
+
+// handleRawPacket send the packet to the raw packet channels; the packet is
+// copied as the packet conn may reuse it.
+func (c *Handler) handleRawPacket(packet *marp.Packet, frame *ethernet.Frame) {
+	atomic.AddUint64(&c.counters.packetsRaw, 1)
+
+	raw := RawPacket{Time: time.Now(), EtherType: ethernet.EtherTypeARP, Operation: packet.Operation,
+		SenderMAC: dupMAC(packet.SenderHardwareAddr), SenderIP: dupIP(packet.SenderIP),
+		TargetMAC: dupMAC(packet.TargetHardwareAddr), TargetIP: dupIP(packet.TargetIP)}
+	if frame != nil {
+		raw.EtherType = frame.EtherType
+	}
+	if c.logArea(LogPackets) {
+		c.log().WithFields(log.Fields{"ethertype": raw.EtherType, "op": raw.Operation, "mac": raw.SenderMAC, "ip": raw.SenderIP}).Debug("ARP raw packet received")
+	}
+
+	c.mutex.RLock()
+	channels := c.rawChannels
+	c.mutex.RUnlock()
+	for _, channel := range channels {
+		select {
+		case channel <- raw:
+		default:
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package arp
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/ethernet"
+)
+
+func Test_RawPacket(t *testing.T) {
+	// RARP frames are decoded with their EtherType
+	var d frameDecoder
+	frame := arpFrame(t, OperationReverseRequest, mac1, net.IPv4zero, mac1, net.IPv4zero)
+	binary.BigEndian.PutUint16(frame[12:14], uint16(EtherTypeRARP))
+	if p, f, err := d.decode(frame); err != nil || f.EtherType != EtherTypeRARP || p.Operation != OperationReverseRequest {
+		t.Fatal("invalid RARP decode ", p, f, err)
+	}
+
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	raw := make(chan RawPacket, 4)
+	h.AddRawPacketChannel(raw)
+	go h.ListenAndServe(0)
+
+	conn.in <- newTestPacket(OperationReverseReply, mac1, ip1, mac2, ip2)
+	select {
+	case p := <-raw:
+		if p.Operation != OperationReverseReply || p.EtherType != ethernet.EtherTypeARP || p.SenderMAC.String() != mac1.String() || !p.TargetIP.Equal(ip2) {
+			t.Errorf("invalid raw packet %+v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for raw packet")
+	}
+	if h.FindMAC(mac1) != nil {
+		t.Error("raw packet updated the table")
+	}
+	if stats := h.Stats(); stats.PacketsRaw != 1 || stats.RequestsRead+stats.RepliesRead != 0 {
+		t.Errorf("invalid stats %+v", stats)
+	}
+}
@@ -0,0 +1,263 @@
+package arp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/bpf"
+)
+
+var (
+	reconnectMinBackoff = time.Second
+	reconnectMaxBackoff = time.Minute
+)
+
+// redialConn is the live interface connection. It reopens the socket when
+// ListenAndServe gets a permanent read error, for example when the interface
+// goes down or the driver is reset.
+type redialConn struct {
+	conn     PacketConn // replaced by redial
+	dial     func() (PacketConn, error)
+	filter   []bpf.RawInstruction // attached to every new socket; see SetBPF
+	promisc  bool                 // see SetPromiscuous
+	closed   bool
+	resetErr error // returned by Read after reset
+	single   singleBatch
+	drops    uint64 // kernel drops of the closed sockets; see KernelDrops
+	mutex    sync.RWMutex
+}
+
+func newRedialConn(conn PacketConn, dial func() (PacketConn, error)) *redialConn {
+	return &redialConn{conn: conn, dial: dial}
+}
+
+func (c *redialConn) current() PacketConn {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.conn
+}
+
+func (c *redialConn) Read() (*marp.Packet, *ethernet.Frame, error) {
+	packet, frame, err := c.current().Read()
+	if err != nil {
+		c.mutex.RLock()
+		if c.resetErr != nil {
+			err = c.resetErr
+		}
+		c.mutex.RUnlock()
+	}
+	return packet, frame, err
+}
+
+// ReadBatch read a batch if the socket supports it or a single packet.
+func (c *redialConn) ReadBatch() ([]*marp.Packet, []*ethernet.Frame, error) {
+	var packets []*marp.Packet
+	var frames []*ethernet.Frame
+	var err error
+	conn := c.current()
+	if r, ok := conn.(batchReader); ok {
+		packets, frames, err = r.ReadBatch()
+	} else {
+		packets, frames, err = c.single.read(conn)
+	}
+	if err != nil {
+		c.mutex.RLock()
+		if c.resetErr != nil {
+			err = c.resetErr
+		}
+		c.mutex.RUnlock()
+	}
+	return packets, frames, err
+}
+
+// WriteBatch send the packets in a single call if the socket supports it or
+// one at a time.
+func (c *redialConn) WriteBatch(packets []*marp.Packet, addrs []net.HardwareAddr) (int, error) {
+	conn := c.current()
+	if w, ok := conn.(batchWriter); ok {
+		return w.WriteBatch(packets, addrs)
+	}
+	for i := range packets {
+		if err := conn.WriteTo(packets[i], addrs[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(packets), nil
+}
+
+func (c *redialConn) WriteTo(p *marp.Packet, addr net.HardwareAddr) error {
+	return c.current().WriteTo(p, addr)
+}
+
+func (c *redialConn) WriteToVLAN(p *marp.Packet, addr net.HardwareAddr, vlan *ethernet.VLAN) error {
+	w, ok := c.current().(vlanWriter)
+	if !ok {
+		return ErrVLANNotSupported
+	}
+	return w.WriteToVLAN(p, addr, vlan)
+}
+
+func (c *redialConn) SetReadDeadline(t time.Time) error { return c.current().SetReadDeadline(t) }
+
+func (c *redialConn) SetWriteDeadline(t time.Time) error {
+	if conn, ok := c.current().(writeDeadliner); ok {
+		return conn.SetWriteDeadline(t)
+	}
+	return nil
+}
+
+// SetBPF attach filter to the socket and to the sockets opened by redial.
+func (c *redialConn) SetBPF(filter []bpf.RawInstruction) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := setSocketFilter(c.conn, filter); err != nil {
+		return err
+	}
+	c.filter = filter
+	return nil
+}
+
+// SetPromiscuous set promiscuous mode on the socket and on the sockets opened by redial.
+func (c *redialConn) SetPromiscuous(b bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	p, ok := c.conn.(promiscuousSetter)
+	if !ok {
+		return ErrPromiscuousNotSupported
+	}
+	if err := p.SetPromiscuous(b); err != nil {
+		return err
+	}
+	c.promisc = b
+	return nil
+}
+
+// configure apply the socket filter and promiscuous mode to a new socket.
+func (c *redialConn) configure(conn PacketConn) error {
+	if c.filter != nil {
+		if err := setSocketFilter(conn, c.filter); err != nil {
+			return err
+		}
+	}
+	if c.promisc {
+		return setPromiscuous(conn)
+	}
+	return nil
+}
+
+// KernelDrops return the frames dropped by the kernel on the current and
+// previous sockets.
+func (c *redialConn) KernelDrops() uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	drops := c.drops
+	if d, ok := c.conn.(dropCounter); ok {
+		drops += d.KernelDrops()
+	}
+	return drops
+}
+
+// ReceiveTimes return the receive times of the current socket or nil.
+func (c *redialConn) ReceiveTimes() []time.Time {
+	if r, ok := c.current().(receiveTimer); ok {
+		return r.ReceiveTimes()
+	}
+	return nil
+}
+
+func (c *redialConn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	return c.conn.Close()
+}
+
+// reset close the socket so a blocked Read returns err; call redial to open a new socket.
+func (c *redialConn) reset(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.closed {
+		c.resetErr = err
+		c.conn.Close()
+	}
+}
+
+// redial open a new socket and close the previous one; it fails if the
+// connection was closed.
+func (c *redialConn) redial() error {
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		conn.Close()
+		return net.ErrClosed
+	}
+	if err := c.configure(conn); err != nil {
+		conn.Close()
+		return err
+	}
+	previous := c.conn
+	c.conn = conn
+	c.resetErr = nil
+	if d, ok := previous.(dropCounter); ok {
+		c.drops += d.KernelDrops()
+	}
+	previous.Close()
+	return nil
+}
+
+// reconnect reopen the socket after a permanent read error; it retries with
+// exponential backoff until it succeeds or the handler stops.
+//
+// EventHandlerDegraded is published before the first attempt and
+// EventHandlerRecovered when the socket is open again. It returns false if the
+// handler is stopping.
+func (c *Handler) reconnect(h *goroutine, cause error) bool {
+	c.log().WithFields(log.Fields{"nic": c.config.NIC}).Error("ARP socket failed - reconnecting ", cause)
+	c.publish(Event{Type: EventHandlerDegraded, Time: time.Now(), NIC: c.config.NIC, Err: cause})
+	atomic.StoreInt32(&c.degraded, 1)
+	defer atomic.StoreInt32(&c.degraded, 0)
+
+	backoff := reconnectMinBackoff
+	for {
+		select {
+		case <-time.After(backoff):
+		case <-c.goroutinePool.StopChannel:
+			return false
+		}
+		if h.Stopping() {
+			return false
+		}
+
+		err := c.redial.redial()
+		if err == nil {
+			err = c.client.SetReadDeadline(time.Time{})
+		}
+		if err == nil {
+			break
+		}
+		if err == net.ErrClosed {
+			return false
+		}
+		if c.logArea(LogPackets) {
+			c.log().WithFields(log.Fields{"nic": c.config.NIC, "backoff": backoff}).Debug("ARP reconnect failed ", err)
+		}
+		if backoff *= 2; backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
+		}
+	}
+
+	c.log().WithFields(log.Fields{"nic": c.config.NIC}).Info("ARP socket reconnected")
+	c.publish(Event{Type: EventHandlerRecovered, Time: time.Now(), NIC: c.config.NIC})
+	c.signalKeepalive()
+	return true
+}
@@ -0,0 +1,97 @@
+package arp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// failConn returns a permanent error on every read
+type failConn struct {
+	*fakeConn
+}
+
+func (f failConn) Read() (*marp.Packet, *ethernet.Frame, error) {
+	return nil, nil, errors.New("network is down")
+}
+
+func Test_Reconnect(t *testing.T) {
+	reconnectMinBackoff = time.Millisecond
+	defer func() { reconnectMinBackoff = time.Second }()
+
+	conn := newFakeConn()
+	dials := 0
+	redial := newRedialConn(failConn{newFakeConn()}, func() (PacketConn, error) {
+		if dials++; dials == 1 {
+			return nil, errors.New("no such device")
+		}
+		return conn, nil
+	})
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(redial))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	h.redial = redial
+	s := h.Subscribe(16, DropNewest)
+	go h.ListenAndServe(0)
+	defer h.Stop()
+
+	for _, want := range []EventType{EventHandlerDegraded, EventHandlerRecovered} {
+		select {
+		case event := <-s.C:
+			if event.Type != want || (want == EventHandlerDegraded && event.Err == nil) {
+				t.Fatalf("expected %s event got %+v", want, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for ", want)
+		}
+	}
+	if dials != 2 {
+		t.Error("expected two dials ", dials)
+	}
+
+	conn.in <- newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2)
+	select {
+	case event := <-s.C:
+		if event.Type != EventNewDevice || !bytes.Equal(event.Entry.MAC, mac1) {
+			t.Error("unexpected event ", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no packet read after reconnect")
+	}
+}
+
+// dropConn reports a fixed number of kernel drops
+type dropConn struct {
+	*fakeConn
+	drops uint64
+}
+
+func (f dropConn) KernelDrops() uint64 { return f.drops }
+
+func Test_KernelDrops(t *testing.T) {
+	redial := newRedialConn(dropConn{newFakeConn(), 3}, func() (PacketConn, error) {
+		return dropConn{newFakeConn(), 2}, nil
+	})
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(redial))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	if n := h.Stats().KernelDrops; n != 3 {
+		t.Error("expected 3 kernel drops got ", n)
+	}
+	// the drops of the previous socket are kept after a redial
+	if err := redial.redial(); err != nil {
+		t.Fatal("redial error ", err)
+	}
+	if n := h.Stats().KernelDrops; n != 5 {
+		t.Error("expected 5 kernel drops got ", n)
+	}
+}
@@ -0,0 +1,132 @@
+package arp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// routerResolveRetries is the number of requests sent to resolve the router MAC
+	routerResolveRetries = 3
+
+	// routerResolveWait is the time to wait for the router reply before retrying
+	routerResolveWait = time.Millisecond * 500
+
+	// routerRefreshInterval is the interval to check the router MAC has not changed
+	routerRefreshInterval = time.Minute * 5
+)
+
+// RouterMAC return the MAC of the router or nil if it has not been resolved.
+//
+// The MAC is resolved when ListenAndServe starts and refreshed periodically
+// so a router replacement is detected.
+func (c *Handler) RouterMAC() net.HardwareAddr {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.config.RouterMAC == nil {
+		return nil
+	}
+	return dupMAC(c.config.RouterMAC)
+}
+
+// isRouterMAC return true if mac is the router MAC; it does not allocate
+func (c *Handler) isRouterMAC(mac net.HardwareAddr) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.config.RouterMAC != nil && bytes.Equal(mac, c.config.RouterMAC)
+}
+
+// SetRouter change the router IP and MAC, i.e. after the router is replaced.
+//
+// If mac is nil, the router MAC is resolved by ListenAndServe. The table is preserved.
+func (c *Handler) SetRouter(ip net.IP, mac net.HardwareAddr) error {
+	if ip.To4() == nil {
+		return fmt.Errorf("invalid router IP %s", ip)
+	}
+	ip = ip.To4()
+	if mac != nil {
+		if err := checkMAC(mac); err != nil {
+			return err
+		}
+	}
+
+	c.mutex.Lock()
+	c.config.RouterIP = dupIP(ip)
+	c.config.RouterMAC = nil
+	if mac != nil {
+		c.config.RouterMAC = dupMAC(mac)
+	}
+	c.mutex.Unlock()
+
+	if c.logArea(LogScan) {
+		c.log().WithFields(log.Fields{"ip": ip, "mac": mac}).Debug("ARP router changed")
+	}
+	c.signalReconfigure()
+	return nil
+}
+
+// routerIP return the router IP; the slice is replaced and never modified by SetRouter
+func (c *Handler) routerIP() net.IP {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.config.RouterIP
+}
+
+// resolveRouter send ARP requests to the router IP until the router is in the
+// table and update the router MAC.
+func (c *Handler) resolveRouter() {
+	routerIP := c.routerIP()
+	if routerIP == nil {
+		return
+	}
+
+	for i := 0; ; i++ {
+		if router := c.FindIP(routerIP); router != nil {
+			c.setRouterMAC(routerIP, router.MAC)
+			return
+		}
+		if c.passive {
+			return // learned when the router sends a packet
+		}
+		if i >= routerResolveRetries {
+			c.log().WithFields(log.Fields{"ip": routerIP}).Warn("ARP cannot resolve router MAC")
+			return
+		}
+
+		if err := c.request(c.config.HostMAC, c.config.HostIP, EthernetBroadcast, routerIP); err != nil {
+			c.log().Error("ARP request error ", err)
+		}
+		select {
+		case <-time.After(routerResolveWait):
+		case <-c.goroutinePool.StopChannel:
+			return
+		}
+	}
+}
+
+// setRouterMAC update the router MAC and log when it changes; ignored if the router IP changed
+func (c *Handler) setRouterMAC(ip net.IP, mac net.HardwareAddr) {
+	c.mutex.Lock()
+	previous := c.config.RouterMAC
+	if !ip.Equal(c.config.RouterIP) || bytes.Equal(previous, mac) {
+		c.mutex.Unlock()
+		return
+	}
+	if c.guard != nil && previous != nil { // pinned; see WithGatewayGuard
+		c.mutex.Unlock()
+		c.log().WithFields(log.Fields{"ip": ip, "mac": mac.String(), "router": previous.String()}).Warn("ARP router MAC change ignored")
+		return
+	}
+	c.config.RouterMAC = dupMAC(mac)
+	c.mutex.Unlock()
+
+	if previous != nil {
+		c.log().WithFields(log.Fields{"ip": ip, "mac": mac.String(), "previous": previous.String()}).Warn("ARP router MAC changed")
+	} else if c.logArea(LogScan) {
+		c.log().WithFields(log.Fields{"ip": ip, "mac": mac.String()}).Debug("ARP router MAC resolved")
+	}
+}
@@ -0,0 +1,82 @@
+package arp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_RouterMAC(t *testing.T) {
+	hostMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	hostIP := net.IPv4(192, 168, 0, 100).To4()
+	conn := newFakeConn()
+
+	h, err := NewHandler("eth0", hostMAC, hostIP, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	go h.ListenAndServe(0)
+	defer h.Stop()
+
+	if h.RouterMAC() != nil {
+		t.Error("router mac set before resolution")
+	}
+
+	// wait for the router request and reply
+	timeout := time.After(time.Second * 2)
+	for found := false; !found; {
+		select {
+		case p := <-conn.out:
+			found = p.Operation == marp.OperationRequest && p.TargetIP.Equal(ip3)
+		case <-timeout:
+			t.Fatal("timeout waiting for router request")
+		}
+	}
+	p, _ := marp.NewPacket(marp.OperationReply, mac3, ip3, hostMAC, hostIP)
+	conn.in <- p
+
+	for !bytes.Equal(h.RouterMAC(), mac3) {
+		select {
+		case <-timeout:
+			t.Fatalf("router mac not resolved %s", h.RouterMAC())
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+}
+
+func Test_Reconfigure(t *testing.T) {
+	hostMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	hostIP := net.IPv4(192, 168, 0, 100).To4()
+	conn := newFakeConn()
+
+	h, err := NewHandler("eth0", hostMAC, hostIP, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	if err := h.SetRouter(nil, nil); err == nil {
+		t.Error("invalid router accepted")
+	}
+	if err := h.SetRouter(ip1, mac1); err != nil || !h.routerIP().Equal(ip1) || !bytes.Equal(h.RouterMAC(), mac1) {
+		t.Errorf("invalid router ip=%s mac=%s error %v", h.routerIP(), h.RouterMAC(), err)
+	}
+	if !h.isRouterMAC(mac1) || h.isRouterMAC(mac2) {
+		t.Error("invalid router mac check")
+	}
+
+	if err := h.SetHomeLAN(net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)}); err == nil {
+		t.Error("invalid home LAN accepted")
+	}
+	if err := h.SetHomeLAN(net.IPNet{IP: net.IPv4(10, 0, 1, 7), Mask: net.CIDRMask(24, 32)}); err != nil || h.config.HomeLAN.String() != "10.0.1.0/24" {
+		t.Errorf("invalid home LAN %s error %v", h.config.HomeLAN.String(), err)
+	}
+
+	h.SetScanInterval(time.Minute)
+	if h.getScanInterval() != time.Minute {
+		t.Errorf("invalid scan interval %s", h.getScanInterval())
+	}
+}
@@ -0,0 +1,328 @@
+package arp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// scheduleInterval is the interval between schedule evaluations
+var scheduleInterval = time.Minute
+
+// ScheduleRule hunts a device or the devices of a group during a period of the
+// day, i.e. to block the console from 22:00 to 07:00; see AddSchedule.
+type ScheduleRule struct {
+	Name  string           `json:"name"`
+	MAC   net.HardwareAddr `json:"mac,omitempty"`   // device to hunt; empty if Group is set
+	Group string           `json:"group,omitempty"` // group to hunt; see CreateGroup
+	Days  []time.Weekday   `json:"days,omitempty"`  // days the period starts; empty for every day
+	Start time.Duration    `json:"start"`           // time of day since midnight, i.e. 22 * time.Hour
+	End   time.Duration    `json:"end"`             // time of day; before Start for periods across midnight
+}
+
+// scheduler holds the schedule rules and the hunts they started
+type scheduler struct {
+	rules   []ScheduleRule  // copy on write
+	started map[string]bool // MACs hunted by the scheduler
+	changed chan struct{}
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{started: make(map[string]bool), changed: make(chan struct{}, 1)}
+}
+
+// scheduleStore is implemented by stores that persist the schedule; the
+// rules are loaded by SetStore and saved when they change.
+type scheduleStore interface {
+	LoadSchedule() ([]ScheduleRule, error)
+	SaveSchedule(rules []ScheduleRule) error
+}
+
+// Active return true if t is in the rule period.
+func (r ScheduleRule) Active(t time.Time) bool {
+	tod := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	day := t.Weekday()
+	switch {
+	case r.Start < r.End:
+		return tod >= r.Start && tod < r.End && r.onDay(day)
+	case tod >= r.Start:
+		return r.onDay(day)
+	case tod < r.End: // period started the previous day
+		return r.onDay((day + 6) % 7)
+	}
+	return false
+}
+
+func (r ScheduleRule) onDay(day time.Weekday) bool {
+	if len(r.Days) == 0 {
+		return true
+	}
+	for _, d := range r.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate return an error if the rule is not valid.
+func (r ScheduleRule) Validate() error {
+	if r.Name == "" {
+		return errors.New("empty schedule name")
+	}
+	if (len(r.MAC) == 0) == (r.Group == "") {
+		return fmt.Errorf("schedule %q must have a MAC or a group", r.Name)
+	}
+	if len(r.MAC) > 0 {
+		if err := checkMAC(r.MAC); err != nil {
+			return err
+		}
+	}
+	if r.Start < 0 || r.Start >= time.Hour*24 || r.End < 0 || r.End >= time.Hour*24 || r.Start == r.End {
+		return fmt.Errorf("schedule %q has an invalid period %s-%s", r.Name, r.Start, r.End)
+	}
+	for _, d := range r.Days {
+		if d < time.Sunday || d > time.Saturday {
+			return fmt.Errorf("schedule %q has an invalid day %d", r.Name, d)
+		}
+	}
+	return nil
+}
+
+// ParseTimeOfDay return the duration since midnight of a "15:04" time.
+func ParseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// formatTimeOfDay return d in "15:04" format
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
+
+// scheduleJSON overrides the MAC and time fields; the times are in "15:04"
+// format. The remaining fields use the ScheduleRule tags.
+type scheduleJSON struct {
+	MAC   string `json:"mac,omitempty"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+	*scheduleFields
+}
+
+type scheduleFields ScheduleRule // no methods; avoids recursion in MarshalJSON
+
+// MarshalJSON implements json.Marshaler
+func (r ScheduleRule) MarshalJSON() ([]byte, error) {
+	v := scheduleJSON{Start: formatTimeOfDay(r.Start), End: formatTimeOfDay(r.End), scheduleFields: (*scheduleFields)(&r)}
+	if len(r.MAC) > 0 {
+		v.MAC = r.MAC.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *ScheduleRule) UnmarshalJSON(b []byte) (err error) {
+	v := scheduleJSON{scheduleFields: (*scheduleFields)(r)}
+	if err = json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	r.MAC = nil
+	if v.MAC != "" {
+		if r.MAC, err = net.ParseMAC(v.MAC); err != nil {
+			return err
+		}
+	}
+	if r.Start, err = ParseTimeOfDay(v.Start); err != nil {
+		return err
+	}
+	r.End, err = ParseTimeOfDay(v.End)
+	return err
+}
+
+// AddSchedule add the rule or replace the rule with the same name. The
+// devices are hunted while a rule is active and the hunts started by the
+// schedule stop when no rule is active for the device; hunts started by
+// StartHunt are not stopped. Rules are evaluated every minute in the local
+// time zone and saved in the store if it supports it.
+func (c *Handler) AddSchedule(rule ScheduleRule) error {
+	if c.passive {
+		return ErrPassive
+	}
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if len(rule.MAC) > 0 {
+		rule.MAC = dupMAC(rule.MAC)
+	}
+	rule.Days = append([]time.Weekday(nil), rule.Days...)
+
+	c.mutex.Lock()
+	rules := make([]ScheduleRule, 0, len(c.schedule.rules)+1)
+	for _, r := range c.schedule.rules {
+		if r.Name != rule.Name {
+			rules = append(rules, r)
+		}
+	}
+	c.schedule.rules = append(rules, rule)
+	c.mutex.Unlock()
+
+	c.scheduleChanged()
+	return nil
+}
+
+// RemoveSchedule remove the rule with name; hunts started by the rule stop on
+// the next evaluation.
+func (c *Handler) RemoveSchedule(name string) error {
+	c.mutex.Lock()
+	rules := make([]ScheduleRule, 0, len(c.schedule.rules))
+	for _, r := range c.schedule.rules {
+		if r.Name != name {
+			rules = append(rules, r)
+		}
+	}
+	if len(rules) == len(c.schedule.rules) {
+		c.mutex.Unlock()
+		return fmt.Errorf("schedule %q %w", name, ErrNotFound)
+	}
+	c.schedule.rules = rules
+	c.mutex.Unlock()
+
+	c.scheduleChanged()
+	return nil
+}
+
+// Schedule return the schedule rules; do not modify.
+func (c *Handler) Schedule() []ScheduleRule {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.schedule.rules
+}
+
+// scheduleChanged save the rules and wake up scheduleLoop
+func (c *Handler) scheduleChanged() {
+	c.mutex.RLock()
+	s, ok := c.store.(scheduleStore)
+	rules := c.schedule.rules
+	c.mutex.RUnlock()
+	if ok {
+		if err := s.SaveSchedule(rules); err != nil {
+			c.log().Error("ARP cannot save schedule ", err)
+		}
+	}
+
+	select {
+	case c.schedule.changed <- struct{}{}:
+	default:
+	}
+}
+
+// loadSchedule set the rules saved in s
+func (c *Handler) loadSchedule(s scheduleStore) error {
+	rules, err := s.LoadSchedule()
+	if err != nil {
+		return err
+	}
+	valid := make([]ScheduleRule, 0, len(rules))
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			c.log().Warn("ARP cannot restore schedule ", err)
+			continue
+		}
+		valid = append(valid, r)
+	}
+	c.mutex.Lock()
+	c.schedule.rules = valid
+	c.mutex.Unlock()
+	return nil
+}
+
+// scheduleLoop apply the schedule every scheduleInterval and when the rules change.
+func (c *Handler) scheduleLoop(h *goroutine) {
+	defer h.End()
+
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+	for {
+		c.applySchedule(time.Now())
+
+		select {
+		case <-c.goroutinePool.StopChannel:
+			return
+		case <-ticker.C:
+		case <-c.schedule.changed:
+		}
+	}
+}
+
+// applySchedule start hunting the devices of the active rules and stop the
+// hunts started by rules no longer active.
+func (c *Handler) applySchedule(now time.Time) {
+	c.mutex.RLock()
+	rules := c.schedule.rules
+	c.mutex.RUnlock()
+
+	active := make(map[string]net.HardwareAddr)
+	for _, r := range rules {
+		if !r.Active(now) {
+			continue
+		}
+		if len(r.MAC) > 0 {
+			active[string(r.MAC)] = r.MAC
+			continue
+		}
+		members, err := c.GroupMembers(r.Group)
+		if err != nil {
+			c.log().WithFields(log.Fields{"schedule": r.Name}).Warn("ARP schedule group ", err)
+			continue
+		}
+		for _, mac := range members {
+			active[string(mac)] = mac
+		}
+	}
+
+	for key, mac := range active {
+		entry := c.FindMAC(mac)
+		if entry == nil {
+			continue
+		}
+		c.mutex.RLock()
+		state := entry.State
+		c.mutex.RUnlock()
+		if state == StateHunt {
+			continue
+		}
+		if err := c.StartHunt(mac); err != nil {
+			if c.logArea(LogSpoof) {
+				c.log().WithFields(log.Fields{"mac": mac.String()}).Debug("ARP schedule cannot start hunt ", err)
+			}
+			continue
+		}
+		c.log().WithFields(log.Fields{"mac": mac.String()}).Info("ARP schedule start hunt")
+		c.mutex.Lock()
+		c.schedule.started[key] = true
+		c.mutex.Unlock()
+	}
+
+	var stop []net.HardwareAddr
+	c.mutex.Lock()
+	for key := range c.schedule.started {
+		if active[key] == nil {
+			delete(c.schedule.started, key)
+			stop = append(stop, net.HardwareAddr(key))
+		}
+	}
+	c.mutex.Unlock()
+	for _, mac := range stop {
+		c.log().WithFields(log.Fields{"mac": mac.String()}).Info("ARP schedule stop hunt")
+		if err := c.StopHunt(mac); err != nil && c.logArea(LogSpoof) {
+			c.log().WithFields(log.Fields{"mac": mac.String()}).Debug("ARP schedule cannot stop hunt ", err)
+		}
+	}
+}
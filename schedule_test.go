@@ -0,0 +1,69 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_ScheduleActive(t *testing.T) {
+	night := ScheduleRule{Name: "night", MAC: mac1, Days: []time.Weekday{time.Friday}, Start: time.Hour * 22, End: time.Hour * 7}
+	friday := time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local) // a Friday
+	tests := []struct {
+		t      time.Time
+		active bool
+	}{
+		{friday.Add(time.Hour * 21), false},
+		{friday.Add(time.Hour * 22), true},
+		{friday.Add(time.Hour * 30), true},  // Saturday 06:00
+		{friday.Add(time.Hour * 31), false}, // Saturday 07:00
+		{friday.Add(time.Hour * 3), false},  // Friday 03:00 started on Thursday
+	}
+	for _, tt := range tests {
+		if got := night.Active(tt.t); got != tt.active {
+			t.Errorf("Active(%s)=%v want %v", tt.t.Format("Mon 15:04"), got, tt.active)
+		}
+	}
+
+	if err := (ScheduleRule{Name: "x", MAC: mac1, Group: "kids", Start: 1, End: 2}).Validate(); err == nil {
+		t.Error("expected error with MAC and group")
+	}
+	if d, err := ParseTimeOfDay("22:30"); err != nil || d != time.Hour*22+time.Minute*30 {
+		t.Error("invalid time of day ", d, err)
+	}
+}
+
+func Test_ApplySchedule(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip3))
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac2, ip2, EthernetBroadcast, ip3))
+	h.CreateGroup("kids")
+	h.AddToGroup("kids", mac2)
+
+	now := time.Now()
+	start := time.Duration(now.Hour()) * time.Hour
+	if err := h.AddSchedule(ScheduleRule{Name: "kids", Group: "kids", Start: start, End: (start + time.Hour) % (time.Hour * 24)}); err != nil {
+		t.Fatal("AddSchedule error ", err)
+	}
+	h.applySchedule(now)
+	hunted := h.HuntList()
+	if len(hunted) != 1 || hunted[0].MAC.String() != mac2.String() {
+		t.Fatalf("invalid hunts %v", hunted)
+	}
+
+	// the hunt stops when the rule is removed
+	h.RemoveSchedule("kids")
+	h.applySchedule(now)
+	for i := 0; len(h.HuntList()) > 0; i++ {
+		if i > 100 {
+			t.Fatal("hunt not stopped ", h.HuntList())
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
@@ -0,0 +1,113 @@
+package arp
+
+import (
+	"encoding/json"
+	"net"
+)
+
+// Snapshot return a deep copy of the arp table excluding virtual hosts.
+//
+// Unlike GetTable, the returned entries are not shared with the handler and
+// are safe to read, modify or serialise without locking.
+func (c *Handler) Snapshot() []Entry {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	table := make([]Entry, 0, len(c.table))
+	for _, entry := range c.table {
+		if entry != nil && entry.State != StateVirtualHost {
+			table = append(table, entry.copy())
+		}
+	}
+	return table
+}
+
+// SnapshotMAC return a deep copy of the entry with mac; it returns false if
+// the MAC is not in the table or is a virtual host. Use it instead of FindMAC
+// to read the entry fields without locking.
+func (c *Handler) SnapshotMAC(mac net.HardwareAddr) (Entry, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry := c.findMACLocked(mac)
+	if entry == nil || entry.State == StateVirtualHost {
+		return Entry{}, false
+	}
+	return entry.copy(), true
+}
+
+// SnapshotDevice return a deep copy of the entry returned by FindDevice; it
+// returns false if the device is not found.
+func (c *Handler) SnapshotDevice(id string) (Entry, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry := c.findDeviceLocked(id)
+	if entry == nil {
+		return Entry{}, false
+	}
+	return entry.copy(), true
+}
+
+// copy return a copy of the entry that does not share the underlying slices.
+func (e *Entry) copy() Entry {
+	n := *e
+	n.MAC = dupMAC(e.MAC)
+	n.IP = dupIP(e.IP)
+	if e.Addresses != nil {
+		n.Addresses = make([]Address, len(e.Addresses))
+		for i := range e.Addresses {
+			n.Addresses[i] = Address{IP: dupIP(e.Addresses[i].IP), LastSeen: e.Addresses[i].LastSeen}
+		}
+	}
+	if e.Labels != nil {
+		n.Labels = make(map[string]string, len(e.Labels))
+		for k, v := range e.Labels {
+			n.Labels[k] = v
+		}
+	}
+	if e.DHCP != nil {
+		info := *e.DHCP
+		if info.RequestedIP != nil {
+			info.RequestedIP = dupIP(info.RequestedIP)
+		}
+		n.DHCP = &info
+	}
+	if e.IPv6 != nil {
+		n.IPv6 = make([]net.IP, len(e.IPv6))
+		for i := range e.IPv6 {
+			n.IPv6[i] = dupIPv6(e.IPv6[i])
+		}
+	}
+	return n
+}
+
+// entryJSON overrides the MAC field; net.HardwareAddr is a byte slice and
+// would otherwise be encoded in base64. The remaining fields use the Entry tags.
+type entryJSON struct {
+	MAC string `json:"mac"`
+	*entryFields
+}
+
+type entryFields Entry // no methods; avoids recursion in MarshalJSON
+
+// MarshalJSON implements json.Marshaler
+func (e Entry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(entryJSON{MAC: e.MAC.String(), entryFields: (*entryFields)(&e)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (e *Entry) UnmarshalJSON(b []byte) (err error) {
+	v := entryJSON{entryFields: (*entryFields)(e)}
+	if err = json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	e.MAC = nil
+	if v.MAC != "" {
+		if e.MAC, err = net.ParseMAC(v.MAC); err != nil {
+			return err
+		}
+	}
+	if ip4 := e.IP.To4(); ip4 != nil {
+		e.IP = ip4
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package arp
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"time"
@@ -16,87 +17,185 @@ import (
 //
 // client will revert back to "normal" when a new IP is detected for the MAC
 func (c *Handler) ForceIPChange(clientHwAddr net.HardwareAddr, clientIP net.IP) error {
-	if LogAll {
-		log.WithFields(log.Fields{"mac": clientHwAddr.String(), "ip": clientIP.String()}).Debug("ARP capture force IP change")
+	return c.forceIPChange(clientHwAddr, clientIP, HuntBoth)
+}
+
+func (c *Handler) forceIPChange(clientHwAddr net.HardwareAddr, clientIP net.IP, direction HuntDirection) error {
+	if c.logArea(LogSpoof) {
+		c.log().WithFields(log.Fields{"mac": clientHwAddr.String(), "ip": clientIP.String(), "direction": direction}).Debug("ARP capture force IP change")
 	}
 
-	client := c.FindMAC(clientHwAddr)
+	if c.passive {
+		return ErrPassive
+	}
+	if err := checkMAC(clientHwAddr); err != nil {
+		return err
+	}
+
+	// check and set the hunt state in a single locked section so concurrent
+	// calls cannot start two hunts for the same client
+	hunt := newHunt(direction)
+	c.mutex.Lock()
+	client := c.findMACLocked(clientHwAddr)
 	if client == nil {
-		err := fmt.Errorf("mac %s is not online", clientHwAddr.String())
-		if LogAll {
-			log.Debug("ARP nothing to do - ", err)
+		c.mutex.Unlock()
+		err := errMACNotFound(clientHwAddr)
+		if c.logArea(LogSpoof) {
+			c.log().Debug("ARP nothing to do - ", err)
 		}
 		return err
 	}
+	previous := *client
 
-	if client.State == StateHunt {
-		err := fmt.Errorf("client already in hunt state %s ", client.IP.String())
-		if LogAll {
-			log.Debug("ARP error in ForceIPChange", err)
+	if previous.State == StateHunt {
+		c.mutex.Unlock()
+		err := fmt.Errorf("mac %s ip %s %w", previous.MAC.String(), previous.IP.String(), ErrAlreadyHunting)
+		if c.logArea(LogSpoof) {
+			c.log().Debug("ARP error in ForceIPChange", err)
 		}
 		return err
 	}
 
-	if client.IP.Equal(clientIP) == false {
-		err := fmt.Errorf("ARP capture error missmatch in client table with actual client %s vs %s", client.IP.String(), clientIP.String())
-		log.Warn("ARP unexpected IP missmatch - do nothing", err)
+	if err := c.checkSpoofLocked(previous.MAC, previous.IP); err != nil {
+		c.mutex.Unlock()
+		c.logSpoofDenied(previous.MAC, previous.IP)
+		return err
+	}
+
+	if previous.IP.Equal(clientIP) == false {
+		c.mutex.Unlock()
+		err := fmt.Errorf("ARP capture error missmatch in client table with actual client %s vs %s", previous.IP.String(), clientIP.String())
+		c.log().Warn("ARP unexpected IP missmatch - do nothing", err)
 		return err
 	}
 
 	// Set client to Hunt
-	client.State = StateHunt
+	if err := c.setStateLocked(client, StateHunt, ReasonHunt); err != nil {
+		c.mutex.Unlock()
+		return err
+	}
+	current := *client
+	c.hunts[string(client.MAC)] = hunt
+	c.mutex.Unlock()
+	c.firewallBlock(current.MAC)
+	c.notify(EventHuntStarted, previous, current)
 
 	// client.IP = nextFakeIP()
 
 	// spoof client until end of hunt phase
-	go c.spoofLoop(client)
+	go c.spoofLoop(c.goroutinePool.Begin("ARP hunt "+current.MAC.String()), client, hunt)
 
 	return nil
 }
 
 // StopIPChange terminate the hunting process
 func (c *Handler) StopIPChange(clientHwAddr net.HardwareAddr) (err error) {
-	if LogAll {
-		log.WithFields(log.Fields{"mac": clientHwAddr.String()}).Debug("ARP stop IP change")
+	if c.logArea(LogSpoof) {
+		c.log().WithFields(log.Fields{"mac": clientHwAddr.String()}).Debug("ARP stop IP change")
 	}
 
+	if err := checkMAC(clientHwAddr); err != nil {
+		return err
+	}
 	client := c.FindMAC(clientHwAddr)
 	if client == nil {
-		log.WithFields(log.Fields{"mac": clientHwAddr}).Error("ARP mac not found")
-		err = fmt.Errorf("mac %s is not online", clientHwAddr.String())
-		return err
+		c.log().WithFields(log.Fields{"mac": clientHwAddr}).Error("ARP mac not found")
+		return errMACNotFound(clientHwAddr)
 	}
 
 	if client.State != StateHunt {
-		if LogAll {
-			log.WithFields(log.Fields{"mac": client.MAC.String(), "ip": client.IP}).Debug("ARP client is not in hunt state", client.State)
+		if c.logArea(LogSpoof) {
+			c.log().WithFields(log.Fields{"mac": client.MAC.String(), "ip": client.IP}).Debug("ARP client is not in hunt state", client.State)
 		}
 	}
 
 	// this will terminate the spoof gorotutine and delete the Virtual MAC
-	client.State = StateNormal
+	c.mutex.Lock()
+	if err := c.setStateLocked(client, StateNormal, ReasonStop); err != nil {
+		c.mutex.Unlock()
+		return err
+	}
+	if hunt, ok := c.hunts[string(client.MAC)]; ok {
+		close(hunt.stop)
+		delete(c.hunts, string(client.MAC))
+	}
+	c.mutex.Unlock()
 	return nil
 }
 
+// StartHunt start hunting the device; see ForceIPChange.
+//
+// It returns ErrNotFound if the MAC is not in the table, ErrHuntNotAllowed if
+// the device is protected by the spoof lists or ErrAlreadyHunting if the
+// device is already being hunted. EventHuntStarted is sent when the hunt starts and
+// EventHuntStopped when it ends.
+func (c *Handler) StartHunt(mac net.HardwareAddr) error {
+	return c.StartHuntDirection(mac, HuntBoth)
+}
+
+// StopHunt stop hunting the device; EventHuntStopped is sent when the hunt goroutine ends.
+//
+// It returns ErrNotFound if the MAC is not in the table or ErrNotHunting if the device is not being hunted.
+func (c *Handler) StopHunt(mac net.HardwareAddr) error {
+	if err := checkMAC(mac); err != nil {
+		return err
+	}
+	entry, ok := c.SnapshotMAC(mac)
+	if !ok {
+		return errMACNotFound(mac)
+	}
+	if entry.State != StateHunt {
+		return fmt.Errorf("mac %s %w", mac.String(), ErrNotHunting)
+	}
+	return c.StopIPChange(entry.MAC)
+}
+
+// SetHuntTimeout set the maximum hunt duration; zero means no limit.
+//
+// A hunt lasting longer than the timeout returns the device to normal state and
+// removes its virtual host; EventHuntTimeout is sent followed by EventHuntStopped.
+// The timeout applies to hunts in progress and is checked every spoof cycle.
+func (c *Handler) SetHuntTimeout(timeout time.Duration) {
+	c.mutex.Lock()
+	c.huntTimeout = timeout
+	c.mutex.Unlock()
+}
+
+// HuntList return a copy of the entries being hunted.
+func (c *Handler) HuntList() []Entry {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	list := []Entry{}
+	for _, entry := range c.table {
+		if entry != nil && entry.State == StateHunt {
+			list = append(list, entry.copy())
+		}
+	}
+	return list
+}
+
 // FakeIPConflict tricks clients to send a new DHCP request to capture the name.
 // It is used to get the initial client name.
 //
 func (c *Handler) FakeIPConflict(clientHwAddr net.HardwareAddr, clientIP net.IP) {
-	if LogAll {
-		log.WithFields(log.Fields{"mac": clientHwAddr.String(), "ip": clientIP.String()}).Debug("ARP fake IP conflict")
+	if c.logArea(LogSpoof) {
+		c.log().WithFields(log.Fields{"mac": clientHwAddr.String(), "ip": clientIP.String()}).Debug("ARP fake IP conflict")
 	}
 
-	go func() {
-
+	c.goroutinePool.Go("ARP fake IP conflict", func(ctx context.Context) error {
 		for i := 0; i < 7; i++ {
 			c.request(c.config.HostMAC, clientIP, EthernetBroadcast, clientIP) // Send ARP announcement
-			time.Sleep(time.Millisecond * 10)
+			if !sleepContext(ctx, time.Millisecond*10) {
+				return nil
+			}
 			// Reply(virtual.MAC, virtual.IP, arpClient.table[i].MAC, virtual.IP) // Send gratuitous ARP reply
 			// Send ARP reply to broadcast MAC
 
 			c.Reply(c.config.HostMAC, clientIP, clientHwAddr, clientIP) // Send gratuitous ARP reply
 		}
-	}()
+		return nil
+	})
 }
 
 // IPChanged is used to notify that the IP has changed.
@@ -112,31 +211,34 @@ func (c *Handler) IPChanged(clientHwAddr net.HardwareAddr, clientIP net.IP) {
 		return
 	}
 
-	if LogAll {
-		log.WithFields(log.Fields{"mac": clientHwAddr, "ip": clientIP}).Debug("ARP new mac or ip - validating")
+	if c.logArea(LogSpoof) {
+		c.log().WithFields(log.Fields{"mac": clientHwAddr, "ip": clientIP}).Debug("ARP new mac or ip - validating")
 	}
 	if err := c.Request(c.config.HostMAC, c.config.HostIP, EthernetBroadcast, clientIP); err != nil {
-		log.WithFields(log.Fields{"mac": clientHwAddr, "ip": clientIP}).Error("ARP request failed", err)
+		c.log().WithFields(log.Fields{"mac": clientHwAddr, "ip": clientIP}).Error("ARP request failed", err)
 	}
 
-	go func() {
+	c.goroutinePool.Go("ARP IP changed", func(ctx context.Context) error {
 		for i := 0; i < 5; i++ {
-			time.Sleep(time.Second * 1)
+			if !sleepContext(ctx, time.Second*1) {
+				return nil
+			}
 			if entry := c.FindMAC(clientHwAddr); entry != nil && entry.IP.Equal(clientIP) {
-				if LogAll {
-					log.WithFields(log.Fields{"mac": clientHwAddr, "ip": clientIP}).Debug("ARP found mac")
+				if c.logArea(LogSpoof) {
+					c.log().WithFields(log.Fields{"mac": clientHwAddr, "ip": clientIP}).Debug("ARP found mac")
 				}
-				return
+				return nil
 			}
 
 			// Silent request
 			if err := c.request(c.config.HostMAC, c.config.HostIP, EthernetBroadcast, clientIP); err != nil {
-				log.WithFields(log.Fields{"mac": clientHwAddr, "ip": clientIP}).Error("ARP request 2 failed", err)
+				c.log().WithFields(log.Fields{"mac": clientHwAddr, "ip": clientIP}).Error("ARP request 2 failed", err)
 			}
 		}
-		log.WithFields(log.Fields{"mac": clientHwAddr, "ip": clientIP}).Error("ARP mac/ip pair does not exist")
+		c.log().WithFields(log.Fields{"mac": clientHwAddr, "ip": clientIP}).Error("ARP mac/ip pair does not exist")
 		c.PrintTable()
-	}()
+		return nil
+	})
 }
 
 // spoofLoop create a virtual host to handle this IP and will spoof
@@ -146,54 +248,154 @@ func (c *Handler) IPChanged(clientHwAddr net.HardwareAddr, clientIP net.IP) {
 //   1. spoof the client arp table to send router packets to us
 //   2. claim the ownership of the IP
 //
-func (c *Handler) spoofLoop(client *Entry) {
-
-	// Goroutine pool
-	h := c.goroutinePool.Begin("ARP hunt " + client.MAC.String())
+// Only the first step runs for HuntClient and the router arp table is spoofed
+// instead of claiming the IP for HuntRouter; there is no virtual host for these.
+//
+func (c *Handler) spoofLoop(h *goroutine, client *Entry, hunt *hunt) {
 	defer h.End()
 
 	// Virtual Host will exist while this goroutine is running
+	var virtual *Entry
 	c.mutex.Lock()
-	virtual := c.arpTableAppendLocked(StateVirtualHost, newVirtualHardwareAddr(), client.IP)
-	virtual.Online = true
+	if hunt.direction == HuntBoth {
+		mac, err := c.reserveMACLocked(false)
+		if err == nil {
+			if virtual = c.arpTableAppendLocked(StateVirtualHost, mac, client.IP); virtual == nil {
+				c.releaseMACLocked(mac)
+				err = ErrTableFull
+			}
+		}
+		if virtual != nil {
+			virtual.Online = true
+			c.setIPv6Locked(virtual, client.IPv6) // claim the IPv6 addresses too; slice is copy on write
+		} else {
+			c.log().WithFields(log.Fields{"mac": client.MAC.String(), "ip": client.IP}).Error("ARP cannot create virtual host ", err)
+		}
+	}
+	start := *client // copy for the hunt stopped event
 	c.mutex.Unlock()
 
-	// Always search for MAC in case it has been deleted.
-	mac := client.MAC
+	mac := client.MAC // never changes
+	ip := start.IP    // the hunted IP; guaranteed to not change
 	nTimes := 0
 	startTime := time.Now()
+	interval := spoofIntervalStart
 
-	log.WithFields(log.Fields{"mac": mac.String(), "ip": virtual.IP}).Infof("ARP claim IP start %v", startTime)
+	c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip, "direction": hunt.direction}).Infof("ARP claim IP start %v", startTime)
 
 	for {
-		client = c.FindMAC(mac)
-		if h.Stopping() == true || client == nil || client.State != StateHunt {
-			c.deleteVirtualMAC(virtual)
+		// Always search for MAC in case it has been deleted; the state is
+		// checked and changed under the lock and only read from the copy after.
+		var previous, current Entry
+		var denied, timedOut bool
+		var stateErr error
+		c.mutex.Lock()
+		client = c.findMACLocked(mac)
+		timeout := c.huntTimeout
+		if client != nil && client.State == StateHunt {
+			// the deny and allow lists may have changed since the hunt started
+			if err := c.checkSpoofLocked(client.MAC, client.IP); err != nil {
+				denied = true
+				stateErr = c.setStateLocked(client, StateNormal, ReasonDenied)
+			} else if timeout > 0 && time.Since(startTime) >= timeout {
+				timedOut, previous = true, *client
+				stateErr = c.setStateLocked(client, StateNormal, ReasonTimeout)
+			}
+		}
+		if client != nil {
+			current = *client
+		}
+		c.mutex.Unlock()
+
+		switch {
+		case denied && stateErr != nil:
+			c.log().WithFields(log.Fields{"mac": mac.String()}).Warn("ARP cannot stop denied hunt ", stateErr)
+		case denied:
+			c.logSpoofDenied(mac, current.IP)
+		case timedOut && stateErr != nil:
+			c.log().WithFields(log.Fields{"mac": mac.String()}).Warn("ARP cannot stop hunt on timeout ", stateErr)
+		case timedOut:
+			c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Warnf("ARP hunt timeout after %v", timeout)
+			c.notify(EventHuntTimeout, previous, current)
+		}
+
+		if h.Stopping() == true || client == nil || current.State != StateHunt {
+			c.mutex.Lock()
+			if c.hunts[string(mac)] == hunt {
+				delete(c.hunts, string(mac))
+			}
+			c.mutex.Unlock()
+			if virtual != nil {
+				c.deleteVirtualMAC(virtual)
+			}
 			newIP := net.IPv4zero
+			stopped := start
+			stopped.State = StateNormal
 			if client != nil {
-				newIP = client.IP
+				newIP = current.IP
+				stopped = current
 			}
-			log.WithFields(log.Fields{"mac": mac.String(), "ip": virtual.IP, "newIP": newIP}).Infof("ARP claim IP end repeat=%v duration=%v", nTimes, time.Now().Sub(startTime))
+			c.firewallUnblock(mac)
+			c.notify(EventHuntStopped, start, stopped)
+
+			// restore the client cache if it is still in the network
+			if client != nil && !h.Stopping() {
+				if hunt.direction == HuntRouter {
+					c.restoreRouter(current.IP)
+				} else if err := c.AnnounceTo(mac, c.routerIP()); err != nil && c.logArea(LogSpoof) {
+					c.log().WithFields(log.Fields{"mac": mac.String()}).Debug("ARP cannot restore client cache ", err)
+				}
+			}
+			c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip, "newIP": newIP}).Infof("ARP claim IP end repeat=%v duration=%v", nTimes, time.Now().Sub(startTime))
 			return
 		}
 
 		if nTimes%16 == 0 {
-			log.WithFields(log.Fields{"mac": mac.String(), "ip": virtual.IP}).Infof("ARP claim IP repeat=%v duration=%v", nTimes, time.Now().Sub(startTime))
+			c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Infof("ARP claim IP repeat=%v duration=%v", nTimes, time.Now().Sub(startTime))
 		}
 		nTimes++
 
 		// Re-arp target to change router to host so all traffic comes to us
 		// i.e. tell target I am 192.168.0.1
 		//
-		// Use virtual IP as it is guaranteed to not change.
-		c.forceSpoof(client.MAC, virtual.IP) // NOTE: virtual is the target IP
+		// Use the start IP as it is guaranteed to not change.
+		last := time.Now()
+		if hunt.direction != HuntRouter {
+			c.forceSpoof(mac, ip)
+		} else {
+			// Re-arp router to change target to host so all replies come to us
+			c.forceSpoofRouter(mac, ip)
+		}
 
 		// Use VirtualHost to request ownership of the IP; try to force target to acquire another IP
-		c.forceAnnouncement(virtual.MAC, virtual.IP)
+		if virtual != nil {
+			c.forceAnnouncement(virtual.MAC, virtual.IP)
+		}
 
-		// 4 second re-arp seem to be adequate;
-		// Experimented with 300ms but no noticeable improvement other the chatty net.
-		time.Sleep(time.Second * 4)
+		// Same for IPv6 if the NDP handler is running
+		if c.ndpConn() != nil && hunt.direction != HuntRouter {
+			c.forceSpoofNDP(client, virtual)
+		}
+
+		// Start with a 4 second re-arp and back off while the client cache
+		// stays poisoned; ramp up when the router or the client correct it.
+		select {
+		case <-time.After(interval):
+			interval = nextSpoofInterval(interval, false)
+		case <-hunt.corrected:
+			interval = nextSpoofInterval(interval, true)
+			if c.logArea(LogSpoof) {
+				c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Debugf("ARP corrective traffic - spoof interval %v", interval)
+			}
+			// limit the rate if the router floods corrective packets
+			select {
+			case <-time.After(spoofIntervalMin - time.Since(last)):
+			case <-hunt.stop:
+			case <-c.goroutinePool.StopChannel:
+			}
+		case <-hunt.stop: // StopIPChange
+		case <-c.goroutinePool.StopChannel:
+		}
 	}
 }
 
@@ -206,20 +408,25 @@ func (c *Handler) spoofLoop(client *Entry) {
 //
 //
 func (c *Handler) forceSpoof(mac net.HardwareAddr, ip net.IP) error {
+	if err := c.CheckSpoof(mac, ip); err != nil {
+		c.logSpoofDenied(mac, ip)
+		return err
+	}
 
 	// Announce to target that we own the router IP
 	// Unicast announcement - this will not work for all devices but should cause no pain
-	err := c.announceUnicast(c.config.HostMAC, c.config.RouterIP, mac)
+	routerIP := c.routerIP()
+	err := c.announceUnicast(c.config.HostMAC, routerIP, mac)
 	if err != nil {
-		log.WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Error("ARP error send announcement packet", err)
+		c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Error("ARP error send announcement packet", err)
 		return err
 	}
 
 	// Send 3 unsolicited ARP reply; clients may discard this
 	for i := 0; i < 2; i++ {
-		err = c.reply(c.config.HostMAC, c.config.RouterIP, mac, ip)
+		err = c.replySpoof(c.config.HostMAC, routerIP, mac, ip)
 		if err != nil {
-			log.WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Error("ARP spoof client error", err)
+			c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Error("ARP spoof client error", err)
 			return err
 		}
 		time.Sleep(time.Millisecond * 10)
@@ -232,19 +439,22 @@ func (c *Handler) forceSpoof(mac net.HardwareAddr, ip net.IP) error {
 func (c *Handler) forceAnnouncement(mac net.HardwareAddr, ip net.IP) error {
 	err := c.announce(mac, ip)
 	if err != nil {
-		log.WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Error("ARP error send announcement packet", err)
+		c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Error("ARP error send announcement packet", err)
 	}
 
 	// Send 4 gratuitous ARP reply : Log the first one only
-	err = c.Reply(mac, ip, EthernetBroadcast, ip) // Send gratuitous ARP reply
+	if c.logArea(LogPackets) {
+		c.log().WithFields(log.Fields{"dstmac": EthernetBroadcast.String(), "dstip": ip.String()}).Debugf("ARP send reply - host %s is at %s", ip.String(), mac.String())
+	}
+	err = c.replySpoof(mac, ip, EthernetBroadcast, ip) // Send gratuitous ARP reply
 	for i := 0; i < 3; i++ {
 		if err != nil {
-			log.WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Error("ARP error send gratuitous packet", err)
+			c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Error("ARP error send gratuitous packet", err)
 		}
 		time.Sleep(time.Millisecond * 10)
 
 		// Dont show in log
-		err = c.reply(mac, ip, EthernetBroadcast, ip) // Send gratuitous ARP reply
+		err = c.replySpoof(mac, ip, EthernetBroadcast, ip) // Send gratuitous ARP reply
 	}
 
 	return nil
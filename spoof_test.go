@@ -0,0 +1,238 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_HuntAPI(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropNewest)
+
+	h.mutex.Lock()
+	h.arpTableAppendLocked(StateNormal, mac1, ip1).Online = true
+	h.mutex.Unlock()
+
+	if err := h.StartHunt(mac2); err == nil {
+		t.Error("StartHunt unknown mac should fail")
+	}
+	if err := h.StopHunt(mac1); !errors.Is(err, ErrNotHunting) {
+		t.Error("StopHunt mac not in hunt should fail ", err)
+	}
+
+	if err := h.StartHunt(mac1); err != nil {
+		t.Fatal("StartHunt error ", err)
+	}
+	if err := h.StartHunt(mac1); !errors.Is(err, ErrAlreadyHunting) {
+		t.Error("StartHunt twice should fail ", err)
+	}
+	if list := h.HuntList(); len(list) != 1 || list[0].MAC.String() != mac1.String() {
+		t.Errorf("invalid hunt list %+v", list)
+	}
+
+	if err := h.StopHunt(mac1); err != nil {
+		t.Fatal("StopHunt error ", err)
+	}
+	if list := h.HuntList(); len(list) != 0 {
+		t.Errorf("invalid hunt list %+v", list)
+	}
+
+	// the hunt goroutine is woken up by StopHunt
+	for _, want := range []EventType{EventHuntStarted, EventHuntStopped} {
+		select {
+		case e := <-s.C:
+			if e.Type != want || e.Entry.MAC.String() != mac1.String() {
+				t.Errorf("invalid event %s want %s", e.Type, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", want)
+		}
+	}
+}
+
+func Test_HuntConcurrentStart(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	h.mutex.Lock()
+	h.arpTableAppendLocked(StateNormal, mac1, ip1).Online = true
+	h.mutex.Unlock()
+
+	// only one of the concurrent calls starts the hunt
+	const n = 8
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() { errs <- h.StartHunt(mac1) }()
+	}
+	started := 0
+	for i := 0; i < n; i++ {
+		if err := <-errs; err == nil {
+			started++
+		}
+	}
+	if started != 1 {
+		t.Error("expected a single hunt got ", started)
+	}
+	if list := h.HuntList(); len(list) != 1 {
+		t.Errorf("invalid hunt list %+v", list)
+	}
+}
+
+func Test_HuntTimeout(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(16, DropNewest)
+
+	h.mutex.Lock()
+	h.arpTableAppendLocked(StateNormal, mac1, ip1).Online = true
+	h.mutex.Unlock()
+
+	h.SetHuntTimeout(time.Nanosecond)
+	if err := h.StartHunt(mac1); err != nil {
+		t.Fatal("StartHunt error ", err)
+	}
+
+	for _, want := range []EventType{EventHuntStarted, EventHuntTimeout, EventHuntStopped} {
+		select {
+		case e := <-s.C:
+			if e.Type != want {
+				t.Errorf("invalid event %s want %s", e.Type, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", want)
+		}
+	}
+	if entry := h.FindMAC(mac1); entry.State != StateNormal {
+		t.Errorf("invalid state %s", entry.State)
+	}
+	if entry := h.FindVirtualIP(ip1); entry != nil {
+		t.Errorf("virtual host not deleted %+v", entry)
+	}
+}
+
+func Test_SpoofInterval(t *testing.T) {
+	interval := spoofIntervalStart
+	for i := 0; i < 20; i++ {
+		interval = nextSpoofInterval(interval, false)
+	}
+	if interval != spoofIntervalMax {
+		t.Errorf("invalid back off interval %v", interval)
+	}
+	for i := 0; i < 20; i++ {
+		interval = nextSpoofInterval(interval, true)
+	}
+	if interval != spoofIntervalMin {
+		t.Errorf("invalid ramp up interval %v", interval)
+	}
+}
+
+func Test_SignalCorrection(t *testing.T) {
+	ipOther := net.IPv4(192, 168, 0, 4).To4()
+	routerMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0xfe}
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	h.SetRouter(ip2, routerMAC)
+
+	hunted := newHunt(HuntBoth)
+	h.hunts[string(mac1)] = hunted
+
+	corrected := func() bool {
+		select {
+		case <-hunted.corrected:
+			return true
+		default:
+			return false
+		}
+	}
+
+	tests := []struct {
+		name   string
+		packet *marp.Packet
+		want   bool
+	}{
+		{"router reply to client", newTestPacket(marp.OperationReply, routerMAC, ip2, mac1, ip1), true},
+		{"router reply to other", newTestPacket(marp.OperationReply, routerMAC, ip2, mac2, ipOther), false},
+		{"router request", newTestPacket(marp.OperationRequest, routerMAC, ip2, EthernetBroadcast, ipOther), true},
+		{"client request for router", newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2), true},
+		{"client request for other", newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ipOther), false},
+		{"other request for router", newTestPacket(marp.OperationRequest, mac2, ipOther, EthernetBroadcast, ip2), false},
+	}
+	for _, tt := range tests {
+		h.signalCorrection(tt.packet)
+		if got := corrected(); got != tt.want {
+			t.Errorf("%s: corrected=%v want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func newTestPacket(op marp.Operation, srcMAC net.HardwareAddr, srcIP net.IP, dstMAC net.HardwareAddr, dstIP net.IP) *marp.Packet {
+	p, _ := marp.NewPacket(op, srcMAC, srcIP, dstMAC, dstIP)
+	return p
+}
+
+func Test_HuntDirection(t *testing.T) {
+	routerMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0xfe}
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	h.mutex.Lock()
+	h.arpTableAppendLocked(StateNormal, mac1, ip1).Online = true
+	h.mutex.Unlock()
+
+	if err := h.StartHuntDirection(mac1, HuntRouter); err == nil {
+		t.Error("HuntRouter without router mac should fail")
+	}
+	if err := h.StartHuntDirection(mac1, HuntDirection(9)); err == nil {
+		t.Error("invalid direction should fail")
+	}
+
+	if err := h.StartHuntDirection(mac1, HuntClient); err != nil {
+		t.Fatal("StartHuntDirection error ", err)
+	}
+	time.Sleep(time.Millisecond * 50)
+	if h.FindVirtualIP(ip1) != nil {
+		t.Error("HuntClient should not claim the client IP")
+	}
+	h.StopHunt(mac1)
+
+	h.SetRouter(ip2, routerMAC)
+	if err := h.StartHuntDirection(mac1, HuntRouter); err != nil {
+		t.Fatal("StartHuntDirection error ", err)
+	}
+	defer h.StopHunt(mac1)
+	for found := false; !found; {
+		select {
+		case p := <-conn.out: // skip the client hunt packets
+			found = p.TargetHardwareAddr.String() == routerMAC.String() && p.SenderIP.Equal(ip1) && p.SenderHardwareAddr.String() == mac3.String()
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for router spoof packet")
+		}
+	}
+
+	for _, s := range []string{"both", "client", "router"} {
+		if d, err := ParseHuntDirection(s); err != nil || d.String() != s {
+			t.Errorf("invalid direction %s %v", d, err)
+		}
+	}
+}
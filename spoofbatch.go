@@ -0,0 +1,95 @@
+package arp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// spoofBatchWindow is the time spoof replies wait for the replies of other
+// hunts to be sent in the same write; see replySpoof.
+var spoofBatchWindow = time.Millisecond * 5
+
+// spoofBatch hold the spoof replies waiting to be sent.
+type spoofBatch struct {
+	mutex   sync.Mutex
+	packets []*marp.Packet
+	addrs   []net.HardwareAddr
+}
+
+// replySpoof send a spoof reply from a hunt.
+//
+// When the socket sends packets in batches (i.e. WithRingBuffer or
+// WithBatchReads on linux) the replies of every hunt due within
+// spoofBatchWindow are sent in a single sendmmsg call instead of one syscall
+// per victim; write errors are logged and not returned. Otherwise, or if
+// middleware is registered with Use, it is the same as reply.
+func (c *Handler) replySpoof(srcHwAddr net.HardwareAddr, srcIP net.IP, dstHwAddr net.HardwareAddr, dstIP net.IP) error {
+	if c.batchWriter() == nil || len(c.middlewareChain()) > 0 {
+		return c.reply(srcHwAddr, srcIP, dstHwAddr, dstIP)
+	}
+	if c.passive {
+		return ErrPassive
+	}
+	p, err := marp.NewPacket(marp.OperationReply, srcHwAddr, srcIP, dstHwAddr, dstIP)
+	if err != nil {
+		return err
+	}
+
+	c.spoofBatch.mutex.Lock()
+	first := len(c.spoofBatch.packets) == 0
+	c.spoofBatch.packets = append(c.spoofBatch.packets, p)
+	c.spoofBatch.addrs = append(c.spoofBatch.addrs, dstHwAddr)
+	c.spoofBatch.mutex.Unlock()
+
+	if first {
+		c.goroutinePool.Go("ARP spoof batch", func(ctx context.Context) error {
+			timer := time.NewTimer(spoofBatchWindow)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				c.flushSpoof()
+			case <-ctx.Done():
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// flushSpoof send the replies queued by replySpoof.
+func (c *Handler) flushSpoof() {
+	c.spoofBatch.mutex.Lock()
+	packets, addrs := c.spoofBatch.packets, c.spoofBatch.addrs
+	c.spoofBatch.packets, c.spoofBatch.addrs = nil, nil
+	c.spoofBatch.mutex.Unlock()
+
+	w := c.batchWriter()
+	if w == nil { // the socket was replaced by one without batch writes
+		for i := range packets {
+			c.write(Packet{Direction: Outbound, Packet: packets[i], Destination: addrs[i]})
+		}
+		return
+	}
+
+	for range packets {
+		c.limiter.wait()
+	}
+	n, err := w.WriteBatch(packets, addrs)
+	atomic.AddUint64(&c.counters.repliesSent, uint64(n))
+	for _, p := range packets[:n] {
+		c.captureSent(p)
+	}
+	if err != nil {
+		c.log().WithFields(log.Fields{"sent": n, "packets": len(packets)}).Error("ARP error sending spoof batch ", writeError(err))
+		return
+	}
+	if c.logArea(LogSpoof) {
+		c.log().WithFields(log.Fields{"packets": n}).Debug("ARP spoof batch sent")
+	}
+}
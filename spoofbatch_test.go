@@ -0,0 +1,63 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+// fakeBatchWriter record the size of each batch written
+type fakeBatchWriter struct {
+	*fakeConn
+	batches chan int
+}
+
+func (f fakeBatchWriter) WriteBatch(packets []*marp.Packet, addrs []net.HardwareAddr) (int, error) {
+	for i := range packets {
+		f.WriteTo(packets[i], addrs[i])
+	}
+	f.batches <- len(packets)
+	return len(packets), nil
+}
+
+func Test_SpoofBatch(t *testing.T) {
+	conn := fakeBatchWriter{fakeConn: newFakeConn(), batches: make(chan int, 16)}
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	// replies from several hunts at the same time go in a single write
+	for i := 0; i < 8; i++ {
+		if err := h.replySpoof(mac3, ip1, mac2, ip2); err != nil {
+			t.Fatal("replySpoof error ", err)
+		}
+	}
+
+	select {
+	case n := <-conn.batches:
+		if n != 8 {
+			t.Error("expected a batch of 8 replies got ", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for batch")
+	}
+	if len(conn.out) != 8 || h.Stats().RepliesSent != 8 {
+		t.Errorf("invalid replies sent %d %+v", len(conn.out), h.Stats())
+	}
+
+	// connections without batch writes send immediately
+	h2, _ := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(newFakeConn()))
+	defer h2.Stop()
+	if h2.batchWriter() != nil {
+		t.Error("expected no batch writer")
+	}
+	if err := h2.replySpoof(mac3, ip1, mac2, ip2); err != nil || h2.Stats().RepliesSent != 1 {
+		t.Error("expected immediate reply ", err, h2.Stats().RepliesSent)
+	}
+}
@@ -0,0 +1,87 @@
+package arp
+
+import (
+	"net"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrSpoofDenied is returned when a device is in the spoof deny list or not in a non empty allow list.
+//
+// Deprecated: use ErrHuntNotAllowed.
+var ErrSpoofDenied = ErrHuntNotAllowed
+
+// spoofList is a set of MACs and IPs; keys are the binary address strings.
+type spoofList map[string]bool
+
+func newSpoofList(macs []net.HardwareAddr, ips []net.IP) spoofList {
+	if len(macs) == 0 && len(ips) == 0 {
+		return nil
+	}
+	list := make(spoofList, len(macs)+len(ips))
+	for _, mac := range macs {
+		list[string(mac)] = true
+	}
+	for _, ip := range ips {
+		list[ipKey(ip)] = true
+	}
+	return list
+}
+
+// contains return true if the mac or the ip are in the list
+func (l spoofList) contains(mac net.HardwareAddr, ip net.IP) bool {
+	if l[string(mac)] {
+		return true
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return l[string(ip4)]
+	}
+	return ip != nil && l[string(ip.To16())]
+}
+
+// SetSpoofDenyList set the MACs and IPs that must never be hunted, i.e. the
+// NAS, the printer or the admin laptop. The deny list takes precedence over
+// the allow list.
+//
+// The list replaces the previous one; a device being hunted that is now
+// denied returns to normal state.
+func (c *Handler) SetSpoofDenyList(macs []net.HardwareAddr, ips []net.IP) {
+	list := newSpoofList(macs, ips)
+	c.mutex.Lock()
+	c.spoofDeny = list
+	c.mutex.Unlock()
+}
+
+// SetSpoofAllowList restrict hunting to the MACs and IPs in the list; an
+// empty list allows all devices not in the deny list.
+//
+// The list replaces the previous one; a device being hunted that is no longer
+// allowed returns to normal state.
+func (c *Handler) SetSpoofAllowList(macs []net.HardwareAddr, ips []net.IP) {
+	list := newSpoofList(macs, ips)
+	c.mutex.Lock()
+	c.spoofAllow = list
+	c.mutex.Unlock()
+}
+
+// CheckSpoof return ErrHuntNotAllowed if the device with mac and ip cannot be hunted.
+func (c *Handler) CheckSpoof(mac net.HardwareAddr, ip net.IP) error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.checkSpoofLocked(mac, ip)
+}
+
+func (c *Handler) checkSpoofLocked(mac net.HardwareAddr, ip net.IP) error {
+	if c.spoofDeny.contains(mac, ip) {
+		return ErrHuntNotAllowed
+	}
+	if c.spoofAllow != nil && !c.spoofAllow.contains(mac, ip) {
+		return ErrHuntNotAllowed
+	}
+	return nil
+}
+
+// logSpoofDenied log an attempt to spoof a protected device
+func (c *Handler) logSpoofDenied(mac net.HardwareAddr, ip net.IP) {
+	c.log().WithFields(log.Fields{"mac": mac.String(), "ip": ip}).Warn("ARP spoof denied for protected device")
+}
@@ -0,0 +1,47 @@
+package arp
+
+import (
+	"net"
+	"testing"
+)
+
+func Test_SpoofPolicy(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	h.mutex.Lock()
+	h.arpTableAppendLocked(StateNormal, mac1, ip1).Online = true
+	h.arpTableAppendLocked(StateNormal, mac2, ip2).Online = true
+	h.mutex.Unlock()
+
+	h.SetSpoofDenyList([]net.HardwareAddr{mac1}, []net.IP{ip2})
+	if err := h.ForceIPChange(mac1, ip1); err != ErrSpoofDenied {
+		t.Errorf("mac in deny list error=%v", err)
+	}
+	if err := h.ForceIPChange(mac2, ip2); err != ErrSpoofDenied {
+		t.Errorf("ip in deny list error=%v", err)
+	}
+	if entry := h.FindMAC(mac1); entry.State != StateNormal {
+		t.Errorf("invalid state %s", entry.State)
+	}
+	if err := h.forceSpoof(mac1, ip1); err != ErrSpoofDenied {
+		t.Errorf("forceSpoof not denied error=%v", err)
+	}
+
+	h.SetSpoofDenyList(nil, nil)
+	h.SetSpoofAllowList([]net.HardwareAddr{mac2}, nil)
+	if err := h.CheckSpoof(mac1, ip1); err != ErrSpoofDenied {
+		t.Errorf("mac not in allow list error=%v", err)
+	}
+	if err := h.CheckSpoof(mac2, ip2); err != nil {
+		t.Errorf("mac in allow list error=%v", err)
+	}
+
+	h.SetSpoofAllowList(nil, nil)
+	if err := h.CheckSpoof(mac1, ip1); err != nil {
+		t.Errorf("empty lists error=%v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package arp
+
+import (
+	"bytes"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+// The spoof interval adapts to the client: it backs off while the client ARP
+// cache stays poisoned and ramps up when corrective traffic is observed.
+var (
+	spoofIntervalMin   = time.Second
+	spoofIntervalStart = time.Second * 4
+	spoofIntervalMax   = time.Second * 30
+)
+
+// hunt is the state shared between a spoofLoop and the packet handler
+type hunt struct {
+	stop      chan struct{} // closed by StopIPChange
+	corrected chan struct{} // corrective traffic seen; see signalCorrection
+	direction HuntDirection // caches to spoof; see StartHuntDirection
+}
+
+func newHunt(direction HuntDirection) *hunt {
+	return &hunt{stop: make(chan struct{}), corrected: make(chan struct{}, 1), direction: direction}
+}
+
+// correct signal corrective traffic to the spoofLoop; it never blocks
+func (h *hunt) correct() {
+	select {
+	case h.corrected <- struct{}{}:
+	default:
+	}
+}
+
+// nextSpoofInterval return the interval to the next spoof cycle
+func nextSpoofInterval(interval time.Duration, corrected bool) time.Duration {
+	if corrected {
+		interval /= 2
+	} else {
+		interval = interval * 3 / 2
+	}
+	if interval < spoofIntervalMin {
+		return spoofIntervalMin
+	}
+	if interval > spoofIntervalMax {
+		return spoofIntervalMax
+	}
+	return interval
+}
+
+// signalCorrection wake up the hunts whose client ARP cache may have been
+// corrected by the packet:
+//  1. the router sent a packet to the client or a broadcast request (RFC 826
+//     hosts update the sender entry from any request)
+//  2. the client asked for the router; its cache entry has expired
+//
+// It does not allocate.
+func (c *Handler) signalCorrection(packet *marp.Packet) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if len(c.hunts) == 0 {
+		return
+	}
+
+	if c.config.RouterMAC != nil && bytes.Equal(packet.SenderHardwareAddr, c.config.RouterMAC) && packet.SenderIP.Equal(c.config.RouterIP) {
+		if h, ok := c.hunts[string(packet.TargetHardwareAddr)]; ok {
+			h.correct()
+			return
+		}
+		if packet.Operation == marp.OperationRequest {
+			for _, h := range c.hunts {
+				h.correct()
+			}
+		}
+		return
+	}
+
+	if packet.Operation == marp.OperationRequest && packet.TargetIP.Equal(c.config.RouterIP) {
+		if h, ok := c.hunts[string(packet.SenderHardwareAddr)]; ok {
+			h.correct()
+		}
+	}
+}
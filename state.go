@@ -0,0 +1,168 @@
+package arp
+
+import (
+	"fmt"
+	"net"
+
+	marp "github.com/mdlayher/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// Transition reasons
+const (
+	ReasonHunt      = "hunt"      // StartHunt or ForceIPChange
+	ReasonStop      = "stop"      // StopHunt or StopIPChange
+	ReasonIPChanged = "ipchanged" // the hunted device changed IP
+	ReasonOffline   = "offline"   // the device went offline
+	ReasonDenied    = "denied"    // the device was added to the spoof deny list
+	ReasonTimeout   = "timeout"   // the hunt exceeded the hunt timeout
+)
+
+// stateTransitions are the valid transitions from each state; virtual hosts
+// never change state.
+var stateTransitions = map[arpState][]arpState{
+	StateNormal:      {StateHunt},
+	StateHunt:        {StateNormal},
+	StateVirtualHost: nil,
+}
+
+// stateAction process a packet from an entry in the state and return 1 if
+// the entry changed.
+type stateAction func(c *Handler, sender *Entry, packet *marp.Packet) int
+
+// stateActions are the request and reply actions of each state; packets from
+// virtual hosts are skipped before the actions.
+var stateActions = map[arpState]struct{ request, reply stateAction }{
+	StateNormal: {request: normalAction, reply: normalAction},
+	StateHunt:   {request: huntRequestAction, reply: huntReplyAction},
+}
+
+// Transition is an entry state change; see BeforeTransition and AfterTransition.
+type Transition struct {
+	From   arpState
+	To     arpState
+	Entry  Entry  // copy of the entry before the change
+	Reason string // i.e. ReasonHunt or ReasonOffline
+}
+
+// transitionHooks are the hooks registered with BeforeTransition and AfterTransition
+type transitionHooks struct {
+	before []func(Transition) error // copy on write
+	after  []func(Transition)       // copy on write
+}
+
+// BeforeTransition register hook to be called before an entry changes state;
+// an error cancels the transition and is returned by the call that requested
+// it, i.e. StartHunt. Transitions started by the handler, i.e. when the device
+// goes offline, are logged and the entry keeps its state.
+//
+// Hooks run with the table locked: they must return quickly and must not call
+// Handler methods; use Subscribe for slow work.
+func (c *Handler) BeforeTransition(hook func(Transition) error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.transitions.before = append(append(make([]func(Transition) error, 0, len(c.transitions.before)+1), c.transitions.before...), hook)
+}
+
+// AfterTransition register hook to be called after an entry changes state; see
+// BeforeTransition for the restrictions.
+func (c *Handler) AfterTransition(hook func(Transition)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.transitions.after = append(append(make([]func(Transition), 0, len(c.transitions.after)+1), c.transitions.after...), hook)
+}
+
+// validTransition return true if the state machine allows from -> to
+func validTransition(from arpState, to arpState) bool {
+	for _, s := range stateTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// setStateLocked move the entry to state and call the hooks. It does nothing
+// if the entry is already in state and returns ErrInvalidTransition if the
+// state machine does not allow the transition.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) setStateLocked(entry *Entry, to arpState, reason string) error {
+	t, err := c.beginTransitionLocked(entry, to, reason)
+	if err != nil {
+		return err
+	}
+	c.endTransitionLocked(entry, t)
+	return nil
+}
+
+// beginTransitionLocked check the transition and call the before hooks without
+// changing the entry; call endTransitionLocked to apply it. The returned
+// transition has From equal To if the entry is already in state.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) beginTransitionLocked(entry *Entry, to arpState, reason string) (Transition, error) {
+	t := Transition{From: entry.State, To: to, Entry: *entry, Reason: reason}
+	if entry.State == to {
+		return t, nil
+	}
+	if !validTransition(entry.State, to) {
+		return t, fmt.Errorf("%w: %s to %s for mac %s", ErrInvalidTransition, entry.State, to, entry.MAC)
+	}
+	for _, hook := range c.transitions.before {
+		if err := hook(t); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// endTransitionLocked move the entry to the transition state and call the after hooks.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) endTransitionLocked(entry *Entry, t Transition) {
+	if t.From == t.To {
+		return
+	}
+	entry.State = t.To
+	for _, hook := range c.transitions.after {
+		hook(t)
+	}
+	if c.logArea(LogTable) {
+		c.log().WithFields(log.Fields{"mac": entry.MAC.String(), "ip": entry.IP, "from": t.From, "to": t.To, "reason": t.Reason}).Debug("ARP state changed")
+	}
+}
+
+// stateAction run the action of the sender state for the packet
+func (c *Handler) stateAction(sender *Entry, packet *marp.Packet) int {
+	actions, ok := stateActions[sender.State]
+	action := actions.request
+	if packet.Operation == marp.OperationReply {
+		action = actions.reply
+	}
+	if !ok || action == nil {
+		c.log().WithFields(log.Fields{"ip": sender.IP, "mac": sender.MAC, "op": packet.Operation}).Error("ARP unexpected client state ", sender.State)
+		return 0
+	}
+	return action(c, sender, packet)
+}
+
+// normalAction update the entry IP
+func normalAction(c *Handler, sender *Entry, packet *marp.Packet) int {
+	return c.actionUpdateClient(sender, packet.SenderHardwareAddr, packet.SenderIP)
+}
+
+// huntRequestAction record the new IP of a hunted device announcing it
+func huntRequestAction(c *Handler, sender *Entry, packet *marp.Packet) int {
+	n, _ := c.actionRequestInHuntState(sender, packet.SenderIP, packet.TargetIP)
+	return n
+}
+
+// huntReplyAction record the new IP of a hunted device replying with it;
+// Android does not send collision detection requests.
+func huntReplyAction(c *Handler, sender *Entry, packet *marp.Packet) int {
+	if !packet.SenderIP.Equal(net.IPv4zero) && !packet.SenderIP.Equal(sender.IP) {
+		return c.actionUpdateClient(sender, packet.SenderHardwareAddr, packet.SenderIP)
+	}
+	return 0
+}
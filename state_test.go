@@ -0,0 +1,95 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_StateMachine(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	var mutex sync.Mutex
+	var transitions []Transition
+	errVeto := errors.New("veto")
+	veto := true
+	h.BeforeTransition(func(tr Transition) error {
+		if veto && tr.To == StateHunt {
+			return errVeto
+		}
+		return nil
+	})
+	h.AfterTransition(func(tr Transition) {
+		mutex.Lock()
+		transitions = append(transitions, tr)
+		mutex.Unlock()
+	})
+
+	h.mutex.Lock()
+	h.arpTableAppendLocked(StateNormal, mac1, ip1).Online = true
+	virtual := h.arpTableAppendLocked(StateVirtualHost, mac2, ip2)
+	if err := h.setStateLocked(virtual, StateHunt, ReasonHunt); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("virtual host transition error %v", err)
+	}
+	h.mutex.Unlock()
+
+	if err := h.StartHunt(mac1); err != errVeto {
+		t.Fatalf("StartHunt not vetoed %v", err)
+	}
+	h.mutex.Lock()
+	veto = false
+	h.mutex.Unlock()
+	if err := h.StartHunt(mac1); err != nil {
+		t.Fatal("StartHunt error ", err)
+	}
+	if err := h.StopHunt(mac1); err != nil {
+		t.Fatal("StopHunt error ", err)
+	}
+	time.Sleep(time.Millisecond * 10)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(transitions) != 2 || transitions[0].From != StateNormal || transitions[0].To != StateHunt || transitions[0].Reason != ReasonHunt ||
+		transitions[1].To != StateNormal || transitions[1].Reason != ReasonStop || transitions[1].Entry.MAC.String() != mac1.String() {
+		t.Errorf("invalid transitions %+v", transitions)
+	}
+}
+
+func Test_StateMachineIPChangeVeto(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	h.mutex.Lock()
+	e := h.arpTableAppendLocked(StateNormal, mac1, ip1)
+	e.Online = true
+	if err := h.setStateLocked(e, StateHunt, ReasonHunt); err != nil {
+		t.Fatal("setStateLocked error ", err)
+	}
+	h.mutex.Unlock()
+
+	errVeto := errors.New("veto")
+	h.BeforeTransition(func(tr Transition) error {
+		if tr.Reason == ReasonIPChanged {
+			return errVeto
+		}
+		return nil
+	})
+
+	if n := h.actionUpdateClient(e, mac1, ip2); n != 0 {
+		t.Errorf("vetoed IP change returned %d", n)
+	}
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if !e.IP.Equal(ip1) || e.hasAddress(ip2) || e.State != StateHunt {
+		t.Errorf("vetoed IP change updated the entry %+v", e)
+	}
+}
@@ -0,0 +1,99 @@
+package arp
+
+import (
+	"sync/atomic"
+)
+
+// counters holds the handler packet counters; all values are atomic.
+type counters struct {
+	packetsRead       uint64
+	requestsSent      uint64
+	repliesSent       uint64
+	readErrors        uint64
+	packetsIgnored    uint64
+	requestsRead      uint64
+	repliesRead       uint64
+	packetsInvalid    uint64
+	notificationsSent uint64
+	watchdogResets    uint64
+	packetsRaw        uint64
+	packetsDropped    uint64
+	lastFrame         int64 // unix nano time of the last frame read; see Healthy
+	lastRead          int64 // unix nano time the last read returned; see WithWatchdog
+	updateLatency     latencyHistogram
+	notifyLatency     latencyHistogram
+}
+
+// Stats holds handler counters.
+type Stats struct {
+	PacketsRead          uint64    // ARP and NDP packets read
+	RequestsSent         uint64    // ARP requests sent
+	RepliesSent          uint64    // ARP replies sent
+	ReadErrors           uint64    // socket read errors
+	PacketsIgnored       uint64    // packets ignored from MACs exceeding the storm threshold
+	NotificationsDropped uint64    // notifications discarded because a subscription queue was full
+	DevicesOnline        int       // entries currently online excluding virtual hosts
+	HuntsActive          int       // entries currently in hunt state
+	RequestsRead         uint64    // ARP requests read
+	RepliesRead          uint64    // ARP replies read
+	PacketsInvalid       uint64    // ARP packets dropped as invalid, i.e. link local addresses; see ValidatePacket
+	NotificationsSent    uint64    // events published to subscribers
+	Goroutines           int       // handler goroutines running
+	TableSize            int       // entries in the table including virtual hosts
+	TableLimit           int       // maximum number of entries; see SetHomeLAN
+	WatchdogResets       uint64    // wedged sockets closed by the watchdog; see WithWatchdog
+	PacketsRaw           uint64    // RARP and non standard ARP packets read; see AddRawPacketChannel
+	PacketsDropped       uint64    // packets discarded because a worker queue was full; see WithWorkers
+	KernelDrops          uint64    // frames dropped by the kernel because the socket receive queue was full; linux only
+	UpdateLatency        Histogram // time from packet receipt to the table update
+	NotifyLatency        Histogram // time from packet receipt to the event publication
+}
+
+// Stats return a snapshot of the handler counters.
+func (c *Handler) Stats() (stats Stats) {
+	stats.PacketsRead = atomic.LoadUint64(&c.counters.packetsRead)
+	stats.RequestsSent = atomic.LoadUint64(&c.counters.requestsSent)
+	stats.RepliesSent = atomic.LoadUint64(&c.counters.repliesSent)
+	stats.ReadErrors = atomic.LoadUint64(&c.counters.readErrors)
+	stats.PacketsIgnored = atomic.LoadUint64(&c.counters.packetsIgnored)
+	stats.RequestsRead = atomic.LoadUint64(&c.counters.requestsRead)
+	stats.RepliesRead = atomic.LoadUint64(&c.counters.repliesRead)
+	stats.PacketsInvalid = atomic.LoadUint64(&c.counters.packetsInvalid)
+	stats.NotificationsSent = atomic.LoadUint64(&c.counters.notificationsSent)
+	stats.WatchdogResets = atomic.LoadUint64(&c.counters.watchdogResets)
+	stats.PacketsRaw = atomic.LoadUint64(&c.counters.packetsRaw)
+	stats.PacketsDropped = atomic.LoadUint64(&c.counters.packetsDropped)
+	stats.UpdateLatency = c.counters.updateLatency.snapshot()
+	stats.NotifyLatency = c.counters.notifyLatency.snapshot()
+	if d, ok := c.client.(dropCounter); ok {
+		stats.KernelDrops = d.KernelDrops()
+	}
+	if c.goroutinePool != nil {
+		stats.Goroutines = int(atomic.LoadInt32(&c.goroutinePool.n))
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	stats.NotificationsDropped = c.droppedNotifications
+	for _, s := range c.subscriptions {
+		stats.NotificationsDropped += s.Dropped()
+	}
+
+	stats.TableLimit = c.tableLimitLocked()
+	for _, entry := range c.table {
+		if entry != nil {
+			stats.TableSize++
+		}
+		if entry == nil || entry.State == StateVirtualHost {
+			continue
+		}
+		if entry.Online {
+			stats.DevicesOnline++
+		}
+		if entry.State == StateHunt {
+			stats.HuntsActive++
+		}
+	}
+	return stats
+}
@@ -0,0 +1,226 @@
+package arp
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Store persists known devices across restarts.
+//
+// See the boltstore package for an implementation.
+type Store interface {
+	// Load return all stored entries
+	Load() ([]Entry, error)
+
+	// Save insert the entry or replace the stored entry with the same MAC
+	Save(entry Entry) error
+}
+
+// storeDeleter is implemented by stores that can delete entries; entries
+// older than the store retention are deleted from these stores.
+type storeDeleter interface {
+	Delete(entry Entry) error
+}
+
+// storeQueueSize is the subscription queue size used to feed the store
+const storeQueueSize = 256
+
+// storePruneInterval is the time between deletions of expired stored entries
+const storePruneInterval = time.Hour
+
+// SetStoreRetention set the time an entry is kept in the store after the
+// device was last seen; zero, the default, keeps stored entries forever.
+//
+// It is independent of SetRetention: entries evicted from the table stay in
+// the store so the name, labels and device ID are restored on the next start.
+// Expired entries are deleted when the store is loaded and every hour after;
+// the store must implement Delete(Entry) error (boltstore does).
+func (c *Handler) SetStoreRetention(retention time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if retention < 0 {
+		retention = 0
+	}
+	c.storeRetention = retention
+}
+
+// SetStore load the entries in s and save changes to s from then on.
+//
+// Loaded entries start offline and are probed by the polling loop; they are
+// marked online when the device responds and follow the normal expiry rules
+// otherwise. The LastUpdate of loaded entries is reset to the load time so the
+// entry is not deleted before it is probed.
+//
+// Entries are saved when a device is added, changes IP or goes online or offline;
+// it must be called once, before ListenAndServe. The caller is responsible
+// for closing the store after Stop.
+func (c *Handler) SetStore(s Store) error {
+	entries, err := s.Load()
+	if err != nil {
+		return fmt.Errorf("cannot load store: %w", err)
+	}
+	entries = c.pruneStore(s, entries)
+
+	c.mutex.Lock()
+	for i := range entries {
+		if entry, err := c.restoreLocked(entries[i]); entry == nil {
+			c.log().WithFields(log.Fields{"mac": entries[i].MAC.String(), "ip": entries[i].IP}).Warn("ARP cannot restore entry ", err)
+		}
+	}
+	c.store = s
+	c.mutex.Unlock()
+
+	if ss, ok := s.(scheduleStore); ok {
+		if err := c.loadSchedule(ss); err != nil {
+			return fmt.Errorf("cannot load schedule: %w", err)
+		}
+	}
+
+	if c.logArea(LogTable) {
+		c.log().Debugf("ARP restored %d entries from store", len(entries))
+	}
+
+	go c.storeLoop(c.goroutinePool.Begin("ARP storeLoop"), s, c.Subscribe(storeQueueSize, DropOldest))
+	return nil
+}
+
+// restoreLocked append a stored entry to the table in offline state; it
+// returns nil and no error if the MAC is empty or already in the table.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) restoreLocked(stored Entry) (*Entry, error) {
+	if len(stored.MAC) == 0 || c.findMACLocked(stored.MAC) != nil {
+		return nil, nil
+	}
+
+	ip := stored.IP
+	if ip.To4() == nil {
+		ip = net.IPv4zero
+	}
+	entry, err := c.appendEntryLocked(StateNormal, stored.MAC, ip)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored.IPv6) > 0 {
+		c.setIPv6Locked(entry, stored.copy().IPv6)
+	}
+	entry.Name = stored.Name
+	if stored.DeviceID != "" {
+		entry.DeviceID = stored.DeviceID
+	}
+	entry.Labels = stored.copy().Labels
+	if !stored.FirstSeen.IsZero() {
+		entry.FirstSeen = stored.FirstSeen
+	}
+	return entry, nil
+}
+
+// storeLoop save entry changes to the store until the handler stops.
+//
+// Periodic seen events are not saved to limit writes; the stored LastUpdate is
+// the time of the last online, offline or IP change.
+func (c *Handler) storeLoop(h *goroutine, s Store, sub *Subscription) {
+	defer h.End()
+	defer c.Unsubscribe(sub)
+
+	prune := time.NewTicker(storePruneInterval)
+	defer prune.Stop()
+	for {
+		select {
+		case <-prune.C:
+			if entries, err := s.Load(); err != nil {
+				c.log().Error("ARP cannot load store ", err)
+			} else {
+				c.pruneStore(s, entries)
+			}
+
+		case <-c.goroutinePool.StopChannel:
+			// save pending changes before exiting
+			for {
+				select {
+				case event := <-sub.C:
+					c.save(s, event)
+				default:
+					return
+				}
+			}
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			c.save(s, event)
+		}
+	}
+}
+
+// pruneStore delete the stored entries not seen in the store retention and
+// return the remaining entries; nothing is deleted if the retention is zero or
+// s cannot delete entries.
+func (c *Handler) pruneStore(s Store, entries []Entry) []Entry {
+	c.mutex.RLock()
+	retention := c.storeRetention
+	c.mutex.RUnlock()
+	d, ok := s.(storeDeleter)
+	if retention == 0 || !ok {
+		return entries
+	}
+
+	deadline := time.Now().Add(-retention)
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.LastUpdate.IsZero() || !entry.LastUpdate.Before(deadline) {
+			kept = append(kept, entry)
+			continue
+		}
+		if err := d.Delete(entry); err != nil {
+			c.log().WithFields(log.Fields{"mac": entry.MAC.String(), "ip": entry.IP}).Error("ARP cannot delete entry ", err)
+			kept = append(kept, entry)
+			continue
+		}
+		if c.logArea(LogTable) {
+			c.log().WithFields(log.Fields{"mac": entry.MAC.String(), "ip": entry.IP, "lastupdate": entry.LastUpdate}).Debug("ARP stored entry expired")
+		}
+	}
+	return kept
+}
+
+func (c *Handler) save(s Store, event Event) {
+	switch event.Type {
+	case EventNewDevice, EventDeviceOnline, EventDeviceOffline, EventIPChanged, EventDeviceLinked:
+	default:
+		return
+	}
+	if event.Entry.State == StateVirtualHost {
+		return
+	}
+
+	entry := event.Entry
+	entry.State = StateNormal
+	if err := s.Save(entry); err != nil {
+		c.log().WithFields(log.Fields{"mac": entry.MAC.String(), "ip": entry.IP}).Error("ARP cannot save entry ", err)
+	}
+}
+
+// SetName set the entry name and save it to the store if there is one.
+func (c *Handler) SetName(mac net.HardwareAddr, name string) error {
+	c.mutex.Lock()
+	entry := c.findMACLocked(mac)
+	if entry == nil {
+		c.mutex.Unlock()
+		return errMACNotFound(mac)
+	}
+	entry.Name = name
+	saved := entry.copy()
+	s := c.store
+	c.mutex.Unlock()
+
+	if s == nil {
+		return nil
+	}
+	saved.State = StateNormal
+	return s.Save(saved)
+}
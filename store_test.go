@@ -0,0 +1,110 @@
+package arp
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	mutex   sync.Mutex
+	entries map[string]Entry
+}
+
+func (s *memoryStore) Load() (entries []Entry, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (s *memoryStore) Save(entry Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.entries[entry.MAC.String()] = entry
+	return nil
+}
+
+func (s *memoryStore) get(key string) Entry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.entries[key]
+}
+
+func Test_SetStore(t *testing.T) {
+	first := time.Now().Add(-time.Hour * 24)
+	s := &memoryStore{entries: map[string]Entry{
+		mac1.String(): {MAC: mac1, IP: ip1, Name: "one", FirstSeen: first, Online: true},
+	}}
+
+	h := &Handler{table: make([]*Entry, 0, 256), goroutinePool: GoroutinePool.new("storetest")}
+	defer h.goroutinePool.Stop()
+	if err := h.SetStore(s); err != nil {
+		t.Fatal("SetStore error ", err)
+	}
+
+	entry := h.FindIP(ip1)
+	if entry == nil || entry.Online || entry.Name != "one" || !entry.FirstSeen.Equal(first) {
+		t.Fatal("invalid restored entry ", entry)
+	}
+
+	h.notify(EventIPChanged, *entry, Entry{MAC: mac1, IP: ip2, Name: "one", FirstSeen: first})
+	for i := 0; !s.get(mac1.String()).IP.Equal(ip2); i++ {
+		if i > 100 {
+			t.Fatal("entry not saved ", s.get(mac1.String()))
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if err := h.SetName(mac1, "renamed"); err != nil || s.get(mac1.String()).Name != "renamed" {
+		t.Error("name not saved ", err, s.get(mac1.String()))
+	}
+	if err := h.SetName(mac2, "none"); err == nil {
+		t.Error("expected error for unknown mac")
+	}
+}
+
+func (s *memoryStore) Delete(entry Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, entry.MAC.String())
+	return nil
+}
+
+func (s *memoryStore) len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.entries)
+}
+
+func Test_StoreRetention(t *testing.T) {
+	s := &memoryStore{entries: map[string]Entry{
+		mac1.String(): {MAC: mac1, IP: ip1, LastUpdate: time.Now().Add(-time.Hour * 48)},
+		mac2.String(): {MAC: mac2, IP: ip2, LastUpdate: time.Now()},
+	}}
+
+	h := &Handler{table: make([]*Entry, 0, 256), goroutinePool: GoroutinePool.new("storetest")}
+	defer h.goroutinePool.Stop()
+	h.SetStoreRetention(time.Hour * 24)
+	if err := h.SetStore(s); err != nil {
+		t.Fatal("SetStore error ", err)
+	}
+	if h.FindMAC(mac1) != nil || h.FindMAC(mac2) == nil || s.len() != 1 {
+		t.Fatalf("expired entry not pruned table=%v store=%v", h.GetTable(), s.entries)
+	}
+
+	// evicted entries are deleted from the table only
+	h.notify(EventDeviceEvicted, Entry{MAC: mac2, IP: ip2}, Entry{MAC: mac2, IP: ip2})
+	h.notify(EventNewDevice, Entry{MAC: mac3, IP: ip3}, Entry{MAC: mac3, IP: ip3})
+	for i := 0; s.len() != 2; i++ {
+		if i > 100 {
+			t.Fatal("new entry not saved")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if s.get(mac2.String()).MAC == nil {
+		t.Error("evicted entry deleted from the store")
+	}
+}
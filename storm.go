@@ -0,0 +1,104 @@
+package arp
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// StormDetection configures the detection of ARP storms; see WithStormDetection.
+type StormDetection struct {
+	PacketsPerSecond int  // maximum packets per second from a single MAC
+	Ignore           bool // ignore packets from the MAC while it exceeds the threshold
+}
+
+// stormMaxRecords limits the memory used by the storm detector
+const stormMaxRecords = 1024
+
+// WithStormDetection track the packet rate of each MAC and send
+// EventStormDetected when a MAC exceeds config.PacketsPerSecond. Event.Alert
+// holds the MAC and the packets counted in the second the threshold was exceeded.
+//
+// If config.Ignore is set, packets from the MAC are ignored until a second
+// below the threshold; these are counted in Stats().PacketsIgnored. It protects
+// the handler and the notification pipeline from broken devices.
+func WithStormDetection(config StormDetection) Option {
+	return func(c *Handler) error {
+		if config.PacketsPerSecond <= 0 {
+			config.PacketsPerSecond = 50
+		}
+		c.storm = &stormDetector{config: config, records: make(map[string]*stormRecord)}
+		return nil
+	}
+}
+
+type stormDetector struct {
+	config  StormDetection
+	mutex   sync.Mutex
+	records map[string]*stormRecord // by sender MAC
+}
+
+type stormRecord struct {
+	second   int64 // unix second of count
+	count    int
+	storming bool
+}
+
+// checkStorm count the packet and return true if it must be ignored; it does
+// not allocate for known MACs.
+func (c *Handler) checkStorm(packet *marp.Packet) (ignore bool) {
+	d := c.storm
+	now := time.Now()
+	second := now.Unix()
+
+	d.mutex.Lock()
+	r, ok := d.records[string(packet.SenderHardwareAddr)]
+	if !ok {
+		if len(d.records) >= stormMaxRecords {
+			d.purge(second)
+		}
+		r = &stormRecord{second: second}
+		d.records[string(packet.SenderHardwareAddr)] = r
+	}
+	if r.second != second {
+		// a quiet second ends the storm
+		if r.storming && (r.second != second-1 || r.count <= d.config.PacketsPerSecond) {
+			r.storming = false
+			if c.logArea(LogSpoof) {
+				c.log().WithFields(log.Fields{"mac": packet.SenderHardwareAddr.String()}).Debug("ARP storm ended")
+			}
+		}
+		r.second, r.count = second, 0
+	}
+	r.count++
+	started := !r.storming && r.count > d.config.PacketsPerSecond
+	if started {
+		r.storming = true
+	}
+	storming, count := r.storming, r.count
+	d.mutex.Unlock()
+
+	if started {
+		alert := &SpoofAlert{Type: AlertStorm, Severity: SeverityMedium, MAC: dupMAC(packet.SenderHardwareAddr), IP: dupIP(packet.SenderIP), Count: count, Window: time.Second}
+		c.log().WithFields(log.Fields{"mac": alert.MAC.String(), "ip": alert.IP, "count": count}).Warn("ARP storm detected")
+		c.publish(Event{Type: EventStormDetected, Time: now, NIC: c.config.NIC, Entry: Entry{MAC: alert.MAC, IP: alert.IP}, Alert: alert})
+	}
+
+	if storming && d.config.Ignore {
+		atomic.AddUint64(&c.counters.packetsIgnored, 1)
+		return true
+	}
+	return false
+}
+
+// purge remove the records not updated in the last minute
+func (d *stormDetector) purge(second int64) {
+	for k, r := range d.records {
+		if second-r.second > 60 {
+			delete(d.records, k)
+		}
+	}
+}
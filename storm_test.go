@@ -0,0 +1,49 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_StormDetection(t *testing.T) {
+	h, err := NewHandler("eth0", mac3, ip3, ip2, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(newFakeConn()), WithStormDetection(StormDetection{PacketsPerSecond: 10, Ignore: true}))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	s := h.Subscribe(64, DropNewest)
+
+	// 25 packets exceed the threshold even if split across two seconds
+	for i := 0; i < 25; i++ {
+		h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2))
+	}
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac2, ip2, EthernetBroadcast, ip1))
+
+	var alerts int
+	for done := false; !done; {
+		select {
+		case e := <-s.C:
+			if e.Type == EventStormDetected {
+				alerts++
+				if e.Alert == nil || e.Alert.Type != AlertStorm || e.Alert.MAC.String() != mac1.String() || e.Alert.Count <= 10 {
+					t.Fatalf("invalid storm alert %+v", e.Alert)
+				}
+			}
+		case <-time.After(time.Millisecond * 100):
+			done = true
+		}
+	}
+	if alerts == 0 || alerts > 2 {
+		t.Errorf("expected storm alert got %d", alerts)
+	}
+	if ignored := h.Stats().PacketsIgnored; ignored == 0 || ignored > 15 {
+		t.Errorf("invalid packets ignored %d", ignored)
+	}
+	if h.FindMAC(mac2) == nil {
+		t.Error("packet from mac2 ignored")
+	}
+}
@@ -0,0 +1,24 @@
+package arp
+
+import (
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// PacketTap is called with every packet read before the handler acts on it;
+// see WithPacketTap.
+type PacketTap func(packet *marp.Packet, frame *ethernet.Frame)
+
+// WithPacketTap call tap with every ARP and raw packet read, before the table
+// is updated, so custom analytics do not require a fork of the read loop.
+//
+// tap runs in the ListenAndServe goroutine and delays the next read; it must
+// return quickly and must not modify or keep references to the packet or the
+// frame as the packet conn may reuse them. Copy the fields needed, i.e. with
+// append([]byte(nil), frame.Payload...).
+func WithPacketTap(tap PacketTap) Option {
+	return func(c *Handler) error {
+		c.tap = tap
+		return nil
+	}
+}
@@ -0,0 +1,43 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+func Test_PacketTap(t *testing.T) {
+	type tapped struct {
+		op    marp.Operation
+		known bool // the sender was in the table when tapped
+	}
+	ch := make(chan tapped, 4)
+
+	conn := newFakeConn()
+	var h *Handler
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn),
+		WithPacketTap(func(p *marp.Packet, f *ethernet.Frame) {
+			ch <- tapped{op: p.Operation, known: h.FindMAC(p.SenderHardwareAddr) != nil}
+		}))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	go h.ListenAndServe(0)
+
+	conn.in <- newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2)
+	conn.in <- newTestPacket(OperationReverseRequest, mac2, ip2, mac2, ip2)
+	for _, want := range []marp.Operation{marp.OperationRequest, OperationReverseRequest} {
+		select {
+		case got := <-ch:
+			if got.op != want || got.known {
+				t.Errorf("invalid tapped packet %+v", got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for tapped packet")
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// tcpProbeTimeout is the time to wait for each TCP connection
+var tcpProbeTimeout = time.Second
+
+// SetTCPProbe set the TCP ports used to confirm the device is online before
+// declaring it offline (i.e. 62078 for iPhones, 445 for Windows or 5353);
+// call with no ports to remove the probe.
+//
+// The probe is a last resort for devices that ignore both ARP and ICMP when
+// idle. The device is online if any port accepts or refuses the connection.
+func (c *Handler) SetTCPProbe(mac net.HardwareAddr, ports ...int) error {
+	for _, port := range ports {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid tcp port %d", port)
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(ports) == 0 {
+		delete(c.tcpProbes, string(mac))
+		return nil
+	}
+	if c.tcpProbes == nil {
+		c.tcpProbes = make(map[string][]int)
+	}
+	c.tcpProbes[string(mac)] = append([]int(nil), ports...)
+	return nil
+}
+
+// tcpAlive return true if the device accepted or refused a connection to one of its probe ports
+func (c *Handler) tcpAlive(local *Entry) bool {
+	c.mutex.RLock()
+	ports := c.tcpProbes[string(local.MAC)]
+	c.mutex.RUnlock()
+
+	for _, port := range ports {
+		err := tcpProbe(local.IP, port, tcpProbeTimeout)
+		if err == nil {
+			if c.logArea(LogScan) {
+				c.log().WithFields(log.Fields{"mac": local.MAC, "ip": local.IP, "port": port}).Debug("ARP device answered tcp probe")
+			}
+			return true
+		}
+		if c.logArea(LogScan) {
+			c.log().WithFields(log.Fields{"mac": local.MAC, "ip": local.IP, "port": port}).Debug("ARP device did not answer tcp probe: ", err)
+		}
+	}
+	return false
+}
+
+// tcpProbe return nil if the host accepted or refused a connection to the port;
+// a refused connection means the host sent a reset so it is online.
+func tcpProbe(ip net.IP, port int, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp4", net.JoinHostPort(ip.String(), strconv.Itoa(port)), timeout)
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return nil
+		}
+		return err
+	}
+	return conn.Close()
+}
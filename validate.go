@@ -0,0 +1,62 @@
+package arp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// Errors returned by ValidatePacket.
+var (
+	// ErrMalformedPacket is returned when the packet is not an ethernet IPv4 ARP request or reply
+	ErrMalformedPacket = errors.New("malformed ARP packet")
+
+	// ErrInvalidSender is returned when the sender MAC is zero or broadcast, or the sender IP cannot be a host address
+	ErrInvalidSender = errors.New("invalid ARP sender address")
+
+	// ErrLinkLocalPacket is returned when the sender or target IP is a link local address
+	ErrLinkLocalPacket = errors.New("link local ARP packet")
+)
+
+var zeroMAC = net.HardwareAddr{0, 0, 0, 0, 0, 0}
+
+// ValidatePacket return an error if the packet must not update the table; the
+// handler counts these packets in Stats.PacketsInvalid and ignores them.
+//
+// A valid packet is an ethernet IPv4 ARP request or reply with 6 byte MACs and
+// 4 byte IPs, a unicast or multicast sender MAC and a sender IP that is not
+// broadcast, multicast, loopback or link local. The sender IP may be zero in
+// requests; see IsProbe. The target addresses may be zero or broadcast, i.e.
+// gratuitous ARP.
+//
+// It does not modify the packet or allocate, so it is safe to call on every
+// packet read.
+func ValidatePacket(p *marp.Packet) error {
+	if p == nil ||
+		(p.Operation != marp.OperationRequest && p.Operation != marp.OperationReply) ||
+		p.HardwareAddrLength != 6 || p.IPLength != 4 ||
+		p.ProtocolType != uint16(ethernet.EtherTypeIPv4) ||
+		len(p.SenderHardwareAddr) != 6 || len(p.TargetHardwareAddr) != 6 ||
+		p.SenderIP.To4() == nil || p.TargetIP.To4() == nil {
+		return ErrMalformedPacket
+	}
+	if p.SenderIP.IsLinkLocalUnicast() || p.TargetIP.IsLinkLocalUnicast() {
+		return ErrLinkLocalPacket
+	}
+	if bytes.Equal(p.SenderHardwareAddr, EthernetBroadcast) || bytes.Equal(p.SenderHardwareAddr, zeroMAC) ||
+		p.SenderIP.Equal(net.IPv4bcast) || p.SenderIP.IsMulticast() || p.SenderIP.IsLoopback() ||
+		(p.Operation == marp.OperationReply && p.SenderIP.Equal(net.IPv4zero)) {
+		return ErrInvalidSender
+	}
+	return nil
+}
+
+// IsProbe return true if the packet is an RFC 5227 address conflict detection
+// probe, i.e. a request with a zero sender IP. Probes are valid but do not add
+// the sender to the table as the sender IP is not assigned yet.
+func IsProbe(p *marp.Packet) bool {
+	return p.Operation == marp.OperationRequest && p.SenderIP.Equal(net.IPv4zero)
+}
@@ -0,0 +1,107 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_ValidatePacket(t *testing.T) {
+	ipv6 := newTestPacket(marp.OperationReply, mac1, ip1, mac3, ip3)
+	ipv6.SenderIP = net.ParseIP("2001:db8::1")
+	shortMAC := newTestPacket(marp.OperationReply, mac1, ip1, mac3, ip3)
+	shortMAC.SenderHardwareAddr = mac1[:4]
+	rarp := newTestPacket(marp.OperationReply, mac1, ip1, mac3, ip3)
+	rarp.Operation = 3
+	lengths := newTestPacket(marp.OperationReply, mac1, ip1, mac3, ip3)
+	lengths.IPLength = 16
+	protocol := newTestPacket(marp.OperationReply, mac1, ip1, mac3, ip3)
+	protocol.ProtocolType = 0x86dd
+
+	tests := []struct {
+		name   string
+		packet *marp.Packet
+		err    error
+	}{
+		{"reply", newTestPacket(marp.OperationReply, mac1, ip1, mac3, ip3), nil},
+		{"request", newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2), nil},
+		{"probe", newTestPacket(marp.OperationRequest, mac1, net.IPv4zero, EthernetBroadcast, ip2), nil},
+		{"announcement", newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip1), nil},
+		{"zero target", newTestPacket(marp.OperationReply, mac1, ip1, mac3, net.IPv4zero), nil},
+		{"nil", nil, ErrMalformedPacket},
+		{"ipv6", ipv6, ErrMalformedPacket},
+		{"short mac", shortMAC, ErrMalformedPacket},
+		{"rarp", rarp, ErrMalformedPacket},
+		{"lengths", lengths, ErrMalformedPacket},
+		{"protocol", protocol, ErrMalformedPacket},
+		{"link local sender", newTestPacket(marp.OperationRequest, mac1, net.IPv4(169, 254, 0, 1), EthernetBroadcast, ip2), ErrLinkLocalPacket},
+		{"link local target", newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, net.IPv4(169, 254, 0, 1)), ErrLinkLocalPacket},
+		{"broadcast mac", newTestPacket(marp.OperationReply, EthernetBroadcast, ip1, mac3, ip3), ErrInvalidSender},
+		{"zero mac", newTestPacket(marp.OperationReply, net.HardwareAddr{0, 0, 0, 0, 0, 0}, ip1, mac3, ip3), ErrInvalidSender},
+		{"zero ip reply", newTestPacket(marp.OperationReply, mac1, net.IPv4zero, mac3, ip3), ErrInvalidSender},
+		{"broadcast ip", newTestPacket(marp.OperationReply, mac1, net.IPv4bcast, mac3, ip3), ErrInvalidSender},
+		{"multicast ip", newTestPacket(marp.OperationReply, mac1, net.IPv4(224, 0, 0, 1), mac3, ip3), ErrInvalidSender},
+		{"loopback ip", newTestPacket(marp.OperationReply, mac1, net.IPv4(127, 0, 0, 1), mac3, ip3), ErrInvalidSender},
+	}
+	for _, tt := range tests {
+		if err := ValidatePacket(tt.packet); !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v got %v", tt.name, tt.err, err)
+		}
+	}
+
+	if !IsProbe(newTestPacket(marp.OperationRequest, mac1, net.IPv4zero, EthernetBroadcast, ip2)) ||
+		IsProbe(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, ip2)) {
+		t.Error("invalid probe detection")
+	}
+
+	p := newTestPacket(marp.OperationReply, mac1, ip1, mac3, ip3)
+	if n := testing.AllocsPerRun(100, func() { ValidatePacket(p) }); n != 0 {
+		t.Errorf("ValidatePacket allocates %v times", n)
+	}
+}
+
+// FuzzValidatePacket feeds arbitrary ARP payloads to the handler; packets
+// rejected by ValidatePacket must not change the table and accepted packets
+// must only add entries with valid addresses.
+func FuzzValidatePacket(f *testing.F) {
+	for _, p := range []*marp.Packet{
+		newTestPacket(marp.OperationReply, mac1, ip1, mac3, ip3),
+		newTestPacket(marp.OperationRequest, mac2, net.IPv4zero, EthernetBroadcast, ip1),
+		newTestPacket(marp.OperationRequest, EthernetBroadcast, net.IPv4(169, 254, 0, 1), EthernetBroadcast, ip1),
+	} {
+		b, err := p.MarshalBinary()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(newFakeConn()))
+	if err != nil {
+		f.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		p := &marp.Packet{}
+		if err := p.UnmarshalBinary(b); err != nil {
+			return
+		}
+		size := len(h.GetTable())
+		invalid := h.Stats().PacketsInvalid
+		err := ValidatePacket(p)
+		h.handlePacket(p)
+
+		if err != nil {
+			if len(h.GetTable()) != size || h.Stats().PacketsInvalid != invalid+1 {
+				t.Fatalf("invalid packet %+v changed the table: %v", p, err)
+			}
+			return
+		}
+		if e := h.FindMAC(p.SenderHardwareAddr); e != nil && (e.IP.To4() == nil || e.IP.IsLinkLocalUnicast() || e.IP.Equal(net.IPv4bcast)) {
+			t.Fatalf("invalid entry %v for packet %+v", e, p)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package arp
+
+import (
+	"fmt"
+	"net"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AddVirtualHost make the handler answer ARP requests for ip with mac, i.e.
+// for a honeypot or a service IP. A nil mac uses a MAC from the virtual MAC
+// pool; traffic to the IP is sent to mac hence the interface must accept it.
+//
+// The IP is not probed nor announced; see ClaimIP to take over an IP in use
+// and Gratuitous to update the caches. Virtual hosts are not in GetTable.
+func (c *Handler) AddVirtualHost(ip net.IP, mac net.HardwareAddr) error {
+	ip, mac, err := c.checkVirtualHost(ip, mac)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.claims[ipKey(ip)] != nil {
+		return fmt.Errorf("ip %s is already claimed", ip)
+	}
+	if _, err := c.addVirtualHostLocked(ip, mac); err != nil {
+		return err
+	}
+	c.log().WithFields(log.Fields{"ip": ip, "mac": mac.String()}).Info("ARP added virtual host")
+	return nil
+}
+
+// RemoveVirtualHost stop answering ARP requests for an IP added with
+// AddVirtualHost or ClaimIP.
+//
+// It returns ErrNotFound if there is no such virtual host and an error if the
+// virtual host belongs to a hunt.
+func (c *Handler) RemoveVirtualHost(ip net.IP) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.virtualHosts[ipKey(ip)] == nil {
+		if lookupIP(c.virtualIndex, ip) != nil {
+			return fmt.Errorf("ip %s virtual host belongs to a hunt", ip)
+		}
+		return fmt.Errorf("ip %s %w", ip, ErrNotFound)
+	}
+	delete(c.claims, ipKey(ip))
+	c.removeVirtualHostLocked(ip)
+	c.log().WithFields(log.Fields{"ip": ip}).Info("ARP removed virtual host")
+	return nil
+}
+
+// VirtualHosts return a copy of the virtual hosts, including the virtual hosts
+// of hunts in progress.
+func (c *Handler) VirtualHosts() []Entry {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	list := []Entry{}
+	for _, entry := range c.table {
+		if entry != nil && entry.State == StateVirtualHost {
+			list = append(list, entry.copy())
+		}
+	}
+	return list
+}
+
+// checkVirtualHost validate the virtual host ip and return a copy of the ip
+// and mac; a nil mac is returned as nil.
+func (c *Handler) checkVirtualHost(ip net.IP, mac net.HardwareAddr) (net.IP, net.HardwareAddr, error) {
+	if c.passive {
+		return nil, nil, ErrPassive
+	}
+	ip4 := ip.To4()
+	if ip4 == nil || !c.config.HomeLAN.Contains(ip4) || ip4.Equal(c.config.HostIP) {
+		return nil, nil, fmt.Errorf("ip %v is not a virtual host address in %s", ip, c.config.HomeLAN.String())
+	}
+	if mac == nil {
+		return dupIP(ip4), nil, nil
+	}
+	if err := checkMAC(mac); err != nil {
+		return nil, nil, err
+	}
+	return dupIP(ip4), dupMAC(mac), nil
+}
+
+// addVirtualHostLocked add an online virtual host for ip; the mac must not be
+// in the table. A nil mac is reserved from the virtual MAC pool.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) addVirtualHostLocked(ip net.IP, mac net.HardwareAddr) (*Entry, error) {
+	if lookupIP(c.virtualIndex, ip) != nil {
+		return nil, fmt.Errorf("ip %s is already a virtual host", ip)
+	}
+	if mac == nil {
+		var err error
+		if mac, err = c.reserveMACLocked(false); err != nil {
+			return nil, err
+		}
+	} else if c.findMACLocked(mac) != nil {
+		return nil, fmt.Errorf("mac %s is already in the table", mac)
+	}
+	virtual := c.arpTableAppendLocked(StateVirtualHost, mac, ip)
+	if virtual == nil {
+		c.releaseMACLocked(mac)
+		return nil, ErrTableFull
+	}
+	virtual.Online = true
+	if c.virtualHosts == nil {
+		c.virtualHosts = make(map[string]*Entry)
+	}
+	c.virtualHosts[ipKey(ip)] = virtual
+	return virtual, nil
+}
+
+// removeVirtualHostLocked delete the virtual host added by addVirtualHostLocked if any.
+//
+// CAUTION: Lock the mutex before calling this.
+func (c *Handler) removeVirtualHostLocked(ip net.IP) {
+	virtual := c.virtualHosts[ipKey(ip)]
+	if virtual == nil {
+		return
+	}
+	delete(c.virtualHosts, ipKey(ip))
+	c.deleteLocked(virtual)
+	c.releaseMACLocked(virtual.MAC)
+}
@@ -0,0 +1,54 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	marp "github.com/mdlayher/arp"
+)
+
+func Test_VirtualHost(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	defer h.Stop()
+	vip := net.IPv4(192, 168, 0, 100).To4()
+	vmac := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x64}
+
+	if err := h.AddVirtualHost(net.IPv4(10, 0, 0, 1), nil); err == nil {
+		t.Error("AddVirtualHost outside the LAN should fail")
+	}
+	if err := h.AddVirtualHost(vip, vmac); err != nil {
+		t.Fatal("AddVirtualHost error ", err)
+	}
+	if err := h.AddVirtualHost(vip, nil); err == nil {
+		t.Error("AddVirtualHost twice should fail")
+	}
+	if list := h.VirtualHosts(); len(list) != 1 || list[0].MAC.String() != vmac.String() || len(h.GetTable()) != 0 {
+		t.Errorf("invalid virtual hosts %+v", list)
+	}
+
+	h.handlePacket(newTestPacket(marp.OperationRequest, mac1, ip1, EthernetBroadcast, vip))
+	var reply *marp.Packet
+	for len(conn.out) > 0 {
+		if p := <-conn.out; p.Operation == marp.OperationReply {
+			reply = p
+		}
+	}
+	if reply == nil || reply.SenderHardwareAddr.String() != vmac.String() || !reply.SenderIP.Equal(vip) {
+		t.Errorf("invalid reply %+v", reply)
+	}
+
+	if err := h.RemoveVirtualHost(vip); err != nil {
+		t.Fatal("RemoveVirtualHost error ", err)
+	}
+	if err := h.RemoveVirtualHost(vip); !errors.Is(err, ErrNotFound) {
+		t.Error("expected ErrNotFound ", err)
+	}
+	if h.FindVirtualIP(vip) != nil || h.FindMAC(vmac) != nil {
+		t.Error("virtual host not deleted")
+	}
+}
@@ -0,0 +1,113 @@
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// ErrVLANNotSupported is returned when the handler is scoped to a VLAN and the
+// packet connection cannot send tagged frames.
+var ErrVLANNotSupported = errors.New("packet connection does not support VLAN tags")
+
+type vlanWriter interface {
+	WriteToVLAN(p *marp.Packet, addr net.HardwareAddr, vlan *ethernet.VLAN) error
+}
+
+// WithVLAN scope the handler to the 802.1Q VLAN id; use it to run the handler
+// on a trunk port instead of a tagged sub-interface (i.e. eth0 instead of eth0.10).
+//
+// Frames without the tag or tagged with a different id are ignored and every
+// frame sent is tagged with id. On linux the kernel removes the tag from
+// received frames so the handler reads from a TPACKET_V3 ring buffer, where
+// the tag is available; see WithRingBuffer.
+//
+// A connection set with WithPacketConn must implement
+//
+//	WriteToVLAN(p *arp.Packet, addr net.HardwareAddr, vlan *ethernet.VLAN) error
+//
+// otherwise writes return ErrVLANNotSupported.
+func WithVLAN(id uint16) Option {
+	return func(c *Handler) error {
+		if id == ethernet.VLANNone || id >= ethernet.VLANMax {
+			return fmt.Errorf("invalid VLAN id %d", id)
+		}
+		c.vlan = &ethernet.VLAN{ID: id}
+		return nil
+	}
+}
+
+// VLAN return the 802.1Q VLAN id of the handler or zero if the handler is not scoped to a VLAN.
+func (c *Handler) VLAN() uint16 {
+	if c.vlan == nil {
+		return ethernet.VLANNone
+	}
+	return c.vlan.ID
+}
+
+// dialVLAN open the interface with a connection that can read and write tagged frames
+func dialVLAN(nic string) (PacketConn, error) {
+	if runtime.GOOS == "linux" {
+		return dialRing(nic)
+	}
+	return getArpClient(nic)
+}
+
+// vlanConn filters and tags the frames of conn for a single VLAN.
+type vlanConn struct {
+	conn PacketConn
+	vlan ethernet.VLAN
+}
+
+// Read return the next ARP packet tagged with the VLAN id
+func (c *vlanConn) Read() (*marp.Packet, *ethernet.Frame, error) {
+	for {
+		packet, frame, err := c.conn.Read()
+		if err != nil {
+			return nil, nil, err
+		}
+		if frame.VLAN != nil && frame.VLAN.ID == c.vlan.ID {
+			return packet, frame, nil
+		}
+	}
+}
+
+// WriteTo send the packet in a frame tagged with the VLAN id
+func (c *vlanConn) WriteTo(p *marp.Packet, addr net.HardwareAddr) error {
+	w, ok := c.conn.(vlanWriter)
+	if !ok {
+		return ErrVLANNotSupported
+	}
+	return w.WriteToVLAN(p, addr, &c.vlan)
+}
+
+func (c *vlanConn) Close() error { return c.conn.Close() }
+
+func (c *vlanConn) SetReadDeadline(t time.Time) error { return c.conn.SetReadDeadline(t) }
+
+func (c *vlanConn) SetWriteDeadline(t time.Time) error {
+	if conn, ok := c.conn.(writeDeadliner); ok {
+		return conn.SetWriteDeadline(t)
+	}
+	return nil
+}
+
+func (c *vlanConn) KernelDrops() uint64 {
+	if d, ok := c.conn.(dropCounter); ok {
+		return d.KernelDrops()
+	}
+	return 0
+}
+
+// ReceiveTimes forward the receive times of the inner connection.
+func (c *vlanConn) ReceiveTimes() []time.Time {
+	if r, ok := c.conn.(receiveTimer); ok {
+		return r.ReceiveTimes()
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package arp
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// taggedConn is a PacketConn reading encoded frames and recording the VLAN of written frames
+type taggedConn struct {
+	frames  [][]byte
+	decoder frameDecoder
+	mutex   sync.Mutex
+	written []*ethernet.VLAN
+}
+
+func (c *taggedConn) Read() (*marp.Packet, *ethernet.Frame, error) {
+	for len(c.frames) > 0 {
+		b := c.frames[0]
+		c.frames = c.frames[1:]
+		if p, f, err := c.decoder.decode(b); err == nil {
+			return p, f, nil
+		}
+	}
+	return nil, nil, io.EOF
+}
+
+func (c *taggedConn) WriteTo(p *marp.Packet, addr net.HardwareAddr) error {
+	return c.WriteToVLAN(p, addr, nil)
+}
+
+func (c *taggedConn) WriteToVLAN(p *marp.Packet, addr net.HardwareAddr, vlan *ethernet.VLAN) error {
+	c.mutex.Lock()
+	c.written = append(c.written, vlan)
+	c.mutex.Unlock()
+	return nil
+}
+
+func (c *taggedConn) Close() error { return nil }
+
+func (c *taggedConn) SetReadDeadline(t time.Time) error { return nil }
+
+func Test_DecodeVLAN(t *testing.T) {
+	p, _ := marp.NewPacket(marp.OperationReply, mac1, ip1, mac2, ip2)
+
+	var d frameDecoder
+	for _, vlan := range []*ethernet.VLAN{nil, {ID: 10}, {ID: 4094, Priority: ethernet.PriorityVoice, DropEligible: true}} {
+		b, err := encodeFrame(p, vlan)
+		if err != nil {
+			t.Fatal("encode error ", err)
+		}
+		packet, frame, err := d.decode(b)
+		if err != nil {
+			t.Fatalf("decode vlan %v error %s", vlan, err)
+		}
+		if !packet.SenderIP.Equal(ip1) || !packet.TargetIP.Equal(ip2) {
+			t.Errorf("invalid packet %+v", packet)
+		}
+		if (vlan == nil) != (frame.VLAN == nil) || (vlan != nil && *vlan != *frame.VLAN) {
+			t.Errorf("invalid vlan got %v want %v", frame.VLAN, vlan)
+		}
+	}
+
+	// 802.1ad double tagged
+	pb, _ := p.MarshalBinary()
+	b, _ := (&ethernet.Frame{Destination: mac2, Source: mac1, ServiceVLAN: &ethernet.VLAN{ID: 100}, VLAN: &ethernet.VLAN{ID: 10},
+		EtherType: ethernet.EtherTypeARP, Payload: pb}).MarshalBinary()
+	if _, frame, err := d.decode(b); err != nil || frame.ServiceVLAN.ID != 100 || frame.VLAN.ID != 10 {
+		t.Errorf("invalid double tagged frame %+v error %v", frame, err)
+	}
+}
+
+func Test_WithVLAN(t *testing.T) {
+	hostMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	hostIP := net.IPv4(192, 168, 0, 100).To4()
+
+	conn := &taggedConn{}
+	for i, vlan := range []*ethernet.VLAN{nil, {ID: 20}, {ID: 10}} {
+		p, _ := marp.NewPacket(marp.OperationReply, mac1, ip1, hostMAC, hostIP)
+		p.SenderHardwareAddr[5] = byte(i)
+		b, _ := encodeFrame(p, vlan)
+		conn.frames = append(conn.frames, b)
+	}
+
+	if _, err := NewHandler("eth0", hostMAC, hostIP, ip3, net.IPNet{}, WithPacketConn(conn), WithVLAN(4095)); err == nil {
+		t.Error("invalid vlan id accepted")
+	}
+	h, err := NewHandler("eth0", hostMAC, hostIP, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(conn), WithVLAN(10))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	if h.VLAN() != 10 {
+		t.Errorf("invalid vlan %d", h.VLAN())
+	}
+
+	h.ListenAndServe(0) // returns when all frames are read
+	h.Stop()
+
+	table := h.Snapshot()
+	if len(table) != 1 || table[0].MAC[5] != 2 {
+		t.Errorf("invalid table %+v", table)
+	}
+
+	if err := h.Request(hostMAC, hostIP, EthernetBroadcast, ip2); err != nil {
+		t.Fatal("Request error ", err)
+	}
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	for _, vlan := range conn.written {
+		if vlan == nil || vlan.ID != 10 {
+			t.Errorf("frame not tagged %v", vlan)
+		}
+	}
+}
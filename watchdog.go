@@ -0,0 +1,79 @@
+package arp
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrReadLoopStalled is the EventHandlerDegraded error when the watchdog
+// closes a wedged socket; see WithWatchdog.
+var ErrReadLoopStalled = errors.New("ARP read loop stalled")
+
+type watchdog struct {
+	interval time.Duration // read deadline and check interval
+	limit    int           // intervals without a read before the socket is reset
+}
+
+// WithWatchdog detect a wedged read loop and reopen the socket.
+//
+// ListenAndServe sets a read deadline of interval on every read so a working
+// socket returns a frame or a timeout at least once per interval. If no read
+// returns for intervals consecutive intervals, the watchdog closes the socket
+// and ListenAndServe reopens it as for any other permanent error, publishing
+// EventHandlerDegraded with ErrReadLoopStalled and EventHandlerRecovered.
+// Resets are counted in Stats.
+//
+// The watchdog is ignored unless the handler opened the live interface.
+func WithWatchdog(interval time.Duration, intervals int) Option {
+	return func(c *Handler) error {
+		if interval <= 0 || intervals <= 0 {
+			return errors.New("invalid watchdog interval")
+		}
+		c.watchdog = &watchdog{interval: interval, limit: intervals}
+		return nil
+	}
+}
+
+// readReturned record that a read returned a frame, a timeout or an error.
+func (c *Handler) readReturned(now time.Time) {
+	atomic.StoreInt64(&c.counters.lastRead, now.UnixNano())
+}
+
+// isTimeout is true if err is a read deadline error.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// watchdogLoop reset the socket when the read loop does not return for the
+// watchdog limit.
+func (c *Handler) watchdogLoop(h *goroutine) {
+	defer h.End()
+
+	ticker := time.NewTicker(c.watchdog.interval)
+	defer ticker.Stop()
+	limit := c.watchdog.interval * time.Duration(c.watchdog.limit)
+	for {
+		select {
+		case <-c.goroutinePool.StopChannel:
+			return
+
+		case now := <-ticker.C:
+			if atomic.LoadInt32(&c.degraded) != 0 { // reconnecting; the read loop is not reading
+				continue
+			}
+			stalled := now.Sub(time.Unix(0, atomic.LoadInt64(&c.counters.lastRead)))
+			if stalled <= limit {
+				continue
+			}
+			atomic.AddUint64(&c.counters.watchdogResets, 1)
+			c.log().WithFields(log.Fields{"nic": c.config.NIC, "stalled": stalled.Truncate(time.Millisecond)}).Error("ARP watchdog closing wedged socket")
+			c.readReturned(now) // do not reset again before the read loop returns
+			c.redial.reset(ErrReadLoopStalled)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package arp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_Watchdog(t *testing.T) {
+	reconnectMinBackoff = time.Millisecond
+	defer func() { reconnectMinBackoff = time.Second }()
+
+	// fakeConn ignores the read deadline so the read loop is wedged until the watchdog closes it
+	redial := newRedialConn(newFakeConn(), func() (PacketConn, error) { return newFakeConn(), nil })
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(redial), WithWatchdog(time.Millisecond*10, 3))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	h.redial = redial
+	s := h.Subscribe(16, DropNewest)
+	go h.ListenAndServe(0)
+	defer h.Stop()
+
+	for _, want := range []EventType{EventHandlerDegraded, EventHandlerRecovered} {
+		select {
+		case event := <-s.C:
+			if event.Type != want || (want == EventHandlerDegraded && event.Err != ErrReadLoopStalled) {
+				t.Fatalf("expected %s event got %+v", want, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for ", want)
+		}
+	}
+	if stats := h.Stats(); stats.WatchdogResets == 0 {
+		t.Error("expected watchdog reset ", stats.WatchdogResets)
+	}
+}
@@ -0,0 +1,238 @@
+// Package webhook posts arp.Handler events as JSON to a URL so automations
+// can react to devices joining and leaving the network.
+//
+// Each event is sent in its own POST request with the body:
+//
+//	{"type":"online","time":"...","nic":"eth0","entry":{...},"previous":{...}}
+//
+// When a secret is set, the X-ARP-Timestamp header holds the unix time of the
+// attempt in seconds and the X-ARP-Signature header holds "sha256=" followed
+// by the hex HMAC-SHA256 of the timestamp, a dot and the body. Receivers
+// should call Verify, which compares the signature with hmac.Equal and rejects
+// timestamps more than DefaultTolerance away from their clock so a captured
+// request cannot be replayed later. Failed requests are retried with
+// exponential backoff on network errors, 429 and 5xx responses; each retry is
+// signed with a new timestamp.
+//
+// Usage:
+//
+//	sink := webhook.New(handler, "https://example.com/hook", webhook.WithSecret([]byte("secret")))
+//	go sink.Run(ctx)
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/irai/arp"
+	log "github.com/sirupsen/logrus"
+)
+
+// QueueSize is the subscription queue size; the oldest events are dropped
+// if the endpoint is slower than the event rate.
+const QueueSize = 256
+
+// SignatureHeader is the request header with the body HMAC when a secret is set
+const SignatureHeader = "X-ARP-Signature"
+
+// TimestampHeader is the request header with the signed unix time in seconds
+const TimestampHeader = "X-ARP-Timestamp"
+
+// DefaultTolerance is the maximum difference between the signed timestamp and
+// the receiver clock accepted by Verify; it allows for clock skew and retries
+// delayed by the network.
+const DefaultTolerance = time.Minute * 5
+
+// Errors returned by Verify
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside tolerance")
+)
+
+// Payload is the JSON body of the request
+type Payload struct {
+	Type     arp.EventType   `json:"type"`
+	Time     time.Time       `json:"time"`
+	NIC      string          `json:"nic"`
+	Entry    arp.Entry       `json:"entry"`
+	Previous *arp.Entry      `json:"previous,omitempty"` // nil for new devices
+	Alert    *arp.SpoofAlert `json:"alert,omitempty"`
+	Error    string          `json:"error,omitempty"` // read error for arp.EventHandlerDegraded
+	Group    string          `json:"group,omitempty"` // group name for arp.EventGroupOnline and arp.EventGroupOffline
+}
+
+// Sink posts the handler events to a URL
+type Sink struct {
+	handler *arp.Handler
+	url     string
+	secret  []byte
+	client  *http.Client
+	retries int
+	backoff time.Duration
+	types   map[arp.EventType]bool
+}
+
+// Option configures the Sink
+type Option func(*Sink)
+
+// WithSecret sign the request body with HMAC-SHA256 using secret.
+func WithSecret(secret []byte) Option {
+	return func(s *Sink) { s.secret = secret }
+}
+
+// WithRetries set the number of retries and the delay before the first
+// retry; the delay doubles on each retry. The default is 3 retries after 1s.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(s *Sink) { s.retries, s.backoff = retries, backoff }
+}
+
+// WithHTTPClient set the client used to post the events. The default client
+// has a 10s timeout.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) { s.client = client }
+}
+
+// WithEventTypes post only the listed event types. By default all events
+// except the periodic arp.EventDeviceSeen are posted.
+func WithEventTypes(types ...arp.EventType) Option {
+	return func(s *Sink) {
+		s.types = make(map[arp.EventType]bool, len(types))
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+}
+
+// New return a Sink posting the events of h to url.
+func New(h *arp.Handler, url string, opts ...Option) *Sink {
+	s := &Sink{handler: h, url: url, client: &http.Client{Timeout: time.Second * 10}, retries: 3, backoff: time.Second}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Run post the handler events until ctx is done or the handler stops.
+func (s *Sink) Run(ctx context.Context) error {
+	sub := s.handler.Subscribe(QueueSize, arp.DropOldest)
+	defer s.handler.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if !s.wanted(event.Type) {
+				continue
+			}
+			if err := s.Send(ctx, event); err != nil {
+				log.WithFields(log.Fields{"url": s.url, "type": event.Type, "mac": event.Entry.MAC.String()}).Error("WEBHOOK cannot post event ", err)
+			}
+		}
+	}
+}
+
+func (s *Sink) wanted(t arp.EventType) bool {
+	if s.types == nil {
+		return t != arp.EventDeviceSeen
+	}
+	return s.types[t]
+}
+
+// Send post the event and retry on failure; it returns the last error if all
+// attempts fail.
+func (s *Sink) Send(ctx context.Context, event arp.Event) error {
+	p := Payload{Type: event.Type, Time: event.Time, NIC: event.NIC, Entry: event.Entry, Alert: event.Alert, Group: event.Group}
+	if event.Previous.MAC != nil {
+		p.Previous = &event.Previous
+	}
+	if event.Err != nil {
+		p.Error = event.Err.Error()
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, body)
+		if err == nil || !retry || attempt >= s.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post send the body once; retry is true if the error is temporary.
+func (s *Sink) post(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.secret != nil {
+		timestamp := time.Now().Unix()
+		req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(SignatureHeader, Sign(s.secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	io.Copy(io.Discard, resp.Body) // allow the connection to be reused
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+// Sign return the signature header value for body sent at timestamp, in unix seconds.
+func Sign(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify check the signature and timestamp headers of a request with body;
+// it returns ErrStaleTimestamp if the timestamp is more than tolerance away
+// from now and ErrInvalidSignature if the signature does not match. Use
+// DefaultTolerance unless the clocks are known to drift more.
+func Verify(secret []byte, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp %q", ErrInvalidSignature, header.Get(TimestampHeader))
+	}
+	if d := time.Since(time.Unix(timestamp, 0)); d > tolerance || d < -tolerance {
+		return fmt.Errorf("%w: %v", ErrStaleTimestamp, d.Round(time.Second))
+	}
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
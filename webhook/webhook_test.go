@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/irai/arp"
+)
+
+func Test_Send(t *testing.T) {
+	secret := []byte("secret")
+	var calls int32
+	var payload Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err := Verify(secret, r.Header, body, DefaultTolerance); err != nil {
+			t.Error("invalid signature ", err)
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Error("invalid body ", err)
+		}
+	}))
+	defer server.Close()
+
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	s := New(&arp.Handler{}, server.URL, WithSecret(secret), WithRetries(2, time.Millisecond))
+	event := arp.Event{Type: arp.EventNewDevice, Time: time.Now(), NIC: "eth0", Entry: arp.Entry{MAC: mac, IP: net.IPv4(192, 168, 0, 1).To4()}}
+	if err := s.Send(context.Background(), event); err != nil {
+		t.Fatal("send error ", err)
+	}
+	if calls != 2 || payload.Type != arp.EventNewDevice || payload.Entry.MAC.String() != mac.String() || payload.Previous != nil {
+		t.Errorf("invalid payload %+v after %d calls", payload, calls)
+	}
+
+	// client errors are not retried
+	s = New(&arp.Handler{}, server.URL+"/missing", WithRetries(2, time.Millisecond))
+	if err := s.Send(context.Background(), event); err == nil {
+		t.Error("expected error for 404")
+	}
+
+	if s.wanted(arp.EventDeviceSeen) || !s.wanted(arp.EventDeviceOffline) {
+		t.Error("invalid default event types")
+	}
+}
+
+func Test_Verify(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"type":"online"}`)
+	signed := func(timestamp time.Time) http.Header {
+		header := http.Header{}
+		header.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+		header.Set(SignatureHeader, Sign(secret, timestamp.Unix(), body))
+		return header
+	}
+
+	if err := Verify(secret, signed(time.Now()), body, DefaultTolerance); err != nil {
+		t.Error("valid request rejected ", err)
+	}
+	if err := Verify(secret, signed(time.Now().Add(-time.Hour)), body, DefaultTolerance); !errors.Is(err, ErrStaleTimestamp) {
+		t.Error("replayed request accepted ", err)
+	}
+	if err := Verify(secret, signed(time.Now().Add(time.Hour)), body, DefaultTolerance); !errors.Is(err, ErrStaleTimestamp) {
+		t.Error("future request accepted ", err)
+	}
+
+	// the timestamp is part of the signature
+	header := signed(time.Now().Add(-time.Hour))
+	header.Set(TimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+	if err := Verify(secret, header, body, DefaultTolerance); !errors.Is(err, ErrInvalidSignature) {
+		t.Error("modified timestamp accepted ", err)
+	}
+	if err := Verify(secret, signed(time.Now()), []byte(`{}`), DefaultTolerance); !errors.Is(err, ErrInvalidSignature) {
+		t.Error("modified body accepted ", err)
+	}
+	if err := Verify(secret, http.Header{}, body, DefaultTolerance); !errors.Is(err, ErrInvalidSignature) {
+		t.Error("missing headers accepted ", err)
+	}
+}
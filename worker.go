@@ -0,0 +1,164 @@
+package arp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// workerPayloadLen is the largest frame payload copied to a worker item; an
+// ethernet ARP payload is 28 bytes plus padding.
+const workerPayloadLen = 64
+
+// workerPool hold the packet queues of the workers; see WithWorkers.
+type workerPool struct {
+	queues []workerQueue
+}
+
+// workerQueue is the queue of a single worker. Items are preallocated and
+// returned to free after processing, so queueing a packet does not allocate.
+type workerQueue struct {
+	queue chan *workItem
+	free  chan *workItem
+}
+
+// workItem is a received packet copied from the packet conn buffers; the
+// packet and frame slices point to the item arrays. Payloads longer than
+// workerPayloadLen (i.e. trailing padding) are truncated.
+type workItem struct {
+	packet   marp.Packet
+	frame    ethernet.Frame
+	sha      [6]byte
+	tha      [6]byte
+	spa      [4]byte
+	tpa      [4]byte
+	dst      [6]byte
+	src      [6]byte
+	vlan     ethernet.VLAN
+	svlan    ethernet.VLAN
+	payload  [workerPayloadLen]byte
+	hasFrame bool
+	time     time.Time // receive time
+}
+
+// set copy packet and frame to the item without allocating.
+func (item *workItem) set(packet *marp.Packet, frame *ethernet.Frame, t time.Time) {
+	item.time = t
+	item.packet = *packet
+	item.packet.SenderHardwareAddr = item.sha[:copy(item.sha[:], packet.SenderHardwareAddr)]
+	item.packet.TargetHardwareAddr = item.tha[:copy(item.tha[:], packet.TargetHardwareAddr)]
+	item.packet.SenderIP = copyIP4(item.spa[:], packet.SenderIP)
+	item.packet.TargetIP = copyIP4(item.tpa[:], packet.TargetIP)
+	if item.hasFrame = frame != nil; !item.hasFrame {
+		return
+	}
+
+	item.frame = *frame
+	item.frame.Destination = item.dst[:copy(item.dst[:], frame.Destination)]
+	item.frame.Source = item.src[:copy(item.src[:], frame.Source)]
+	if frame.VLAN != nil {
+		item.vlan = *frame.VLAN
+		item.frame.VLAN = &item.vlan
+	}
+	if frame.ServiceVLAN != nil {
+		item.svlan = *frame.ServiceVLAN
+		item.frame.ServiceVLAN = &item.svlan
+	}
+	item.frame.Payload = item.payload[:copy(item.payload[:], frame.Payload)]
+}
+
+// serve process the item packet.
+func (item *workItem) serve(c *Handler) {
+	if item.hasFrame {
+		c.serve(&item.packet, &item.frame, item.time)
+		return
+	}
+	c.serve(&item.packet, nil, item.time)
+}
+
+// copyIP4 copy the IPv4 address ip to b; it returns nil if ip is not IPv4.
+func copyIP4(b []byte, ip net.IP) net.IP {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return nil
+	}
+	copy(b, ip4)
+	return b
+}
+
+// WithWorkers process the received packets in workers goroutines fed by
+// bounded queues of size packets instead of in the ListenAndServe read loop.
+//
+// The read loop only copies the packet to a queue, so table updates,
+// notifications and spoof replies that block do not delay the next read and
+// the kernel does not drop frames. Packets from the same MAC go to the same
+// worker and are processed in order. A packet is discarded if its queue is
+// full; discarded packets are counted in Stats.
+func WithWorkers(workers int, size int) Option {
+	return func(c *Handler) error {
+		if workers <= 0 || size <= 0 {
+			return errors.New("invalid worker pool size")
+		}
+		c.workers = &workerPool{queues: make([]workerQueue, workers)}
+		for i := range c.workers.queues {
+			q := &c.workers.queues[i]
+			// size items waiting plus the item being processed
+			q.queue = make(chan *workItem, size+1)
+			q.free = make(chan *workItem, size+1)
+			for n := 0; n < size+1; n++ {
+				q.free <- &workItem{}
+			}
+		}
+		return nil
+	}
+}
+
+// startWorkers start a goroutine for each worker queue; they exit when the
+// handler stops.
+func (c *Handler) startWorkers() {
+	for i := range c.workers.queues {
+		q := &c.workers.queues[i]
+		c.goroutinePool.Go("ARP worker "+strconv.Itoa(i), func(ctx context.Context) error {
+			for {
+				select {
+				case item := <-q.queue:
+					item.serve(c)
+					q.free <- item
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		})
+	}
+}
+
+// enqueue copy the packet to the queue of the sender MAC worker; the packet
+// is discarded if the queue is full.
+func (c *Handler) enqueue(packet *marp.Packet, frame *ethernet.Frame, t time.Time) {
+	q := &c.workers.queues[workerIndex(packet.SenderHardwareAddr, len(c.workers.queues))]
+
+	var item *workItem
+	select {
+	case item = <-q.free:
+	default:
+		atomic.AddUint64(&c.counters.packetsDropped, 1)
+		return
+	}
+	item.set(packet, frame, t)
+	q.queue <- item // never blocks; the queue has a slot for every item
+}
+
+// workerIndex return the worker for mac so packets from a MAC stay in order.
+func workerIndex(mac []byte, workers int) int {
+	var h uint32
+	for _, b := range mac {
+		h = h*31 + uint32(b)
+	}
+	return int(h % uint32(workers))
+}
@@ -0,0 +1,121 @@
+package arp
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	marp "github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+func Test_Workers(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(conn), WithWorkers(2, 1))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+
+	// block the worker in the middleware so the queue fills up
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var served int32
+	h.Use(func(p Packet, next func(Packet) error) error {
+		if p.Direction == Inbound {
+			received <- struct{}{}
+			<-release
+			atomic.AddInt32(&served, 1)
+		}
+		return next(p)
+	})
+	s := h.Subscribe(16, DropNewest)
+	go h.ListenAndServe(0)
+	defer h.Stop()
+
+	p, _ := marp.NewPacket(marp.OperationReply, mac1, ip1, mac3, ip3)
+	conn.in <- p
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for worker")
+	}
+	for i := 0; i < 4; i++ {
+		conn.in <- p
+	}
+	deadline := time.Now().Add(time.Second)
+	for h.Stats().PacketsRead != 5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	// one packet in the worker and one in the queue; the rest are discarded
+	if stats := h.Stats(); stats.PacketsRead != 5 || stats.PacketsDropped != 3 {
+		t.Errorf("expected 3 dropped packets got %+v", stats)
+	}
+	close(release)
+
+	select {
+	case e := <-s.C:
+		if e.Type != EventNewDevice || !e.Entry.IP.Equal(ip1) {
+			t.Errorf("invalid event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	deadline = time.Now().Add(time.Second)
+	for atomic.LoadInt32(&served) != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&served); n != 2 {
+		t.Error("expected 2 packets served got ", n)
+	}
+}
+
+func Test_WorkerIndex(t *testing.T) {
+	if workerIndex(mac1, 4) != workerIndex(dupMAC(mac1), 4) {
+		t.Error("expected same worker for the same MAC")
+	}
+	if i := workerIndex(mac2, 1); i != 0 {
+		t.Error("invalid worker ", i)
+	}
+}
+
+func Test_WorkItemAllocs(t *testing.T) {
+	p, _ := marp.NewPacket(marp.OperationReply, mac1, ip1, mac3, ip3)
+	f := &ethernet.Frame{Destination: mac3, Source: mac1, EtherType: ethernet.EtherTypeARP, Payload: make([]byte, 46)}
+	item := &workItem{}
+	now := time.Now()
+	if n := testing.AllocsPerRun(100, func() { item.set(p, f, now) }); n != 0 {
+		t.Errorf("set allocates %v times per packet", n)
+	}
+	if item.packet.SenderHardwareAddr.String() != mac1.String() || !item.packet.TargetIP.Equal(ip3) ||
+		item.frame.Source.String() != mac1.String() || len(item.frame.Payload) != 46 {
+		t.Errorf("invalid item %+v", item.packet)
+	}
+}
+
+func Test_WorkersOnlineRace(t *testing.T) {
+	conn := newFakeConn()
+	h, err := NewHandler("eth0", mac3, ip3, ip3, net.IPNet{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(24, 32)},
+		WithPacketConn(conn), WithWorkers(2, 16))
+	if err != nil {
+		t.Fatal("NewHandler error ", err)
+	}
+	go h.ListenAndServe(0)
+	defer h.Stop()
+
+	// the workers set Online while Snapshot reads it; run with -race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			h.Snapshot()
+			time.Sleep(time.Microsecond * 100)
+		}
+	}()
+	for i := 0; i < 32; i++ {
+		p, _ := marp.NewPacket(marp.OperationReply, net.HardwareAddr{0x02, 0, 0, 0, 0, byte(i)}, net.IPv4(192, 168, 0, byte(10+i)), mac3, ip3)
+		conn.in <- p
+	}
+	<-done
+}